@@ -0,0 +1,743 @@
+// Package amd64 lowers IR into x86-64 assembly in AT&T syntax for the System V ABI,
+// which the GNU assembler, or a C compiler driver, turns into an executable.
+package amd64
+
+import (
+	"fmt"
+	"math/bits"
+	"strconv"
+	"strings"
+
+	"github.com/yuniruyuni/lang/ir"
+	"github.com/yuniruyuni/lang/libc"
+)
+
+// reg is a general purpose register named by its 64 bit name.
+type reg string
+
+const (
+	rax reg = "rax"
+	rbx reg = "rbx"
+	rcx reg = "rcx"
+	rdx reg = "rdx"
+	rsi reg = "rsi"
+	rdi reg = "rdi"
+	r8  reg = "r8"
+	r9  reg = "r9"
+	r11 reg = "r11"
+	r12 reg = "r12"
+	r13 reg = "r13"
+	r14 reg = "r14"
+	r15 reg = "r15"
+)
+
+// lower names the lower 32 and 8 bits of each register.
+var lower = map[reg][2]string{
+	rax: {"eax", "al"},
+	rbx: {"ebx", "bl"},
+	rcx: {"ecx", "cl"},
+	rdx: {"edx", "dl"},
+	rsi: {"esi", "sil"},
+	rdi: {"edi", "dil"},
+	r8:  {"r8d", "r8b"},
+	r9:  {"r9d", "r9b"},
+	r11: {"r11d", "r11b"},
+	r12: {"r12d", "r12b"},
+	r13: {"r13d", "r13b"},
+	r14: {"r14d", "r14b"},
+	r15: {"r15d", "r15b"},
+}
+
+// sized names r as an operand accessing its lower bits like `%eax`.
+func (r reg) sized(bits int) string {
+	switch bits {
+	case 8:
+		return "%" + lower[r][1]
+	case 32:
+		return "%" + lower[r][0]
+	default:
+		return "%" + string(r)
+	}
+}
+
+// argRegs are registers which pass the first integer arguments.
+var argRegs = []reg{rdi, rsi, rdx, rcx, r8, r9}
+
+// calleeSaved are registers values are allocated into.
+// Callees preserve them, so values survive calls without saving them around each call.
+// The other registers are left as scratch for lowering each instruction.
+var calleeSaved = []reg{rbx, r12, r13, r14, r15}
+
+// width returns the bits of the register part holding a value of t, an i1 is held in a byte.
+func width(t ir.Type) int {
+	if !t.IsInt() {
+		return 64
+	}
+	if t.Bits() <= 8 {
+		return 8
+	}
+	return t.Bits()
+}
+
+// suffix is the suffix of an instruction operating bits wide like `movl`.
+func suffix(bits int) string {
+	switch bits {
+	case 8:
+		return "b"
+	case 32:
+		return "l"
+	default:
+		return "q"
+	}
+}
+
+// conds maps each icmp condition to the suffix of `set` and `j` instructions.
+var conds = map[ir.Pred]string{
+	ir.EQ:  "e",
+	ir.NE:  "ne",
+	ir.SLT: "l",
+	ir.SLE: "le",
+	ir.SGT: "g",
+	ir.SGE: "ge",
+}
+
+// Generate lowers m into assembly.
+// It reports IR which cannot be lowered, like a type this backend doesn't know.
+func Generate(m *ir.Module) (src string, err error) {
+	// lowering reports unsupported IR by panicking with an error.
+	defer func() {
+		if r := recover(); r != nil {
+			e, ok := r.(error)
+			if !ok {
+				panic(r)
+			}
+			err = e
+		}
+	}()
+
+	if m.Target != nil && !strings.HasPrefix(m.Target.Triple, "x86_64-") {
+		return "", fmt.Errorf("cannot generate x86-64 assembly for the target %s", m.Target.Triple)
+	}
+
+	g := &asmgen{out: new(strings.Builder)}
+	g.emit(".text")
+	for _, f := range m.Functions {
+		if !f.IsDecl() {
+			g.function(f)
+		}
+	}
+	for _, gl := range m.Globals {
+		g.global(gl)
+	}
+	g.emit(`.section .note.GNU-stack,"",@progbits`)
+	return g.out.String(), nil
+}
+
+type asmgen struct {
+	out *strings.Builder
+}
+
+func (g *asmgen) emit(format string, args ...interface{}) {
+	fmt.Fprintf(g.out, "\t"+format+"\n", args...)
+}
+
+func (g *asmgen) label(l string) {
+	fmt.Fprintf(g.out, "%s:\n", l)
+}
+
+// symbol names gl in assembly, a private global is a local label like `.Lstr.1`.
+func symbol(gl *ir.Global) string {
+	if isPrivate(gl) {
+		return ".L" + strings.TrimPrefix(gl.Name, ".")
+	}
+	return gl.Name
+}
+
+func isPrivate(gl *ir.Global) bool {
+	return strings.Contains(gl.Linkage, "private")
+}
+
+func (g *asmgen) global(gl *ir.Global) {
+	if gl.Const {
+		g.emit(".section .rodata")
+	} else {
+		g.emit(".data")
+	}
+	sym := symbol(gl)
+	if !isPrivate(gl) {
+		g.emit(".globl %s", sym)
+	}
+	g.emit(".p2align %d", bits.TrailingZeros(uint(gl.Align)))
+	g.label(sym)
+	g.emit("%s", data(gl))
+}
+
+// data makes the directive which places the initializer of gl.
+func data(gl *ir.Global) string {
+	switch {
+	case strings.HasPrefix(gl.Init, `c"`):
+		s := libc.Unescape(strings.TrimSuffix(strings.TrimPrefix(gl.Init, `c"`), `"`))
+		return ".ascii " + quote(s)
+	case gl.Init == "zeroinitializer":
+		return fmt.Sprintf(".zero %d", sizeOf(gl.Elem))
+	case gl.Init == "null":
+		return ".quad 0"
+	case gl.Elem.IsInt():
+		n, err := strconv.ParseInt(gl.Init, 10, 64)
+		if err != nil {
+			break
+		}
+		return fmt.Sprintf(".%s %d", map[int]string{8: "byte", 32: "long", 64: "quad"}[width(gl.Elem)], n)
+	}
+	panic(fmt.Errorf("cannot lower the initializer of %s: %s", gl.Ident(), gl.Init))
+}
+
+// quote makes a string literal for the assembler, escaping unprintable bytes in octal.
+func quote(s string) string {
+	b := strings.Builder{}
+	b.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '"' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c < 0x20 || c >= 0x7f:
+			fmt.Fprintf(&b, "\\%03o", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// sizeOf returns the size of a value of t in bytes.
+func sizeOf(t ir.Type) int {
+	if t.IsInt() {
+		return (t.Bits() + 7) / 8
+	}
+	if n, elem, ok := array(t); ok {
+		return n * sizeOf(elem)
+	}
+	return 8
+}
+
+// array splits the array type t like `[4 x i8]` into its length and element type.
+func array(t ir.Type) (int, ir.Type, bool) {
+	s := string(t)
+	if !strings.HasPrefix(s, "[") || !strings.HasSuffix(s, "]") {
+		return 0, "", false
+	}
+	parts := strings.SplitN(s[1:len(s)-1], " x ", 2)
+	n, err := strconv.Atoi(parts[0])
+	if err != nil || len(parts) < 2 {
+		return 0, "", false
+	}
+	return n, ir.Type(parts[1]), true
+}
+
+// funcgen lowers a function into the locations given by register allocation.
+type funcgen struct {
+	*asmgen
+	f     *ir.Function
+	alloc *allocation
+	// allocas maps each alloca into the stack slot at the lowest address of its memory.
+	allocas map[*ir.Instr]int
+	// stubs emit code for edges which copy phi operands, placed after the function.
+	stubs []func()
+}
+
+// function lowers f. The frame looks like below, from higher addresses:
+// the return address, the saved %rbp, registers in alloc.saved, then stack slots.
+func (g *asmgen) function(f *ir.Function) {
+	fg := &funcgen{asmgen: g, f: f, alloc: allocate(f, calleeSaved), allocas: map[*ir.Instr]int{}}
+
+	slots := fg.alloc.slots
+	for _, bb := range f.Blocks {
+		for _, in := range bb.Instrs {
+			if in.Op == ir.OpAlloca {
+				slots += (sizeOf(in.Elem) + 7) / 8
+				fg.allocas[in] = slots
+			}
+		}
+	}
+	// %rsp is 16 bytes aligned after pushing %rbp, and must be kept so at every call.
+	frame := (8*(len(fg.alloc.saved)+slots) + 15) / 16 * 16
+
+	g.emit(".globl %s", f.Name)
+	g.emit(".type %s, @function", f.Name)
+	g.label(f.Name)
+	g.emit("pushq %%rbp")
+	g.emit("movq %%rsp, %%rbp")
+	if frame > 0 {
+		g.emit("subq $%d, %%rsp", frame)
+	}
+	for i, r := range fg.alloc.saved {
+		g.emit("movq %s, %d(%%rbp)", r.sized(64), -8*(i+1))
+	}
+	for i, p := range f.Params {
+		if i < len(argRegs) {
+			fg.move(argRegs[i], p)
+			continue
+		}
+		// arguments after registers are placed above the return address.
+		b := width(p.Typ)
+		g.emit("mov%s %d(%%rbp), %s", suffix(b), 16+8*(i-len(argRegs)), rax.sized(b))
+		fg.move(rax, p)
+	}
+
+	for i, bb := range f.Blocks {
+		var next *ir.BasicBlock
+		if i+1 < len(f.Blocks) {
+			next = f.Blocks[i+1]
+		}
+		fg.block(bb, next)
+	}
+	for _, s := range fg.stubs {
+		s()
+	}
+	g.emit(".size %s, .-%s", f.Name, f.Name)
+}
+
+// blockLabel names bb uniquely in the whole assembly.
+func (g *funcgen) blockLabel(bb *ir.BasicBlock) string {
+	return fmt.Sprintf(".L%s.%s", g.f.Name, bb.Name)
+}
+
+// slot formats the stack slot n as a memory operand.
+func (g *funcgen) slot(n int) string {
+	return fmt.Sprintf("%d(%%rbp)", -8*(len(g.alloc.saved)+n))
+}
+
+// at formats the location of v allocated by register allocation.
+func (g *funcgen) at(v ir.Value, bits int) string {
+	l, ok := g.alloc.locs[v]
+	if !ok {
+		panic(fmt.Errorf("%s is not allocated in %s", v.Ident(), g.f.Name))
+	}
+	if l.reg != "" {
+		return l.reg.sized(bits)
+	}
+	return g.slot(l.slot)
+}
+
+// src returns an operand which reads v as bits wide.
+// A value without such an operand, like an address, is computed into r.
+func (g *funcgen) src(v ir.Value, bits int, r reg) string {
+	switch v := v.(type) {
+	case *ir.Const:
+		if n, ok := ir.IntValue(v); ok {
+			n = truncate(n, bits)
+			if n != int64(int32(n)) {
+				g.emit("movabsq $%d, %s", n, r.sized(64))
+				return r.sized(64)
+			}
+			return fmt.Sprintf("$%d", n)
+		}
+		if v.Text == "null" {
+			return "$0"
+		}
+		if addr, ok := constAddr(v); ok {
+			g.emit("leaq %s, %s", addr, r.sized(64))
+			return r.sized(64)
+		}
+		panic(fmt.Errorf("cannot lower the constant %s", v.Text))
+	case *ir.Global:
+		g.emit("leaq %s(%%rip), %s", symbol(v), r.sized(64))
+		return r.sized(64)
+	case *ir.Function:
+		if v.IsDecl() {
+			// a function in a shared library is reached through the GOT.
+			g.emit("movq %s@GOTPCREL(%%rip), %s", v.Name, r.sized(64))
+		} else {
+			g.emit("leaq %s(%%rip), %s", v.Name, r.sized(64))
+		}
+		return r.sized(64)
+	case *ir.Instr:
+		if s, ok := g.allocas[v]; ok {
+			g.emit("leaq %s, %s", g.slot(s), r.sized(64))
+			return r.sized(64)
+		}
+	}
+	return g.at(v, bits)
+}
+
+// truncate wraps n around into bits, so it can be an immediate of the instruction.
+func truncate(n int64, bits int) int64 {
+	switch bits {
+	case 8:
+		return int64(int8(n))
+	case 32:
+		return int64(int32(n))
+	default:
+		return n
+	}
+}
+
+// constAddr returns the memory operand of a constant getelementptr
+// which points the head of a global, like a string literal does.
+func constAddr(c *ir.Const) (string, bool) {
+	if len(c.Refs) != 1 {
+		return "", false
+	}
+	gl, ok := c.Refs[0].(*ir.Global)
+	if !ok {
+		return "", false
+	}
+	rest := c.Text[strings.Index(c.Text, gl.Ident())+len(gl.Ident()):]
+	rest = strings.TrimPrefix(strings.TrimSuffix(rest, ")"), ", ")
+	if rest != "" {
+		for _, idx := range strings.Split(rest, ", ") {
+			if !strings.HasSuffix(idx, " 0") {
+				return "", false
+			}
+		}
+	}
+	return symbol(gl) + "(%rip)", true
+}
+
+// load moves v into r.
+func (g *funcgen) load(v ir.Value, bits int, r reg) {
+	if s := g.src(v, bits, r); s != r.sized(bits) {
+		g.emit("mov%s %s, %s", suffix(bits), s, r.sized(bits))
+	}
+}
+
+// move moves r into the location of v.
+func (g *funcgen) move(r reg, v ir.Value) {
+	b := width(v.Type())
+	if d := g.at(v, b); d != r.sized(b) {
+		g.emit("mov%s %s, %s", suffix(b), r.sized(b), d)
+	}
+}
+
+// mem returns a memory operand at the address ptr, computing it into r if needed.
+func (g *funcgen) mem(ptr ir.Value, r reg) string {
+	switch p := ptr.(type) {
+	case *ir.Global:
+		return symbol(p) + "(%rip)"
+	case *ir.Const:
+		if addr, ok := constAddr(p); ok {
+			return addr
+		}
+	case *ir.Instr:
+		if s, ok := g.allocas[p]; ok {
+			return g.slot(s)
+		}
+	}
+	g.load(ptr, 64, r)
+	return "(" + r.sized(64) + ")"
+}
+
+func (g *funcgen) block(bb, next *ir.BasicBlock) {
+	g.label(g.blockLabel(bb))
+	for i, in := range bb.Instrs {
+		if g.isTailCall(in, bb.Instrs[i+1:]) {
+			g.tailCall(in)
+			// the following ret is never reached.
+			return
+		}
+		g.instr(bb, in, next)
+	}
+}
+
+func (g *funcgen) instr(bb *ir.BasicBlock, in *ir.Instr, next *ir.BasicBlock) {
+	switch in.Op {
+	case ir.OpAdd, ir.OpSub, ir.OpMul:
+		op := map[ir.Op]string{ir.OpAdd: "add", ir.OpSub: "sub", ir.OpMul: "imul"}[in.Op]
+		b := width(in.Typ)
+		g.load(in.Args[0], b, rax)
+		g.emit("%s%s %s, %s", op, suffix(b), g.src(in.Args[1], b, rcx), rax.sized(b))
+		g.move(rax, in)
+	case ir.OpSDiv:
+		b := width(in.Typ)
+		g.load(in.Args[0], b, rax)
+		g.load(in.Args[1], b, rcx)
+		if b == 64 {
+			g.emit("cqto")
+		} else {
+			g.emit("cltd")
+		}
+		g.emit("idiv%s %s", suffix(b), rcx.sized(b))
+		g.move(rax, in)
+	case ir.OpICmp:
+		b := width(in.Args[0].Type())
+		g.load(in.Args[0], b, rax)
+		g.emit("cmp%s %s, %s", suffix(b), g.src(in.Args[1], b, rcx), rax.sized(b))
+		g.emit("set%s %%al", conds[in.Pred])
+		g.move(rax, in)
+	case ir.OpZExt:
+		g.load(in.Args[0], width(in.Args[0].Type()), rax)
+		if width(in.Args[0].Type()) == 8 {
+			g.emit("movzbl %%al, %%eax")
+		} else {
+			// writing a 32 bit register clears the upper bits.
+			g.emit("movl %%eax, %%eax")
+		}
+		g.move(rax, in)
+	case ir.OpSExt:
+		from, to := width(in.Args[0].Type()), width(in.Typ)
+		g.load(in.Args[0], from, rax)
+		if in.Args[0].Type() == ir.I1 {
+			g.emit("negb %%al")
+		}
+		g.emit("movs%s%s %s, %s", suffix(from), suffix(to), rax.sized(from), rax.sized(to))
+		g.move(rax, in)
+	case ir.OpTrunc:
+		// the lower bits of a register or memory are the truncated value.
+		g.load(in.Args[0], width(in.Typ), rax)
+		if in.Typ == ir.I1 {
+			g.emit("andb $1, %%al")
+		}
+		g.move(rax, in)
+	case ir.OpBitCast:
+		g.load(in.Args[0], 64, rax)
+		g.move(rax, in)
+	case ir.OpAlloca, ir.OpPhi:
+		// allocas have stack slots, and phis are copied at the end of predecessors.
+	case ir.OpLoad:
+		b := width(in.Typ)
+		g.emit("mov%s %s, %s", suffix(b), g.mem(in.Args[0], rcx), rax.sized(b))
+		g.move(rax, in)
+	case ir.OpStore:
+		b := width(in.Args[0].Type())
+		s := g.src(in.Args[0], b, rax)
+		if strings.HasSuffix(s, ")") {
+			// an instruction cannot take two memory operands.
+			g.emit("mov%s %s, %s", suffix(b), s, rax.sized(b))
+			s = rax.sized(b)
+		}
+		g.emit("mov%s %s, %s", suffix(b), s, g.mem(in.Args[1], rcx))
+	case ir.OpGEP:
+		g.gep(in)
+	case ir.OpCall:
+		// there is no debug information in the assembly, so debug intrinsics are dropped.
+		if !in.IsDebugIntrinsic() {
+			g.call(in)
+		}
+	case ir.OpBr:
+		g.br(bb, in, next)
+	case ir.OpRet:
+		if len(in.Args) > 0 {
+			g.load(in.Args[0], width(in.Args[0].Type()), rax)
+		}
+		g.leave()
+		g.emit("ret")
+	case ir.OpUnreachable:
+		g.emit("ud2")
+	default:
+		panic(fmt.Errorf("cannot lower %s", in.Op))
+	}
+}
+
+// gep adds offsets of each index to the pointer.
+func (g *funcgen) gep(in *ir.Instr) {
+	g.load(in.Args[0], 64, rax)
+	t := in.Elem
+	for i, idx := range in.Args[1:] {
+		if i > 0 {
+			_, elem, ok := array(t)
+			if !ok {
+				panic(fmt.Errorf("cannot index into %s", t))
+			}
+			t = elem
+		}
+		size := sizeOf(t)
+		if n, ok := ir.IntValue(idx); ok {
+			if n != 0 {
+				g.emit("addq $%d, %%rax", n*int64(size))
+			}
+			continue
+		}
+		b := width(idx.Type())
+		g.load(idx, b, rcx)
+		if b < 64 {
+			g.emit("movs%sq %s, %%rcx", suffix(b), rcx.sized(b))
+		}
+		g.emit("imulq $%d, %%rcx, %%rcx", size)
+		g.emit("addq %%rcx, %%rax")
+	}
+	g.move(rax, in)
+}
+
+// args loads arguments of the call in, which are passed in registers.
+func (g *funcgen) args(in *ir.Instr) {
+	for i, a := range in.CallArgs() {
+		if i < len(argRegs) {
+			g.load(a, width(a.Type()), argRegs[i])
+		}
+	}
+}
+
+// target returns the operand of call or jmp for the callee of in.
+// It also tells variadic callees no vector registers are used, by %al.
+func (g *funcgen) target(in *ir.Instr) string {
+	t := "*" + r11.sized(64)
+	if f, ok := in.Callee().(*ir.Function); ok {
+		t = f.Name
+		if f.IsDecl() {
+			t += "@PLT"
+		}
+	} else {
+		g.load(in.Callee(), 64, r11)
+	}
+	if in.Callee().Type().IsVariadic() {
+		g.emit("movl $0, %%eax")
+	}
+	return t
+}
+
+func (g *funcgen) call(in *ir.Instr) {
+	stack := in.CallArgs()
+	if len(stack) > len(argRegs) {
+		stack = stack[len(argRegs):]
+	} else {
+		stack = nil
+	}
+	// the stack stays 16 bytes aligned at the call.
+	pad := len(stack) % 2 * 8
+	if pad > 0 {
+		g.emit("subq $%d, %%rsp", pad)
+	}
+	for i := len(stack) - 1; i >= 0; i-- {
+		g.load(stack[i], width(stack[i].Type()), rax)
+		g.emit("pushq %%rax")
+	}
+	g.args(in)
+	g.emit("call %s", g.target(in))
+	if n := 8*len(stack) + pad; n > 0 {
+		g.emit("addq $%d, %%rsp", n)
+	}
+	if in.HasResult() {
+		g.move(rax, in)
+	}
+}
+
+// isTailCall reports whether in is a call marked as a tail call, whose result is returned by rest,
+// and which passes every argument in registers so the frame can be released before the call.
+func (g *funcgen) isTailCall(in *ir.Instr, rest []*ir.Instr) bool {
+	if in.Op != ir.OpCall || in.Tail == ir.NoTail || len(in.CallArgs()) > len(argRegs) || len(rest) != 1 {
+		return false
+	}
+	ret := rest[0]
+	if ret.Op != ir.OpRet {
+		return false
+	}
+	if len(ret.Args) == 0 {
+		return !in.HasResult()
+	}
+	return ret.Args[0] == in
+}
+
+// tailCall jumps into the callee after releasing the frame, so the callee returns to our caller.
+func (g *funcgen) tailCall(in *ir.Instr) {
+	g.args(in)
+	t := g.target(in)
+	g.leave()
+	g.emit("jmp %s", t)
+}
+
+// leave restores saved registers and releases the frame.
+func (g *funcgen) leave() {
+	for i, r := range g.alloc.saved {
+		g.emit("movq %d(%%rbp), %s", -8*(i+1), r.sized(64))
+	}
+	g.emit("leave")
+}
+
+func (g *funcgen) br(bb *ir.BasicBlock, in *ir.Instr, next *ir.BasicBlock) {
+	if len(in.Targets) == 1 {
+		g.jump(bb, in.Targets[0], next)
+		return
+	}
+
+	then, els := in.Targets[0], in.Targets[1]
+	if n, ok := ir.IntValue(in.Args[0]); ok {
+		if n != 0 {
+			els = then
+		}
+		g.jump(bb, els, next)
+		return
+	}
+	if c := g.src(in.Args[0], 8, rax); strings.HasPrefix(c, "%") {
+		g.emit("testb %s, %s", c, c)
+	} else {
+		g.emit("cmpb $0, %s", c)
+	}
+	g.emit("jne %s", g.edge(bb, then))
+	g.jump(bb, els, next)
+}
+
+// jump copies phi operands for the edge from bb into to and jumps, or falls through into next.
+func (g *funcgen) jump(bb, to, next *ir.BasicBlock) {
+	g.copyPhis(bb, to)
+	if to != next {
+		g.emit("jmp %s", g.blockLabel(to))
+	}
+}
+
+// edge returns the label to branch from bb into to.
+// If phi operands must be copied, it is a stub which copies them before jumping into to.
+func (g *funcgen) edge(bb, to *ir.BasicBlock) string {
+	if len(phiMoves(bb, to)) == 0 {
+		return g.blockLabel(to)
+	}
+	l := fmt.Sprintf(".L%s.%s.%s", g.f.Name, bb.Name, to.Name)
+	g.stubs = append(g.stubs, func() {
+		g.label(l)
+		g.jump(bb, to, nil)
+	})
+	return l
+}
+
+// phiMove is a copy of a phi operand into the phi.
+type phiMove struct {
+	phi *ir.Instr
+	v   ir.Value
+}
+
+// phiMoves lists copies needed for phis of to on the edge from bb.
+func phiMoves(bb, to *ir.BasicBlock) []phiMove {
+	moves := []phiMove{}
+	for _, in := range to.Instrs {
+		if in.Op != ir.OpPhi {
+			break
+		}
+		for _, inc := range in.Incomings {
+			if inc.Block == bb && inc.Value != ir.Value(in) {
+				moves = append(moves, phiMove{phi: in, v: inc.Value})
+			}
+		}
+	}
+	return moves
+}
+
+// copyPhis copies phi operands for the edge from bb into to.
+func (g *funcgen) copyPhis(bb, to *ir.BasicBlock) {
+	moves := phiMoves(bb, to)
+	if len(moves) == 1 {
+		m := moves[0]
+		b := width(m.phi.Typ)
+		s, d := g.src(m.v, b, rax), g.at(m.phi, b)
+		if strings.HasSuffix(s, ")") && strings.HasSuffix(d, ")") {
+			// an instruction cannot take two memory operands.
+			g.emit("mov%s %s, %s", suffix(b), s, rax.sized(b))
+			s = rax.sized(b)
+		}
+		if s != d {
+			g.emit("mov%s %s, %s", suffix(b), s, d)
+		}
+		return
+	}
+	// phis are assigned at once, and one can be an operand of another,
+	// so every operand is read onto the stack before any phi is written.
+	for _, m := range moves {
+		g.load(m.v, width(m.phi.Typ), rax)
+		g.emit("pushq %%rax")
+	}
+	for i := len(moves) - 1; i >= 0; i-- {
+		g.emit("popq %%rax")
+		g.move(rax, moves[i].phi)
+	}
+}
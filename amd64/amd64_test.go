@@ -0,0 +1,153 @@
+package amd64_test
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"gotest.tools/assert"
+
+	"github.com/yuniruyuni/lang/amd64"
+	"github.com/yuniruyuni/lang/gen"
+	"github.com/yuniruyuni/lang/ir"
+	"github.com/yuniruyuni/lang/module"
+	"github.com/yuniruyuni/lang/opt"
+)
+
+func TestGenerate(t *testing.T) {
+	if runtime.GOOS != "linux" || runtime.GOARCH != "amd64" {
+		t.Skip("the assembly is for x86-64 Linux")
+	}
+	cc, err := exec.LookPath("cc")
+	if err != nil {
+		t.Skip("cc is not installed")
+	}
+
+	tests := []struct {
+		name     string
+		code     string
+		level    int
+		input    string
+		want     string
+		wantCode int
+	}{
+		{
+			name:     "arithmetic and comparisons",
+			code:     `func main(){ printf("%d,%d,%d,%d,%d,%d", 7 / 2, 0 - 7 / 2, 2147483647 + 1, 3 < 4, 4 == 5, 65536 * 65536,); 6 * 7 }`,
+			want:     "3,-3,-2147483648,1,0,0",
+			wantCode: 42,
+		},
+		{
+			name:     "if and while with locals",
+			code:     `func main(){ let i = 0; let s = 0; while i < 10 { if i < 5 { s = s + i } else { s = s + 10 }; i = i + 1 }; s }`,
+			wantCode: 60,
+		},
+		{
+			name:     "phis swapping values",
+			code:     `func main(){ let a = 0; let b = 1; let i = 0; while i < 10 { let t = a + b; a = b; b = t; i = i + 1 }; a }`,
+			level:    1,
+			wantCode: 55,
+		},
+		{
+			name: "values spilled out of registers",
+			code: `func main(){
+				let a = read(); let b = read(); let c = read(); let d = read();
+				let e = read(); let f = read(); let g = read(); let h = read();
+				printf("%d,%d", a * b + c * d + e * f + g * h, a + b + c + d + e + f + g + h,); 0 }`,
+			level: 1,
+			input: "1\n2\n3\n4\n5\n6\n7\n8\n",
+			want:  "100,36",
+		},
+		{
+			name: "arguments passed on the stack",
+			code: `func f(a: i32, b: i32, c: i32, d: i32, e: i32, g: i32, h: i32,) -> i32 { a - b + c - d + e - g + h * 10 }
+				func k(a: i32, b: i32, c: i32, d: i32, e: i32, g: i32, h: i32, i: i32,) -> i32 { h * i - a }
+				func main(){ printf("%d,%d,%d,%d,%d,%d,%d,%d,%d,%d", 1, 2, 3, 4, 5, 6, 7, 8, f(1, 2, 3, 4, 5, 6, 7,), k(1, 2, 3, 4, 5, 6, 7, 8,),); 0 }`,
+			want: "1,2,3,4,5,6,7,8,-71,55",
+		},
+		{
+			name: "function values and globals",
+			code: `var n = 1
+				func double(x: i32,) -> i32 { x * 2 }
+				func apply(f: fn(i32) -> i32, x: i32,) -> i32 { n = n + 1; f(x,) }
+				func main(){ let f = double; apply(f, 10,) + apply(double, n,) }`,
+			level:    2,
+			wantCode: 24,
+		},
+		{
+			name: "tail calls",
+			code: `func inc(x: i32,) -> i32 { x + 1 }
+				func apply(f: fn(i32) -> i32, x: i32,) -> i32 { f(x,) }
+				func show(x: i32,) -> i32 { printf("%d,", x,) }
+				func main(){ show(apply(inc, 41,),); apply(inc, 1,) }`,
+			level:    1,
+			want:     "42,",
+			wantCode: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prog, err := module.New(nil).LoadSource("<test>", tt.code)
+			assert.NilError(t, err)
+			ll := gen.LLFile{AST: prog}
+			m := ll.Generate()
+			assert.NilError(t, opt.NewManager(tt.level).Run(m))
+
+			src, err := amd64.Generate(m)
+			assert.NilError(t, err)
+
+			dir := t.TempDir()
+			path := filepath.Join(dir, "main.s")
+			assert.NilError(t, os.WriteFile(path, []byte(src), 0o644))
+			out, err := exec.Command(cc, "-o", filepath.Join(dir, "prog"), path).CombinedOutput()
+			assert.NilError(t, err, "%s\n%s", out, src)
+
+			cmd := exec.Command(filepath.Join(dir, "prog"))
+			cmd.Stdin = strings.NewReader(tt.input)
+			stdout := new(bytes.Buffer)
+			cmd.Stdout = stdout
+			code := 0
+			if err := cmd.Run(); err != nil {
+				exit, ok := err.(*exec.ExitError)
+				assert.Assert(t, ok, err)
+				code = exit.ExitCode()
+			}
+			assert.Equal(t, tt.want, stdout.String())
+			assert.Equal(t, tt.wantCode, code)
+		})
+	}
+}
+
+func TestGenerate_Errors(t *testing.T) {
+	arm, err := ir.LookupTarget("armv7-unknown-linux-gnueabihf")
+	assert.NilError(t, err)
+
+	tests := []struct {
+		name  string
+		build func(m *ir.Module)
+		want  string
+	}{
+		{
+			name:  "unsupported initializer",
+			build: func(m *ir.Module) { m.NewGlobal("x", ir.I32, "undef") },
+			want:  "cannot lower the initializer of @x: undef",
+		},
+		{
+			name:  "another target",
+			build: func(m *ir.Module) { m.Target = arm },
+			want:  "cannot generate x86-64 assembly for the target armv7-unknown-linux-gnueabihf",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := ir.NewModule()
+			tt.build(m)
+			_, err := amd64.Generate(m)
+			assert.Error(t, err, tt.want)
+		})
+	}
+}
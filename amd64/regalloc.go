@@ -0,0 +1,256 @@
+package amd64
+
+import (
+	"sort"
+
+	"github.com/yuniruyuni/lang/ir"
+)
+
+// loc is where a value lives, a register or a stack slot.
+type loc struct {
+	// reg is the register holding the value, empty if it is spilled.
+	reg reg
+	// slot is the number of the stack slot for a spilled value, counted from 1.
+	slot int
+}
+
+// interval is the range of positions where a value is live.
+type interval struct {
+	value      ir.Value
+	start, end int
+}
+
+// allocation is the result of register allocation for a function.
+type allocation struct {
+	locs map[ir.Value]loc
+	// saved lists registers the function must save because values are allocated into them.
+	saved []reg
+	// slots is the number of stack slots used by spilled values.
+	slots int
+}
+
+// allocatable reports whether v needs a location, allocas live at fixed stack slots instead.
+func allocatable(v ir.Value) bool {
+	switch v := v.(type) {
+	case *ir.Param:
+		return true
+	case *ir.Instr:
+		return v.HasResult() && v.Op != ir.OpAlloca
+	default:
+		return false
+	}
+}
+
+// allocate assigns a location to every value of f by linear scan over live intervals.
+// Only registers in regs are used, and values which don't fit are spilled into stack slots.
+func allocate(f *ir.Function, regs []reg) *allocation {
+	a := &allocation{locs: map[ir.Value]loc{}}
+
+	ivs := liveIntervals(f)
+	sort.SliceStable(ivs, func(i, j int) bool { return ivs[i].start < ivs[j].start })
+
+	free := append([]reg{}, regs...)
+	used := map[reg]bool{}
+	active := []*interval{}
+	spill := func(iv *interval) {
+		a.slots++
+		a.locs[iv.value] = loc{slot: a.slots}
+	}
+
+	for _, iv := range ivs {
+		// expire intervals which end before this one starts.
+		rest := active[:0]
+		for _, o := range active {
+			if o.end < iv.start {
+				free = append(free, a.locs[o.value].reg)
+				continue
+			}
+			rest = append(rest, o)
+		}
+		active = rest
+
+		if len(free) > 0 {
+			r := takeFirst(&free, regs)
+			used[r] = true
+			a.locs[iv.value] = loc{reg: r}
+			active = append(active, iv)
+			continue
+		}
+
+		// spill whichever lives longer, so the register is released sooner.
+		last := 0
+		for i, o := range active {
+			if o.end > active[last].end {
+				last = i
+			}
+		}
+		if o := active[last]; o.end > iv.end {
+			a.locs[iv.value] = a.locs[o.value]
+			spill(o)
+			active[last] = iv
+		} else {
+			spill(iv)
+		}
+	}
+
+	for _, r := range regs {
+		if used[r] {
+			a.saved = append(a.saved, r)
+		}
+	}
+	return a
+}
+
+// takeFirst removes the register which comes first in order from free, so allocation is deterministic.
+func takeFirst(free *[]reg, order []reg) reg {
+	for _, r := range order {
+		for i, f := range *free {
+			if f == r {
+				*free = append((*free)[:i], (*free)[i+1:]...)
+				return r
+			}
+		}
+	}
+	panic("no free register")
+}
+
+// liveIntervals computes the interval of every allocatable value of f.
+// Instructions are numbered in the order of blocks, and an interval covers every position
+// from the definition to the last use, including whole blocks the value is live through.
+// Phi operands are used at the end of the predecessor, where they are copied.
+func liveIntervals(f *ir.Function) []*interval {
+	start := map[*ir.BasicBlock]int{}
+	end := map[*ir.BasicBlock]int{}
+	at := map[*ir.Instr]int{}
+	pos := 0
+	for _, bb := range f.Blocks {
+		start[bb] = pos
+		for _, in := range bb.Instrs {
+			at[in] = pos
+			pos++
+		}
+		end[bb] = pos - 1
+	}
+
+	liveIn, liveOut := liveness(f)
+
+	ivs := []*interval{}
+	byValue := map[ir.Value]*interval{}
+	extend := func(v ir.Value, p int) {
+		if !allocatable(v) {
+			return
+		}
+		iv, ok := byValue[v]
+		if !ok {
+			iv = &interval{value: v, start: p, end: p}
+			byValue[v] = iv
+			ivs = append(ivs, iv)
+		}
+		if p < iv.start {
+			iv.start = p
+		}
+		if p > iv.end {
+			iv.end = p
+		}
+	}
+
+	// intervals are made in the order of definitions first, so the allocation is deterministic.
+	// parameters are defined on entry before any instruction.
+	for _, p := range f.Params {
+		extend(p, -1)
+	}
+	for _, bb := range f.Blocks {
+		for _, in := range bb.Instrs {
+			extend(in, at[in])
+		}
+	}
+	for _, bb := range f.Blocks {
+		for _, in := range bb.Instrs {
+			if in.Op != ir.OpPhi {
+				for _, v := range in.Args {
+					extend(v, at[in])
+				}
+				continue
+			}
+			// a phi is written where its operands are copied, so it lives there too.
+			for _, inc := range in.Incomings {
+				extend(in, end[inc.Block])
+				extend(inc.Value, end[inc.Block])
+			}
+		}
+		for v := range liveIn[bb] {
+			extend(v, start[bb])
+		}
+		for v := range liveOut[bb] {
+			extend(v, start[bb])
+			extend(v, end[bb])
+		}
+	}
+	return ivs
+}
+
+// liveness computes values live on entry and exit of each block by the usual backward dataflow.
+func liveness(f *ir.Function) (liveIn, liveOut map[*ir.BasicBlock]map[ir.Value]bool) {
+	uses := map[*ir.BasicBlock]map[ir.Value]bool{}
+	defs := map[*ir.BasicBlock]map[ir.Value]bool{}
+	// phiUses holds the values each block passes into phis of its successors.
+	phiUses := map[*ir.BasicBlock]map[ir.Value]bool{}
+	liveIn = map[*ir.BasicBlock]map[ir.Value]bool{}
+	liveOut = map[*ir.BasicBlock]map[ir.Value]bool{}
+	for _, bb := range f.Blocks {
+		uses[bb] = map[ir.Value]bool{}
+		defs[bb] = map[ir.Value]bool{}
+		phiUses[bb] = map[ir.Value]bool{}
+		liveIn[bb] = map[ir.Value]bool{}
+		liveOut[bb] = map[ir.Value]bool{}
+	}
+
+	for _, bb := range f.Blocks {
+		for _, in := range bb.Instrs {
+			if allocatable(in) {
+				defs[bb][in] = true
+			}
+			if in.Op == ir.OpPhi {
+				for _, inc := range in.Incomings {
+					if allocatable(inc.Value) {
+						phiUses[inc.Block][inc.Value] = true
+					}
+				}
+				continue
+			}
+			// in SSA form a use in the defining block always follows the definition.
+			for _, v := range in.Args {
+				if allocatable(v) {
+					uses[bb][v] = true
+				}
+			}
+		}
+	}
+
+	for changed := true; changed; {
+		changed = false
+		for i := len(f.Blocks) - 1; i >= 0; i-- {
+			bb := f.Blocks[i]
+			out := liveOut[bb]
+			for v := range phiUses[bb] {
+				out[v] = true
+			}
+			for _, s := range bb.Succs() {
+				for v := range liveIn[s] {
+					out[v] = true
+				}
+			}
+
+			in := liveIn[bb]
+			for _, vs := range []map[ir.Value]bool{uses[bb], out} {
+				for v := range vs {
+					if !defs[bb][v] && !in[v] {
+						in[v] = true
+						changed = true
+					}
+				}
+			}
+		}
+	}
+	return liveIn, liveOut
+}
@@ -0,0 +1,29 @@
+package ast
+
+// Names of builtin functions which print their arguments by the types of them.
+// They are called only if no function of the same name is visible.
+const (
+	// PrintBuiltin prints arguments without separators.
+	PrintBuiltin Name = "print"
+	// PrintlnBuiltin prints arguments and a newline.
+	PrintlnBuiltin Name = "println"
+)
+
+// Names of builtin functions which stop the program by a runtime error.
+// They are called only if no function of the same name is visible.
+const (
+	// PanicBuiltin stops the program with the message given as a string.
+	PanicBuiltin Name = "panic"
+	// AssertBuiltin stops the program if the condition is false, optionally with a message.
+	AssertBuiltin Name = "assert"
+)
+
+// IsPrintBuiltin reports whether n names a builtin printing function.
+func IsPrintBuiltin(n Name) bool {
+	return n == PrintBuiltin || n == PrintlnBuiltin
+}
+
+// IsBuiltin reports whether n names a builtin function like `print` or `panic`.
+func IsBuiltin(n Name) bool {
+	return IsPrintBuiltin(n) || n == PanicBuiltin || n == AssertBuiltin
+}
@@ -0,0 +1,16 @@
+package ast
+
+type Const struct {
+	Span
+	// for `const x = y`,
+	LHS AST // x
+	RHS AST // y
+}
+
+func (s *Const) Name() Name {
+	return s.LHS.Name()
+}
+
+func (s *Const) Type() Type {
+	return "i32"
+}
@@ -0,0 +1,14 @@
+package ast
+
+// Ellipsis is `...` at the end of parameters for a variadic function.
+type Ellipsis struct {
+	Span
+}
+
+func (s *Ellipsis) Name() Name {
+	return ""
+}
+
+func (s *Ellipsis) Type() Type {
+	return "..."
+}
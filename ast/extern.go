@@ -0,0 +1,33 @@
+package ast
+
+import (
+	"github.com/yuniruyuni/lang/ir"
+)
+
+type Extern struct {
+	Span
+	// for `extern func Name(Params) -> RetType`,
+	FuncName AST
+	Params   AST
+	// nil means i32.
+	RetType AST
+}
+
+// Name is the symbol name of the external function.
+// It is shared by all modules so it is never qualified by a module name.
+func (s *Extern) Name() Name {
+	return s.FuncName.Name()
+}
+
+func (s *Extern) Type() Type {
+	if s.RetType == nil {
+		return "i32"
+	}
+	return typeOf(s.RetType)
+}
+
+// FuncType returns the function pointer type for this function.
+func (s *Extern) FuncType() Type {
+	ps := s.Params.(*Params)
+	return ir.FuncType(s.Type(), ps.Types(), ps.Variadic)
+}
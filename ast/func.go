@@ -5,9 +5,12 @@ import (
 )
 
 type Func struct {
+	Span
 	FuncName AST
 	Params   AST
-	Execute  AST
+	// for `-> T`, nil means i32.
+	RetType AST
+	Execute AST
 }
 
 func (s *Func) Name() Name {
@@ -15,43 +18,13 @@ func (s *Func) Name() Name {
 }
 
 func (s *Func) Type() Type {
-	return "i32"
+	if s.RetType == nil {
+		return "i32"
+	}
+	return typeOf(s.RetType)
 }
 
-func (s *Func) ResultReg() Reg {
-	return 0
-}
-
-func (s *Func) ResultLabel() Label {
-	return 0
-}
-
-func (s *Func) GenHeader(g *Gen) ir.IR {
-	g.RegisterFunc(s.Name(), s.Params.Type())
-	return s.Execute.GenHeader(g)
-}
-
-func (s *Func) GenBody(g *Gen) ir.IR {
-	name := s.Name()
-	params := s.Params.GenBody(g)
-	body := s.Execute.GenBody(g)
-
-	return ir.IR(`
-		define i32 @%s(%s) {
-			%s
-			ret i32 %%%d
-		}
-	`).Expand(
-		name, params,
-		body,
-		s.Execute.ResultReg(),
-	)
-}
-
-func (s *Func) GenArg() ir.IR {
-	return ""
-}
-
-func (s *Func) GenPrinter() ir.IR {
-	return ""
+// FuncType returns the function pointer type for this function.
+func (s *Func) FuncType() Type {
+	return ir.FuncType(s.Type(), s.Params.(*Params).Types(), false)
 }
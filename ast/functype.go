@@ -0,0 +1,20 @@
+package ast
+
+import (
+	"github.com/yuniruyuni/lang/ir"
+)
+
+type FuncType struct {
+	Span
+	// for `fn(T, U) -> R`,
+	Params AST // T, U
+	Ret    AST // R
+}
+
+func (s *FuncType) Name() Name {
+	return ""
+}
+
+func (s *FuncType) Type() Type {
+	return ir.FuncType(typeOf(s.Ret), s.Params.(*TypeList).Elems(), false)
+}
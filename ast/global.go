@@ -0,0 +1,16 @@
+package ast
+
+type Global struct {
+	Span
+	// for `var x = y`,
+	LHS AST // x
+	RHS AST // y
+}
+
+func (s *Global) Name() Name {
+	return s.LHS.Name()
+}
+
+func (s *Global) Type() Type {
+	return "i32"
+}
@@ -0,0 +1,11 @@
+package ast
+
+type Import struct {
+	Span
+	// for `import "path"`
+	Path string
+}
+
+func (s *Import) Name() Name {
+	return ""
+}
@@ -0,0 +1,38 @@
+package ast
+
+// Info holds what code generation resolves for nodes of a program,
+// so that the nodes themselves are left as parsed
+// and the same tree can be generated again or walked by other backends.
+// Checking the program isn't a pass of its own: gen.LLFile checks it while generating LLVM IR and fills Info,
+// so the other backends run LLFile first.
+type Info struct {
+	// Types maps each expression to the type of its value.
+	Types map[AST]Type
+	// Calls maps each call to what it calls.
+	Calls map[*Call]Callee
+	// Names maps each reference to a module level definition, like a variable, an assignment
+	// or the function name of a call, to the qualified name of the definition.
+	// References to variables of a function are not held.
+	Names map[AST]Name
+}
+
+// Callee describes what a call calls.
+type Callee struct {
+	// FuncType is the function pointer type of the called function, empty for a builtin.
+	FuncType Type
+	// Builtin is the name of the builtin like `print` which the call is, empty for a function call.
+	Builtin Name
+}
+
+func NewInfo() *Info {
+	return &Info{
+		Types: map[AST]Type{},
+		Calls: map[*Call]Callee{},
+		Names: map[AST]Name{},
+	}
+}
+
+// TypeOf returns the type of the expression n, or empty if n is not resolved.
+func (i *Info) TypeOf(n AST) Type {
+	return i.Types[n]
+}
@@ -0,0 +1,26 @@
+package ast
+
+type Module struct {
+	Span
+	// ModName prefixes every module level name defined in this module.
+	// It is empty for the root module.
+	ModName Name
+	// Imports maps each import name like `math` to its module name.
+	Imports map[Name]Name
+	// the definitions in this module.
+	Defs AST
+	// File is the source file of this module, to find lines of positions in it.
+	File *File
+}
+
+func (s *Module) Name() Name {
+	return s.ModName
+}
+
+// Qualify makes the module level name for n defined in this module.
+func (s *Module) Qualify(n Name) Name {
+	if s.ModName == "" {
+		return n
+	}
+	return s.ModName + "." + n
+}
@@ -1,13 +1,10 @@
 package ast
 
-import (
-	"github.com/yuniruyuni/lang/ir"
-)
-
 type Param struct {
-	Result  Reg
-	Label   Label
+	Span
 	VarName Name
+	// for `x: T`, nil means i32.
+	VarType AST
 }
 
 func (s *Param) Name() Name {
@@ -15,30 +12,8 @@ func (s *Param) Name() Name {
 }
 
 func (s *Param) Type() Type {
-	return "i32"
-}
-
-func (s *Param) ResultReg() Reg {
-	return s.Result
-}
-
-func (s *Param) ResultLabel() Label {
-	return s.Label
-}
-
-func (s *Param) GenHeader(g *Gen) ir.IR {
-	return ""
-}
-
-func (s *Param) GenBody(g *Gen) ir.IR {
-	g.RegisterVariable(s.Name(), "i32")
-	return ir.IR(`i32 %%%s`).Expand(s.Name())
-}
-
-func (s *Param) GenArg() ir.IR {
-	return ""
-}
-
-func (s *Param) GenPrinter() ir.IR {
-	return GenIntPrinter(s.Result)
+	if s.VarType == nil {
+		return "i32"
+	}
+	return typeOf(s.VarType)
 }
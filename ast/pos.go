@@ -0,0 +1,57 @@
+package ast
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Span is the range of source code which a node is parsed from, in byte offsets.
+type Span struct {
+	// Beg is the offset of the first byte.
+	Beg int
+	// End is the offset next to the last byte.
+	End int
+}
+
+// Pos returns the span of the node which embeds this span.
+func (s *Span) Pos() Span {
+	return *s
+}
+
+// SetPos sets the span of the node which embeds this span.
+func (s *Span) SetPos(p Span) {
+	*s = p
+}
+
+// File is a source file of a module, which finds lines and columns of offsets.
+type File struct {
+	// Path is the path of the file, or a name like `<stdin>` for code not in a file.
+	Path string
+	// lines holds the offset where each line starts.
+	lines []int
+}
+
+func NewFile(path, code string) *File {
+	lines := []int{0}
+	for i := 0; i < len(code); i++ {
+		if code[i] == '\n' {
+			lines = append(lines, i+1)
+		}
+	}
+	return &File{Path: path, lines: lines}
+}
+
+// Position returns the line and the column of the offset, both counted from 1.
+func (f *File) Position(offset int) (line, col int) {
+	l := sort.Search(len(f.lines), func(i int) bool { return f.lines[i] > offset })
+	return l, offset - f.lines[l-1] + 1
+}
+
+// Where returns the position of n in the file f like `main.yuni:3:5`, or `<unknown>`.
+func Where(f *File, n AST) string {
+	if f == nil || n.Pos() == (Span{}) {
+		return "<unknown>"
+	}
+	line, col := f.Position(n.Pos().Beg)
+	return fmt.Sprintf("%s:%d:%d", f.Path, line, col)
+}
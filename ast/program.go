@@ -0,0 +1,12 @@
+package ast
+
+type Program struct {
+	Span
+	// all modules linked into the program.
+	// Every module comes after the modules it imports.
+	Modules []AST
+}
+
+func (s *Program) Name() Name {
+	return ""
+}
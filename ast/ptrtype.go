@@ -0,0 +1,19 @@
+package ast
+
+import (
+	"github.com/yuniruyuni/lang/ir"
+)
+
+type PtrType struct {
+	Span
+	// for `*T`,
+	Elem AST // T
+}
+
+func (s *PtrType) Name() Name {
+	return ""
+}
+
+func (s *PtrType) Type() Type {
+	return ir.PointerTo(typeOf(s.Elem))
+}
@@ -0,0 +1,11 @@
+package ast
+
+type Pub struct {
+	Span
+	// for `pub <Def>`, Def is visible from other modules.
+	Def AST
+}
+
+func (s *Pub) Name() Name {
+	return s.Def.Name()
+}
@@ -1,9 +1,7 @@
 package ast
 
-import "github.com/yuniruyuni/lang/ir"
-
 type Sub struct {
-	Result Reg
+	Span
 	// for `x - y`,
 	LHS AST // x
 	RHS AST // y
@@ -12,38 +10,3 @@ type Sub struct {
 func (nd *Sub) Name() Name {
 	return ""
 }
-
-func (s *Sub) Type() Type {
-	return "i32"
-}
-
-func (s *Sub) ResultReg() Reg {
-	return s.Result
-}
-
-func (s *Sub) ResultLabel() Label {
-	return s.RHS.ResultLabel()
-}
-
-func (s *Sub) GenHeader(g *Gen) ir.IR {
-	return s.LHS.GenHeader(g) + s.RHS.GenHeader(g)
-}
-
-func (s *Sub) GenBody(g *Gen) ir.IR {
-	lhsBody := s.LHS.GenBody(g)
-	rhsBody := s.RHS.GenBody(g)
-	s.Result = g.NextReg()
-
-	body := ir.IR(`%%%d = sub i32 %%%d, %%%d`).
-		Expand(s.Result, s.LHS.ResultReg(), s.RHS.ResultReg())
-
-	return ir.Concat(lhsBody, rhsBody, body)
-}
-
-func (s *Sub) GenArg() ir.IR {
-	return ir.IR(`i32 %%%d`).Expand(s.ResultReg())
-}
-
-func (s *Sub) GenPrinter() ir.IR {
-	return GenIntPrinter(s.Result)
-}
@@ -0,0 +1,20 @@
+package ast
+
+import (
+	"github.com/yuniruyuni/lang/ir"
+)
+
+// builtinTypes maps type names written in source code to its Type.
+var builtinTypes = map[Name]Type{
+	"bool": ir.I1,
+	"i8":   ir.I8,
+	"u8":   ir.I8,
+	"i32":  ir.I32,
+	"i64":  ir.I64,
+}
+
+// LookupType finds the Type for the type name n.
+func LookupType(n Name) (Type, bool) {
+	t, ok := builtinTypes[n]
+	return t, ok
+}
@@ -0,0 +1,20 @@
+package ast
+
+type TypeList struct {
+	Span
+	// for `T, U, V`
+	Types []AST
+}
+
+func (s *TypeList) Name() Name {
+	return ""
+}
+
+// Elems returns each type in this list.
+func (s *TypeList) Elems() []Type {
+	ts := make([]Type, 0, len(s.Types))
+	for _, t := range s.Types {
+		ts = append(ts, typeOf(t))
+	}
+	return ts
+}
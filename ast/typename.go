@@ -0,0 +1,15 @@
+package ast
+
+type TypeName struct {
+	Span
+	// for `i32`,
+	TypeName Type
+}
+
+func (s *TypeName) Name() Name {
+	return ""
+}
+
+func (s *TypeName) Type() Type {
+	return s.TypeName
+}
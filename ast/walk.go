@@ -0,0 +1,138 @@
+package ast
+
+import (
+	"fmt"
+)
+
+// Visitor visits nodes of a tree by Walk.
+// Passes which only need the shape of the tree, like collecting declarations, are visitors,
+// while checking types stays in code generation as Info describes.
+// Visit is called for each node, and the children of the node are visited by the result w
+// unless it is nil, like the Visitor of go/ast.
+type Visitor interface {
+	Visit(n AST) (w Visitor)
+}
+
+// Walk traverses the tree n in depth-first order, children in the order they are written in source.
+// It calls v.Visit(n) first, walks each child of n with the result w unless it is nil,
+// and calls w.Visit(nil) at last. Children which are omitted in source, like a missing return type, are nil
+// and skipped.
+func Walk(v Visitor, n AST) {
+	if v = v.Visit(n); v == nil {
+		return
+	}
+
+	switch n := n.(type) {
+	case *Program:
+		walkList(v, n.Modules)
+	case *Module:
+		walk(v, n.Defs)
+	case *Definitions:
+		walkList(v, n.Defs)
+	case *Pub:
+		walk(v, n.Def)
+	case *Func:
+		walk(v, n.FuncName)
+		walk(v, n.Params)
+		walk(v, n.RetType)
+		walk(v, n.Execute)
+	case *Extern:
+		walk(v, n.FuncName)
+		walk(v, n.Params)
+		walk(v, n.RetType)
+	case *Params:
+		walkList(v, n.Vars)
+	case *Param:
+		walk(v, n.VarType)
+	case *Global:
+		walk(v, n.LHS)
+		walk(v, n.RHS)
+	case *Const:
+		walk(v, n.LHS)
+		walk(v, n.RHS)
+
+	case *PtrType:
+		walk(v, n.Elem)
+	case *FuncType:
+		walk(v, n.Params)
+		walk(v, n.Ret)
+	case *TypeList:
+		walkList(v, n.Types)
+
+	case *Let:
+		walk(v, n.LHS)
+		walk(v, n.RHS)
+	case *Assign:
+		walk(v, n.LHS)
+		walk(v, n.RHS)
+	case *Sequence:
+		walk(v, n.LHS)
+		walk(v, n.RHS)
+	case *If:
+		walk(v, n.Cond)
+		walk(v, n.Then)
+		walk(v, n.Else)
+	case *While:
+		walk(v, n.Cond)
+		walk(v, n.Proc)
+	case *Call:
+		walk(v, n.FuncName)
+		walk(v, n.Args)
+	case *Args:
+		walkList(v, n.Values)
+	case *Add:
+		walk(v, n.LHS)
+		walk(v, n.RHS)
+	case *Sub:
+		walk(v, n.LHS)
+		walk(v, n.RHS)
+	case *Mul:
+		walk(v, n.LHS)
+		walk(v, n.RHS)
+	case *Div:
+		walk(v, n.LHS)
+		walk(v, n.RHS)
+	case *Less:
+		walk(v, n.LHS)
+		walk(v, n.RHS)
+	case *Equal:
+		walk(v, n.LHS)
+		walk(v, n.RHS)
+
+	case *Import, *FuncName, *TypeName, *Ellipsis, *Integer, *String, *Variable:
+		// leaves have no children.
+	default:
+		panic(fmt.Errorf("ast.Walk: unexpected node %T", n))
+	}
+
+	v.Visit(nil)
+}
+
+// walk walks the child n unless it is omitted.
+func walk(v Visitor, n AST) {
+	if n != nil {
+		Walk(v, n)
+	}
+}
+
+func walkList(v Visitor, ns []AST) {
+	for _, n := range ns {
+		walk(v, n)
+	}
+}
+
+type inspector func(AST) bool
+
+func (f inspector) Visit(n AST) Visitor {
+	if f(n) {
+		return f
+	}
+	return nil
+}
+
+// Inspect traverses the tree n in depth-first order like Walk.
+// It calls f(n) for each node, and walks the children of n only if it returns true.
+// After the children, f(nil) is called.
+func Inspect(n AST, f func(AST) bool) {
+	Walk(inspector(f), n)
+}
@@ -0,0 +1,89 @@
+package ast_test
+
+import (
+	"fmt"
+	"testing"
+
+	"gotest.tools/assert"
+
+	"github.com/yuniruyuni/lang/ast"
+)
+
+// tree is `func f(x: i64,) { x + 1 }`, whose return type is omitted.
+func tree() ast.AST {
+	return &ast.Definitions{Span: ast.Span{Beg: 0, End: 25}, Defs: []ast.AST{
+		&ast.Func{
+			Span:     ast.Span{Beg: 0, End: 25},
+			FuncName: &ast.FuncName{Span: ast.Span{Beg: 5, End: 6}, FuncName: "f"},
+			Params: &ast.Params{Span: ast.Span{Beg: 7, End: 14}, Vars: []ast.AST{
+				&ast.Param{
+					Span:    ast.Span{Beg: 7, End: 13},
+					VarName: "x",
+					VarType: &ast.TypeName{Span: ast.Span{Beg: 10, End: 13}, TypeName: "i64"},
+				},
+			}},
+			Execute: &ast.Add{
+				Span: ast.Span{Beg: 18, End: 23},
+				LHS:  &ast.Variable{Span: ast.Span{Beg: 18, End: 19}, VarName: "x"},
+				RHS:  &ast.Integer{Span: ast.Span{Beg: 22, End: 23}, Value: 1},
+			},
+		},
+	}}
+}
+
+// describe writes n with its span like `*ast.Variable@18-19`.
+func describe(n ast.AST) string {
+	return fmt.Sprintf("%T@%d-%d", n, n.Pos().Beg, n.Pos().End)
+}
+
+func TestInspect(t *testing.T) {
+	got := []string{}
+	ast.Inspect(tree(), func(n ast.AST) bool {
+		if n == nil {
+			return false
+		}
+		got = append(got, describe(n))
+		// parameters are not walked into.
+		_, params := n.(*ast.Params)
+		return !params
+	})
+
+	assert.DeepEqual(t, []string{
+		"*ast.Definitions@0-25",
+		"*ast.Func@0-25",
+		"*ast.FuncName@5-6",
+		"*ast.Params@7-14",
+		"*ast.Add@18-23",
+		"*ast.Variable@18-19",
+		"*ast.Integer@22-23",
+	}, got)
+}
+
+// depthVisitor records each node with the depth where it is visited.
+type depthVisitor struct {
+	depth int
+	got   *[]string
+}
+
+func (v depthVisitor) Visit(n ast.AST) ast.Visitor {
+	if n == nil {
+		*v.got = append(*v.got, fmt.Sprintf("%d end", v.depth))
+		return nil
+	}
+	*v.got = append(*v.got, fmt.Sprintf("%d %T", v.depth, n))
+	return depthVisitor{depth: v.depth + 1, got: v.got}
+}
+
+func TestWalk(t *testing.T) {
+	got := []string{}
+	ast.Walk(depthVisitor{got: &got}, tree().(*ast.Definitions).Defs[0].(*ast.Func).Params)
+
+	assert.DeepEqual(t, []string{
+		"0 *ast.Params",
+		"1 *ast.Param",
+		"2 *ast.TypeName",
+		"3 end",
+		"2 end",
+		"1 end",
+	}, got)
+}
@@ -0,0 +1,132 @@
+// Package conformance holds programs which every backend should run in the same way,
+// so that tests of backends share them instead of copying them.
+// Runtime errors are left to each backend, since backends report them differently.
+package conformance
+
+// Case is a program with its input and what it should result.
+type Case struct {
+	Name  string
+	Code  string
+	Input string
+	// Want is what the program writes into stdout.
+	Want string
+	// WantCode is the exit code, which is the result of `main`.
+	WantCode int
+}
+
+// Cases lists programs for every backend.
+var Cases = []Case{
+	{
+		Name:     "arithmetic",
+		Code:     `func main(){ printf("%d", 3 * 2 + 10 / 3 - 1,); 7 }`,
+		Want:     "8",
+		WantCode: 7,
+	},
+	{
+		Name:     "arithmetic wraps around",
+		Code:     `func main(){ printf("%d,%d,", 2147483647 + 1, 65536 * 65536,); 7 / 2 }`,
+		Want:     "-2147483648,0,",
+		WantCode: 3,
+	},
+	{
+		Name: "arithmetic in the common type of operands",
+		Code: `func square(x: i64,) -> i64 { x * x }
+			func succ(c: i8,) -> i32 { c + 1 }
+			func main(){ println(square(100000,), ",", succ(127,), ",", square(3,) / 2 < 5,); 0 }`,
+		Want: "10000000000,128,1\n",
+	},
+	{
+		Name:     "assignment converts into the type of the variable",
+		Code:     `func narrow(c: i8,) -> i32 { let x = c; x = 300; x } func main(){ narrow(1,) }`,
+		WantCode: 44,
+	},
+	{
+		Name: "operands keep the order of evaluation",
+		Code: `var g = 1 func bump() -> i32 { g = g + 10 } func main(){ let x = 1; printf("%d,%d", g + bump(), x + if 1 { x = 5 } else { 0 },); 0 }`,
+		Want: "12,6",
+	},
+	{
+		Name: "if and while expressions",
+		Code: `func f(n: i32,) -> i32 { let i = 0; while i < n { i = i + 1; if i < 3 { i * 10 } else { i } } }
+			func main(){ printf("%d,%d", f(0,), f(2,) + f(5,),); 0 }`,
+		Want: "0,25",
+	},
+	{
+		Name: "if results the common type of its clauses",
+		Code: `func wide(x: i64,) -> i64 { x }
+			func pick(c: i32,) -> i64 { if c { wide(100000,) * 50000 } else { 0 - 1 } }
+			func main(){ println(pick(1,), ",", pick(0,),); 0 }`,
+		Want: "5000000000,-1\n",
+	},
+	{
+		Name: "while results the last iteration",
+		Code: `func f() -> i32 { let i = 0; while i < 4 { i = i + 1; i * 10 } } func main(){ printf("%d", f(),); 0 }`,
+		Want: "40",
+	},
+	{
+		Name: "recursion and function values",
+		Code: `func fib(n: i32,) -> i32 { if n < 2 { n } else { fib(n - 1,) + fib(n - 2,) } }
+			func apply(f: fn(i32) -> i32, x: i32,) -> i32 { f(x,) }
+			func main(){ let f = fib; printf("%d", apply(f, 10,),); 0 }`,
+		Want: "55",
+	},
+	{
+		Name: "globals and constants",
+		Code: `const N = 3 var total = 0 func add(x: i32,) -> i32 { total = total + x } func main(){ add(N,); add(N * 2,); printf("%d", total,); 0 }`,
+		Want: "9",
+	},
+	{
+		Name:     "strings and globals",
+		Code:     `const N = 2 var total = 40 func main(){ printf("\22%s\22\0A", "a\5Cb",); total = total + N }`,
+		Want:     "\"a\\b\"\n",
+		WantCode: 42,
+	},
+	{
+		Name: "printf formats",
+		Code: `func main(){ printf("[%5d|%-3d|%x|%c|%s|%%]\0A", 42, 7, 255, 65, "hi",); 0 }`,
+		Want: "[   42|7  |ff|A|hi|%]\n",
+	},
+	{
+		Name: "variadic arguments in variadic arguments",
+		Code: `extern func labs(x: i64) -> i64
+			func show(x: i32,) -> i32 { printf("<%d>", x,) }
+			func main(){ printf("%s%d%ld\0A", "a\5Cb", show(7,), labs(0 - 9,),); 0 }`,
+		Want: "<7>a\\b39\n",
+	},
+	{
+		Name: "print and println by the types of arguments",
+		Code: `extern func labs(x: i64) -> i64
+			func even(x: i32,) -> bool { x / 2 + x / 2 == x }
+			func main(){ print("n=", 42, " ", even(4,),); println(even(3,), " ", labs(0 - 9,), " \5Cn",); println(); 0 }`,
+		Want: "n=42 truefalse 9 \\n\n\n",
+	},
+	{
+		Name:  "read from stdin",
+		Code:  `func main(){ let x = read(); let y = read(); printf("%d", x * y,); 0 }`,
+		Input: "6\n7\n",
+		Want:  "42",
+	},
+	{
+		Name: "read results 0 at the end of input",
+		Code: `func main(){ printf("%d", read(),); 0 }`,
+		Want: "0",
+	},
+	{
+		Name:     "condition with calls",
+		Code:     `func main(){ let n = 0; while read() < 3 { n = n + 1 }; n }`,
+		Input:    "1\n2\n3\n",
+		WantCode: 2,
+	},
+	{
+		Name:  "read integers and chars until the end of input",
+		Code:  `func main(){ let sum = 0; while eof() == 0 { sum = sum + read_int() }; println(sum, " ", read_char(), " ", read_ok(),); 0 }`,
+		Input: "1 2\n3\n",
+		Want:  "6 -1 0\n",
+	},
+	{
+		Name:  "read_int fails on a minus without digits",
+		Code:  `func main(){ let x = read_int(); println(x, " ", read_ok(), " ", read_char(),); 0 }`,
+		Input: "- 3\n",
+		Want:  "0 0 32\n",
+	},
+}
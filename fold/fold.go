@@ -0,0 +1,321 @@
+// Package fold simplifies an AST before code generation.
+// It folds arithmetic and comparisons on literals,
+// removes identities like `x + 0` or `x * 1`
+// and chooses a branch of `if` whose condition is a literal.
+package fold
+
+import (
+	"github.com/yuniruyuni/lang/ast"
+)
+
+// Fold simplifies n and every node under n, which has been checked into info.
+// It returns the node which replaces n, and n is left as is:
+// a node is copied if any node under it changes, otherwise it is shared with n.
+func Fold(n ast.AST, info *ast.Info) ast.AST {
+	f := &folder{info: info}
+	return f.fold(n)
+}
+
+// folder folds nodes with their types resolved by checking.
+type folder struct {
+	info *ast.Info
+}
+
+func (f *folder) fold(n ast.AST) ast.AST {
+	switch n := n.(type) {
+	case *ast.Program:
+		if ms, ok := f.foldAll(n.Modules); ok {
+			c := *n
+			c.Modules = ms
+			return &c
+		}
+	case *ast.Module:
+		if d := f.fold(n.Defs); d != n.Defs {
+			c := *n
+			c.Defs = d
+			return &c
+		}
+	case *ast.Definitions:
+		if ds, ok := f.foldAll(n.Defs); ok {
+			c := *n
+			c.Defs = ds
+			return &c
+		}
+	case *ast.Pub:
+		if d := f.fold(n.Def); d != n.Def {
+			c := *n
+			c.Def = d
+			return &c
+		}
+	case *ast.Func:
+		if e := f.fold(n.Execute); e != n.Execute {
+			c := *n
+			c.Execute = e
+			return &c
+		}
+	case *ast.Const:
+		if r := f.fold(n.RHS); r != n.RHS {
+			c := *n
+			c.RHS = r
+			return &c
+		}
+	case *ast.Global:
+		if r := f.fold(n.RHS); r != n.RHS {
+			c := *n
+			c.RHS = r
+			return &c
+		}
+	case *ast.Let:
+		if r := f.fold(n.RHS); r != n.RHS {
+			c := *n
+			c.RHS = r
+			return &c
+		}
+	case *ast.Assign:
+		if r := f.fold(n.RHS); r != n.RHS {
+			c := *n
+			c.RHS = r
+			return &c
+		}
+	case *ast.Call:
+		if a := f.fold(n.Args); a != n.Args {
+			c := *n
+			c.Args = a
+			return &c
+		}
+	case *ast.Args:
+		if vs, ok := f.foldAll(n.Values); ok {
+			c := *n
+			c.Values = vs
+			return &c
+		}
+	case *ast.Sequence:
+		return f.foldSequence(n)
+	case *ast.If:
+		return f.foldIf(n)
+	case *ast.While:
+		cond, proc := f.fold(n.Cond), f.fold(n.Proc)
+		if cond != n.Cond || proc != n.Proc {
+			c := *n
+			c.Cond, c.Proc = cond, proc
+			return &c
+		}
+	case *ast.Add:
+		return f.foldAdd(n)
+	case *ast.Sub:
+		return f.foldSub(n)
+	case *ast.Mul:
+		return f.foldMul(n)
+	case *ast.Div:
+		return f.foldDiv(n)
+	case *ast.Less:
+		return f.foldLess(n)
+	case *ast.Equal:
+		return f.foldEqual(n)
+	}
+	return n
+}
+
+// foldAll folds every node in ns into a new slice.
+// It reports false with ns itself if none of them changes.
+func (f *folder) foldAll(ns []ast.AST) ([]ast.AST, bool) {
+	folded := make([]ast.AST, len(ns))
+	changed := false
+	for i, n := range ns {
+		folded[i] = f.fold(n)
+		changed = changed || folded[i] != n
+	}
+	if !changed {
+		return ns, false
+	}
+	return folded, true
+}
+
+// foldSequence drops the left hand side of `x; y` if evaluating x has no effect.
+func (f *folder) foldSequence(n *ast.Sequence) ast.AST {
+	x, y := f.fold(n.LHS), f.fold(n.RHS)
+	if isPure(x) {
+		return y
+	}
+	if x == n.LHS && y == n.RHS {
+		return n
+	}
+	c := *n
+	c.LHS, c.RHS = x, y
+	return &c
+}
+
+// foldIf chooses a clause if the condition is a literal.
+func (f *folder) foldIf(n *ast.If) ast.AST {
+	cond, then, els := f.fold(n.Cond), f.fold(n.Then), f.fold(n.Else)
+
+	if c, ok := literal(cond); ok {
+		if c != 0 {
+			return then
+		}
+		return els
+	}
+	if cond == n.Cond && then == n.Then && els == n.Else {
+		return n
+	}
+	c := *n
+	c.Cond, c.Then, c.Else = cond, then, els
+	return &c
+}
+
+func (f *folder) foldAdd(n *ast.Add) ast.AST {
+	lhs, rhs := f.fold(n.LHS), f.fold(n.RHS)
+	x, xok := literal(lhs)
+	y, yok := literal(rhs)
+	switch {
+	case xok && yok:
+		return integer(n, x+y)
+	case xok && x == 0 && f.promoted(rhs):
+		return rhs
+	case yok && y == 0 && f.promoted(lhs):
+		return lhs
+	}
+	if lhs == n.LHS && rhs == n.RHS {
+		return n
+	}
+	c := *n
+	c.LHS, c.RHS = lhs, rhs
+	return &c
+}
+
+func (f *folder) foldSub(n *ast.Sub) ast.AST {
+	lhs, rhs := f.fold(n.LHS), f.fold(n.RHS)
+	x, xok := literal(lhs)
+	y, yok := literal(rhs)
+	switch {
+	case xok && yok:
+		return integer(n, x-y)
+	case yok && y == 0 && f.promoted(lhs):
+		return lhs
+	}
+	if lhs == n.LHS && rhs == n.RHS {
+		return n
+	}
+	c := *n
+	c.LHS, c.RHS = lhs, rhs
+	return &c
+}
+
+func (f *folder) foldMul(n *ast.Mul) ast.AST {
+	lhs, rhs := f.fold(n.LHS), f.fold(n.RHS)
+	x, xok := literal(lhs)
+	y, yok := literal(rhs)
+	switch {
+	case xok && yok:
+		return integer(n, x*y)
+	case xok && x == 1 && f.promoted(rhs):
+		return rhs
+	case yok && y == 1 && f.promoted(lhs):
+		return lhs
+	case xok && x == 0 && isPure(rhs), yok && y == 0 && isPure(lhs):
+		return integer(n, 0)
+	}
+	if lhs == n.LHS && rhs == n.RHS {
+		return n
+	}
+	c := *n
+	c.LHS, c.RHS = lhs, rhs
+	return &c
+}
+
+func (f *folder) foldDiv(n *ast.Div) ast.AST {
+	lhs, rhs := f.fold(n.LHS), f.fold(n.RHS)
+	x, xok := literal(lhs)
+	y, yok := literal(rhs)
+	switch {
+	// division by zero is left as is to behave same as without folding.
+	case xok && yok && y != 0:
+		return integer(n, x/y)
+	case yok && y == 1 && f.promoted(lhs):
+		return lhs
+	}
+	if lhs == n.LHS && rhs == n.RHS {
+		return n
+	}
+	c := *n
+	c.LHS, c.RHS = lhs, rhs
+	return &c
+}
+
+func (f *folder) foldLess(n *ast.Less) ast.AST {
+	lhs, rhs := f.fold(n.LHS), f.fold(n.RHS)
+	if v, ok := compare(lhs, rhs, func(x, y int32) bool { return x < y }); ok {
+		return integer(n, v)
+	}
+	if lhs == n.LHS && rhs == n.RHS {
+		return n
+	}
+	c := *n
+	c.LHS, c.RHS = lhs, rhs
+	return &c
+}
+
+func (f *folder) foldEqual(n *ast.Equal) ast.AST {
+	lhs, rhs := f.fold(n.LHS), f.fold(n.RHS)
+	if v, ok := compare(lhs, rhs, func(x, y int32) bool { return x == y }); ok {
+		return integer(n, v)
+	}
+	if lhs == n.LHS && rhs == n.RHS {
+		return n
+	}
+	c := *n
+	c.LHS, c.RHS = lhs, rhs
+	return &c
+}
+
+// compare returns 1 if cmp holds on the literals lhs and rhs, otherwise 0.
+// It reports false unless both of them are literals.
+func compare(lhs, rhs ast.AST, cmp func(x, y int32) bool) (int32, bool) {
+	x, xok := literal(lhs)
+	y, yok := literal(rhs)
+	if !xok || !yok {
+		return 0, false
+	}
+	if cmp(x, y) {
+		return 1, true
+	}
+	return 0, true
+}
+
+// literal returns the value of n if n is an integer literal.
+func literal(n ast.AST) (int32, bool) {
+	i, ok := n.(*ast.Integer)
+	if !ok {
+		return 0, false
+	}
+	return int32(i.Value), true
+}
+
+// integer makes the literal v which replaces n, keeping the position of n.
+func integer(n ast.AST, v int32) ast.AST {
+	return &ast.Integer{Span: n.Pos(), Value: int(v)}
+}
+
+// promoted reports whether the operand n already has the type which arithmetic promotes it into,
+// so that an identity like `x + 0` can be replaced by x without changing the type.
+// Integers narrower than i32 like bool are promoted, and nodes made by folding are unknown to info
+// except arithmetic and comparisons, which result i32 or wider.
+func (f *folder) promoted(n ast.AST) bool {
+	switch n.(type) {
+	case *ast.Integer, *ast.Add, *ast.Sub, *ast.Mul, *ast.Div, *ast.Less, *ast.Equal:
+		return true
+	}
+	t := f.info.TypeOf(n)
+	return t.IsInt() && t.Bits() >= 32
+}
+
+// isPure reports whether evaluating n has no effect and can be omitted.
+// A variable is not, because referring an undefined variable must still be reported.
+func isPure(n ast.AST) bool {
+	switch n.(type) {
+	case *ast.Integer, *ast.String:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,168 @@
+package fold_test
+
+import (
+	"testing"
+
+	"gotest.tools/assert"
+
+	"github.com/yuniruyuni/lang/ast"
+	"github.com/yuniruyuni/lang/fold"
+	"github.com/yuniruyuni/lang/ir"
+)
+
+func num(v int) *ast.Integer {
+	return &ast.Integer{Value: v}
+}
+
+func TestFold(t *testing.T) {
+	// x is an i32 variable, and b is a bool variable which arithmetic promotes to i32.
+	x, b := &ast.Variable{VarName: "x"}, &ast.Variable{VarName: "b"}
+	info := ast.NewInfo()
+	info.Types[x] = ir.I32
+	info.Types[b] = ir.I1
+	call := func() ast.AST {
+		return &ast.Call{FuncName: &ast.FuncName{FuncName: "f"}, Args: &ast.Args{Values: []ast.AST{}}}
+	}
+
+	tests := []struct {
+		name string
+		in   ast.AST
+		want ast.AST
+	}{
+		{
+			name: "1+2*3 folds into 7",
+			in:   &ast.Add{LHS: num(1), RHS: &ast.Mul{LHS: num(2), RHS: num(3)}},
+			want: num(7),
+		},
+		{
+			name: "arithmetic wraps around as i32",
+			in:   &ast.Mul{LHS: num(65536), RHS: num(65536)},
+			want: num(0),
+		},
+		{
+			name: "10-4/2 folds into 8",
+			in:   &ast.Sub{LHS: num(10), RHS: &ast.Div{LHS: num(4), RHS: num(2)}},
+			want: num(8),
+		},
+		{
+			name: "division by zero is left as is",
+			in:   &ast.Div{LHS: num(1), RHS: num(0)},
+			want: &ast.Div{LHS: num(1), RHS: num(0)},
+		},
+		{
+			name: "comparisons fold into 0 or 1",
+			in:   &ast.Add{LHS: &ast.Less{LHS: num(1), RHS: num(2)}, RHS: &ast.Equal{LHS: num(1), RHS: num(2)}},
+			want: num(1),
+		},
+		{
+			name: "x+0 and 0+x fold into x",
+			in:   &ast.Add{LHS: num(0), RHS: &ast.Add{LHS: x, RHS: num(0)}},
+			want: x,
+		},
+		{
+			name: "x-0 folds into x",
+			in:   &ast.Sub{LHS: x, RHS: num(0)},
+			want: x,
+		},
+		{
+			name: "0-x is left as is",
+			in:   &ast.Sub{LHS: num(0), RHS: x},
+			want: &ast.Sub{LHS: num(0), RHS: x},
+		},
+		{
+			name: "x*1, 1*x and x/1 fold into x",
+			in:   &ast.Mul{LHS: num(1), RHS: &ast.Div{LHS: &ast.Mul{LHS: x, RHS: num(1)}, RHS: num(1)}},
+			want: x,
+		},
+		{
+			name: "identities on a narrower operand are left for its promotion",
+			in:   &ast.Add{LHS: &ast.Mul{LHS: b, RHS: num(1)}, RHS: &ast.Div{LHS: &ast.Add{LHS: num(0), RHS: b}, RHS: num(1)}},
+			want: &ast.Add{LHS: &ast.Mul{LHS: b, RHS: num(1)}, RHS: &ast.Add{LHS: num(0), RHS: b}},
+		},
+		{
+			name: "x*0 keeps x because it may be undefined",
+			in:   &ast.Mul{LHS: x, RHS: num(0)},
+			want: &ast.Mul{LHS: x, RHS: num(0)},
+		},
+		{
+			name: "if with a true condition folds into then clause",
+			in:   &ast.If{Cond: &ast.Less{LHS: num(1), RHS: num(2)}, Then: num(10), Else: call()},
+			want: num(10),
+		},
+		{
+			name: "if with a false condition folds into else clause",
+			in:   &ast.If{Cond: num(0), Then: call(), Else: &ast.Add{LHS: num(2), RHS: num(3)}},
+			want: num(5),
+		},
+		{
+			name: "if with a variable condition folds only clauses",
+			in:   &ast.If{Cond: x, Then: &ast.Add{LHS: num(2), RHS: num(3)}, Else: call()},
+			want: &ast.If{Cond: x, Then: num(5), Else: call()},
+		},
+		{
+			name: "literal before ; is removed",
+			in:   &ast.Sequence{LHS: num(1), RHS: call()},
+			want: call(),
+		},
+		{
+			name: "call before ; is kept",
+			in:   &ast.Sequence{LHS: call(), RHS: num(1)},
+			want: &ast.Sequence{LHS: call(), RHS: num(1)},
+		},
+		{
+			name: "definitions are folded",
+			in: &ast.Definitions{Defs: []ast.AST{
+				&ast.Const{LHS: &ast.Variable{VarName: "N"}, RHS: &ast.Mul{LHS: num(6), RHS: num(7)}},
+				&ast.Func{
+					FuncName: &ast.FuncName{FuncName: "main"},
+					Params:   &ast.Params{Vars: []ast.AST{}},
+					Execute: &ast.While{
+						Cond: x,
+						Proc: &ast.Let{LHS: x, RHS: &ast.Sub{LHS: num(3), RHS: num(1)}},
+					},
+				},
+			}},
+			want: &ast.Definitions{Defs: []ast.AST{
+				&ast.Const{LHS: &ast.Variable{VarName: "N"}, RHS: num(42)},
+				&ast.Func{
+					FuncName: &ast.FuncName{FuncName: "main"},
+					Params:   &ast.Params{Vars: []ast.AST{}},
+					Execute: &ast.While{
+						Cond: x,
+						Proc: &ast.Let{LHS: x, RHS: num(2)},
+					},
+				},
+			}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.DeepEqual(t, tt.want, fold.Fold(tt.in, info))
+		})
+	}
+}
+
+func TestFold_LeavesInput(t *testing.T) {
+	in := func() ast.AST {
+		return &ast.Definitions{Defs: []ast.AST{
+			&ast.Func{
+				FuncName: &ast.FuncName{FuncName: "main"},
+				Params:   &ast.Params{Vars: []ast.AST{}},
+				Execute: &ast.Sequence{
+					LHS: &ast.Variable{VarName: "x"},
+					RHS: &ast.If{Cond: num(1), Then: &ast.Add{LHS: num(2), RHS: num(3)}, Else: num(4)},
+				},
+			},
+			&ast.Const{LHS: &ast.Variable{VarName: "N"}, RHS: num(1)},
+		}}
+	}
+
+	n := in()
+	folded := fold.Fold(n, ast.NewInfo()).(*ast.Definitions)
+
+	assert.DeepEqual(t, in(), n)
+	// nodes which don't change are shared.
+	assert.Assert(t, folded != n)
+	assert.Assert(t, folded.Defs[1] == n.(*ast.Definitions).Defs[1])
+	assert.Assert(t, fold.Fold(folded, ast.NewInfo()) == ast.AST(folded))
+}
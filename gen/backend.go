@@ -0,0 +1,60 @@
+package gen
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/yuniruyuni/lang/ast"
+)
+
+// Backend selects the language which a program is compiled into.
+type Backend string
+
+const (
+	// LLVM generates LLVM IR by LLFile.
+	LLVM Backend = "llvm"
+	// Asm generates x86-64 assembly from the optimized IR by package amd64.
+	Asm Backend = "asm"
+	// C generates C99 source by CFile.
+	C Backend = "c"
+	// WAT generates a WebAssembly text module by WATFile.
+	WAT Backend = "wat"
+	// Bytecode generates bytecode for the VM in package vm.
+	Bytecode Backend = "bytecode"
+	// MIR prints the optimized IR in SSA form with its control-flow graph by ir.Module.MIR, for inspection.
+	MIR Backend = "mir"
+)
+
+// Backends lists every backend in the order shown to users.
+var Backends = []Backend{LLVM, Asm, C, WAT, Bytecode, MIR}
+
+// ParseBackend finds the backend named s, like `c` for `-emit=c`.
+func ParseBackend(s string) (Backend, error) {
+	for _, b := range Backends {
+		if string(b) == s {
+			return b, nil
+		}
+	}
+	return "", fmt.Errorf("unknown backend %s, want one of %s", s, BackendNames())
+}
+
+// BackendNames joins names of every backend for messages.
+func BackendNames() string {
+	ns := make([]string, 0, len(Backends))
+	for _, b := range Backends {
+		ns = append(ns, string(b))
+	}
+	return strings.Join(ns, ", ")
+}
+
+// defs lists module level definitions of m, unwrapping `pub`.
+func defs(m *ast.Module) []ast.AST {
+	ds := []ast.AST{}
+	for _, d := range m.Defs.(*ast.Definitions).Defs {
+		if p, ok := d.(*ast.Pub); ok {
+			d = p.Def
+		}
+		ds = append(ds, d)
+	}
+	return ds
+}
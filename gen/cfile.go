@@ -0,0 +1,893 @@
+package gen
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/yuniruyuni/lang/ast"
+	"github.com/yuniruyuni/lang/ir"
+	"github.com/yuniruyuni/lang/libc"
+)
+
+// cPrelude starts every generated C source.
+// Arithmetic of yuni wraps around on overflow, which is undefined for signed integers in C,
+// so it is computed on unsigned integers.
+// Runtime errors like division by zero are reported by yuni_trap with the position given by the caller.
+// It follows the headers which headers includes.
+const cPrelude = `
+static inline int32_t yuni_add(int32_t x, int32_t y) { return (int32_t)((uint32_t)x + (uint32_t)y); }
+static inline int32_t yuni_sub(int32_t x, int32_t y) { return (int32_t)((uint32_t)x - (uint32_t)y); }
+static inline int32_t yuni_mul(int32_t x, int32_t y) { return (int32_t)((uint32_t)x * (uint32_t)y); }
+static inline int64_t yuni_add64(int64_t x, int64_t y) { return (int64_t)((uint64_t)x + (uint64_t)y); }
+static inline int64_t yuni_sub64(int64_t x, int64_t y) { return (int64_t)((uint64_t)x - (uint64_t)y); }
+static inline int64_t yuni_mul64(int64_t x, int64_t y) { return (int64_t)((uint64_t)x * (uint64_t)y); }
+
+static inline void yuni_trap(const char *where, const char *reason, const char *detail) {
+	fflush(stdout);
+	fprintf(stderr, "%s: runtime error: %s%s\n", where, reason, detail);
+	abort();
+}
+
+static inline int32_t yuni_div(int32_t x, int32_t y, const char *where) {
+	if (y == 0) {
+		yuni_trap(where, "division by zero", "");
+	}
+	return y == -1 ? yuni_sub(0, x) : x / y;
+}
+
+static inline int64_t yuni_div64(int64_t x, int64_t y, const char *where) {
+	if (y == 0) {
+		yuni_trap(where, "division by zero", "");
+	}
+	return y == -1 ? yuni_sub64(0, x) : x / y;
+}
+`
+
+// cInput is the C version of the state which the runtime reads input with.
+const cInput = `static int yuni_peek = -2;
+static int32_t yuni_readok = 0;
+
+static inline int yuni_getc(void) {
+	int c = yuni_peek;
+	if (c == -2) {
+		return getchar();
+	}
+	yuni_peek = -2;
+	return c;
+}
+`
+
+// cRuntime is the C version of runtimeFuncs, which define generates for LLVM.
+// A function comes after the functions it calls.
+var cRuntime = []struct {
+	name ast.Name
+	// uses lists runtime functions which this function calls.
+	uses []ast.Name
+	src  string
+}{
+	{name: "read_int", src: `static int32_t yuni_read_int(void) {
+	int c, neg = 0;
+	uint32_t x = 0;
+	do {
+		c = yuni_getc();
+	} while (c == ' ' || c == '\t' || c == '\r' || c == '\n');
+	if (c == '-') {
+		neg = 1;
+		c = yuni_getc();
+	}
+	if (c < '0' || c > '9') {
+		yuni_peek = c;
+		yuni_readok = 0;
+		return 0;
+	}
+	for (; c >= '0' && c <= '9'; c = yuni_getc()) {
+		x = x * 10 + (uint32_t)(c - '0');
+	}
+	while (c == ' ' || c == '\t' || c == '\r') {
+		c = yuni_getc();
+	}
+	if (c != '\n') {
+		yuni_peek = c;
+	}
+	yuni_readok = 1;
+	return (int32_t)(neg ? 0u - x : x);
+}
+`},
+	{name: "read", uses: []ast.Name{"read_int"}, src: `static int32_t yuni_read(void) {
+	return yuni_read_int();
+}
+`},
+	{name: "read_char", src: `static int32_t yuni_read_char(void) {
+	int c = yuni_getc();
+	yuni_readok = c != -1;
+	return c;
+}
+`},
+	{name: "read_line", src: `static char *yuni_read_line(void) {
+	int32_t n = 0, cap = 16;
+	char *buf = malloc(cap);
+	int c = yuni_getc();
+	yuni_readok = c != -1;
+	for (; c != -1 && c != '\n'; c = yuni_getc()) {
+		if (n + 1 == cap) {
+			cap *= 2;
+			buf = realloc(buf, cap);
+		}
+		buf[n++] = (char)c;
+	}
+	buf[n] = 0;
+	return buf;
+}
+`},
+	{name: "read_ok", src: `static int32_t yuni_read_ok(void) {
+	return yuni_readok;
+}
+`},
+	{name: "eof", src: `static int32_t yuni_eof(void) {
+	yuni_peek = yuni_getc();
+	return yuni_peek == -1;
+}
+`},
+}
+
+// cBuiltinMismatch disables the warning of compilers for a function of libc declared with another type.
+const cBuiltinMismatch = `#if defined(__clang__)
+#pragma clang diagnostic ignored "-Wincompatible-library-redeclaration"
+#elif defined(__GNUC__)
+#pragma GCC diagnostic ignored "-Wbuiltin-declaration-mismatch"
+#endif
+`
+
+// cLibc lists what the generated code uses from the headers of libc.
+// External functions of these names keep the declarations of the headers
+// instead of being declared again with types of yuni like `*u8` for `const char *`.
+var cLibc = map[ast.Name]bool{
+	"printf":  true,
+	"fprintf": true,
+	"fflush":  true,
+	"stdout":  true,
+	"stderr":  true,
+	"getchar": true,
+	"malloc":  true,
+	"realloc": true,
+	"abort":   true,
+}
+
+// cKeywords are reserved by C99, so names of yuni which collide with them get a suffix.
+var cKeywords = map[string]bool{
+	"auto": true, "break": true, "case": true, "char": true, "const": true, "continue": true,
+	"default": true, "do": true, "double": true, "else": true, "enum": true, "extern": true,
+	"float": true, "for": true, "goto": true, "if": true, "inline": true, "int": true,
+	"long": true, "register": true, "restrict": true, "return": true, "short": true,
+	"signed": true, "sizeof": true, "static": true, "struct": true, "switch": true,
+	"typedef": true, "union": true, "unsigned": true, "void": true, "volatile": true,
+	"while": true, "_Bool": true, "_Complex": true, "_Imaginary": true,
+}
+
+// CFile generates C99 source for a program, which builds with any C compiler
+// and whose functions can be called from C.
+type CFile struct {
+	AST ast.AST
+}
+
+// Generate builds the whole C source for the program.
+// The program is checked by LLFile, whose types of nodes decide C types,
+// so an invalid program panics with the same error as LLFile.
+func (c *CFile) Generate() string {
+	ll := LLFile{AST: c.AST}
+	g := newCGen(ll.Generate(), ll.Info)
+
+	prog, ok := c.AST.(*ast.Program)
+	if !ok {
+		panic(fmt.Errorf("%T cannot be generated into C", c.AST))
+	}
+	for _, m := range prog.Modules {
+		g.declare(m.(*ast.Module))
+	}
+	for _, m := range prog.Modules {
+		g.genFuncs(m.(*ast.Module))
+	}
+	return g.String()
+}
+
+// cgen walks the AST and writes C source.
+//
+// Expressions are written as C expressions without side effects.
+// Calls, assignments and control flow are emitted as statements before the expression using them,
+// storing their values into temporaries, so that they run in the same order as LLVM IR does.
+type cgen struct {
+	module *ir.Module
+	// info has types of expressions which LLFile resolves.
+	info *ast.Info
+
+	// sections of the output.
+	types, decls, protos, funcs strings.Builder
+	// typedefs names function pointer types.
+	typedefs map[ir.Type]string
+	// runtime holds functions of the runtime which the program uses, which are defined only if needed.
+	runtime map[ast.Name]bool
+
+	// module level names of yuni for functions, external functions and globals.
+	funcNames   map[ast.Name]bool
+	externNames map[ast.Name]bool
+	globalNames map[ast.Name]bool
+	// cGlobals holds C names of every module level definition, and cGlobalVars holds ones of globals.
+	cGlobals    map[string]bool
+	cGlobalVars map[string]bool
+
+	// mod is the module generating now.
+	mod *ast.Module
+
+	// locals maps variables of the function generating now to their C names,
+	// and cLocals holds C names which the function uses.
+	locals  map[ast.Name]string
+	cLocals map[string]bool
+	// vars declares local variables at the top of the function.
+	vars []string
+	body []string
+	// indent is the depth of nested blocks in the body.
+	indent int
+	// writes lists C variables which emitted statements assign, and "" for a call,
+	// which can assign any global.
+	writes []string
+}
+
+func newCGen(m *ir.Module, info *ast.Info) *cgen {
+	return &cgen{
+		module:      m,
+		info:        info,
+		typedefs:    map[ir.Type]string{},
+		runtime:     map[ast.Name]bool{},
+		funcNames:   map[ast.Name]bool{},
+		externNames: map[ast.Name]bool{},
+		globalNames: map[ast.Name]bool{},
+		cGlobals:    map[string]bool{},
+		cGlobalVars: map[string]bool{},
+	}
+}
+
+// String joins the sections of the output.
+func (g *cgen) String() string {
+	b := strings.Builder{}
+	b.WriteString(g.headers())
+	b.WriteString(cPrelude)
+	for _, s := range []string{g.types.String(), g.decls.String(), g.protos.String()} {
+		if s != "" {
+			b.WriteString("\n" + s)
+		}
+	}
+	if len(g.runtime) > 0 {
+		b.WriteString("\n" + cInput)
+	}
+	for _, f := range cRuntime {
+		if g.runtime[f.name] {
+			b.WriteString("\n" + f.src)
+		}
+	}
+	b.WriteString(g.funcs.String())
+	return b.String()
+}
+
+// headers includes the headers of libc which the runtime needs.
+// External functions of the program other than cLibc may take any names from libc with their own types like
+// `extern func exit(c: i32) -> i32`, so the headers declare them under other names renamed by macros,
+// and compilers are told not to warn that the types differ from their builtin functions.
+func (g *cgen) headers() string {
+	hidden := []string{}
+	for n := range g.externNames {
+		if !cLibc[n] {
+			hidden = append(hidden, string(n))
+		}
+	}
+	sort.Strings(hidden)
+
+	b := strings.Builder{}
+	b.WriteString("#include <stdint.h>\n")
+	for _, n := range hidden {
+		fmt.Fprintf(&b, "#define %s yuni_libc_%s\n", n, n)
+	}
+	b.WriteString("#include <stdio.h>\n#include <stdlib.h>\n")
+	for _, n := range hidden {
+		fmt.Fprintf(&b, "#undef %s\n", n)
+	}
+	if len(hidden) > 0 {
+		b.WriteString(cBuiltinMismatch)
+	}
+	return b.String()
+}
+
+// declare writes declarations of functions, external functions and globals in m.
+func (g *cgen) declare(m *ast.Module) {
+	g.mod = m
+	for _, d := range defs(m) {
+		switch d := d.(type) {
+		case *ast.Func:
+			n := m.Qualify(d.Name())
+			g.funcNames[n] = true
+			g.cGlobals[cName(n)] = true
+			fmt.Fprintf(&g.protos, "%s;\n", g.signature(cName(n), d))
+		case *ast.Extern:
+			// the same function can be declared in multiple modules.
+			if g.externNames[d.Name()] {
+				continue
+			}
+			g.externNames[d.Name()] = true
+			g.cGlobals[string(d.Name())] = true
+			if cLibc[d.Name()] {
+				continue
+			}
+			ps := []string{}
+			for _, t := range d.FuncType().Params() {
+				if t == "..." {
+					ps = append(ps, "...")
+					continue
+				}
+				ps = append(ps, g.cType(t))
+			}
+			if len(ps) == 0 {
+				ps = append(ps, "void")
+			}
+			fmt.Fprintf(&g.decls, "extern %s(%s);\n", g.cDecl(d.Type(), string(d.Name())), strings.Join(ps, ", "))
+		case *ast.Const, *ast.Global:
+			n := m.Qualify(d.Name())
+			g.globalNames[n] = true
+			g.cGlobals[cName(n)] = true
+			g.cGlobalVars[cName(n)] = true
+
+			qual := ""
+			if _, ok := d.(*ast.Const); ok {
+				qual = "const "
+			}
+			init := g.module.Global(string(n)).Init
+			fmt.Fprintf(&g.decls, "%s%s = %s;\n", qual, g.cDecl(d.(ast.Typed).Type(), cName(n)), init)
+		}
+	}
+}
+
+// signature writes the declarator of the function f named name.
+func (g *cgen) signature(name string, f *ast.Func) string {
+	params := f.Params.(*ast.Params).Vars
+	// C requires `main` to return int.
+	if name == "main" && len(params) == 0 {
+		return "int main(void)"
+	}
+
+	ps := []string{}
+	for _, p := range params {
+		ps = append(ps, g.cDecl(p.(*ast.Param).Type(), safeName(string(p.Name()))))
+	}
+	if len(ps) == 0 {
+		ps = append(ps, "void")
+	}
+	return fmt.Sprintf("%s(%s)", g.cDecl(f.Type(), name), strings.Join(ps, ", "))
+}
+
+// genFuncs writes functions defined in m.
+func (g *cgen) genFuncs(m *ast.Module) {
+	g.mod = m
+	for _, d := range defs(m) {
+		f, ok := d.(*ast.Func)
+		if !ok {
+			continue
+		}
+
+		g.locals = map[ast.Name]string{}
+		g.cLocals = map[string]bool{}
+		g.vars, g.body, g.indent, g.writes = nil, nil, 0, nil
+		for _, p := range f.Params.(*ast.Params).Vars {
+			c := safeName(string(p.Name()))
+			g.locals[p.Name()] = c
+			g.cLocals[c] = true
+		}
+
+		g.emit("return %s;", g.value(f.Execute))
+
+		fmt.Fprintf(&g.funcs, "\n%s {\n", g.signature(cName(m.Qualify(f.Name())), f))
+		for _, v := range g.vars {
+			fmt.Fprintf(&g.funcs, "\t%s;\n", v)
+		}
+		if len(g.vars) > 0 {
+			g.funcs.WriteString("\n")
+		}
+		for _, l := range g.body {
+			fmt.Fprintf(&g.funcs, "%s\n", l)
+		}
+		g.funcs.WriteString("}\n")
+	}
+}
+
+// emit appends a statement to the body.
+func (g *cgen) emit(format string, args ...interface{}) {
+	g.body = append(g.body, strings.Repeat("\t", g.indent+1)+fmt.Sprintf(format, args...))
+}
+
+// block emits statements by f in a nested block.
+func (g *cgen) block(f func()) {
+	g.indent++
+	f()
+	g.indent--
+}
+
+// local declares a new local variable typed t, naming it after base.
+func (g *cgen) local(base string, t ir.Type) string {
+	n := safeName(base)
+	for i := 1; g.cLocals[n] || g.cGlobals[n]; i++ {
+		n = fmt.Sprintf("%s_%d", safeName(base), i)
+	}
+	return g.declareLocal(n, t)
+}
+
+// temp declares a new temporary typed t.
+func (g *cgen) temp(t ir.Type) string {
+	n := "t0"
+	for i := 1; g.cLocals[n] || g.cGlobals[n]; i++ {
+		n = fmt.Sprintf("t%d", i)
+	}
+	return g.declareLocal(n, t)
+}
+
+func (g *cgen) declareLocal(n string, t ir.Type) string {
+	g.cLocals[n] = true
+	g.vars = append(g.vars, g.cDecl(t, n))
+	return n
+}
+
+// expr emits statements which n needs and returns a C expression for its value.
+// The expression has no side effects except runtime errors like division by zero.
+func (g *cgen) expr(n ast.AST) string {
+	switch n := n.(type) {
+	case *ast.Integer:
+		return cInt(n.Value)
+	case *ast.String:
+		return cString(n.Word)
+	case *ast.Variable:
+		return g.ref(n)
+	case *ast.Let:
+		v := g.value(n.RHS)
+		c := g.local(string(n.Name()), g.info.TypeOf(n))
+		g.locals[n.Name()] = c
+		g.assign(c, v)
+		return c
+	case *ast.Assign:
+		v := g.value(n.RHS)
+		// the value is converted into the type of the variable explicitly, so that C compilers don't warn narrowing.
+		if t := g.info.TypeOf(n); t != g.info.TypeOf(n.RHS) {
+			v = fmt.Sprintf("(%s)(%s)", g.cType(t), v)
+		}
+		c := g.ref(n)
+		g.assign(c, v)
+		return c
+	case *ast.Sequence:
+		g.stmt(n.LHS)
+		return g.expr(n.RHS)
+	case *ast.If:
+		t := g.temp(g.info.TypeOf(n))
+		g.emit("if (%s) {", g.expr(n.Cond))
+		g.block(func() { g.assign(t, g.value(n.Then)) })
+		g.emit("} else {")
+		g.block(func() { g.assign(t, g.value(n.Else)) })
+		g.emit("}")
+		return t
+	case *ast.While:
+		// each iteration overwrites t, which is initialized for a loop not iterating at all.
+		t := g.temp(g.info.TypeOf(n))
+		g.assign(t, "0")
+		g.loop(n, func() { g.assign(t, g.value(n.Proc)) })
+		return t
+	case *ast.Call:
+		t := g.temp(g.info.TypeOf(n))
+		g.assign(t, g.call(n))
+		return t
+	case *ast.Add:
+		return g.binary(n.LHS, n.RHS, "yuni_add"+g.width(n)+"(%s, %s)")
+	case *ast.Sub:
+		return g.binary(n.LHS, n.RHS, "yuni_sub"+g.width(n)+"(%s, %s)")
+	case *ast.Mul:
+		return g.binary(n.LHS, n.RHS, "yuni_mul"+g.width(n)+"(%s, %s)")
+	case *ast.Div:
+		return g.binary(n.LHS, n.RHS, "yuni_div"+g.width(n)+"(%s, %s, "+strings.ReplaceAll(g.where(n), "%", "%%")+")")
+	case *ast.Less:
+		return g.binary(n.LHS, n.RHS, "%s < %s")
+	case *ast.Equal:
+		return g.binary(n.LHS, n.RHS, "%s == %s")
+	default:
+		panic(fmt.Errorf("%T cannot be generated into C", n))
+	}
+}
+
+// value is expr, except that a call is returned as is instead of stored into a temporary.
+// The result must be used by the statement emitted next.
+func (g *cgen) value(n ast.AST) string {
+	if c, ok := n.(*ast.Call); ok {
+		return g.call(c)
+	}
+	return g.expr(n)
+}
+
+// stmt emits n discarding its value.
+func (g *cgen) stmt(n ast.AST) {
+	switch n := n.(type) {
+	case *ast.Call:
+		if c := g.call(n); g.info.Calls[n].Builtin == "" {
+			g.emit("%s;", c)
+		}
+	case *ast.Sequence:
+		g.stmt(n.LHS)
+		g.stmt(n.RHS)
+	case *ast.If:
+		g.emit("if (%s) {", g.expr(n.Cond))
+		g.block(func() { g.stmt(n.Then) })
+		els := len(g.body)
+		g.emit("} else {")
+		g.block(func() { g.stmt(n.Else) })
+		if len(g.body) == els+1 {
+			g.body = g.body[:els]
+		}
+		g.emit("}")
+	case *ast.While:
+		g.loop(n, func() { g.stmt(n.Proc) })
+	case *ast.Integer, *ast.String, *ast.Variable, *ast.Let, *ast.Assign:
+		g.expr(n)
+	default:
+		// the value is discarded, but computing it may stop the program like division by zero does.
+		g.emit("(void)(%s);", g.expr(n))
+	}
+}
+
+// assign emits storing the C expression v into the variable c.
+func (g *cgen) assign(c, v string) {
+	g.emit("%s = %s;", c, v)
+	g.writes = append(g.writes, c)
+}
+
+// loop emits the while loop n whose body is emitted by proc.
+// A condition which needs statements is checked inside the loop.
+func (g *cgen) loop(n *ast.While, proc func()) {
+	body := g.body
+	g.body = nil
+	g.indent++
+	cond := g.expr(n.Cond)
+	condBody := g.body
+	g.indent--
+	g.body = body
+
+	if len(condBody) == 0 {
+		g.emit("while (%s) {", cond)
+	} else {
+		g.emit("while (1) {")
+		g.body = append(g.body, condBody...)
+		g.block(func() { g.emit("if (!%s) break;", paren(n.Cond, cond)) })
+	}
+	g.block(proc)
+	g.emit("}")
+}
+
+// call emits arguments of n and returns the C expression calling it.
+// A builtin printing call is emitted as a statement, whose value is 0.
+func (g *cgen) call(n *ast.Call) string {
+	switch b := g.info.Calls[n].Builtin; b {
+	case "":
+	case ast.PanicBuiltin:
+		g.panic(n)
+		return "0"
+	case ast.AssertBuiltin:
+		g.assert(n)
+		return "0"
+	default:
+		g.print(n, b)
+		return "0"
+	}
+	args := g.sequence(n.Args.(*ast.Args).Values)
+	callee := g.ref(n.FuncName)
+	g.writes = append(g.writes, "")
+	return fmt.Sprintf("%s(%s)", callee, strings.Join(args, ", "))
+}
+
+// print emits the builtin `print` or `println` named b called by n
+// as printf with the format for the types of its arguments.
+func (g *cgen) print(n *ast.Call, b ast.Name) {
+	values := n.Args.(*ast.Args).Values
+	vs := g.sequence(values)
+	format := ""
+	args := []string{}
+	for i, v := range values {
+		switch t := g.info.TypeOf(v); {
+		case t == ir.I1:
+			format += "%s"
+			args = append(args, fmt.Sprintf(`%s ? "true" : "false"`, paren(v, vs[i])))
+		case t == ir.I64:
+			format += "%lld"
+			args = append(args, "(long long)"+paren(v, vs[i]))
+		case t.IsInt():
+			format += "%d"
+			args = append(args, vs[i])
+		default:
+			format += "%s"
+			args = append(args, vs[i])
+		}
+	}
+	if b == ast.PrintlnBuiltin {
+		format += `\0A`
+	}
+	if format == "" {
+		return
+	}
+	g.emit("printf(%s);", strings.Join(append([]string{cString(format)}, args...), ", "))
+	g.writes = append(g.writes, "")
+}
+
+// panic emits the builtin `panic` called by n, which stops the program by yuni_trap.
+func (g *cgen) panic(n *ast.Call) {
+	vs := g.sequence(n.Args.(*ast.Args).Values)
+	g.emit(`yuni_trap(%s, "panic: ", %s);`, g.where(n), vs[0])
+	g.writes = append(g.writes, "")
+}
+
+// assert emits the builtin `assert` called by n, which stops the program if the condition is false.
+func (g *cgen) assert(n *ast.Call) {
+	values := n.Args.(*ast.Args).Values
+	vs := g.sequence(values)
+	g.emit("if (!%s) {", paren(values[0], vs[0]))
+	g.block(func() {
+		if len(vs) == 1 {
+			g.emit(`yuni_trap(%s, "assertion failed", "");`, g.where(n))
+		} else {
+			g.emit(`yuni_trap(%s, "assertion failed: ", %s);`, g.where(n), vs[1])
+		}
+	})
+	g.emit("}")
+	g.writes = append(g.writes, "")
+}
+
+// where writes the position of n as a string literal for runtime errors.
+func (g *cgen) where(n ast.AST) string {
+	// cString takes a string of yuni, where a backslash starts an escape.
+	return cString(strings.ReplaceAll(ast.Where(g.mod.File, n), `\`, `\5C`))
+}
+
+// width returns the suffix of the arithmetic function for the type of n, which is "64" for i64.
+// Narrower operands are converted into the parameter types of the function as C does.
+func (g *cgen) width(n ast.AST) string {
+	if g.info.TypeOf(n) == ir.I64 {
+		return "64"
+	}
+	return ""
+}
+
+// binary emits operands lhs and rhs and formats them by format.
+func (g *cgen) binary(lhs, rhs ast.AST, format string) string {
+	vs := g.sequence([]ast.AST{lhs, rhs})
+	if strings.HasPrefix(format, "yuni_") {
+		return fmt.Sprintf(format, vs[0], vs[1])
+	}
+	return fmt.Sprintf(format, paren(lhs, vs[0]), paren(rhs, vs[1]))
+}
+
+// sequence emits ns in order and returns their values.
+// A value which statements of later nodes can change, like `x` in `x + (x = 1)`,
+// is stored into a temporary before them.
+func (g *cgen) sequence(ns []ast.AST) []string {
+	vs := make([]string, len(ns))
+	at := make([]int, len(ns))
+	mark := make([]int, len(ns))
+	for i, n := range ns {
+		vs[i] = g.expr(n)
+		at[i], mark[i] = len(g.body), len(g.writes)
+	}
+
+	// spill from the last, so that inserting statements doesn't move positions of earlier ones.
+	for i := len(ns) - 1; i >= 0; i-- {
+		if !g.clobbered(vs[i], g.writes[mark[i]:]) {
+			continue
+		}
+		t := g.temp(g.info.TypeOf(ns[i]))
+		line := strings.Repeat("\t", g.indent+1) + fmt.Sprintf("%s = %s;", t, vs[i])
+		g.body = append(g.body[:at[i]], append([]string{line}, g.body[at[i]:]...)...)
+		vs[i] = t
+	}
+	return vs
+}
+
+// clobbered reports whether writes can change the value of the C expression e.
+func (g *cgen) clobbered(e string, writes []string) bool {
+	for _, id := range identifiers(e) {
+		for _, w := range writes {
+			if w == id || w == "" && g.cGlobalVars[id] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ref returns the C name of the variable or function which n refers.
+func (g *cgen) ref(n ast.AST) string {
+	if c, ok := g.locals[n.Name()]; ok {
+		return c
+	}
+	r := g.info.Names[n]
+	if g.funcNames[r] || g.globalNames[r] {
+		return cName(r)
+	}
+	if g.externNames[r] {
+		return string(r)
+	}
+	if isRuntimeFunc(r) {
+		g.useRuntime(r)
+		return string(runtimeSymbol(r))
+	}
+	panic(fmt.Errorf("Variable %s doesn't exist.", n.Name()))
+}
+
+// useRuntime marks the runtime function n and functions it calls to be defined.
+func (g *cgen) useRuntime(n ast.Name) {
+	g.runtime[n] = true
+	for _, f := range cRuntime {
+		if f.name == n {
+			for _, u := range f.uses {
+				g.useRuntime(u)
+			}
+		}
+	}
+}
+
+// cType writes the C type for t.
+// Function pointer types are named by typedefs as they cannot be written in place.
+func (g *cgen) cType(t ir.Type) string {
+	switch {
+	case t == ir.I1:
+		return "_Bool"
+	case t.IsInt():
+		return fmt.Sprintf("int%d_t", t.Bits())
+	case t == "i8*":
+		return "char *"
+	case t.IsFunc():
+		return g.typedef(t)
+	case t.IsPointer():
+		elem := g.cType(t.Elem())
+		if strings.HasSuffix(elem, "*") {
+			return elem + "*"
+		}
+		return elem + " *"
+	default:
+		panic(fmt.Errorf("type %s cannot be generated into C", t))
+	}
+}
+
+// cDecl writes the declaration of n typed t like `char *s`.
+func (g *cgen) cDecl(t ir.Type, n string) string {
+	ct := g.cType(t)
+	if strings.HasSuffix(ct, "*") {
+		return ct + n
+	}
+	return ct + " " + n
+}
+
+// typedef names the function pointer type t.
+func (g *cgen) typedef(t ir.Type) string {
+	if n, ok := g.typedefs[t]; ok {
+		return n
+	}
+
+	ps := []string{}
+	for _, p := range t.Params() {
+		if p == "..." {
+			ps = append(ps, "...")
+			continue
+		}
+		ps = append(ps, g.cType(p))
+	}
+	if len(ps) == 0 {
+		ps = append(ps, "void")
+	}
+	ret := g.cType(t.Return())
+
+	n := fmt.Sprintf("yuni_fn%d", len(g.typedefs))
+	g.typedefs[t] = n
+	fmt.Fprintf(&g.types, "typedef %s (*%s)(%s);\n", ret, n, strings.Join(ps, ", "))
+	return n
+}
+
+// cName makes the C name for the module level name n like `math.gcd`.
+func cName(n ast.Name) string {
+	return safeName(strings.ReplaceAll(string(n), ".", "__"))
+}
+
+// safeName avoids n colliding with C keywords.
+func safeName(n string) string {
+	if cKeywords[n] {
+		return n + "_"
+	}
+	return n
+}
+
+// paren parenthesizes the C expression e for n if it is an infix expression.
+func paren(n ast.AST, e string) string {
+	if isIdentifier(e) {
+		return e
+	}
+	switch n := n.(type) {
+	case *ast.Less, *ast.Equal:
+		return "(" + e + ")"
+	case *ast.Sequence:
+		return paren(n.RHS, e)
+	}
+	return e
+}
+
+// cInt writes x as an i32 literal.
+func cInt(x int) string {
+	v := int32(x)
+	// -2147483648 is the negation of a literal too large for int.
+	if v == -1<<31 {
+		return "(-2147483647 - 1)"
+	}
+	return fmt.Sprintf("%d", v)
+}
+
+// cString writes the string literal of yuni s as a C string literal.
+func cString(s string) string {
+	b := strings.Builder{}
+	b.WriteByte('"')
+	prev := byte(0)
+	for _, c := range []byte(libc.Unescape(s)) {
+		switch {
+		case c == '"' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c == '\n':
+			b.WriteString(`\n`)
+		case c == '\t':
+			b.WriteString(`\t`)
+		case c == '?' && prev == '?':
+			// avoid trigraphs like `??(`.
+			b.WriteString(`\?`)
+		case c < 0x20 || c >= 0x7f:
+			fmt.Fprintf(&b, `\%03o`, c)
+		default:
+			b.WriteByte(c)
+		}
+		prev = c
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// identifiers lists identifiers in the C expression e, skipping string literals.
+func identifiers(e string) []string {
+	ids := []string{}
+	for i := 0; i < len(e); {
+		c := e[i]
+		switch {
+		case c == '"':
+			for i++; i < len(e) && e[i] != '"'; i++ {
+				if e[i] == '\\' {
+					i++
+				}
+			}
+			i++
+		case isIdentStart(c) || '0' <= c && c <= '9':
+			j := i
+			for j < len(e) && (isIdentStart(e[j]) || '0' <= e[j] && e[j] <= '9') {
+				j++
+			}
+			if isIdentStart(c) {
+				ids = append(ids, e[i:j])
+			}
+			i = j
+		default:
+			i++
+		}
+	}
+	return ids
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+func isIdentifier(e string) bool {
+	ids := identifiers(e)
+	return len(ids) == 1 && ids[0] == e
+}
@@ -0,0 +1,132 @@
+package gen_test
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gotest.tools/assert"
+
+	"github.com/yuniruyuni/lang/conformance"
+	"github.com/yuniruyuni/lang/gen"
+	"github.com/yuniruyuni/lang/module"
+)
+
+// runC generates C from code, builds it by cc and runs it with input.
+// It returns what the program writes into stdout and stderr, and its exit code,
+// which is -1 if the program is aborted.
+func runC(t *testing.T, cc, code, input string) (string, string, int) {
+	t.Helper()
+	prog, err := module.New(nil).LoadSource("<test>", code)
+	assert.NilError(t, err)
+	c := gen.CFile{AST: prog}
+
+	dir := t.TempDir()
+	src, exe := filepath.Join(dir, "main.c"), filepath.Join(dir, "main")
+	assert.NilError(t, os.WriteFile(src, []byte(c.Generate()), 0644))
+	out, err := exec.Command(cc, "-std=c99", "-pedantic", "-Werror", "-o", exe, src).CombinedOutput()
+	assert.NilError(t, err, string(out))
+
+	cmd := exec.Command(exe)
+	cmd.Stdin = strings.NewReader(input)
+	stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	err = cmd.Run()
+	if exit, ok := err.(*exec.ExitError); ok {
+		return stdout.String(), stderr.String(), exit.ExitCode()
+	}
+	assert.NilError(t, err)
+	return stdout.String(), stderr.String(), 0
+}
+
+func TestCFile_Conformance(t *testing.T) {
+	cc, err := exec.LookPath("cc")
+	if err != nil {
+		t.Skip("cc is not installed")
+	}
+
+	for _, tt := range conformance.Cases {
+		t.Run(tt.Name, func(t *testing.T) {
+			stdout, stderr, code := runC(t, cc, tt.Code, tt.Input)
+			assert.Equal(t, tt.Want, stdout)
+			assert.Equal(t, "", stderr)
+			assert.Equal(t, tt.WantCode, code)
+		})
+	}
+}
+
+func TestCFile_Generate(t *testing.T) {
+	cc, err := exec.LookPath("cc")
+	if err != nil {
+		t.Skip("cc is not installed")
+	}
+
+	tests := []struct {
+		name     string
+		code     string
+		input    string
+		want     string
+		wantCode int
+		// wantStderr is the message of a runtime error, which exits with the code -1 by abort.
+		wantStderr string
+	}{
+		{
+			name:  "read lines, integers and chars until the end of input",
+			code:  `func main(){ println("[", read_line(), "] ", read_ok(),); let sum = 0; while eof() == 0 { sum = sum + read_int() }; println(sum, " ", read_char(), " ", read_ok(),); 0 }`,
+			input: "name\n1 2\n3\n",
+			want:  "[name] 1\n6 -1 0\n",
+		},
+		{
+			name: "function values and names reserved by C",
+			code: `func double(int: i32,) -> i32 { int * 2 }
+				func apply(f: fn(i32) -> i32, x: i32,) -> i32 { f(x,) }
+				func main(){ let char = double; apply(char, 21,) }`,
+			wantCode: 42,
+		},
+		{
+			name:       "division by zero traps",
+			code:       `func main(){ let x = 0; let m = 0 - 2147483647; m = m - 1; printf("%d,", m / (0 - 1),); 1 / x }`,
+			want:       "-2147483648,",
+			wantCode:   -1,
+			wantStderr: "<test>:1:89: runtime error: division by zero\n",
+		},
+		{
+			name: "external functions named as functions of libc",
+			code: `extern func puts(s: *u8) -> i32 extern func atoi(s: *u8) -> i64 extern func exit(c: i32) -> i32 extern func malloc(n: i64) -> *u8
+				func main(){ let p = malloc(8,); puts("hi",); exit(atoi("3",),) }`,
+			want:     "hi\n",
+			wantCode: 3,
+		},
+		{
+			name:       "division by zero traps even if the result is discarded",
+			code:       `func main(){ let z = 0; 5 / z; 0 }`,
+			wantCode:   -1,
+			wantStderr: "<test>:1:25: runtime error: division by zero\n",
+		},
+		{
+			name:       "failed assertion",
+			code:       `func main(){ assert(1 < 2,); printf("ok,",); assert(2 < 1, "order\0A",); 0 }`,
+			want:       "ok,",
+			wantCode:   -1,
+			wantStderr: "<test>:1:46: runtime error: assertion failed: order\n\n",
+		},
+		{
+			name:       "panic",
+			code:       `func main(){ panic("boom",); 0 }`,
+			wantCode:   -1,
+			wantStderr: "<test>:1:14: runtime error: panic: boom\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stdout, stderr, code := runC(t, cc, tt.code, tt.input)
+			assert.Equal(t, tt.want, stdout)
+			assert.Equal(t, tt.wantStderr, stderr)
+			assert.Equal(t, tt.wantCode, code)
+		})
+	}
+}
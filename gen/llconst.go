@@ -0,0 +1,67 @@
+package gen
+
+import (
+	"errors"
+
+	"github.com/yuniruyuni/lang/ast"
+)
+
+// EvalConst evaluates the constant expression n at compile time.
+// A constant expression consists of integer literals, arithmetic,
+// comparisons and references to other constants.
+func (g *llgen) EvalConst(n ast.AST) (int, error) {
+	switch n := n.(type) {
+	case *ast.Integer:
+		return int(int32(n.Value)), nil
+	case *ast.Variable:
+		g.refer(n, n.Name())
+		return g.GetConst(n.Name())
+	case *ast.Add:
+		return g.evalBinary(n.LHS, n.RHS, func(x, y int64) (int64, error) { return x + y, nil })
+	case *ast.Sub:
+		return g.evalBinary(n.LHS, n.RHS, func(x, y int64) (int64, error) { return x - y, nil })
+	case *ast.Mul:
+		return g.evalBinary(n.LHS, n.RHS, func(x, y int64) (int64, error) { return x * y, nil })
+	case *ast.Div:
+		return g.evalBinary(n.LHS, n.RHS, func(x, y int64) (int64, error) {
+			if y == 0 {
+				return 0, errors.New("division by zero in constant expression")
+			}
+			return x / y, nil
+		})
+	case *ast.Less:
+		return g.evalBinary(n.LHS, n.RHS, func(x, y int64) (int64, error) { return boolToInt64(x < y), nil })
+	case *ast.Equal:
+		return g.evalBinary(n.LHS, n.RHS, func(x, y int64) (int64, error) { return boolToInt64(x == y), nil })
+	default:
+		return 0, errors.New("only integers, arithmetic, comparisons and constants can be used")
+	}
+}
+
+// evalBinary evaluates op on the constant expressions lhs and rhs as i32.
+// The result wraps around on overflow, or it is an error if arithmetic is checked.
+func (g *llgen) evalBinary(lhs, rhs ast.AST, op func(x, y int64) (int64, error)) (int, error) {
+	x, err := g.EvalConst(lhs)
+	if err != nil {
+		return 0, err
+	}
+	y, err := g.EvalConst(rhs)
+	if err != nil {
+		return 0, err
+	}
+	v, err := op(int64(x), int64(y))
+	if err != nil {
+		return 0, err
+	}
+	if g.checked && v != int64(int32(v)) {
+		return 0, errors.New("integer overflow")
+	}
+	return int(int32(v)), nil
+}
+
+func boolToInt64(b bool) int64 {
+	if b {
+		return 1
+	}
+	return 0
+}
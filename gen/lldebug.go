@@ -0,0 +1,255 @@
+package gen
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/yuniruyuni/lang/ast"
+	"github.com/yuniruyuni/lang/ir"
+)
+
+// debugInfo builds DWARF metadata which describes generated code by yuni source positions.
+type debugInfo struct {
+	m *ir.Module
+	// unit is the `!DICompileUnit` every subprogram belongs to.
+	unit *ir.Metadata
+	// src and file are the source file of the module generating now and its `!DIFile`.
+	src  *ast.File
+	file *ir.Metadata
+	// scope is the `!DISubprogram` of the function generating now, nil outside functions.
+	scope *ir.Metadata
+
+	files map[string]*ir.Metadata
+	types map[ir.Type]*ir.Metadata
+	locs  map[location]*ir.Metadata
+}
+
+// location is the key to share `!DILocation` nodes.
+type location struct {
+	line, col int
+	scope     *ir.Metadata
+}
+
+// EnableDebug makes g attach debug information to generated code.
+// The compile unit is named by root, the source file of the root module.
+func (g *llgen) EnableDebug(root *ast.File) {
+	d := &debugInfo{
+		m:     g.Module,
+		files: map[string]*ir.Metadata{},
+		types: map[ir.Type]*ir.Metadata{},
+		locs:  map[location]*ir.Metadata{},
+	}
+	d.unit = g.Module.NewDistinctMetadata(fmt.Sprintf(
+		`!DICompileUnit(language: DW_LANG_C99, file: %s, producer: "yuni", isOptimized: false, runtimeVersion: 0, emissionKind: FullDebug)`,
+		d.fileOf(root).Ident(),
+	))
+	g.Module.AddNamedMetadata("llvm.dbg.cu", d.unit)
+	g.Module.AddNamedMetadata("llvm.module.flags",
+		g.Module.NewMetadata(`!{i32 7, !"Dwarf Version", i32 4}`),
+		g.Module.NewMetadata(`!{i32 2, !"Debug Info Version", i32 3}`),
+	)
+	g.debug = d
+}
+
+// EnterFile sets the source file of the module generating now,
+// which positions of nodes are found in.
+func (g *llgen) EnterFile(f *ast.File) {
+	g.file = f
+	if g.debug == nil {
+		return
+	}
+	g.debug.src = f
+	g.debug.file = g.debug.fileOf(f)
+}
+
+// DescribeFunc attaches the subprogram for the function n to f, which g starts to build.
+// Instructions are located at n until the returned function is called at the end of f.
+func (g *llgen) DescribeFunc(f *ir.Function, n *ast.Func) func() {
+	if g.debug == nil {
+		return func() {}
+	}
+	d := g.debug
+	line, _ := d.position(n.Pos())
+	linkage := ""
+	if f.Name != string(n.Name()) {
+		linkage = fmt.Sprintf(`linkageName: %s, `, quote(f.Name))
+	}
+	f.Dbg = g.Module.NewDistinctMetadata(fmt.Sprintf(
+		`!DISubprogram(name: %s, %sscope: %s, file: %s, line: %d, type: %s, scopeLine: %d, spFlags: DISPFlagDefinition, unit: %s)`,
+		quote(string(n.Name())), linkage, d.file.Ident(), d.file.Ident(), line, d.subroutine(f.Type()).Ident(), line, d.unit.Ident(),
+	))
+	d.scope = f.Dbg
+	g.Loc = d.location(n.Pos())
+	return func() {
+		d.scope = nil
+		g.Loc = nil
+	}
+}
+
+// Locate attaches the position of n to instructions emitted until the returned function is called,
+// which restores the previous location. Nodes made by the compiler without position keep the previous one.
+func (g *llgen) Locate(n ast.AST) func() {
+	if g.debug == nil || g.debug.scope == nil || n.Pos() == (ast.Span{}) {
+		return func() {}
+	}
+	prev := g.Loc
+	g.Loc = g.debug.location(n.Pos())
+	return func() { g.Loc = prev }
+}
+
+// LocateEnd is same as Locate but attaches the position where n ends, like the closing brace of a function.
+func (g *llgen) LocateEnd(n ast.AST) func() {
+	if g.debug == nil || g.debug.scope == nil || n.Pos() == (ast.Span{}) {
+		return func() {}
+	}
+	prev := g.Loc
+	g.Loc = g.debug.location(ast.Span{Beg: n.Pos().End - 1, End: n.Pos().End})
+	return func() { g.Loc = prev }
+}
+
+// DeclareVariable describes the variable n typed t held in the alloca-ed slot for debuggers.
+func (g *llgen) DeclareVariable(n ast.AST, t ir.Type, slot ir.Value) {
+	if g.debug == nil || g.debug.scope == nil {
+		return
+	}
+	v := g.debug.variable(n, t, 0)
+	g.Call(g.debug.intrinsic("llvm.dbg.declare"), &ir.Meta{Value: slot}, &ir.Meta{Node: v}, &ir.Meta{Text: "!DIExpression()"})
+}
+
+// DeclareParam describes the parameter n held in p for debuggers.
+func (g *llgen) DeclareParam(n ast.AST, p *ir.Param) {
+	if g.debug == nil || g.debug.scope == nil {
+		return
+	}
+	arg := 0
+	for k, q := range g.Func.Params {
+		if q == p {
+			arg = k + 1
+		}
+	}
+	v := g.debug.variable(n, p.Type(), arg)
+	g.Call(g.debug.intrinsic("llvm.dbg.value"), &ir.Meta{Value: p}, &ir.Meta{Node: v}, &ir.Meta{Text: "!DIExpression()"})
+}
+
+// fileOf returns the `!DIFile` for the source file f.
+func (d *debugInfo) fileOf(f *ast.File) *ir.Metadata {
+	path := "<unknown>"
+	if f != nil {
+		path = f.Path
+	}
+	if md, ok := d.files[path]; ok {
+		return md
+	}
+	md := d.m.NewMetadata(fmt.Sprintf(`!DIFile(filename: %s, directory: %s)`, quote(filepath.Base(path)), quote(filepath.Dir(path))))
+	d.files[path] = md
+	return md
+}
+
+// position finds the line and the column where the span s begins in current source file.
+func (d *debugInfo) position(s ast.Span) (line, col int) {
+	if d.src == nil {
+		return 1, 1
+	}
+	return d.src.Position(s.Beg)
+}
+
+// location returns the `!DILocation` of the span s within current scope.
+func (d *debugInfo) location(s ast.Span) *ir.Metadata {
+	line, col := d.position(s)
+	key := location{line: line, col: col, scope: d.scope}
+	if md, ok := d.locs[key]; ok {
+		return md
+	}
+	md := d.m.NewMetadata(fmt.Sprintf(`!DILocation(line: %d, column: %d, scope: %s)`, line, col, d.scope.Ident()))
+	d.locs[key] = md
+	return md
+}
+
+// variable makes the `!DILocalVariable` for n typed t, which is the arg-th parameter or a local variable if arg is 0.
+func (d *debugInfo) variable(n ast.AST, t ir.Type, arg int) *ir.Metadata {
+	line, _ := d.position(n.Pos())
+	argNo := ""
+	if arg > 0 {
+		argNo = fmt.Sprintf("arg: %d, ", arg)
+	}
+	return d.m.NewMetadata(fmt.Sprintf(
+		`!DILocalVariable(name: %s, %sscope: %s, file: %s, line: %d, type: %s)`,
+		quote(string(n.Name())), argNo, d.scope.Ident(), d.file.Ident(), line, ident(d.typeOf(t)),
+	))
+}
+
+// typeOf returns the debug type for t, or nil for void.
+func (d *debugInfo) typeOf(t ir.Type) *ir.Metadata {
+	if md, ok := d.types[t]; ok {
+		return md
+	}
+	var md *ir.Metadata
+	switch {
+	case t == ir.Void:
+		return nil
+	case t.IsFunc():
+		md = d.pointer(d.subroutine(t))
+	case t.IsPointer():
+		md = d.pointer(d.typeOf(t.Elem()))
+	case t == ir.I1:
+		md = d.m.NewMetadata(`!DIBasicType(name: "bool", size: 8, encoding: DW_ATE_boolean)`)
+	case t == ir.I8:
+		md = d.m.NewMetadata(`!DIBasicType(name: "i8", size: 8, encoding: DW_ATE_signed_char)`)
+	default:
+		md = d.m.NewMetadata(fmt.Sprintf(`!DIBasicType(name: %s, size: %d, encoding: DW_ATE_signed)`, quote(string(t)), t.Bits()))
+	}
+	d.types[t] = md
+	return md
+}
+
+// pointer makes the pointer type to elem, sized for the target.
+func (d *debugInfo) pointer(elem *ir.Metadata) *ir.Metadata {
+	return d.m.NewMetadata(fmt.Sprintf(`!DIDerivedType(tag: DW_TAG_pointer_type, baseType: %s, size: %d)`, ident(elem), d.m.Layout().PointerBits))
+}
+
+// subroutine makes the `!DISubroutineType` for the function pointer type t.
+func (d *debugInfo) subroutine(t ir.Type) *ir.Metadata {
+	ts := []string{ident(d.typeOf(t.Return()))}
+	for _, p := range t.Params() {
+		if p == "..." {
+			continue
+		}
+		ts = append(ts, ident(d.typeOf(p)))
+	}
+	types := d.m.NewMetadata("!{" + strings.Join(ts, ", ") + "}")
+	return d.m.NewMetadata(fmt.Sprintf(`!DISubroutineType(types: %s)`, types.Ident()))
+}
+
+// intrinsic finds the debug intrinsic name, declaring it on first use.
+func (d *debugInfo) intrinsic(name string) *ir.Function {
+	if f := d.m.Function(name); f != nil {
+		return f
+	}
+	md := ir.MetadataType
+	return d.m.NewFunction(name, ir.FuncType(ir.Void, []ir.Type{md, md, md}, false))
+}
+
+// ident writes md as an operand of another node, `null` for nil.
+func ident(md *ir.Metadata) string {
+	if md == nil {
+		return "null"
+	}
+	return md.Ident()
+}
+
+// quote writes s as a metadata string, escaping quotes, backslashes and unprintable bytes like `\0A`.
+func quote(s string) string {
+	b := new(strings.Builder)
+	b.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c == '"' || c == '\\' || c < ' ' || c > '~' {
+			fmt.Fprintf(b, "\\%02X", c)
+			continue
+		}
+		b.WriteByte(c)
+	}
+	b.WriteByte('"')
+	return b.String()
+}
@@ -0,0 +1,158 @@
+package gen
+
+import (
+	"fmt"
+
+	"github.com/yuniruyuni/lang/ast"
+	"github.com/yuniruyuni/lang/ir"
+)
+
+// declare registers every module level definition in the tree n
+// and makes the global for every string literal, which results the pointer to its first character.
+func (g *llgen) declare(n ast.AST) {
+	ast.Inspect(n, func(n ast.AST) bool {
+		switch n := n.(type) {
+		case *ast.Module:
+			g.EnterModule(n.ModName, n.Imports)
+		case *ast.Pub:
+			g.Export(g.Qualify(n.Name()))
+		case *ast.Func:
+			g.declareFunc(n)
+		case *ast.Extern:
+			g.declareExtern(n)
+			return false
+		case *ast.Global:
+			g.declareGlobal(n)
+			return false
+		case *ast.Const:
+			g.declareConst(n)
+			return false
+		case *ast.String:
+			g.declareString(n)
+		}
+		return true
+	})
+}
+
+func (g *llgen) declareFunc(n *ast.Func) {
+	if n.Params.(*ast.Params).Variadic {
+		panic(fmt.Errorf("Function %s cannot be variadic, only extern functions can be.", n.Name()))
+	}
+	// functions of the runtime like `read` share the namespace of the root module.
+	if _, ok := g.runtime[g.Qualify(n.Name())]; ok {
+		panic(fmt.Errorf("Function %s is already defined by the runtime.", n.Name()))
+	}
+	if g.IsDefined(g.Qualify(n.Name())) {
+		panic(fmt.Errorf("Function %s is already defined.", n.Name()))
+	}
+	g.RegisterFunc(g.Qualify(n.Name()), n.FuncType(), irParams(n.Params.(*ast.Params))...)
+}
+
+// irParams makes the parameters for the function taking ps.
+func irParams(ps *ast.Params) []*ir.Param {
+	params := make([]*ir.Param, 0, len(ps.Vars))
+	for _, v := range ps.Vars {
+		params = append(params, &ir.Param{Name: string(v.Name()), Typ: v.(*ast.Param).Type()})
+	}
+	return params
+}
+
+func (g *llgen) declareExtern(n *ast.Extern) {
+	t := n.FuncType()
+
+	if _, ok := g.runtime[n.Name()]; ok {
+		panic(fmt.Errorf("extern %s is already defined by the runtime.", n.Name()))
+	}
+	// the same function can be declared in multiple modules.
+	if _, ok := g.globals[n.Name()]; ok {
+		panic(fmt.Errorf("Function %s is already defined.", n.Name()))
+	}
+	if prev, ok := g.GetSymbol(n.Name()); ok {
+		if prev.Type() != t {
+			panic(fmt.Errorf("extern %s is already declared as %s.", n.Name(), prev.Type()))
+		}
+		return
+	}
+	g.RegisterFunc(n.Name(), t)
+}
+
+func (g *llgen) declareGlobal(n *ast.Global) {
+	if g.IsDefined(g.Qualify(n.Name())) {
+		panic(fmt.Errorf("Variable %s is already defined.", n.Name()))
+	}
+	v, err := g.EvalConst(n.RHS)
+	if err != nil {
+		panic(fmt.Errorf("initializer of var %s must be a constant expression: %s", n.Name(), err))
+	}
+	q := g.Qualify(n.Name())
+	gl := g.Module.NewGlobal(string(q), n.Type(), fmt.Sprintf("%d", v))
+	g.RegisterGlobal(q, gl)
+}
+
+func (g *llgen) declareConst(n *ast.Const) {
+	if g.IsDefined(g.Qualify(n.Name())) {
+		panic(fmt.Errorf("Constant %s is already defined.", n.Name()))
+	}
+	v, err := g.EvalConst(n.RHS)
+	if err != nil {
+		panic(fmt.Errorf("const %s must be a constant expression: %s", n.Name(), err))
+	}
+	q := g.Qualify(n.Name())
+	gl := g.Module.NewGlobal(string(q), n.Type(), fmt.Sprintf("%d", v))
+	gl.Const = true
+	g.RegisterConst(q, gl, v)
+}
+
+func (g *llgen) declareString(n *ast.String) {
+	name := fmt.Sprintf(".str.%d", g.NextConstant())
+	t := ir.ArrayOf(n.WordLen(), ir.I8)
+	init := fmt.Sprintf(`c"%s\00"`, n.Word)
+
+	gl := g.Module.NewGlobal(name, t, init)
+	gl.Const = true
+	gl.Linkage = "private unnamed_addr"
+	gl.Align = 1
+	g.record(n, "i8*", g.ArrayPtr(gl))
+}
+
+// define generates bodies of functions in the tree n, which declare has registered.
+func (g *llgen) define(n ast.AST) {
+	switch n := n.(type) {
+	case *ast.Program:
+		for _, m := range n.Modules {
+			g.define(m)
+		}
+	case *ast.Module:
+		g.EnterModule(n.ModName, n.Imports)
+		g.EnterFile(n.File)
+		g.define(n.Defs)
+	case *ast.Definitions:
+		for _, d := range n.Defs {
+			g.ResetVariables()
+			g.define(d)
+		}
+	case *ast.Pub:
+		g.define(n.Def)
+	case *ast.Func:
+		g.defineFunc(n)
+	}
+}
+
+func (g *llgen) defineFunc(n *ast.Func) {
+	f, _ := g.GetSymbol(g.Qualify(n.Name()))
+	g.SetFunc(f)
+	defer g.DescribeFunc(f, n)()
+	for i, v := range n.Params.(*ast.Params).Vars {
+		g.RegisterParam(v.Name(), f.Params[i])
+		g.DeclareParam(v, f.Params[i])
+	}
+	g.PushFrame(g.Qualify(n.Name()))
+	g.expr(n.Execute)
+	defer g.LocateEnd(n)()
+	if t := g.TypeOf(n.Execute); !assignable(t, n.Type()) {
+		panic(fmt.Errorf("Function %s must return %s, not %s.", n.Name(), n.Type(), t))
+	}
+	ret := g.Coerce(g.ValueOf(n.Execute), g.TypeOf(n.Execute), n.Type())
+	g.PopFrame()
+	g.Ret(ret)
+}
@@ -0,0 +1,372 @@
+package gen
+
+import (
+	"fmt"
+
+	"github.com/yuniruyuni/lang/ast"
+	"github.com/yuniruyuni/lang/ir"
+)
+
+// expr generates the expression n and records its type and value, which TypeOf and ValueOf return.
+// Instructions for n are located at n in debug builds.
+func (g *llgen) expr(n ast.AST) {
+	defer g.Locate(n)()
+
+	switch n := n.(type) {
+	case *ast.Integer:
+		// a literal is an immediate operand, so it emits no instruction.
+		// It wraps around into i32 as arithmetic does.
+		g.record(n, ir.I32, ir.Int(ir.I32, int(int32(n.Value))))
+	case *ast.String:
+		// the global is made by declare.
+	case *ast.Variable:
+		g.variable(n)
+	case *ast.Let:
+		g.let(n)
+	case *ast.Assign:
+		g.assign(n)
+	case *ast.Sequence:
+		g.expr(n.LHS)
+		g.expr(n.RHS)
+		g.record(n, g.TypeOf(n.RHS), g.ValueOf(n.RHS))
+	case *ast.If:
+		g.ifElse(n)
+	case *ast.While:
+		g.loop(n)
+	case *ast.Call:
+		g.call(n)
+	case *ast.Add:
+		x, y := g.operands(n.LHS, n.RHS)
+		g.record(n, x.Type(), g.Arith(n, ir.OpAdd, x, y))
+	case *ast.Sub:
+		x, y := g.operands(n.LHS, n.RHS)
+		g.record(n, x.Type(), g.Arith(n, ir.OpSub, x, y))
+	case *ast.Mul:
+		x, y := g.operands(n.LHS, n.RHS)
+		g.record(n, x.Type(), g.Arith(n, ir.OpMul, x, y))
+	case *ast.Div:
+		x, y := g.operands(n.LHS, n.RHS)
+		g.record(n, x.Type(), g.Div(n, x, y))
+	case *ast.Less:
+		g.compare(n, ir.SLT, n.LHS, n.RHS)
+	case *ast.Equal:
+		g.compare(n, ir.EQ, n.LHS, n.RHS)
+	default:
+		panic(fmt.Errorf("%T cannot be generated into LLVM IR", n))
+	}
+}
+
+// compare generates lhs pred rhs for n, which results 1 if it holds, otherwise 0.
+func (g *llgen) compare(n ast.AST, pred ir.Pred, lhs, rhs ast.AST) {
+	x, y := g.operands(lhs, rhs)
+	cmp := g.ICmp(pred, x, y)
+	g.record(n, ir.I32, g.ZExt(cmp, ir.I32))
+}
+
+// operands generates lhs and rhs of arithmetic or a comparison, which must be integers,
+// and converts them into their common type, which is the wider of them. Integers narrower than i32 are promoted to i32 as C does,
+// so a bool is computed as 0 or 1.
+func (g *llgen) operands(lhs, rhs ast.AST) (ir.Value, ir.Value) {
+	g.expr(lhs)
+	x := g.promote(g.ValueOf(lhs), g.TypeOf(lhs))
+	g.expr(rhs)
+	y := g.promote(g.ValueOf(rhs), g.TypeOf(rhs))
+	for _, t := range []ir.Type{g.TypeOf(lhs), g.TypeOf(rhs)} {
+		if !t.IsInt() {
+			panic(fmt.Errorf("Value of type %s cannot be an operand of arithmetic or comparison.", t))
+		}
+	}
+	switch {
+	case x.Type().Bits() < y.Type().Bits():
+		x = g.Coerce(x, x.Type(), y.Type())
+	case x.Type().Bits() > y.Type().Bits():
+		y = g.Coerce(y, y.Type(), x.Type())
+	}
+	return x, y
+}
+
+// promote converts the integer v typed t into i32 if it is narrower than i32.
+func (g *llgen) promote(v ir.Value, t ir.Type) ir.Value {
+	if t.IsInt() && t.Bits() < ir.I32.Bits() {
+		return g.Coerce(v, t, ir.I32)
+	}
+	return v
+}
+
+// variable generates reading the variable named by n,
+// or referring the function of the name as a function pointer value like `let f = double`.
+func (g *llgen) variable(n *ast.Variable) {
+	g.refer(n, n.Name())
+	if !g.IsVariable(n.Name()) {
+		f, err := g.GetFunc(n.Name())
+		if err != nil {
+			panic(err)
+		}
+		g.record(n, f.Type(), f)
+		return
+	}
+
+	v, err := g.GetVariable(n.Name())
+	if err != nil {
+		panic(err)
+	}
+	if v.Slot {
+		g.record(n, v.Type, g.Load(v.Ref))
+		return
+	}
+	g.record(n, v.Type, v.Ref)
+}
+
+func (g *llgen) let(n *ast.Let) {
+	g.expr(n.RHS)
+
+	t := g.TypeOf(n.RHS)
+	slot := g.EmitNamed(string(n.Name()), ir.Alloca(t))
+	g.RegisterVariable(n.Name(), t, slot)
+	g.DeclareVariable(n, t, slot)
+
+	g.Store(g.ValueOf(n.RHS), slot)
+	g.record(n, t, g.Load(slot))
+}
+
+func (g *llgen) assign(n *ast.Assign) {
+	g.expr(n.RHS)
+	g.refer(n, n.Name())
+
+	v, err := g.GetVariable(n.Name())
+	if err != nil {
+		panic(err)
+	}
+	if v.Const {
+		panic(fmt.Errorf("Constant %s cannot be assigned.", n.Name()))
+	}
+	t := g.TypeOf(n.RHS)
+	if !assignable(t, v.Type) {
+		panic(fmt.Errorf("Variable %s is %s, so a value of type %s cannot be assigned.", n.Name(), v.Type, t))
+	}
+
+	g.Store(g.Coerce(g.ValueOf(n.RHS), t, v.Type), v.Ref)
+	g.record(n, v.Type, g.Load(v.Ref))
+}
+
+func (g *llgen) ifElse(n *ast.If) {
+	// ------- check the condition meets or not
+	g.expr(n.Cond)
+	cond := g.ICmp(ir.NE, g.ValueOf(n.Cond), ir.Zero(g.TypeOf(n.Cond)))
+	thenBlock := g.NewBlock()
+	elseBlock := g.NewBlock()
+	phiBlock := g.NewBlock()
+	g.CondBr(cond, thenBlock, elseBlock)
+
+	// ------- then clause
+	// the blocks where the clauses end differ from thenBlock and elseBlock if they contain control flow.
+	// They jump to phiBlock after both clauses are generated, since the value of each is converted into the type of the other.
+	g.SetBlock(thenBlock)
+	g.expr(n.Then)
+	thenEnd := g.Block
+
+	// ------- else clause
+	g.SetBlock(elseBlock)
+	g.expr(n.Else)
+	elseEnd := g.Block
+
+	t := g.branchType(n)
+	g.SetBlock(thenEnd)
+	then := g.Coerce(g.ValueOf(n.Then), g.TypeOf(n.Then), t)
+	g.Br(phiBlock)
+	g.SetBlock(elseEnd)
+	els := g.Coerce(g.ValueOf(n.Else), g.TypeOf(n.Else), t)
+	g.Br(phiBlock)
+
+	// ------- phi block for an if expression
+	g.SetBlock(phiBlock)
+	g.record(n, t, g.Phi(t,
+		ir.Incoming{Value: then, Block: thenEnd},
+		ir.Incoming{Value: els, Block: elseEnd},
+	))
+}
+
+// branchType returns the type of the if expression n, which is the type of both clauses.
+// Integers of different types meet in their common type as operands of arithmetic do.
+func (g *llgen) branchType(n *ast.If) ir.Type {
+	x, y := g.TypeOf(n.Then), g.TypeOf(n.Else)
+	switch {
+	case x == y:
+		return x
+	case !x.IsInt() || !y.IsInt():
+		panic(fmt.Errorf("%s: Clauses of if are %s and %s, which have no common type.", ast.Where(g.file, n), x, y))
+	case x.Bits() < y.Bits():
+		x = y
+	}
+	if x.Bits() < ir.I32.Bits() {
+		return ir.I32
+	}
+	return x
+}
+
+// loop generates the while loop n, whose type is the type of Proc because a while expression results
+// the value of Proc in the last iteration.
+func (g *llgen) loop(n *ast.While) {
+	tryBlock := g.NewBlock()
+	procBlock := g.NewBlock()
+	endBlock := g.NewBlock()
+
+	// ------- entry
+	entryEnd := g.Block
+	g.Br(tryBlock)
+
+	// ------- condition
+	// the type of the result is known after Proc is generated,
+	// so the phi is typed and gets its incoming values then.
+	g.SetBlock(tryBlock)
+	result := g.Phi(ir.I32)
+	g.expr(n.Cond)
+	cond := g.ICmp(ir.NE, g.ValueOf(n.Cond), ir.Zero(g.TypeOf(n.Cond)))
+	g.CondBr(cond, procBlock, endBlock)
+
+	// ------- loop clause
+	g.SetBlock(procBlock)
+	g.expr(n.Proc)
+	// the block where Proc ends differs from procBlock if Proc contains control flow.
+	procEnd := g.Block
+	g.Br(tryBlock)
+
+	// the result is zero if the loop doesn't iterate at all.
+	t := g.TypeOf(n.Proc)
+	result.Typ = t
+	result.AddIncoming(ir.Zero(t), entryEnd)
+	result.AddIncoming(g.ValueOf(n.Proc), procEnd)
+	g.record(n, t, result)
+
+	// ------- block for ending loop
+	g.SetBlock(endBlock)
+}
+
+// call generates the call n, which calls a builtin, a function or a function value held in a variable.
+func (g *llgen) call(n *ast.Call) {
+	args := n.Args.(*ast.Args).Values
+	for _, a := range args {
+		g.expr(a)
+	}
+	if g.isBuiltin(n) {
+		g.builtin(n)
+		return
+	}
+	callee, t := g.callee(n)
+	g.info.Calls[n] = ast.Callee{FuncType: t}
+
+	params := t.Params()
+	checkArity(n, params)
+	g.record(n, t.Return(), g.Call(callee, g.coercedArgs(n, args, params)...))
+}
+
+// isBuiltin reports whether n calls a builtin function,
+// which a function or a variable of the same name hides.
+func (g *llgen) isBuiltin(n *ast.Call) bool {
+	name := n.FuncName.Name()
+	if !ast.IsBuiltin(name) || g.IsVariable(name) {
+		return false
+	}
+	_, err := g.GetFunc(name)
+	return err != nil
+}
+
+// builtin generates the builtin function which n calls, which results 0.
+func (g *llgen) builtin(n *ast.Call) {
+	b := n.FuncName.Name()
+	g.info.Calls[n] = ast.Callee{Builtin: b}
+	switch b {
+	case ast.PanicBuiltin:
+		g.panic(n)
+	case ast.AssertBuiltin:
+		g.assert(n)
+	default:
+		g.print(n, b)
+	}
+	g.record(n, ir.I32, ir.Int(ir.I32, 0))
+}
+
+// checkArity checks the number of arguments of n meets params.
+func checkArity(n *ast.Call, params []ir.Type) {
+	given := len(n.Args.(*ast.Args).Values)
+
+	variadic := len(params) > 0 && params[len(params)-1] == "..."
+	if variadic {
+		if given < len(params)-1 {
+			panic(fmt.Errorf("Function %s takes at least %d arguments but %d given.", n.FuncName.Name(), len(params)-1, given))
+		}
+		return
+	}
+	if given != len(params) {
+		panic(fmt.Errorf("Function %s takes %d arguments but %d given.", n.FuncName.Name(), len(params), given))
+	}
+}
+
+// callee generates the callee for n and returns it with its function pointer type.
+// A name of a variable is called indirectly through its function pointer value,
+// otherwise the name is called directly as a defined function.
+func (g *llgen) callee(n *ast.Call) (ir.Value, ir.Type) {
+	name := n.FuncName.Name()
+	g.refer(n.FuncName, name)
+
+	if !g.IsVariable(name) {
+		f, err := g.GetFunc(name)
+		if err != nil {
+			panic(err)
+		}
+		return f, f.Type()
+	}
+
+	v := &ast.Variable{VarName: name}
+	g.variable(v)
+	t := g.TypeOf(v)
+	if !t.IsFunc() {
+		panic(fmt.Errorf("Variable %s is not a function.", name))
+	}
+	return g.ValueOf(v), t
+}
+
+// coercedArgs returns values of args of the call n passed to parameters typed as params.
+// An integer argument is converted to its parameter type by Coerce
+// as C does, so external functions like `labs(x: i64)` accept i32 values,
+// and an integer passed as a variadic argument is promoted like C.
+// Arguments of other types must be typed as their parameters.
+func (g *llgen) coercedArgs(n *ast.Call, args []ast.AST, params []ir.Type) []ir.Value {
+	vs := make([]ir.Value, 0, len(args))
+	for i, a := range args {
+		t := g.TypeOf(a)
+		if i >= len(params) || params[i] == "..." {
+			vs = append(vs, g.promote(g.ValueOf(a), t))
+			continue
+		}
+		if !assignable(t, params[i]) {
+			panic(fmt.Errorf("Argument %d of function %s must be %s, not %s.", i+1, n.FuncName.Name(), params[i], t))
+		}
+		vs = append(vs, g.Coerce(g.ValueOf(a), t, params[i]))
+	}
+	return vs
+}
+
+// assignable reports whether a value typed from can be converted into the type to by Coerce.
+func assignable(from, to ir.Type) bool {
+	return from == to || from.IsInt() && to.IsInt()
+}
+
+// Coerce converts the value v typed from into the type to.
+// Integers are sign-extended or truncated, an integer becomes a bool by whether it is non-zero
+// and a bool becomes 0 or 1. Values of other types are returned as is.
+func (g *llgen) Coerce(v ir.Value, from, to ir.Type) ir.Value {
+	if from == to || !from.IsInt() || !to.IsInt() {
+		return v
+	}
+	switch {
+	case to == ir.I1:
+		return g.ICmp(ir.NE, v, ir.Zero(from))
+	case from == ir.I1:
+		return g.ZExt(v, to)
+	case from.Bits() > to.Bits():
+		return g.Cast(ir.OpTrunc, v, to)
+	default:
+		return g.Cast(ir.OpSExt, v, to)
+	}
+}
@@ -5,43 +5,53 @@ import (
 	"github.com/yuniruyuni/lang/ir"
 )
 
-const header = ir.IR(`
-@.intfmt = private unnamed_addr constant [4 x i8] c"%d\0A\00", align 1
-@.readfmt = private unnamed_addr constant [4 x i8] c"%d\0A\00", align 1
-
-define i32 @read() {
-	%1 = alloca i32, align 4
-	store i32 0, i32* %1, align 4
-	call i32 (i8*, ...) @scanf(
-		i8* getelementptr inbounds (
-			[4 x i8],
-			[4 x i8]* @.readfmt,
-			i64 0,
-			i64 0
-		),
-		i32* %1
-	)
-	%3 = load i32, i32* %1, align 4
-	ret i32 %3
-}
-
-declare i32 @scanf(i8*, ...)
-declare i32 @printf(i8*, ...)
-`)
-
 type LLFile struct {
 	AST ast.AST
+	// Debug attaches DWARF debug information which locates code in yuni source.
+	Debug bool
+	// Target is the machine to generate code for, the host if it is nil.
+	Target *ir.Target
+	// CheckedArithmetic traps on signed overflow of arithmetic instead of wrapping around.
+	CheckedArithmetic bool
+
+	// Info is set by Generate to types and callees resolved for nodes of AST,
+	// which backends walking the AST depend on. AST itself is left as parsed.
+	Info *ast.Info
 }
 
-func (ll *LLFile) Generate() ir.IR {
-	gen := ast.NewGen()
-
-	gen.RegisterFunc("printf", "i8*,...")
-	gen.RegisterFunc("read", "")
+// Generate builds the whole LLVM module for the program.
+func (ll *LLFile) Generate() *ir.Module {
+	m := ir.NewModule()
+	m.Target = ll.Target
+	if m.Target == nil {
+		m.Target = ir.HostTarget()
+	}
+	gen := newLLGen(m)
+
+	if ll.Debug {
+		gen.EnableDebug(rootFile(ll.AST))
+	}
+	if ll.CheckedArithmetic {
+		gen.EnableCheckedArithmetic()
+	}
+
+	// functions reading input are defined by the runtime,
+	// other external functions are declared by `extern` in yuni code.
+	rt := declareRuntime(gen)
+
+	gen.declare(ll.AST)
+	gen.define(ll.AST)
+	rt.define()
+
+	ll.Info = gen.info
+	return m
+}
 
-	return ir.Concat(
-		header,
-		ll.AST.GenHeader(gen),
-		ll.AST.GenBody(gen),
-	)
+// rootFile finds the source file of the root module, which comes last in the program.
+func rootFile(root ast.AST) *ast.File {
+	p, ok := root.(*ast.Program)
+	if !ok || len(p.Modules) == 0 {
+		return nil
+	}
+	return p.Modules[len(p.Modules)-1].(*ast.Module).File
 }
@@ -0,0 +1,446 @@
+package gen_test
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gotest.tools/assert"
+
+	"github.com/yuniruyuni/lang/ast"
+	"github.com/yuniruyuni/lang/gen"
+	"github.com/yuniruyuni/lang/ir"
+	"github.com/yuniruyuni/lang/module"
+	"github.com/yuniruyuni/lang/opt"
+)
+
+func TestLLFile_Generate(t *testing.T) {
+	tests := []struct {
+		name string
+		code string
+	}{
+		{
+			name: "if in then clause",
+			code: `func main(){ if 1 { if 0 { 10 } else { 20 } } else { 30 } }`,
+		},
+		{
+			name: "if in an operand",
+			code: `func main(){ 1 + if 1 { if 0 { 10 } else { 20 } + 1 } else { 30 } }`,
+		},
+		{
+			name: "if in arguments",
+			code: `func f(x: i32, y: i32,) -> i32 { x + y } func main(){ f(if 1 { 2 } else { 3 }, if 0 { 4 } else { 5 },) }`,
+		},
+		{
+			name: "if in loop",
+			code: `func main(){ let i = 0; while i < 3 { if i == 1 { i = i + 2 } else { i = i + 1 } } }`,
+		},
+		{
+			name: "loop in loop",
+			code: `func main(){ let i = 0; while i < 3 { let j = 0; while j < i { j = j + 1 }; i = i + 1 } }`,
+		},
+		{
+			name: "loop in if",
+			code: `func main(){ if 1 { let i = 0; while i < 3 { i = i + 1 }; i } else { 0 } }`,
+		},
+		{
+			name: "loop in if condition",
+			code: `func main(){ let i = 0; if while i < 3 { i = i + 1 } { 1 } else { 0 } }`,
+		},
+		{
+			name: "loop as a result",
+			code: `func main(){ let i = 0; while i < 3 { if i < 1 { i = i + 1 } else { i = i + 2 } } }`,
+		},
+		{
+			name: "print in loop",
+			code: `func main(){ let i = 0; while i < 3 { print(i, ",",); i = i + 1 }; println(if i < 5 { i } else { 0 },) }`,
+		},
+		{
+			name: "bool parameters and results",
+			code: `func not(b: bool,) -> bool { b == 0 } func main(){ println(not(1,), not(not(0,),),); not(7,) }`,
+		},
+		{
+			name: "bool operands",
+			code: `func f(x: i32,) -> bool { x < 3 } func main(){ println(f(2,) + 1, f(1,) == f(2,), 0 - f(5,),); 0 }`,
+		},
+		{
+			name: "if with clauses of different integer types",
+			code: `func wide(x: i64,) -> i64 { x } func f(c: i32, b: bool,) -> i64 { if c { wide(5,) } else { if c < 0 { b } else { c } } } func main(){ f(1, 1,); 0 }`,
+		},
+		{
+			name: "values named as the entry block",
+			code: `func f(entry: i32,) -> i32 { entry } func main(){ let entry = 3; f(entry,) }`,
+		},
+		{
+			name: "runtime input functions",
+			code: `func main(){ while eof() == 0 { println(read_int(), read_char(), read_line(), read_ok(),) }; read() }`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prog, err := module.New(nil).LoadSource("<test>", tt.code)
+			assert.NilError(t, err)
+
+			ll := gen.LLFile{AST: prog}
+			assert.NilError(t, ir.Verify(ll.Generate()))
+		})
+	}
+}
+
+func TestLLFile_Generate_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		code string
+		want string
+	}{
+		{
+			name: "if with an integer and a string",
+			code: `func main(){ if 1 { 2 } else { "two" }; 0 }`,
+			want: "<test>:1:14: Clauses of if are i32 and i8*, which have no common type.",
+		},
+		{
+			name: "function named as a runtime function",
+			code: `func eof() -> i32 { 0 } func main(){ eof() }`,
+			want: "Function eof is already defined by the runtime.",
+		},
+		{
+			name: "extern named as a runtime function",
+			code: `extern func eof() -> i32 func main(){ eof() }`,
+			want: "extern eof is already defined by the runtime.",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prog, err := module.New(nil).LoadSource("<test>", tt.code)
+			assert.NilError(t, err)
+
+			defer func() {
+				err, _ := recover().(error)
+				assert.Error(t, err, tt.want)
+			}()
+			ll := gen.LLFile{AST: prog}
+			ll.Generate()
+		})
+	}
+}
+
+func TestLLFile_Debug(t *testing.T) {
+	code := "func add(x: i32, y: i32,) -> i32 {\n  let z = x + y;\n  z\n}\nfunc main() {\n  add(1, 2,)\n}\n"
+
+	tests := []struct {
+		name    string
+		level   int
+		want    []string
+		notWant []string
+	}{
+		{
+			name: "without optimization",
+			want: []string{
+				`define i32 @add(i32 %x, i32 %y) !dbg !8 {`,
+				`call void (metadata,metadata,metadata) @llvm.dbg.value(metadata i32 %x, metadata !10, metadata !DIExpression()), !dbg !9`,
+				`%0 = add i32 %x, %y, !dbg !13`,
+				`call void (metadata,metadata,metadata) @llvm.dbg.declare(metadata i32* %z, metadata !15, metadata !DIExpression()), !dbg !12`,
+				`ret i32 %2, !dbg !17`,
+				`!llvm.dbg.cu = !{!1}`,
+				`!1 = distinct !DICompileUnit(language: DW_LANG_C99, file: !0, producer: "yuni", isOptimized: false, runtimeVersion: 0, emissionKind: FullDebug)`,
+				`!8 = distinct !DISubprogram(name: "add", scope: !0, file: !0, line: 1, type: !7, scopeLine: 1, spFlags: DISPFlagDefinition, unit: !1)`,
+				`!10 = !DILocalVariable(name: "x", arg: 1, scope: !8, file: !0, line: 1, type: !5)`,
+				`!13 = !DILocation(line: 2, column: 11, scope: !8)`,
+				`!15 = !DILocalVariable(name: "z", scope: !8, file: !0, line: 2, type: !5)`,
+				`!17 = !DILocation(line: 4, column: 1, scope: !8)`,
+			},
+		},
+		{
+			name:  "promoted variables and inlined calls",
+			level: 2,
+			want: []string{
+				`define i32 @main() !dbg !20 {`,
+				`ret i32 3, !dbg !`,
+			},
+			notWant: []string{"@llvm.dbg.declare(", "@llvm.dbg.value("},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prog, err := module.New(nil).LoadSource("<test>", code)
+			assert.NilError(t, err)
+
+			ll := gen.LLFile{AST: prog, Debug: true}
+			m := ll.Generate()
+			assert.NilError(t, ir.Verify(m))
+			assert.NilError(t, opt.NewManager(tt.level).Run(m))
+			out := m.String()
+			for _, w := range tt.want {
+				assert.Assert(t, strings.Contains(out, w), "%q is not in\n%s", w, out)
+			}
+			for _, w := range tt.notWant {
+				assert.Assert(t, !strings.Contains(out, w), "%q is in\n%s", w, out)
+			}
+
+			// llc verifies the debug information and the object file has it as DWARF.
+			llc, err := exec.LookPath("llc")
+			if err != nil {
+				return
+			}
+			dir := t.TempDir()
+			src := filepath.Join(dir, "main.ll")
+			obj := filepath.Join(dir, "main.o")
+			assert.NilError(t, os.WriteFile(src, []byte(out), 0o644))
+			msg, err := exec.Command(llc, "-filetype=obj", "-o", obj, src).CombinedOutput()
+			assert.NilError(t, err, string(msg))
+
+			dump, err := exec.LookPath("llvm-dwarfdump")
+			if err != nil {
+				return
+			}
+			info, err := exec.Command(dump, "--debug-info", obj).CombinedOutput()
+			assert.NilError(t, err, string(info))
+			assert.Assert(t, strings.Contains(string(info), `DW_AT_name	("main")`), string(info))
+		})
+	}
+}
+
+func TestLLFile_Repeat(t *testing.T) {
+	code := `func f(x: i32,) -> i64 { let y = x * 2; y } func main(){ let i = 0; while i < 3 { i = i + 1 }; println(f(i,), "done",); 0 }`
+
+	prog, err := module.New(nil).LoadSource("<test>", code)
+	assert.NilError(t, err)
+	parsed, err := module.New(nil).LoadSource("<test>", code)
+	assert.NilError(t, err)
+
+	ll := gen.LLFile{AST: prog}
+	first := ll.Generate().String()
+	info := ll.Info
+	second := ll.Generate().String()
+
+	// generating doesn't change the tree, so the same tree generates the same module again.
+	assert.Equal(t, first, second)
+	assert.Assert(t, reflect.DeepEqual(parsed, prog))
+	// each generation resolves its own info.
+	assert.Assert(t, info != ll.Info)
+	assert.Equal(t, len(info.Types), len(ll.Info.Types))
+	assert.Equal(t, len(info.Calls), len(ll.Info.Calls))
+	assert.Equal(t, len(info.Names), len(ll.Info.Names))
+}
+
+func TestLLFile_Names(t *testing.T) {
+	code := `var g = 1 const M = 2 const N = M func f(x: i32,) -> i32 { g = x; g + N } func main(){ let h = f; h(f(1,),) }`
+
+	prog, err := module.New(nil).LoadSource("<test>", code)
+	assert.NilError(t, err)
+	ll := gen.LLFile{AST: prog}
+	ll.Generate()
+
+	// variables of functions like x and h are not module level names.
+	got := map[ast.Name]int{}
+	for _, r := range ll.Info.Names {
+		got[r]++
+	}
+	assert.DeepEqual(t, map[ast.Name]int{"g": 2, "M": 1, "N": 1, "f": 2}, got)
+}
+
+func TestLLFile_Target(t *testing.T) {
+	code := `func main(){ let s = "hi"; let f = main; printf("%s", s,); 0 }`
+
+	tests := []struct {
+		triple string
+		want   []string
+	}{
+		{
+			triple: "x86_64-pc-linux-gnu",
+			want: []string{
+				`target triple = "x86_64-pc-linux-gnu"`,
+				`store i8* getelementptr inbounds ([3 x i8], [3 x i8]* @.str.1, i64 0, i64 0), i8** %s, align 8`,
+				`%s = alloca i8*, align 8`,
+			},
+		},
+		{
+			triple: "armv7-unknown-linux-gnueabihf",
+			want: []string{
+				`target datalayout = "e-m:e-p:32:32-Fi8-i64:64-v128:64:128-a:0:32-n32-S64"`,
+				`target triple = "armv7-unknown-linux-gnueabihf"`,
+				`store i8* getelementptr inbounds ([3 x i8], [3 x i8]* @.str.1, i32 0, i32 0), i8** %s, align 4`,
+				`%s = alloca i8*, align 4`,
+				`%f = alloca i32 ()*, align 4`,
+			},
+		},
+		{
+			triple: "i686-pc-linux-gnu",
+			want: []string{
+				`target triple = "i686-pc-linux-gnu"`,
+				`%s = alloca i8*, align 4`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.triple, func(t *testing.T) {
+			target, err := ir.LookupTarget(tt.triple)
+			assert.NilError(t, err)
+			prog, err := module.New(nil).LoadSource("<test>", code)
+			assert.NilError(t, err)
+
+			ll := gen.LLFile{AST: prog, Target: target}
+			m := ll.Generate()
+			assert.NilError(t, ir.Verify(m))
+			out := m.String()
+			for _, w := range tt.want {
+				assert.Assert(t, strings.Contains(out, w), "%q is not in\n%s", w, out)
+			}
+
+			// llc builds an object for the target from the module as is.
+			llc, err := exec.LookPath("llc")
+			if err != nil {
+				return
+			}
+			dir := t.TempDir()
+			src := filepath.Join(dir, "main.ll")
+			assert.NilError(t, os.WriteFile(src, []byte(out), 0o644))
+			msg, err := exec.Command(llc, "-filetype=obj", "-o", filepath.Join(dir, "main.o"), src).CombinedOutput()
+			assert.NilError(t, err, string(msg))
+		})
+	}
+}
+
+func TestLLFile_Traps(t *testing.T) {
+	arith := `func f(x: i32, y: i32,) -> i32 { x * 2 + y / x }`
+	panics := `func f(x: i32,) -> i32 { assert(x < 9,); assert(0 < x, "positive",); x } func main(){ panic("boom",); f(1,) }`
+
+	tests := []struct {
+		name    string
+		code    string
+		checked bool
+		debug   bool
+		want    []string
+		notWant []string
+	}{
+		{
+			name: "division by zero",
+			code: arith,
+			want: []string{
+				`c"<test>:1:42\00"`,
+				`c"division by zero\00"`,
+				`call void (i8*,i8*,i8*) @yuni_trap(i8* getelementptr inbounds`,
+				`sdiv i32 %y, %x`,
+				`sub i32 0, %y`,
+				`mul i32 %x, 2`,
+			},
+			notWant: []string{"with.overflow"},
+		},
+		{
+			name:    "checked arithmetic",
+			code:    arith,
+			checked: true,
+			want: []string{
+				`c"<test>:1:34\00"`,
+				`c"<test>:1:42\00"`,
+				`c"integer overflow\00"`,
+				`call { i32, i1 } (i32,i32) @llvm.smul.with.overflow.i32(i32 %x, i32 2)`,
+				`call { i32, i1 } (i32,i32) @llvm.sadd.with.overflow.i32(`,
+				`call { i32, i1 } (i32,i32) @llvm.ssub.with.overflow.i32(i32 0, i32 %y)`,
+				`declare { i32, i1 } @llvm.smul.with.overflow.i32(i32, i32)`,
+			},
+			notWant: []string{"mul i32 %x, 2"},
+		},
+		{
+			name: "panic and assert",
+			code: panics,
+			want: []string{
+				`c"<test>:1:26\00"`,
+				`c"<test>:1:42\00"`,
+				`c"<test>:1:87\00"`,
+				`c"assertion failed\00"`,
+				`c"assertion failed: \00"`,
+				`c"panic: \00"`,
+				`c"positive\00"`,
+				`c"%s: runtime error: %s%s\0A\00"`,
+				`declare void @abort()`,
+			},
+			notWant: []string{"@yuni_enter", "@.frames", "backtrace"},
+		},
+		{
+			name:  "backtrace in debug builds",
+			code:  panics,
+			debug: true,
+			want: []string{
+				`@.frames = private global [1024 x i8*] zeroinitializer`,
+				`@.depth = private global i32 0`,
+				`c"backtrace:\0A\00"`,
+				`c"    %s\0A\00"`,
+				`define void @yuni_enter(i8* %name)`,
+				`define void @yuni_leave()`,
+				`c"main\00"`,
+				`c"f\00"`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prog, err := module.New(nil).LoadSource("<test>", tt.code)
+			assert.NilError(t, err)
+
+			ll := gen.LLFile{AST: prog, CheckedArithmetic: tt.checked, Debug: tt.debug}
+			m := ll.Generate()
+			assert.NilError(t, ir.Verify(m))
+			out := m.String()
+			for _, w := range tt.want {
+				assert.Assert(t, strings.Contains(out, w), "%q is not in\n%s", w, out)
+			}
+			for _, w := range tt.notWant {
+				assert.Assert(t, !strings.Contains(out, w), "%q is in\n%s", w, out)
+			}
+		})
+	}
+}
+
+func TestLLFile_Runtime(t *testing.T) {
+	tests := []struct {
+		name    string
+		code    string
+		want    []string
+		notWant []string
+	}{
+		{
+			name:    "no runtime functions",
+			code:    `func main(){ println("hi",); 0 }`,
+			notWant: []string{"@yuni_getc", "@yuni_trap", "@yuni_read_int", "@getchar", "@malloc", "@dprintf", "@abort", "@.peek", "@.readok"},
+		},
+		{
+			name: "functions called by runtime functions",
+			code: `func main(){ read() }`,
+			want: []string{
+				`define i32 @yuni_read()`,
+				`define i32 @yuni_read_int()`,
+				`define i32 @yuni_getc()`,
+				`declare i32 @getchar()`,
+				`@.peek = private global i32 -2`,
+			},
+			notWant: []string{"@yuni_read_line", "@malloc", "@yuni_eof", "@read()"},
+		},
+		{
+			name:    "libc functions declared by the program",
+			code:    "extern func malloc(n: i32) -> *u8\nfunc main(){ malloc(4,); 0 }",
+			want:    []string{`declare i8* @malloc(i32)`},
+			notWant: []string{"@yuni_read_line", "@realloc"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prog, err := module.New(nil).LoadSource("<test>", tt.code)
+			assert.NilError(t, err)
+
+			ll := gen.LLFile{AST: prog}
+			m := ll.Generate()
+			assert.NilError(t, ir.Verify(m))
+			out := m.String()
+			for _, w := range tt.want {
+				assert.Assert(t, strings.Contains(out, w), "%q is not in\n%s", w, out)
+			}
+			for _, w := range tt.notWant {
+				assert.Assert(t, !strings.Contains(out, w), "%q is in\n%s", w, out)
+			}
+		})
+	}
+}
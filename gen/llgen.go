@@ -0,0 +1,259 @@
+package gen
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/yuniruyuni/lang/ast"
+	"github.com/yuniruyuni/lang/ir"
+)
+
+// variable describes how a variable is held in generated code.
+type variable struct {
+	// Type is the type of the value which the variable holds.
+	Type ir.Type
+	// Slot is true if the variable is a memory slot like an alloca or a global
+	// and false if it is a plain SSA value like a parameter.
+	Slot bool
+	// Const is true if the variable cannot be assigned.
+	Const bool
+	// Ref is the value which generated code refers for the variable,
+	// a pointer to the slot or the value itself.
+	Ref ir.Value
+}
+
+// llgen walks the AST and builds an LLVM module.
+// It declares every definition first by declare, so that bodies generated by define can refer
+// definitions written after them.
+type llgen struct {
+	// Builder emits instructions into the function generating now.
+	*ir.Builder
+	// Module is the LLVM module which holds every generated definition.
+	Module *ir.Module
+
+	constant int
+	vartypes map[ast.Name]variable
+	globals  map[ast.Name]variable
+	consts   map[ast.Name]int
+
+	// module is the name of the module which is generating now.
+	module ast.Name
+	// imports maps an import name to its module name for current module.
+	imports map[ast.Name]ast.Name
+	// exported holds module level names marked as `pub`.
+	exported map[ast.Name]bool
+	// runtime maps names of runtimeFuncs, which programs call, to their functions.
+	runtime map[ast.Name]*ir.Function
+	// cstrings holds constant strings made by CString.
+	cstrings map[string]*ir.Const
+	// file is the source file of the module generating now, nil if it is unknown.
+	file *ast.File
+	// checked makes arithmetic trap on signed overflow instead of wrapping around.
+	checked bool
+
+	// debug builds debug information, nil unless it is enabled.
+	debug *debugInfo
+
+	// info records types and callees resolved for nodes.
+	info *ast.Info
+	// values holds the value each expression results, used within the function generating it.
+	values map[ast.AST]ir.Value
+}
+
+func newLLGen(m *ir.Module) *llgen {
+	return &llgen{
+		Builder:  &ir.Builder{Target: m.Layout()},
+		Module:   m,
+		globals:  map[ast.Name]variable{},
+		consts:   map[ast.Name]int{},
+		imports:  map[ast.Name]ast.Name{},
+		exported: map[ast.Name]bool{},
+		runtime:  map[ast.Name]*ir.Function{},
+		cstrings: map[string]*ir.Const{},
+		info:     ast.NewInfo(),
+		values:   map[ast.AST]ir.Value{},
+	}
+}
+
+// record records that the expression n results v typed t.
+func (g *llgen) record(n ast.AST, t ir.Type, v ir.Value) {
+	g.info.Types[n] = t
+	g.values[n] = v
+}
+
+// refer records the module level name which n refers by the name,
+// unless the name is a variable of the function generating now.
+func (g *llgen) refer(n ast.AST, name ast.Name) {
+	if _, ok := g.vartypes[name]; ok {
+		return
+	}
+	if r, err := g.Resolve(name); err == nil {
+		g.info.Names[n] = r
+	}
+}
+
+// ValueOf returns the value which the expression n results.
+// It is available at the end of the block which g.Block points after generating n.
+func (g *llgen) ValueOf(n ast.AST) ir.Value {
+	return g.values[n]
+}
+
+// TypeOf returns the type of the expression n, which is resolved by generating n.
+func (g *llgen) TypeOf(n ast.AST) ir.Type {
+	return g.info.TypeOf(n)
+}
+
+// ArrayPtr makes the pointer to the first element of the array held in gl.
+// It is indexed by the integer as wide as pointers of the target.
+func (g *llgen) ArrayPtr(gl *ir.Global) *ir.Const {
+	zero := ir.Int(g.Module.Layout().IntPtr(), 0)
+	return ir.ConstGEP(gl, zero, zero)
+}
+
+// EnterModule switches the namespace to the module m
+// which imports other modules as imports.
+// The root module should have an empty name so its names are left as is.
+func (g *llgen) EnterModule(m ast.Name, imports map[ast.Name]ast.Name) {
+	g.module = m
+	g.imports = imports
+}
+
+// Qualify makes the module level name for n defined in current module.
+func (g *llgen) Qualify(n ast.Name) ast.Name {
+	if g.module == "" {
+		return n
+	}
+	return g.module + "." + n
+}
+
+// Export marks the module level name n as visible from other modules.
+func (g *llgen) Export(n ast.Name) {
+	g.exported[n] = true
+}
+
+// Resolve finds the module level name which n refers from current module.
+// `m.x` refers the exported name x in the imported module m,
+// and a plain name refers current module's definition first
+// and then the name shared by all modules like `printf`.
+func (g *llgen) Resolve(n ast.Name) (ast.Name, error) {
+	if i := strings.Index(string(n), "."); i >= 0 {
+		alias, member := n[:i], n[i+1:]
+		m, ok := g.imports[alias]
+		if !ok {
+			return "", fmt.Errorf("Module %s is not imported.", alias)
+		}
+		full := m + "." + member
+		if !g.exported[full] {
+			return "", fmt.Errorf("%s is not exported by module %s.", member, alias)
+		}
+		return full, nil
+	}
+
+	local := g.Qualify(n)
+	if g.Module.Function(string(local)) != nil {
+		return local, nil
+	}
+	if _, ok := g.globals[local]; ok {
+		return local, nil
+	}
+	return n, nil
+}
+
+func (g *llgen) NextConstant() int {
+	g.constant += 1
+	return g.constant
+}
+
+// RegisterVariable registers n as a variable held in the alloca-ed slot.
+func (g *llgen) RegisterVariable(n ast.Name, t ir.Type, slot ir.Value) {
+	g.vartypes[n] = variable{Type: t, Slot: true, Ref: slot}
+}
+
+// RegisterParam registers n as a variable held in the parameter p.
+func (g *llgen) RegisterParam(n ast.Name, p *ir.Param) {
+	g.vartypes[n] = variable{Type: p.Type(), Slot: false, Ref: p}
+}
+
+// RegisterGlobal registers n as a module level variable held in gl.
+func (g *llgen) RegisterGlobal(n ast.Name, gl *ir.Global) {
+	g.globals[n] = variable{Type: gl.Elem, Slot: true, Ref: gl}
+}
+
+// RegisterConst registers n as a module level constant held in gl which has value v.
+func (g *llgen) RegisterConst(n ast.Name, gl *ir.Global, v int) {
+	g.globals[n] = variable{Type: gl.Elem, Slot: true, Const: true, Ref: gl}
+	g.consts[n] = v
+}
+
+// GetConst finds the value of the constant n.
+func (g *llgen) GetConst(n ast.Name) (int, error) {
+	r, err := g.Resolve(n)
+	if err != nil {
+		return 0, err
+	}
+	v, ok := g.consts[r]
+	if !ok {
+		return 0, fmt.Errorf("Constant %s doesn't exist.", n)
+	}
+	return v, nil
+}
+
+func (g *llgen) ResetVariables() {
+	g.vartypes = map[ast.Name]variable{}
+}
+
+// GetVariable finds the variable n.
+// Variables in current function shadow module level ones.
+func (g *llgen) GetVariable(n ast.Name) (variable, error) {
+	if v, ok := g.vartypes[n]; ok {
+		return v, nil
+	}
+	r, err := g.Resolve(n)
+	if err != nil {
+		return variable{}, err
+	}
+	if v, ok := g.globals[r]; ok {
+		return v, nil
+	}
+	return variable{}, fmt.Errorf("Variable %s doesn't exist.", n)
+}
+
+// IsVariable reports whether n is a variable visible from current function.
+func (g *llgen) IsVariable(n ast.Name) bool {
+	_, err := g.GetVariable(n)
+	return err == nil
+}
+
+// RegisterFunc adds n as a function which has function pointer type t.
+func (g *llgen) RegisterFunc(n ast.Name, t ir.Type, params ...*ir.Param) *ir.Function {
+	return g.Module.NewFunction(string(n), t, params...)
+}
+
+// GetFunc finds the function n visible from current module.
+func (g *llgen) GetFunc(n ast.Name) (*ir.Function, error) {
+	r, err := g.Resolve(n)
+	if err != nil {
+		return nil, err
+	}
+	f := g.Module.Function(string(r))
+	if f == nil {
+		f = g.runtime[r]
+	}
+	if f == nil {
+		return nil, fmt.Errorf("Function %s doesn't exist.", n)
+	}
+	return f, nil
+}
+
+// IsDefined reports whether the exact name n is taken by a function, a module level variable or a constant.
+func (g *llgen) IsDefined(n ast.Name) bool {
+	_, ok := g.globals[n]
+	return ok || g.Module.Function(string(n)) != nil
+}
+
+// GetSymbol finds the function registered as the exact name n
+// without resolving it from current module.
+func (g *llgen) GetSymbol(n ast.Name) (*ir.Function, bool) {
+	f := g.Module.Function(string(n))
+	return f, f != nil
+}
@@ -0,0 +1,76 @@
+package gen
+
+import (
+	"fmt"
+
+	"github.com/yuniruyuni/lang/ast"
+	"github.com/yuniruyuni/lang/ir"
+)
+
+// print generates the builtin b, `print` or `println`, called by n,
+// which prints every argument by its type.
+func (g *llgen) print(n *ast.Call, b ast.Name) {
+	for _, a := range n.Args.(*ast.Args).Values {
+		g.printValue(g.TypeOf(a), g.ValueOf(a))
+	}
+	if b == ast.PrintlnBuiltin {
+		g.printf("\n")
+	}
+}
+
+// printValue generates printing v typed t without a newline:
+// integers in decimal, bools as `true` or `false` and strings as they are.
+func (g *llgen) printValue(t ir.Type, v ir.Value) {
+	switch {
+	case t == ir.I1:
+		g.printf("%s", g.genBoolName(v))
+	case t == ir.I64:
+		g.printf("%lld", v)
+	case t.IsInt():
+		g.printf("%d", g.Coerce(v, t, ir.I32))
+	case t == "i8*":
+		g.printf("%s", v)
+	default:
+		panic(fmt.Errorf("Value of type %s cannot be printed.", t))
+	}
+}
+
+// printf generates calling printf with the format and args.
+func (g *llgen) printf(format string, args ...ir.Value) {
+	printf, err := g.GetFunc("printf")
+	if err != nil {
+		panic(err)
+	}
+	g.Call(printf, append([]ir.Value{g.CString(format)}, args...)...)
+}
+
+// genBoolName generates choosing the string `true` or `false` by the bool v.
+func (g *llgen) genBoolName(v ir.Value) ir.Value {
+	entry := g.Block
+	els := g.NewBlock()
+	end := g.NewBlock()
+	g.CondBr(v, end, els)
+
+	g.SetBlock(els)
+	g.Br(end)
+
+	g.SetBlock(end)
+	return g.Phi("i8*",
+		ir.Incoming{Value: g.CString("true"), Block: entry},
+		ir.Incoming{Value: g.CString("false"), Block: els},
+	)
+}
+
+// CString returns the pointer to a null terminated constant string s,
+// which is shared by every use of the same string.
+func (g *llgen) CString(s string) *ir.Const {
+	if p, ok := g.cstrings[s]; ok {
+		return p
+	}
+	gl := g.Module.NewGlobal(fmt.Sprintf(".cstr.%d", len(g.cstrings)), ir.ArrayOf(len(s)+1, ir.I8), "c"+quote(s+"\x00"))
+	gl.Const = true
+	gl.Linkage = "private unnamed_addr"
+	gl.Align = 1
+	g.cstrings[s] = g.ArrayPtr(gl)
+	return g.cstrings[s]
+}
@@ -0,0 +1,183 @@
+package gen
+
+import (
+	"fmt"
+
+	"github.com/yuniruyuni/lang/ast"
+	"github.com/yuniruyuni/lang/ir"
+)
+
+// Runtime functions which report runtime errors.
+const (
+	// trapFunc stops the program by a runtime error.
+	// It takes the position of the failure, the reason and the detail like the message of `panic`,
+	// prints them like `main.yuni:3:5: runtime error: panic: boom`
+	// and the backtrace of yuni functions in debug builds.
+	trapFunc ast.Name = "yuni_trap"
+	// enterFunc and leaveFunc push and pop the name of a function on the shadow stack,
+	// which the backtrace is made from. They are called only in debug builds.
+	enterFunc ast.Name = "yuni_enter"
+	leaveFunc ast.Name = "yuni_leave"
+)
+
+// maxBacktrace is the number of functions which the shadow stack holds.
+// Functions called deeper are left out from the backtrace.
+const maxBacktrace = 1024
+
+// overflows maps arithmetic to the intrinsic which computes it with the overflow flag.
+var overflows = map[ir.Op]string{
+	ir.OpAdd: "llvm.sadd.with.overflow",
+	ir.OpSub: "llvm.ssub.with.overflow",
+	ir.OpMul: "llvm.smul.with.overflow",
+}
+
+// EnableCheckedArithmetic makes g generate arithmetic which traps on signed overflow.
+func (g *llgen) EnableCheckedArithmetic() {
+	g.checked = true
+}
+
+// Trap generates stopping the program by the runtime error reason located at n.
+// It ends current block, so the caller should switch to another block after it.
+func (g *llgen) Trap(n ast.AST, reason string) {
+	g.trap(n, reason, g.CString(""))
+}
+
+// trap is same as Trap but the string detail follows the reason in the message.
+func (g *llgen) trap(n ast.AST, reason string, detail ir.Value) {
+	g.Call(g.runtimeFunc(trapFunc), g.CString(ast.Where(g.file, n)), g.CString(reason), detail)
+	g.Unreachable()
+}
+
+// PushFrame generates pushing the function n on the shadow stack in debug builds.
+func (g *llgen) PushFrame(n ast.Name) {
+	if g.debug != nil {
+		g.Call(g.runtimeFunc(enterFunc), g.CString(string(n)))
+	}
+}
+
+// PopFrame generates popping the function on the top of the shadow stack in debug builds.
+func (g *llgen) PopFrame() {
+	if g.debug != nil {
+		g.Call(g.runtimeFunc(leaveFunc))
+	}
+}
+
+// Debugging reports whether g builds debug information.
+func (g *llgen) Debugging() bool {
+	return g.debug != nil
+}
+
+// runtimeFunc finds the function n which the runtime defines.
+func (g *llgen) runtimeFunc(n ast.Name) *ir.Function {
+	f, ok := g.GetSymbol(n)
+	if !ok {
+		panic(fmt.Errorf("Function %s doesn't exist.", n))
+	}
+	return f
+}
+
+// trapIf generates trapping by reason located at n if cond is true.
+func (g *llgen) trapIf(n ast.AST, cond ir.Value, reason string) {
+	trap, cont := g.NewBlock(), g.NewBlock()
+	g.CondBr(cond, trap, cont)
+
+	g.SetBlock(trap)
+	g.Trap(n, reason)
+
+	g.SetBlock(cont)
+}
+
+// Arith generates x op y for n, where op is OpAdd, OpSub or OpMul.
+// It wraps around on overflow, or traps with checked arithmetic.
+func (g *llgen) Arith(n ast.AST, op ir.Op, x, y ir.Value) ir.Value {
+	if !g.checked {
+		switch op {
+		case ir.OpAdd:
+			return g.Add(x, y)
+		case ir.OpSub:
+			return g.Sub(x, y)
+		default:
+			return g.Mul(x, y)
+		}
+	}
+	r := g.Call(g.overflowIntrinsic(op, x.Type()), x, y)
+	g.trapIf(n, g.Extract(r, 1), "integer overflow")
+	return g.Extract(r, 0)
+}
+
+// overflowIntrinsic declares the intrinsic which computes op on t with the overflow flag.
+func (g *llgen) overflowIntrinsic(op ir.Op, t ir.Type) *ir.Function {
+	name := fmt.Sprintf("%s.%s", overflows[op], t)
+	if f := g.Module.Function(name); f != nil {
+		return f
+	}
+	return g.Module.NewFunction(name, ir.FuncType(ir.StructOf(t, ir.I1), []ir.Type{t, t}, false))
+}
+
+// Div generates x / y for n, which traps if y is zero.
+// Dividing the minimum integer by -1 is computed as `0 - x`,
+// which wraps around as other arithmetic does, or traps with checked arithmetic.
+func (g *llgen) Div(n ast.AST, x, y ir.Value) ir.Value {
+	t := x.Type()
+	g.trapIf(n, g.ICmp(ir.EQ, y, ir.Int(t, 0)), "division by zero")
+
+	neg, div, end := g.NewBlock(), g.NewBlock(), g.NewBlock()
+	g.CondBr(g.ICmp(ir.EQ, y, ir.Int(t, -1)), neg, div)
+
+	g.SetBlock(neg)
+	negated := g.Arith(n, ir.OpSub, ir.Int(t, 0), x)
+	from := g.Block
+	g.Br(end)
+
+	g.SetBlock(div)
+	q := g.SDiv(x, y)
+	g.Br(end)
+
+	g.SetBlock(end)
+	return g.Phi(t,
+		ir.Incoming{Value: negated, Block: from},
+		ir.Incoming{Value: q, Block: div},
+	)
+}
+
+// panic generates stopping the program with the message of `panic(msg,)` called by n.
+// Code after it is unreachable, but it is generated into a new block as usual.
+func (g *llgen) panic(n *ast.Call) {
+	checkArity(n, []ir.Type{"i8*"})
+	g.trap(n, "panic: ", g.message(n, 0))
+	g.SetBlock(g.NewBlock())
+}
+
+// assert generates checking the condition of `assert(cond,)` or `assert(cond, msg,)` called by n,
+// which stops the program if it is false.
+func (g *llgen) assert(n *ast.Call) {
+	vs := n.Args.(*ast.Args).Values
+	if len(vs) != 1 && len(vs) != 2 {
+		panic(fmt.Errorf("Function %s takes 1 or 2 arguments but %d given.", ast.AssertBuiltin, len(vs)))
+	}
+	t := g.TypeOf(vs[0])
+	if !t.IsInt() {
+		panic(fmt.Errorf("Value of type %s cannot be asserted.", t))
+	}
+
+	fail, ok := g.NewBlock(), g.NewBlock()
+	g.CondBr(g.Coerce(g.ValueOf(vs[0]), t, ir.I1), ok, fail)
+
+	g.SetBlock(fail)
+	if len(vs) == 1 {
+		g.Trap(n, "assertion failed")
+	} else {
+		g.trap(n, "assertion failed: ", g.message(n, 1))
+	}
+
+	g.SetBlock(ok)
+}
+
+// message returns the i-th argument of n, which should be a string.
+func (g *llgen) message(n *ast.Call, i int) ir.Value {
+	v := n.Args.(*ast.Args).Values[i]
+	if t := g.TypeOf(v); t != "i8*" {
+		panic(fmt.Errorf("Message of %s must be a string, not %s.", n.FuncName.Name(), t))
+	}
+	return g.ValueOf(v)
+}
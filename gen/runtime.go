@@ -0,0 +1,473 @@
+package gen
+
+import (
+	"fmt"
+
+	"github.com/yuniruyuni/lang/ast"
+	"github.com/yuniruyuni/lang/ir"
+)
+
+// runtimeFuncs are functions defined by the runtime, which every program can call without importing.
+//
+// Input is read byte by byte with one byte of lookahead, so that `eof` can find the end of input
+// without consuming it. Integer readers skip blanks after the integer through the end of its line.
+// Each reader records whether it succeeded, which `read_ok` results.
+var runtimeFuncs = []struct {
+	name ast.Name
+	typ  ir.Type
+}{
+	// read reads an integer like read_int, for programs written before read_int.
+	{name: "read", typ: ir.FuncType(ir.I32, nil, false)},
+	// read_int reads a decimal integer after whitespace, or 0 if there is no integer.
+	{name: "read_int", typ: ir.FuncType(ir.I32, nil, false)},
+	// read_char reads a byte, or -1 at the end of input.
+	{name: "read_char", typ: ir.FuncType(ir.I32, nil, false)},
+	// read_line reads a line without its newline, or an empty string at the end of input.
+	{name: "read_line", typ: ir.FuncType("i8*", nil, false)},
+	// read_ok results 1 if the last read succeeded, otherwise 0.
+	{name: "read_ok", typ: ir.FuncType(ir.I32, nil, false)},
+	// eof results 1 if no byte is left in the input, otherwise 0.
+	{name: "eof", typ: ir.FuncType(ir.I32, nil, false)},
+}
+
+// runtimeSymbol is the name of the runtime function n in generated code.
+// It is prefixed as yuni_getc is, so that it cannot replace a function of libc like `read` when it is linked.
+func runtimeSymbol(n ast.Name) ast.Name {
+	return "yuni_" + n
+}
+
+// isRuntimeFunc reports whether n is one of runtimeFuncs.
+func isRuntimeFunc(n ast.Name) bool {
+	for _, f := range runtimeFuncs {
+		if f.name == n {
+			return true
+		}
+	}
+	return false
+}
+
+const (
+	// peekNone in `.peek` tells that no byte is read ahead.
+	peekNone = -2
+	// lineCap is the initial capacity of the buffer for a line.
+	lineCap = 16
+)
+
+// getcFunc reads a byte through the lookahead of the input.
+const getcFunc ast.Name = "yuni_getc"
+
+// runtime generates bodies of runtimeFuncs with the external functions of libc.
+// Only functions which the program uses are generated, with the externals and globals they need.
+type runtime struct {
+	g     *llgen
+	funcs map[ast.Name]*ir.Function
+	// bodies generates each function of funcs, in the order they are defined.
+	bodies []runtimeBody
+
+	// peek is the byte read ahead or peekNone, and ok is the result of the last read.
+	peek, ok *ir.Global
+	// frames holds names of functions being called up to depth, as the shadow stack of debug builds.
+	frames, depth *ir.Global
+}
+
+// runtimeBody is a generator of the runtime function name.
+type runtimeBody struct {
+	name ast.Name
+	gen  func()
+}
+
+// declareRuntime registers runtimeFuncs and the functions which generated code calls,
+// so that programs can refer them.
+func declareRuntime(g *llgen) *runtime {
+	rt := &runtime{g: g, funcs: map[ast.Name]*ir.Function{}}
+	for _, f := range runtimeFuncs {
+		rt.funcs[f.name] = g.RegisterFunc(runtimeSymbol(f.name), f.typ)
+		g.runtime[f.name] = rt.funcs[f.name]
+	}
+	rt.funcs[getcFunc] = g.RegisterFunc(getcFunc, ir.FuncType(ir.I32, nil, false))
+	rt.funcs[trapFunc] = g.RegisterFunc(trapFunc, ir.FuncType(ir.Void, []ir.Type{"i8*", "i8*", "i8*"}, false),
+		&ir.Param{Name: "where", Typ: "i8*"}, &ir.Param{Name: "reason", Typ: "i8*"}, &ir.Param{Name: "detail", Typ: "i8*"})
+	if g.Debugging() {
+		rt.funcs[enterFunc] = g.RegisterFunc(enterFunc, ir.FuncType(ir.Void, []ir.Type{"i8*"}, false), &ir.Param{Name: "name", Typ: "i8*"})
+		rt.funcs[leaveFunc] = g.RegisterFunc(leaveFunc, ir.FuncType(ir.Void, nil, false))
+	}
+	rt.bodies = []runtimeBody{
+		{"read", rt.genRead},
+		{"read_int", rt.genReadInt},
+		{"read_char", rt.genReadChar},
+		{"read_line", rt.genReadLine},
+		{"read_ok", rt.genReadOK},
+		{"eof", rt.genEOF},
+		{getcFunc, rt.genGetc},
+		{trapFunc, rt.genTrap},
+		{enterFunc, rt.genEnter},
+		{leaveFunc, rt.genLeave},
+	}
+	return rt
+}
+
+// define generates bodies of the runtime functions which the program uses, and removes the others.
+// It should be called after modules are defined, so that the runtime knows which functions are used
+// and shares declarations of libc with the external functions of modules.
+func (rt *runtime) define() {
+	defined := map[*ir.Function]bool{}
+	for progress := true; progress; {
+		progress = false
+		used := rt.used()
+		for _, b := range rt.bodies {
+			f := rt.funcs[b.name]
+			if f == nil || defined[f] || !used[f] {
+				continue
+			}
+			defined[f] = true
+			b.gen()
+			progress = true
+		}
+	}
+	for _, f := range rt.funcs {
+		if !defined[f] {
+			rt.g.Module.RemoveFunction(f)
+		}
+	}
+}
+
+// used finds functions which are called or referred from the bodies of the module.
+func (rt *runtime) used() map[*ir.Function]bool {
+	used := map[*ir.Function]bool{}
+	mark := func(v ir.Value) {
+		if f, ok := v.(*ir.Function); ok {
+			used[f] = true
+		}
+	}
+	for _, f := range rt.g.Module.Functions {
+		for _, bb := range f.Blocks {
+			for _, i := range bb.Instrs {
+				for _, v := range i.Operands() {
+					mark(v)
+					if c, ok := v.(*ir.Const); ok {
+						for _, r := range c.Refs {
+							mark(r)
+						}
+					}
+				}
+			}
+		}
+	}
+	return used
+}
+
+// libc declares the function n of libc used by the runtime.
+func (rt *runtime) libc(n ast.Name) *ir.Function {
+	size := rt.g.Module.Layout().IntPtr()
+	types := map[ast.Name]ir.Type{
+		"getchar": ir.FuncType(ir.I32, nil, false),
+		"malloc":  ir.FuncType("i8*", []ir.Type{size}, false),
+		"realloc": ir.FuncType("i8*", []ir.Type{"i8*", size}, false),
+		"fflush":  ir.FuncType(ir.I32, []ir.Type{"i8*"}, false),
+		"dprintf": ir.FuncType(ir.I32, []ir.Type{ir.I32, "i8*"}, true),
+		"abort":   ir.FuncType(ir.Void, nil, false),
+	}
+	return rt.external(n, types[n])
+}
+
+// input makes the globals holding the state of the input at first use.
+func (rt *runtime) input() (peek, ok *ir.Global) {
+	if rt.peek == nil {
+		rt.peek = rt.global(".peek", peekNone)
+		rt.ok = rt.global(".readok", 0)
+	}
+	return rt.peek, rt.ok
+}
+
+// shadowStack makes the globals of the shadow stack at first use.
+func (rt *runtime) shadowStack() (frames, depth *ir.Global) {
+	if rt.frames == nil {
+		rt.frames = rt.g.Module.NewGlobal(".frames", ir.ArrayOf(maxBacktrace, "i8*"), "zeroinitializer")
+		rt.frames.Linkage = "private"
+		rt.depth = rt.global(".depth", 0)
+	}
+	return rt.frames, rt.depth
+}
+
+// external finds the external function n declared by modules, or declares it typed t.
+func (rt *runtime) external(n ast.Name, t ir.Type) *ir.Function {
+	f, ok := rt.g.GetSymbol(n)
+	if !ok {
+		return rt.g.RegisterFunc(n, t)
+	}
+	if f.Type() != t {
+		panic(fmt.Errorf("extern %s is declared as %s, but the runtime uses it as %s.", n, f.Type(), t))
+	}
+	return f
+}
+
+// global makes a private i32 global initialized by v.
+func (rt *runtime) global(n string, v int) *ir.Global {
+	gl := rt.g.Module.NewGlobal(n, ir.I32, fmt.Sprintf("%d", v))
+	gl.Linkage = "private"
+	return gl
+}
+
+// genGetc generates yuni_getc, which results the byte read ahead if any, or reads a byte by getchar.
+func (rt *runtime) genGetc() {
+	g := rt.g
+	peek, _ := rt.input()
+	g.SetFunc(rt.funcs[getcFunc])
+	p := g.Load(peek)
+	read, peeked := g.NewBlock(), g.NewBlock()
+	g.CondBr(g.ICmp(ir.EQ, p, ir.Int(ir.I32, peekNone)), read, peeked)
+
+	g.SetBlock(read)
+	g.Ret(g.Call(rt.libc("getchar")))
+
+	g.SetBlock(peeked)
+	g.Store(ir.Int(ir.I32, peekNone), peek)
+	g.Ret(p)
+}
+
+// genRead generates read, which calls read_int.
+func (rt *runtime) genRead() {
+	g := rt.g
+	g.SetFunc(rt.funcs["read"])
+	g.Ret(g.Call(rt.funcs["read_int"]))
+}
+
+// genReadInt generates read_int.
+// A byte which doesn't continue the integer is left in the input, except a newline after it.
+func (rt *runtime) genReadInt() {
+	g := rt.g
+	peek, ok := rt.input()
+	g.SetFunc(rt.funcs["read_int"])
+	c := g.Alloca(ir.I32)
+	x := g.Alloca(ir.I32)
+	neg := g.Alloca(ir.I32)
+	g.Store(ir.Int(ir.I32, 0), x)
+	g.Store(ir.Int(ir.I32, 0), neg)
+	skip, sign, minus, first, fail, digits, blank, skipBlank, end, unget, done, negate, positive :=
+		g.NewBlock(), g.NewBlock(), g.NewBlock(), g.NewBlock(), g.NewBlock(), g.NewBlock(), g.NewBlock(),
+		g.NewBlock(), g.NewBlock(), g.NewBlock(), g.NewBlock(), g.NewBlock(), g.NewBlock()
+	g.Br(skip)
+
+	// ------- whitespace before the integer
+	g.SetBlock(skip)
+	g.Store(g.Call(rt.funcs[getcFunc]), c)
+	rt.branchIn(g.Load(c), " \t\r\n", skip, sign)
+
+	g.SetBlock(sign)
+	g.CondBr(g.ICmp(ir.EQ, g.Load(c), ir.Int(ir.I32, '-')), minus, first)
+
+	g.SetBlock(minus)
+	g.Store(ir.Int(ir.I32, 1), neg)
+	g.Store(g.Call(rt.funcs[getcFunc]), c)
+	g.Br(first)
+
+	g.SetBlock(first)
+	rt.branchDigit(g.Load(c), digits, fail)
+
+	g.SetBlock(fail)
+	g.Store(g.Load(c), peek)
+	g.Store(ir.Int(ir.I32, 0), ok)
+	g.Ret(ir.Int(ir.I32, 0))
+
+	// ------- digits
+	g.SetBlock(digits)
+	d := g.Sub(g.Load(c), ir.Int(ir.I32, '0'))
+	g.Store(g.Add(g.Mul(g.Load(x), ir.Int(ir.I32, 10)), d), x)
+	g.Store(g.Call(rt.funcs[getcFunc]), c)
+	rt.branchDigit(g.Load(c), digits, blank)
+
+	// ------- blanks through the end of the line
+	g.SetBlock(blank)
+	rt.branchIn(g.Load(c), " \t\r", skipBlank, end)
+
+	g.SetBlock(skipBlank)
+	g.Store(g.Call(rt.funcs[getcFunc]), c)
+	g.Br(blank)
+
+	g.SetBlock(end)
+	g.CondBr(g.ICmp(ir.EQ, g.Load(c), ir.Int(ir.I32, '\n')), done, unget)
+
+	g.SetBlock(unget)
+	g.Store(g.Load(c), peek)
+	g.Br(done)
+
+	g.SetBlock(done)
+	g.Store(ir.Int(ir.I32, 1), ok)
+	g.CondBr(g.ICmp(ir.NE, g.Load(neg), ir.Int(ir.I32, 0)), negate, positive)
+
+	g.SetBlock(negate)
+	g.Ret(g.Sub(ir.Int(ir.I32, 0), g.Load(x)))
+
+	g.SetBlock(positive)
+	g.Ret(g.Load(x))
+}
+
+// genReadChar generates read_char.
+func (rt *runtime) genReadChar() {
+	g := rt.g
+	_, ok := rt.input()
+	g.SetFunc(rt.funcs["read_char"])
+	c := g.Call(rt.funcs[getcFunc])
+	g.Store(g.ZExt(g.ICmp(ir.NE, c, ir.Int(ir.I32, -1)), ir.I32), ok)
+	g.Ret(c)
+}
+
+// genReadLine generates read_line, which stores the line into a buffer allocated by malloc.
+// The buffer is doubled by realloc when it is full.
+func (rt *runtime) genReadLine() {
+	g := rt.g
+	_, ok := rt.input()
+	g.SetFunc(rt.funcs["read_line"])
+	size := g.Module.Layout().IntPtr()
+	c := g.Alloca(ir.I32)
+	n := g.Alloca(ir.I32)
+	capacity := g.Alloca(ir.I32)
+	buf := g.Alloca("i8*")
+	g.Store(g.Call(rt.libc("malloc"), ir.Int(size, lineCap)), buf)
+	g.Store(ir.Int(ir.I32, 0), n)
+	g.Store(ir.Int(ir.I32, lineCap), capacity)
+
+	// reading fails only if the input ends before the line.
+	g.Store(g.Call(rt.funcs[getcFunc]), c)
+	g.Store(g.ZExt(g.ICmp(ir.NE, g.Load(c), ir.Int(ir.I32, -1)), ir.I32), ok)
+	loop, check, room, grow, put, end := g.NewBlock(), g.NewBlock(), g.NewBlock(), g.NewBlock(), g.NewBlock(), g.NewBlock()
+	g.Br(loop)
+
+	g.SetBlock(loop)
+	g.CondBr(g.ICmp(ir.EQ, g.Load(c), ir.Int(ir.I32, -1)), end, check)
+
+	g.SetBlock(check)
+	g.CondBr(g.ICmp(ir.EQ, g.Load(c), ir.Int(ir.I32, '\n')), end, room)
+
+	// one byte is left for the terminating null.
+	g.SetBlock(room)
+	g.CondBr(g.ICmp(ir.EQ, g.Add(g.Load(n), ir.Int(ir.I32, 1)), g.Load(capacity)), grow, put)
+
+	g.SetBlock(grow)
+	g.Store(g.Mul(g.Load(capacity), ir.Int(ir.I32, 2)), capacity)
+	g.Store(g.Call(rt.libc("realloc"), g.Load(buf), g.Coerce(g.Load(capacity), ir.I32, size)), buf)
+	g.Br(put)
+
+	g.SetBlock(put)
+	g.Store(g.Cast(ir.OpTrunc, g.Load(c), ir.I8), g.GEP(g.Load(buf), g.Load(n)))
+	g.Store(g.Add(g.Load(n), ir.Int(ir.I32, 1)), n)
+	g.Store(g.Call(rt.funcs[getcFunc]), c)
+	g.Br(loop)
+
+	g.SetBlock(end)
+	g.Store(ir.Int(ir.I8, 0), g.GEP(g.Load(buf), g.Load(n)))
+	g.Ret(g.Load(buf))
+}
+
+// genReadOK generates read_ok.
+func (rt *runtime) genReadOK() {
+	g := rt.g
+	_, ok := rt.input()
+	g.SetFunc(rt.funcs["read_ok"])
+	g.Ret(g.Load(ok))
+}
+
+// genEOF generates eof, which reads a byte ahead to see whether the input ends.
+func (rt *runtime) genEOF() {
+	g := rt.g
+	peek, _ := rt.input()
+	g.SetFunc(rt.funcs["eof"])
+	c := g.Call(rt.funcs[getcFunc])
+	g.Store(c, peek)
+	g.Ret(g.ZExt(g.ICmp(ir.EQ, c, ir.Int(ir.I32, -1)), ir.I32))
+}
+
+// genTrap generates yuni_trap, which writes the message into stderr and aborts.
+// Output written before is flushed, so that it comes before the message.
+// In debug builds, the backtrace follows the message from the innermost function.
+func (rt *runtime) genTrap() {
+	g := rt.g
+	trap := rt.funcs[trapFunc]
+	g.SetFunc(trap)
+	ps := trap.Params
+	g.Call(rt.libc("fflush"), ir.Null("i8*"))
+	g.Call(rt.libc("dprintf"), ir.Int(ir.I32, 2), g.CString("%s: runtime error: %s%s\n"), ps[0], ps[1], ps[2])
+	if g.Debugging() {
+		rt.genBacktrace()
+	}
+	g.Call(rt.libc("abort"))
+	g.Unreachable()
+}
+
+// genBacktrace generates printing names of functions on the shadow stack from the top.
+func (rt *runtime) genBacktrace() {
+	g := rt.g
+	frames, depth := rt.shadowStack()
+	g.Call(rt.libc("dprintf"), ir.Int(ir.I32, 2), g.CString("backtrace:\n"))
+	i := g.Alloca(ir.I32)
+	g.Store(g.Load(depth), i)
+	loop, next, print, done := g.NewBlock(), g.NewBlock(), g.NewBlock(), g.NewBlock()
+	g.Br(loop)
+
+	g.SetBlock(loop)
+	g.Store(g.Sub(g.Load(i), ir.Int(ir.I32, 1)), i)
+	g.CondBr(g.ICmp(ir.SLT, g.Load(i), ir.Int(ir.I32, 0)), done, next)
+
+	// frames deeper than the shadow stack are not held.
+	g.SetBlock(next)
+	g.CondBr(g.ICmp(ir.SLT, g.Load(i), ir.Int(ir.I32, maxBacktrace)), print, loop)
+
+	g.SetBlock(print)
+	name := g.Load(g.GEP(frames, ir.Int(ir.I32, 0), g.Load(i)))
+	g.Call(rt.libc("dprintf"), ir.Int(ir.I32, 2), g.CString("    %s\n"), name)
+	g.Br(loop)
+
+	g.SetBlock(done)
+}
+
+// genEnter generates yuni_enter, which pushes the name of a function on the shadow stack.
+func (rt *runtime) genEnter() {
+	g := rt.g
+	enter := rt.funcs[enterFunc]
+	frames, depth := rt.shadowStack()
+	g.SetFunc(enter)
+	d := g.Load(depth)
+	push, end := g.NewBlock(), g.NewBlock()
+	g.CondBr(g.ICmp(ir.SLT, d, ir.Int(ir.I32, maxBacktrace)), push, end)
+
+	g.SetBlock(push)
+	g.Store(enter.Params[0], g.GEP(frames, ir.Int(ir.I32, 0), d))
+	g.Br(end)
+
+	g.SetBlock(end)
+	g.Store(g.Add(d, ir.Int(ir.I32, 1)), depth)
+	g.Ret(nil)
+}
+
+// genLeave generates yuni_leave, which pops the function on the top of the shadow stack.
+func (rt *runtime) genLeave() {
+	g := rt.g
+	_, depth := rt.shadowStack()
+	g.SetFunc(rt.funcs[leaveFunc])
+	g.Store(g.Sub(g.Load(depth), ir.Int(ir.I32, 1)), depth)
+	g.Ret(nil)
+}
+
+// branchIn branches to then if the byte c is one of cs, otherwise to els.
+func (rt *runtime) branchIn(c ir.Value, cs string, then, els *ir.BasicBlock) {
+	g := rt.g
+	for i := 0; i < len(cs); i++ {
+		next := els
+		if i < len(cs)-1 {
+			next = g.NewBlock()
+		}
+		g.CondBr(g.ICmp(ir.EQ, c, ir.Int(ir.I32, int(cs[i]))), then, next)
+		if next != els {
+			g.SetBlock(next)
+		}
+	}
+}
+
+// branchDigit branches to then if the byte c is a decimal digit, otherwise to els.
+func (rt *runtime) branchDigit(c ir.Value, then, els *ir.BasicBlock) {
+	g := rt.g
+	low := g.NewBlock()
+	g.CondBr(g.ICmp(ir.SGE, c, ir.Int(ir.I32, '0')), low, els)
+	g.SetBlock(low)
+	g.CondBr(g.ICmp(ir.SLE, c, ir.Int(ir.I32, '9')), then, els)
+}
@@ -0,0 +1,694 @@
+package gen
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/yuniruyuni/lang/ast"
+	"github.com/yuniruyuni/lang/ir"
+	"github.com/yuniruyuni/lang/libc"
+)
+
+const (
+	// wasmPage is the size of a page of WebAssembly memory.
+	wasmPage = 65536
+	// wasmDataBase is the address of the first string, leaving 0 as the null pointer.
+	wasmDataBase = 8
+	// wasmVAStack is the space left for arguments of variadic functions after strings.
+	wasmVAStack = 16384
+	// wasmVASlot is the size of a variadic argument in memory.
+	wasmVASlot = 8
+	// wasmImportModule is the module which external functions are imported from.
+	wasmImportModule = "env"
+)
+
+// WATFile generates a WebAssembly module in the text format for a program.
+//
+// External functions and the runtime functions like `read_int` are imported from the host by the module "env",
+// and the module exports its memory as "memory" and functions of the root module like "main".
+// A runtime error like division by zero calls the imported `yuni_trap` with the position, the reason and the detail of it.
+// A variadic function like `printf(fmt: *u8, ...)` is imported taking its fixed parameters
+// and a pointer to the variadic arguments, each of which is stored into 8 bytes in memory
+// as a little endian integer, and a string is the address of its null terminated bytes.
+type WATFile struct {
+	AST ast.AST
+}
+
+// Generate builds the whole WebAssembly module for the program.
+// It needs LLFile for types of nodes and initial values of globals,
+// so an invalid program panics with the same error as LLFile.
+func (w *WATFile) Generate() string {
+	ll := LLFile{AST: w.AST}
+	m := ll.Generate()
+	g := newWATGen(m, ll.Info)
+
+	prog, ok := w.AST.(*ast.Program)
+	if !ok {
+		panic(fmt.Errorf("%T cannot be generated into WebAssembly", w.AST))
+	}
+	for _, m := range prog.Modules {
+		g.declare(m.(*ast.Module))
+	}
+	for _, m := range prog.Modules {
+		g.genFuncs(m.(*ast.Module))
+	}
+	g.genDivs()
+	return g.String()
+}
+
+// watgen walks the AST and writes a WebAssembly module.
+// Every expression pushes exactly one value onto the operand stack.
+type watgen struct {
+	module *ir.Module
+	// info holds types and callees resolved by LLFile.
+	info *ast.Info
+
+	// sections of the output.
+	globals, funcs strings.Builder
+	// types names signatures of functions called indirectly.
+	types     map[string]string
+	typeOrder []string
+	// table lists functions used as values, whose index is the function value.
+	table      []string
+	tableIndex map[string]int
+	// indirect is true if the module calls a function value, which requires the table.
+	indirect bool
+	// data holds strings placed from wasmDataBase, and strs maps literals to their addresses.
+	data []byte
+	strs map[string]int
+	// usesVA is true if the module calls a variadic function.
+	usesVA bool
+	// divs holds types which the module divides in.
+	divs map[ir.Type]bool
+
+	// module level names of yuni for functions and globals.
+	funcNames   map[ast.Name]bool
+	globalNames map[ast.Name]bool
+	// externs holds types of external functions, and imported lists ones called or referred.
+	externs  map[ast.Name]ir.Type
+	imported []ast.Name
+	used     map[ast.Name]bool
+
+	// file is the source of the module generating now, to report positions of runtime errors.
+	file *ast.File
+	// locals maps variables of the function generating now to their names,
+	// and names holds names which the function uses.
+	locals map[ast.Name]string
+	names  map[string]bool
+	vars   []string
+	body   []string
+	// indent is the depth of nested blocks in the body.
+	indent int
+	// labels counts labels of loops in the function.
+	labels int
+}
+
+func newWATGen(m *ir.Module, info *ast.Info) *watgen {
+	return &watgen{
+		module:      m,
+		info:        info,
+		types:       map[string]string{},
+		tableIndex:  map[string]int{},
+		strs:        map[string]int{},
+		funcNames:   map[ast.Name]bool{},
+		globalNames: map[ast.Name]bool{},
+		externs:     runtimeImports(),
+		used:        map[ast.Name]bool{},
+		divs:        map[ir.Type]bool{},
+	}
+}
+
+// runtimeImports returns types of the runtime functions imported from the host.
+// Functions resulting a string like `read_line` are left out,
+// since the host cannot allocate memory of the module.
+func runtimeImports() map[ast.Name]ir.Type {
+	ts := map[ast.Name]ir.Type{}
+	for _, f := range runtimeFuncs {
+		if f.typ.Return().IsInt() {
+			ts[f.name] = f.typ
+		}
+	}
+	return ts
+}
+
+// String joins the sections of the output.
+// Imports come first as WebAssembly requires them before other definitions.
+func (g *watgen) String() string {
+	b := strings.Builder{}
+	b.WriteString("(module\n")
+	for _, sig := range g.typeOrder {
+		fmt.Fprintf(&b, "  (type %s (func%s))\n", g.types[sig], sig)
+	}
+	for _, n := range g.imported {
+		fmt.Fprintf(&b, "  (import %q %q (func $%s%s))\n", wasmImportModule, n, n, g.signature(g.externs[n]))
+	}
+
+	end := wasmDataBase + len(g.data) + wasmVAStack
+	pages := (end + wasmPage - 1) / wasmPage
+	fmt.Fprintf(&b, "  (memory (export \"memory\") %d)\n", pages)
+	if g.usesVA {
+		fmt.Fprintf(&b, "  (global $.va (mut i32) (i32.const %d))\n", pages*wasmPage)
+	}
+	b.WriteString(g.globals.String())
+
+	if len(g.table) > 0 || g.indirect {
+		fmt.Fprintf(&b, "  (table %d funcref)\n", len(g.table))
+	}
+	if len(g.table) > 0 {
+		fmt.Fprintf(&b, "  (elem (i32.const 0) func %s)\n", strings.Join(g.table, " "))
+	}
+	b.WriteString(g.funcs.String())
+	if len(g.data) > 0 {
+		fmt.Fprintf(&b, "  (data (i32.const %d) \"%s\")\n", wasmDataBase, watString(g.data))
+	}
+	b.WriteString(")\n")
+	return b.String()
+}
+
+// declare registers functions, external functions and globals in m.
+func (g *watgen) declare(m *ast.Module) {
+	for _, d := range defs(m) {
+		switch d := d.(type) {
+		case *ast.Func:
+			g.funcNames[m.Qualify(d.Name())] = true
+		case *ast.Extern:
+			g.externs[d.Name()] = d.FuncType()
+		case *ast.Const, *ast.Global:
+			n := m.Qualify(d.Name())
+			g.globalNames[n] = true
+
+			t := "i32"
+			if _, ok := d.(*ast.Global); ok {
+				t = "(mut i32)"
+			}
+			init := g.module.Global(string(n)).Init
+			fmt.Fprintf(&g.globals, "  (global $%s %s (i32.const %s))\n", n, t, init)
+		}
+	}
+}
+
+// signature writes parameters and the result of the function pointer type t.
+// Variadic arguments are passed by a pointer to them, and a void function has no result.
+func (g *watgen) signature(t ir.Type) string {
+	b := strings.Builder{}
+	for _, p := range t.Params() {
+		if p == "..." {
+			p = ir.PointerTo(ir.I8)
+		}
+		fmt.Fprintf(&b, " (param %s)", wasmType(p))
+	}
+	if t.Return() != ir.Void {
+		fmt.Fprintf(&b, " (result %s)", wasmType(t.Return()))
+	}
+	return b.String()
+}
+
+// typeOf names the signature of the function pointer type t for `call_indirect`.
+func (g *watgen) typeOf(t ir.Type) string {
+	sig := g.signature(t)
+	if n, ok := g.types[sig]; ok {
+		return n
+	}
+	n := fmt.Sprintf("$.sig%d", len(g.typeOrder))
+	g.types[sig] = n
+	g.typeOrder = append(g.typeOrder, sig)
+	return n
+}
+
+// genFuncs writes functions defined in m.
+func (g *watgen) genFuncs(m *ast.Module) {
+	g.file = m.File
+	for _, d := range defs(m) {
+		f, ok := d.(*ast.Func)
+		if !ok {
+			continue
+		}
+
+		g.locals = map[ast.Name]string{}
+		g.names = map[string]bool{}
+		g.vars, g.body, g.indent, g.labels = nil, nil, 0, 0
+
+		n := m.Qualify(f.Name())
+		fmt.Fprintf(&g.funcs, "  (func $%s", n)
+		if m.ModName == "" {
+			fmt.Fprintf(&g.funcs, " (export %q)", f.Name())
+		}
+		for _, p := range f.Params.(*ast.Params).Vars {
+			g.locals[p.Name()] = string(p.Name())
+			g.names[string(p.Name())] = true
+			fmt.Fprintf(&g.funcs, " (param $%s %s)", p.Name(), wasmType(p.(*ast.Param).Type()))
+		}
+		fmt.Fprintf(&g.funcs, " (result %s)\n", wasmType(f.Type()))
+
+		g.expr(f.Execute)
+		g.coerce(g.info.TypeOf(f.Execute), f.Type())
+
+		for _, v := range g.vars {
+			fmt.Fprintf(&g.funcs, "    %s\n", v)
+		}
+		for _, l := range g.body {
+			fmt.Fprintf(&g.funcs, "    %s\n", l)
+		}
+		g.funcs.WriteString("  )\n")
+	}
+}
+
+// emit appends an instruction to the body.
+func (g *watgen) emit(format string, args ...interface{}) {
+	g.body = append(g.body, strings.Repeat("  ", g.indent)+fmt.Sprintf(format, args...))
+}
+
+// local declares a new local variable typed t, naming it after base.
+func (g *watgen) local(base string, t ir.Type) string {
+	n := base
+	for i := 1; g.names[n]; i++ {
+		n = fmt.Sprintf("%s.%d", base, i)
+	}
+	g.names[n] = true
+	g.vars = append(g.vars, fmt.Sprintf("(local $%s %s)", n, wasmType(t)))
+	return n
+}
+
+// temp declares a new temporary typed t, whose name cannot collide with variables of yuni.
+func (g *watgen) temp(t ir.Type) string {
+	return g.local(".t", t)
+}
+
+// expr emits n which pushes exactly one value.
+func (g *watgen) expr(n ast.AST) {
+	switch n := n.(type) {
+	case *ast.Integer:
+		g.emit("i32.const %d", int32(n.Value))
+	case *ast.String:
+		g.emit("i32.const %d", g.str(n.Word))
+	case *ast.Variable:
+		g.load(n)
+	case *ast.Let:
+		g.expr(n.RHS)
+		l := g.local(string(n.Name()), g.info.TypeOf(n))
+		g.locals[n.Name()] = l
+		g.emit("local.tee $%s", l)
+	case *ast.Assign:
+		g.expr(n.RHS)
+		g.coerce(g.info.TypeOf(n.RHS), g.info.TypeOf(n))
+		if l, ok := g.locals[n.Name()]; ok {
+			g.emit("local.tee $%s", l)
+			return
+		}
+		r := g.info.Names[n]
+		g.emit("global.set $%s", r)
+		g.emit("global.get $%s", r)
+	case *ast.Sequence:
+		g.expr(n.LHS)
+		g.emit("drop")
+		g.expr(n.RHS)
+	case *ast.If:
+		g.cond(n.Cond)
+		g.emit("if (result %s)", wasmType(g.info.TypeOf(n)))
+		g.indent++
+		g.expr(n.Then)
+		g.coerce(g.info.TypeOf(n.Then), g.info.TypeOf(n))
+		g.indent--
+		g.emit("else")
+		g.indent++
+		g.expr(n.Else)
+		g.coerce(g.info.TypeOf(n.Else), g.info.TypeOf(n))
+		g.indent--
+		g.emit("end")
+	case *ast.While:
+		g.loop(n)
+	case *ast.Call:
+		g.call(n)
+	case *ast.Add:
+		g.binary(n.LHS, n.RHS, "add")
+	case *ast.Sub:
+		g.binary(n.LHS, n.RHS, "sub")
+	case *ast.Mul:
+		g.binary(n.LHS, n.RHS, "mul")
+	case *ast.Div:
+		g.divide(n)
+	case *ast.Less:
+		g.binary(n.LHS, n.RHS, "lt_s")
+	case *ast.Equal:
+		g.binary(n.LHS, n.RHS, "eq")
+	default:
+		panic(fmt.Errorf("%T cannot be generated into WebAssembly", n))
+	}
+}
+
+// binary emits op on lhs and rhs.
+func (g *watgen) binary(lhs, rhs ast.AST, op string) {
+	t := g.operands(lhs, rhs)
+	g.emit("%s.%s", wasmType(t), op)
+}
+
+// operands emits lhs and rhs and returns the type which they are computed in,
+// which is i64 if either of them is i64, otherwise i32.
+func (g *watgen) operands(lhs, rhs ast.AST) ir.Type {
+	t := ir.I32
+	if g.info.TypeOf(lhs) == ir.I64 || g.info.TypeOf(rhs) == ir.I64 {
+		t = ir.I64
+	}
+	g.expr(lhs)
+	g.coerce(g.info.TypeOf(lhs), t)
+	g.expr(rhs)
+	g.coerce(g.info.TypeOf(rhs), t)
+	return t
+}
+
+// divide emits n by calling the division function for its type with the position of n.
+// `div_s` itself isn't used, since it traps without the position and on the minimum integer divided by -1.
+func (g *watgen) divide(n *ast.Div) {
+	t := g.operands(n.LHS, n.RHS)
+	g.divs[t] = true
+	g.emit("i32.const %d", g.str(g.where(n)))
+	g.emit("call $%s", watDivName(t))
+}
+
+// watDiv is the division function, which reports division by zero at the position given by the caller
+// and wraps the minimum integer divided by -1 around as other backends do.
+// It takes the name, the value type and addresses of the message of the error.
+const watDiv = `  (func $%[1]s (param $x %[2]s) (param $y %[2]s) (param $where i32) (result %[2]s)
+    local.get $y
+    %[2]s.eqz
+    if (result %[2]s)
+      local.get $where
+      i32.const %[3]d
+      i32.const %[4]d
+      call $%[5]s
+      unreachable
+    else
+      local.get $y
+      %[2]s.const -1
+      %[2]s.eq
+      if (result %[2]s)
+        %[2]s.const 0
+        local.get $x
+        %[2]s.sub
+      else
+        local.get $x
+        local.get $y
+        %[2]s.div_s
+      end
+    end
+  )
+`
+
+// genDivs writes division functions for types which the module divides in,
+// importing yuni_trap from the host to report division by zero.
+func (g *watgen) genDivs() {
+	for _, t := range []ir.Type{ir.I32, ir.I64} {
+		if !g.divs[t] {
+			continue
+		}
+		if !g.used[trapFunc] {
+			g.used[trapFunc] = true
+			g.externs[trapFunc] = ir.FuncType(ir.Void, []ir.Type{"i8*", "i8*", "i8*"}, false)
+			g.imported = append(g.imported, trapFunc)
+		}
+		fmt.Fprintf(&g.funcs, watDiv, watDivName(t), wasmType(t), g.str("division by zero"), g.str(""), trapFunc)
+	}
+}
+
+// watDivName names the division function for t, which cannot collide with functions of yuni.
+func watDivName(t ir.Type) string {
+	if t == ir.I64 {
+		return ".div64"
+	}
+	return ".div"
+}
+
+// cond emits n as a condition, which is an i32 being non-zero if it holds.
+func (g *watgen) cond(n ast.AST) {
+	g.expr(n)
+	if wasmType(g.info.TypeOf(n)) == "i64" {
+		g.emit("i64.eqz")
+		g.emit("i32.eqz")
+	}
+}
+
+// loop emits the while loop n, which sets the value of every iteration into a temporary
+// and pushes it after the loop, or zero if the condition fails first.
+func (g *watgen) loop(n *ast.While) {
+	t := wasmType(g.info.TypeOf(n))
+	res := g.temp(g.info.TypeOf(n))
+	exit := fmt.Sprintf("$.exit%d", g.labels)
+	cont := fmt.Sprintf("$.cont%d", g.labels)
+	g.labels++
+
+	g.emit("%s.const 0", t)
+	g.emit("local.set $%s", res)
+	g.emit("block %s", exit)
+	g.indent++
+	g.emit("loop %s", cont)
+	g.indent++
+	g.cond(n.Cond)
+	g.emit("i32.eqz")
+	g.emit("br_if %s", exit)
+	g.expr(n.Proc)
+	g.emit("local.set $%s", res)
+	g.emit("br %s", cont)
+	g.indent--
+	g.emit("end")
+	g.indent--
+	g.emit("end")
+	g.emit("local.get $%s", res)
+}
+
+// call emits a call of the function named by n, or the function value held in the variable.
+// Arguments are converted to their parameter types as code generation does.
+func (g *watgen) call(n *ast.Call) {
+	callee := g.info.Calls[n]
+	switch callee.Builtin {
+	case "":
+	case ast.PanicBuiltin, ast.AssertBuiltin:
+		panic(fmt.Errorf("%s cannot be generated into WebAssembly", callee.Builtin))
+	default:
+		g.print(n, callee.Builtin)
+		return
+	}
+	t := callee.FuncType
+	params := t.Params()
+	args := n.Args.(*ast.Args).Values
+
+	fixed := len(params)
+	if t.IsVariadic() {
+		fixed--
+	}
+	for i, a := range args[:fixed] {
+		g.expr(a)
+		g.coerce(g.info.TypeOf(a), params[i])
+	}
+
+	if t.IsVariadic() {
+		g.varargs(args[fixed:])
+	}
+
+	if _, ok := g.locals[n.FuncName.Name()]; ok || g.globalNames[g.info.Names[n.FuncName]] {
+		g.indirect = true
+		g.load(n.FuncName)
+		g.emit("call_indirect (type %s)", g.typeOf(t))
+	} else {
+		g.emit("call $%s", g.function(g.info.Names[n.FuncName]))
+	}
+
+	if t.IsVariadic() {
+		g.releaseVA(len(args[fixed:]))
+	}
+}
+
+// print emits the builtin `print` or `println` called by n as printf
+// with the format for the types of its arguments, which results 0.
+func (g *watgen) print(n *ast.Call, b ast.Name) {
+	values := n.Args.(*ast.Args).Values
+	format := ""
+	for _, a := range values {
+		switch t := g.info.TypeOf(a); {
+		case t == ir.I1:
+			format += "%s"
+		case t == ir.I64:
+			format += "%lld"
+		case t.IsInt():
+			format += "%d"
+		default:
+			format += "%s"
+		}
+	}
+	if b == ast.PrintlnBuiltin {
+		format += `\0A`
+	}
+
+	g.emit("i32.const %d", g.str(format))
+	temps := make([]string, len(values))
+	types := make([]ir.Type, len(values))
+	for i, a := range values {
+		g.expr(a)
+		types[i] = g.info.TypeOf(a)
+		if g.info.TypeOf(a) == ir.I1 {
+			// a bool is printed as the string chosen by it.
+			cond := g.temp(ir.I1)
+			g.emit("local.set $%s", cond)
+			g.emit("i32.const %d", g.str("true"))
+			g.emit("i32.const %d", g.str("false"))
+			g.emit("local.get $%s", cond)
+			g.emit("select")
+			types[i] = "i8*"
+		}
+		temps[i] = g.temp(types[i])
+		g.emit("local.set $%s", temps[i])
+	}
+	g.storeVA(temps, types)
+	g.emit("call $%s", g.function("printf"))
+	g.releaseVA(len(values))
+	g.emit("drop")
+	g.emit("i32.const 0")
+}
+
+// varargs stores variadic arguments args into memory and pushes the pointer to them.
+func (g *watgen) varargs(args []ast.AST) {
+	// arguments are evaluated before reserving the space,
+	// so that calls in them can use the space too.
+	temps := make([]string, len(args))
+	types := make([]ir.Type, len(args))
+	for i, a := range args {
+		g.expr(a)
+		types[i] = g.info.TypeOf(a)
+		temps[i] = g.temp(types[i])
+		g.emit("local.set $%s", temps[i])
+	}
+	g.storeVA(temps, types)
+}
+
+// storeVA reserves the space for variadic arguments held in locals temps typed types,
+// stores them into it and pushes the pointer to them.
+func (g *watgen) storeVA(temps []string, types []ir.Type) {
+	g.usesVA = true
+
+	g.emit("global.get $.va")
+	g.emit("i32.const %d", len(temps)*wasmVASlot)
+	g.emit("i32.sub")
+	g.emit("global.set $.va")
+	for i := range temps {
+		g.emit("global.get $.va")
+		g.emit("local.get $%s", temps[i])
+		if wasmType(types[i]) == "i32" {
+			g.emit("i64.extend_i32_s")
+		}
+		if i == 0 {
+			g.emit("i64.store")
+		} else {
+			g.emit("i64.store offset=%d", i*wasmVASlot)
+		}
+	}
+	g.emit("global.get $.va")
+}
+
+// releaseVA frees the space for n variadic arguments after the call using them.
+func (g *watgen) releaseVA(n int) {
+	g.emit("global.get $.va")
+	g.emit("i32.const %d", n*wasmVASlot)
+	g.emit("i32.add")
+	g.emit("global.set $.va")
+}
+
+// coerce converts the value typed from on the stack into the type to.
+// An integer becomes a bool by whether it is non-zero.
+func (g *watgen) coerce(from, to ir.Type) {
+	if from == to || !from.IsInt() || !to.IsInt() {
+		return
+	}
+	if to == ir.I1 {
+		g.emit("%s.eqz", wasmType(from))
+		g.emit("i32.eqz")
+		return
+	}
+	switch {
+	case from.Bits() <= 32 && to.Bits() == 64:
+		g.emit("i64.extend_i32_s")
+	case from.Bits() == 64 && to.Bits() <= 32:
+		g.emit("i32.wrap_i64")
+	}
+	if to.Bits() == 8 && from.Bits() > 8 {
+		g.emit("i32.extend8_s")
+	}
+}
+
+// load emits pushing the variable, global or function which n refers.
+func (g *watgen) load(n ast.AST) {
+	if l, ok := g.locals[n.Name()]; ok {
+		g.emit("local.get $%s", l)
+		return
+	}
+	r := g.info.Names[n]
+	if g.globalNames[r] {
+		g.emit("global.get $%s", r)
+		return
+	}
+
+	f := "$" + string(g.function(r))
+	i, ok := g.tableIndex[f]
+	if !ok {
+		i = len(g.table)
+		g.tableIndex[f] = i
+		g.table = append(g.table, f)
+	}
+	g.emit("i32.const %d", i)
+}
+
+// function returns the function resolved as r, importing it if it is external.
+func (g *watgen) function(r ast.Name) ast.Name {
+	if g.funcNames[r] {
+		return r
+	}
+	if _, ok := g.externs[r]; !ok && isRuntimeFunc(r) {
+		panic(fmt.Errorf("%s cannot be generated into WebAssembly", r))
+	}
+	if _, ok := g.externs[r]; !ok {
+		panic(fmt.Errorf("Function %s doesn't exist.", r))
+	}
+	if !g.used[r] {
+		g.used[r] = true
+		g.imported = append(g.imported, r)
+	}
+	return r
+}
+
+// where returns the position of n as a string literal of yuni.
+func (g *watgen) where(n ast.AST) string {
+	// str takes a string of yuni, where a backslash starts an escape.
+	return strings.ReplaceAll(ast.Where(g.file, n), `\`, `\5C`)
+}
+
+// str places the string literal of yuni s into memory and returns its address.
+func (g *watgen) str(s string) int {
+	if addr, ok := g.strs[s]; ok {
+		return addr
+	}
+	addr := wasmDataBase + len(g.data)
+	g.strs[s] = addr
+	g.data = append(g.data, libc.Unescape(s)...)
+	g.data = append(g.data, 0)
+	return addr
+}
+
+// wasmType returns the WebAssembly value type for t.
+// Pointers and function values are addresses and table indices.
+func wasmType(t ir.Type) string {
+	if t == ir.I64 {
+		return "i64"
+	}
+	return "i32"
+}
+
+// watString writes bytes b as the content of a string in the text format.
+func watString(b []byte) string {
+	s := strings.Builder{}
+	for _, c := range b {
+		if c < 0x20 || c >= 0x7f || c == '"' || c == '\\' {
+			fmt.Fprintf(&s, `\%02x`, c)
+			continue
+		}
+		s.WriteByte(c)
+	}
+	return s.String()
+}
@@ -0,0 +1,86 @@
+package gen_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"gotest.tools/assert"
+
+	"github.com/yuniruyuni/lang/conformance"
+	"github.com/yuniruyuni/lang/gen"
+	"github.com/yuniruyuni/lang/module"
+	"github.com/yuniruyuni/lang/wasm"
+)
+
+// runWATCode generates WebAssembly from code and runs it with input.
+func runWATCode(t *testing.T, code, input string) (string, int, error) {
+	t.Helper()
+	prog, err := module.New(nil).LoadSource("<test>", code)
+	assert.NilError(t, err)
+	w := gen.WATFile{AST: prog}
+
+	out := new(bytes.Buffer)
+	exit, err := wasm.Run(w.Generate(), strings.NewReader(input), out)
+	return out.String(), exit, err
+}
+
+func TestWATFile_Conformance(t *testing.T) {
+	for _, tt := range conformance.Cases {
+		t.Run(tt.Name, func(t *testing.T) {
+			out, code, err := runWATCode(t, tt.Code, tt.Input)
+			assert.NilError(t, err)
+			assert.Equal(t, tt.Want, out)
+			assert.Equal(t, tt.WantCode, code)
+		})
+	}
+}
+
+func TestWATFile_Generate_Division(t *testing.T) {
+	out, code, err := runWATCode(t, `func neg(w: i64,) -> i64 { w / (0 - 1) } func main(){ let m = 0 - 2147483647; m = m - 1; printf("%d,%ld,", m / (0 - 1), neg(9,),); m / m }`, "")
+	assert.NilError(t, err)
+	assert.Equal(t, "-2147483648,-9,", out)
+	assert.Equal(t, 1, code)
+}
+
+func TestWATFile_Generate_DivisionByZero(t *testing.T) {
+	out, _, err := runWATCode(t, `func main(){ printf("before",); let x = 0; 5 / x; 0 }`, "")
+	assert.Equal(t, "before", out)
+	assert.ErrorContains(t, err, "<test>:1:44: runtime error: division by zero")
+}
+
+func TestWATFile_Generate_Imports(t *testing.T) {
+	prog, err := module.New(nil).LoadSource("<test>", `extern func putchar(c: i32) -> i32 func main(){ putchar(read(),) }`)
+	assert.NilError(t, err)
+	w := gen.WATFile{AST: prog}
+	wat := w.Generate()
+
+	assert.Assert(t, strings.Contains(wat, `(import "env" "putchar" (func $putchar (param i32) (result i32)))`), wat)
+	assert.Assert(t, strings.Contains(wat, `(import "env" "read" (func $read (result i32)))`), wat)
+	assert.Assert(t, !strings.Contains(wat, `"printf"`), "unused externs must not be imported:\n%s", wat)
+	assert.Assert(t, strings.Contains(wat, `(func $main (export "main")`), wat)
+}
+
+func TestWATFile_Generate_ReadLine(t *testing.T) {
+	prog, err := module.New(nil).LoadSource("<test>", `func main(){ println(read_line(),); 0 }`)
+	assert.NilError(t, err)
+	w := gen.WATFile{AST: prog}
+
+	defer func() {
+		err, _ := recover().(error)
+		assert.ErrorContains(t, err, "read_line cannot be generated into WebAssembly")
+	}()
+	w.Generate()
+}
+
+func TestWATFile_Generate_Panic(t *testing.T) {
+	prog, err := module.New(nil).LoadSource("<test>", `func main(){ panic("boom",); 0 }`)
+	assert.NilError(t, err)
+	w := gen.WATFile{AST: prog}
+
+	defer func() {
+		err, _ := recover().(error)
+		assert.ErrorContains(t, err, "panic cannot be generated into WebAssembly")
+	}()
+	w.Generate()
+}
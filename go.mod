@@ -2,9 +2,9 @@ module github.com/yuniruyuni/lang
 
 go 1.17
 
-require gotest.tools v2.2.0+incompatible
-
 require (
-	github.com/google/go-cmp v0.5.7 // indirect
-	github.com/pkg/errors v0.9.1 // indirect
+	github.com/google/go-cmp v0.5.7
+	gotest.tools v2.2.0+incompatible
 )
+
+require github.com/pkg/errors v0.9.1 // indirect
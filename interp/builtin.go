@@ -0,0 +1,157 @@
+package interp
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/yuniruyuni/lang/ast"
+	"github.com/yuniruyuni/lang/ir"
+	"github.com/yuniruyuni/lang/libc"
+)
+
+// builtins implements the runtime functions and external functions from libc which programs can call.
+var builtins = map[ast.Name]func(ip *Interp, args []Value) Value{
+	"read":      input((*libc.Input).ReadInt),
+	"read_int":  input((*libc.Input).ReadInt),
+	"read_char": input((*libc.Input).ReadChar),
+	"read_line": readLine,
+	"read_ok":   input((*libc.Input).OK),
+	"eof":       input((*libc.Input).EOF),
+	"printf":    printf,
+	"putchar":   putchar,
+	"abs":       abs,
+	"labs":      abs,
+}
+
+// unsupported makes a builtin for the external function n which the interpreter doesn't implement.
+func unsupported(n ast.Name) func(ip *Interp, args []Value) Value {
+	return func(ip *Interp, args []Value) Value {
+		panic(&RuntimeError{Reason: fmt.Sprintf("extern function %s is not supported by the interpreter", n)})
+	}
+}
+
+// input makes a builtin of the runtime function f which reads stdin into an integer.
+func input(f func(in *libc.Input) int64) func(ip *Interp, args []Value) Value {
+	return func(ip *Interp, args []Value) Value {
+		return f(ip.in)
+	}
+}
+
+// readLine results the line as a string value, since strings of the interpreter are Go strings.
+func readLine(ip *Interp, args []Value) Value {
+	return ip.in.ReadLine()
+}
+
+func printf(ip *Interp, args []Value) Value {
+	format, ok := args[0].(string)
+	if !ok {
+		panic(&RuntimeError{Reason: "the format of printf must be a string"})
+	}
+	s, err := libc.Sprintf(format, &valueArgs{args: args[1:]})
+	ip.out.WriteString(s)
+	if err != nil {
+		panic(&RuntimeError{Reason: "printf: " + err.Error()})
+	}
+	return int64(len(s))
+}
+
+func putchar(ip *Interp, args []Value) Value {
+	c := toInt(args[0])
+	ip.out.WriteByte(byte(c))
+	return c
+}
+
+func abs(ip *Interp, args []Value) Value {
+	x := toInt(args[0])
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// print implements the builtins `print` and `println` called by n,
+// which format arguments by their types.
+func (ip *Interp) print(fr *frame, n *ast.Call, b ast.Name) Value {
+	for _, a := range n.Args.(*ast.Args).Values {
+		v := ip.eval(fr, a)
+		switch t := ip.info.TypeOf(a); {
+		case t == ir.I1:
+			ip.out.WriteString(strconv.FormatBool(truthy(v)))
+		case t.IsInt():
+			ip.out.WriteString(strconv.FormatInt(toInt(v), 10))
+		default:
+			s, ok := v.(string)
+			if !ok {
+				panic(&RuntimeError{Reason: fmt.Sprintf("%v cannot be printed", v)})
+			}
+			ip.out.WriteString(libc.Unescape(s))
+		}
+	}
+	if b == ast.PrintlnBuiltin {
+		ip.out.WriteByte('\n')
+	}
+	return int64(0)
+}
+
+// assert implements the builtin `assert` called by n,
+// which stops the program if the condition is false.
+// Every argument is evaluated before the check as code generation does.
+func (ip *Interp) assert(fr *frame, n *ast.Call) Value {
+	ok := truthy(ip.eval(fr, n.Args.(*ast.Args).Values[0]))
+	reason := "assertion failed"
+	if len(n.Args.(*ast.Args).Values) == 2 {
+		reason += ": " + ip.message(fr, n, 1)
+	}
+	if !ok {
+		panic(ip.fail(fr, n, reason))
+	}
+	return int64(0)
+}
+
+// message evaluates the i-th argument of n as a string and unescapes it as it is printed.
+func (ip *Interp) message(fr *frame, n *ast.Call, i int) string {
+	v := ip.eval(fr, n.Args.(*ast.Args).Values[i])
+	s, ok := v.(string)
+	if !ok {
+		panic(&RuntimeError{Reason: fmt.Sprintf("%v is not a string", v)})
+	}
+	return libc.Unescape(s)
+}
+
+// valueArgs reads arguments of printf from values.
+type valueArgs struct {
+	args []Value
+}
+
+func (a *valueArgs) next() (Value, error) {
+	if len(a.args) == 0 {
+		return nil, libc.ErrTooFewArgs
+	}
+	v := a.args[0]
+	a.args = a.args[1:]
+	return v, nil
+}
+
+func (a *valueArgs) Int() (int64, error) {
+	v, err := a.next()
+	if err != nil {
+		return 0, err
+	}
+	x, ok := v.(int64)
+	if !ok {
+		return 0, fmt.Errorf("%v is not an integer", v)
+	}
+	return x, nil
+}
+
+func (a *valueArgs) String() (string, error) {
+	v, err := a.next()
+	if err != nil {
+		return "", err
+	}
+	s, ok := v.(string)
+	if !ok {
+		return "", fmt.Errorf("%v is not a string", v)
+	}
+	return s, nil
+}
@@ -0,0 +1,334 @@
+// Package interp runs a program by walking its AST directly.
+// It follows the semantics of the code generated for LLVM,
+// so integers wrap around at their width and `printf` and `read` behave like the C runtime.
+package interp
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/yuniruyuni/lang/ast"
+	"github.com/yuniruyuni/lang/libc"
+)
+
+// Value is a value at runtime.
+// It is an int64 for every integer type, a string for a pointer to a string literal
+// and a *Function for a function.
+type Value interface{}
+
+// Function is a function which can be called at runtime.
+type Function struct {
+	Name ast.Name
+	// Def is the definition of a function written in yuni, nil for a builtin.
+	Def *ast.Func
+	// Builtin implements an external function like `printf`.
+	Builtin func(in *Interp, args []Value) Value
+
+	// module is the module where Def is written.
+	module *ast.Module
+}
+
+// Interp holds the state of a running program.
+type Interp struct {
+	in  *libc.Input
+	out *bufio.Writer
+	// info holds types and callees resolved by code generation.
+	info *ast.Info
+
+	funcs   map[ast.Name]*Function
+	globals map[ast.Name]Value
+	consts  map[ast.Name]bool
+	// stack holds names of functions being called now, the innermost last.
+	stack []ast.Name
+}
+
+// maxDepth limits nested calls so that deep recursion fails like a stack overflow of native code
+// before it exhausts the stack of Go.
+const maxDepth = 200000
+
+// frame holds variables of a function call.
+type frame struct {
+	module *ast.Module
+	vars   map[ast.Name]Value
+}
+
+// RuntimeError is an error which stops the running program, like division by zero.
+type RuntimeError struct {
+	// Where is the position of the failure like `main.yuni:3:5`, or empty if it is unknown.
+	Where  string
+	Reason string
+	// Backtrace lists names of functions being called at the failure, the innermost first.
+	Backtrace []ast.Name
+}
+
+func (e *RuntimeError) Error() string {
+	msg := "runtime error: " + e.Reason
+	if e.Where != "" {
+		msg = e.Where + ": " + msg
+	}
+	if len(e.Backtrace) > 0 {
+		msg += "\nbacktrace:"
+		for _, n := range e.Backtrace {
+			msg += "\n    " + string(n)
+		}
+	}
+	return msg
+}
+
+// Run runs `main` of prog reading stdin from in and writing stdout into out,
+// and returns the value `main` returns.
+// prog must have passed code generation, which checks it and resolves the types of its nodes into info.
+func Run(prog *ast.Program, info *ast.Info, in io.Reader, out io.Writer) (code int, err error) {
+	w := bufio.NewWriter(out)
+	ip := &Interp{
+		info:    info,
+		in:      libc.NewInput(in, w),
+		out:     w,
+		funcs:   map[ast.Name]*Function{},
+		globals: map[ast.Name]Value{},
+		consts:  map[ast.Name]bool{},
+	}
+	defer ip.out.Flush()
+
+	// errors at runtime are reported by panicking with a *RuntimeError.
+	defer func() {
+		if r := recover(); r != nil {
+			e, ok := r.(*RuntimeError)
+			if !ok {
+				panic(r)
+			}
+			err = e
+		}
+	}()
+
+	for n, f := range builtins {
+		ip.funcs[n] = &Function{Name: n, Builtin: f}
+	}
+	for _, m := range prog.Modules {
+		ip.define(m.(*ast.Module))
+	}
+
+	main, ok := ip.funcs["main"]
+	if !ok {
+		return 0, errors.New("function main doesn't exist")
+	}
+	return int(int32(toInt(ip.call(main, nil)))), nil
+}
+
+// define registers module level definitions of m.
+func (ip *Interp) define(m *ast.Module) {
+	fr := &frame{module: m, vars: map[ast.Name]Value{}}
+	for _, d := range m.Defs.(*ast.Definitions).Defs {
+		if p, ok := d.(*ast.Pub); ok {
+			d = p.Def
+		}
+
+		switch d := d.(type) {
+		case *ast.Func:
+			n := m.Qualify(d.Name())
+			ip.funcs[n] = &Function{Name: n, Def: d, module: m}
+		case *ast.Extern:
+			if _, ok := ip.funcs[d.Name()]; !ok {
+				ip.funcs[d.Name()] = &Function{Name: d.Name(), Builtin: unsupported(d.Name())}
+			}
+		case *ast.Const:
+			n := m.Qualify(d.Name())
+			ip.globals[n] = ip.eval(fr, d.RHS)
+			ip.consts[n] = true
+		case *ast.Global:
+			ip.globals[m.Qualify(d.Name())] = ip.eval(fr, d.RHS)
+		}
+	}
+}
+
+// call calls f with args.
+func (ip *Interp) call(f *Function, args []Value) Value {
+	if f.Builtin != nil {
+		return f.Builtin(ip, args)
+	}
+
+	ip.stack = append(ip.stack, f.Name)
+	defer func() { ip.stack = ip.stack[:len(ip.stack)-1] }()
+	if len(ip.stack) > maxDepth {
+		panic(&RuntimeError{Reason: fmt.Sprintf("stack overflow in %s", f.Name)})
+	}
+
+	fr := &frame{module: f.module, vars: map[ast.Name]Value{}}
+	for i, p := range f.Def.Params.(*ast.Params).Vars {
+		fr.vars[p.Name()] = wrap(args[i], p.(*ast.Param).Type())
+	}
+	return ip.eval(fr, f.Def.Execute)
+}
+
+// eval evaluates n in the frame fr.
+func (ip *Interp) eval(fr *frame, n ast.AST) Value {
+	switch n := n.(type) {
+	case *ast.Integer:
+		return wrap(int64(n.Value), ip.info.TypeOf(n))
+	case *ast.String:
+		return n.Word
+	case *ast.Variable:
+		return ip.load(fr, n)
+	case *ast.Let:
+		v := ip.eval(fr, n.RHS)
+		fr.vars[n.Name()] = v
+		return v
+	case *ast.Assign:
+		// the value is converted into the type of the variable.
+		v := wrap(ip.eval(fr, n.RHS), ip.info.TypeOf(n))
+		ip.store(fr, n, v)
+		return v
+	case *ast.Sequence:
+		ip.eval(fr, n.LHS)
+		return ip.eval(fr, n.RHS)
+	case *ast.If:
+		if truthy(ip.eval(fr, n.Cond)) {
+			return ip.eval(fr, n.Then)
+		}
+		return ip.eval(fr, n.Else)
+	case *ast.While:
+		// v stays 0 if the condition fails first.
+		var v Value = int64(0)
+		for truthy(ip.eval(fr, n.Cond)) {
+			v = ip.eval(fr, n.Proc)
+		}
+		return v
+	case *ast.Call:
+		return ip.evalCall(fr, n)
+	case *ast.Add:
+		return ip.arith(fr, n.LHS, n.RHS, ip.info.TypeOf(n), func(x, y int64) int64 { return x + y })
+	case *ast.Sub:
+		return ip.arith(fr, n.LHS, n.RHS, ip.info.TypeOf(n), func(x, y int64) int64 { return x - y })
+	case *ast.Mul:
+		return ip.arith(fr, n.LHS, n.RHS, ip.info.TypeOf(n), func(x, y int64) int64 { return x * y })
+	case *ast.Div:
+		return ip.arith(fr, n.LHS, n.RHS, ip.info.TypeOf(n), func(x, y int64) int64 {
+			if y == 0 {
+				panic(ip.fail(fr, n, "division by zero"))
+			}
+			return x / y
+		})
+	case *ast.Less:
+		return ip.arith(fr, n.LHS, n.RHS, ip.info.TypeOf(n), func(x, y int64) int64 { return boolToInt(x < y) })
+	case *ast.Equal:
+		return ip.arith(fr, n.LHS, n.RHS, ip.info.TypeOf(n), func(x, y int64) int64 { return boolToInt(x == y) })
+	default:
+		panic(&RuntimeError{Reason: fmt.Sprintf("%T cannot be evaluated", n)})
+	}
+}
+
+// evalCall calls the function named by n, or the function value held in the variable.
+func (ip *Interp) evalCall(fr *frame, n *ast.Call) Value {
+	callee := ip.info.Calls[n]
+	switch callee.Builtin {
+	case "":
+	case ast.PanicBuiltin:
+		panic(ip.fail(fr, n, "panic: "+ip.message(fr, n, 0)))
+	case ast.AssertBuiltin:
+		return ip.assert(fr, n)
+	default:
+		return ip.print(fr, n, callee.Builtin)
+	}
+	f, ok := ip.load(fr, n.FuncName).(*Function)
+	if !ok {
+		panic(&RuntimeError{Reason: fmt.Sprintf("%s is not a function", n.FuncName.Name())})
+	}
+
+	params := callee.FuncType.Params()
+	args := []Value{}
+	for i, a := range n.Args.(*ast.Args).Values {
+		v := ip.eval(fr, a)
+		// integer arguments are extended or truncated to their parameter as code generation does.
+		if i < len(params) && params[i] != "..." && params[i].IsInt() {
+			v = wrap(v, params[i])
+		}
+		args = append(args, v)
+	}
+	return wrap(ip.call(f, args), callee.FuncType.Return())
+}
+
+// fail makes the runtime error reason located at n with the backtrace of functions being called.
+func (ip *Interp) fail(fr *frame, n ast.AST, reason string) *RuntimeError {
+	bt := make([]ast.Name, 0, len(ip.stack))
+	for i := len(ip.stack) - 1; i >= 0; i-- {
+		bt = append(bt, ip.stack[i])
+	}
+	return &RuntimeError{Where: ast.Where(fr.module.File, n), Reason: reason, Backtrace: bt}
+}
+
+// load reads the variable, global or function which n refers.
+func (ip *Interp) load(fr *frame, n ast.AST) Value {
+	if v, ok := fr.vars[n.Name()]; ok {
+		return v
+	}
+	r := ip.info.Names[n]
+	if v, ok := ip.globals[r]; ok {
+		return v
+	}
+	if f, ok := ip.funcs[r]; ok {
+		return f
+	}
+	panic(&RuntimeError{Reason: fmt.Sprintf("%s doesn't exist", n.Name())})
+}
+
+// store writes v into the variable or global which n refers.
+func (ip *Interp) store(fr *frame, n ast.AST, v Value) {
+	if _, ok := fr.vars[n.Name()]; ok {
+		fr.vars[n.Name()] = v
+		return
+	}
+	r := ip.info.Names[n]
+	if _, ok := ip.globals[r]; !ok || ip.consts[r] {
+		panic(&RuntimeError{Reason: fmt.Sprintf("%s cannot be assigned", n.Name())})
+	}
+	ip.globals[r] = v
+}
+
+// arith evaluates a binary operator op on integers resulting a value typed as t.
+func (ip *Interp) arith(fr *frame, lhs, rhs ast.AST, t ast.Type, op func(x, y int64) int64) Value {
+	x := toInt(ip.eval(fr, lhs))
+	y := toInt(ip.eval(fr, rhs))
+	return wrap(op(x, y), t)
+}
+
+// wrap truncates the integer v to the width of t and sign-extends it back,
+// or makes it 0 or 1 for a bool. Values of other types are returned as is.
+func wrap(v Value, t ast.Type) Value {
+	x, ok := v.(int64)
+	if !ok || !t.IsInt() {
+		return v
+	}
+	switch t.Bits() {
+	case 1:
+		return boolToInt(x != 0)
+	case 8:
+		return int64(int8(x))
+	case 16:
+		return int64(int16(x))
+	case 32:
+		return int64(int32(x))
+	default:
+		return x
+	}
+}
+
+func toInt(v Value) int64 {
+	x, ok := v.(int64)
+	if !ok {
+		panic(&RuntimeError{Reason: fmt.Sprintf("%v is not an integer", v)})
+	}
+	return x
+}
+
+func truthy(v Value) bool {
+	return toInt(v) != 0
+}
+
+func boolToInt(b bool) int64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,91 @@
+package interp_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"gotest.tools/assert"
+
+	"github.com/yuniruyuni/lang/conformance"
+	"github.com/yuniruyuni/lang/gen"
+	"github.com/yuniruyuni/lang/interp"
+	"github.com/yuniruyuni/lang/module"
+)
+
+// run runs code by the interpreter with input, and returns what it writes into stdout.
+func run(t *testing.T, code, input string) (string, int, error) {
+	t.Helper()
+	prog, err := module.New(nil).LoadSource("<test>", code)
+	assert.NilError(t, err)
+	ll := gen.LLFile{AST: prog}
+	ll.Generate()
+
+	out := new(bytes.Buffer)
+	exit, err := interp.Run(prog, ll.Info, strings.NewReader(input), out)
+	return out.String(), exit, err
+}
+
+func TestRun_Conformance(t *testing.T) {
+	for _, tt := range conformance.Cases {
+		t.Run(tt.Name, func(t *testing.T) {
+			out, code, err := run(t, tt.Code, tt.Input)
+			assert.NilError(t, err)
+			assert.Equal(t, tt.Want, out)
+			assert.Equal(t, tt.WantCode, code)
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name     string
+		code     string
+		input    string
+		want     string
+		wantCode int
+		wantErr  string
+	}{
+		{
+			name:  "read lines, integers and chars until the end of input",
+			code:  `func main(){ println("[", read_line(), "] ", read_ok(),); let sum = 0; while eof() == 0 { sum = sum + read_int() }; println(sum, " ", read_char(), " ", read_ok(),); 0 }`,
+			input: "name\n1 2\n3\n",
+			want:  "[name] 1\n6 -1 0\n",
+		},
+		{
+			name:    "division by zero",
+			code:    `func main(){ let x = 0; printf("before",); 1 / x }`,
+			want:    "before",
+			wantErr: "<test>:1:44: runtime error: division by zero\nbacktrace:\n    main",
+		},
+		{
+			name:    "panic with the position and the backtrace",
+			code:    `func f(x: i32,) -> i32 { if x < 2 { panic("too small",) } else { x } } func main(){ print("before",); f(1,) }`,
+			want:    "before",
+			wantErr: "<test>:1:37: runtime error: panic: too small\nbacktrace:\n    f\n    main",
+		},
+		{
+			name:    "failed assertion",
+			code:    `func main(){ assert(1 < 2,); assert(2 < 1, "order",); 0 }`,
+			wantErr: "<test>:1:30: runtime error: assertion failed: order",
+		},
+		{
+			name:    "unsupported extern",
+			code:    `extern func getchar() -> i32 func main(){ getchar() }`,
+			wantErr: "extern function getchar is not supported",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, code, err := run(t, tt.code, tt.input)
+			assert.Equal(t, tt.want, out)
+			if tt.wantErr != "" {
+				assert.Assert(t, err != nil)
+				assert.Assert(t, strings.Contains(err.Error(), tt.wantErr), err.Error())
+				return
+			}
+			assert.NilError(t, err)
+			assert.Equal(t, tt.wantCode, code)
+		})
+	}
+}
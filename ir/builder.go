@@ -0,0 +1,147 @@
+package ir
+
+// Builder appends instructions at the end of its current block.
+type Builder struct {
+	Func  *Function
+	Block *BasicBlock
+	// Loc is the source location attached to emitted instructions, nil for no location.
+	Loc *Metadata
+	// Target aligns memory instructions, DefaultTarget is used if it is nil.
+	Target *Target
+}
+
+// SetFunc starts to build the body of f from a new entry block.
+func (b *Builder) SetFunc(f *Function) {
+	b.Func = f
+	b.SetBlock(f.NewBlock("entry"))
+}
+
+// SetBlock moves the insertion point to the end of bb.
+// bb is placed after the blocks placed before.
+func (b *Builder) SetBlock(bb *BasicBlock) {
+	if !bb.placed {
+		bb.placed = true
+		b.Func.Blocks = append(b.Func.Blocks, bb)
+	}
+	b.Block = bb
+}
+
+// NewBlock makes a new block labeled uniquely in current function.
+func (b *Builder) NewBlock() *BasicBlock {
+	return b.Func.NewBlock(b.Func.NextLabel())
+}
+
+// Emit appends i into current block and numbers its result register.
+func (b *Builder) Emit(i *Instr) *Instr {
+	if i.HasResult() {
+		if i.Name != "" {
+			i.Name = b.Func.uniqueName(i.Name)
+		} else {
+			i.ID = b.Func.nextID
+			b.Func.nextID += 1
+		}
+	}
+	if i.Dbg == nil {
+		i.Dbg = b.Loc
+	}
+	if i.Align == 0 {
+		i.Align = b.align(i)
+	}
+	i.Parent = b.Block
+	b.Block.Instrs = append(b.Block.Instrs, i)
+	return i
+}
+
+// align decides the alignment of i if it accesses memory, otherwise returns 0.
+func (b *Builder) align(i *Instr) int {
+	t := b.Target
+	if t == nil {
+		t = DefaultTarget
+	}
+	switch i.Op {
+	case OpAlloca, OpLoad:
+		return t.Align(i.Elem)
+	case OpStore:
+		return t.Align(i.Args[0].Type())
+	default:
+		return 0
+	}
+}
+
+// EmitNamed appends i with the result register named as name like `%x`.
+func (b *Builder) EmitNamed(name string, i *Instr) *Instr {
+	i.Name = name
+	return b.Emit(i)
+}
+
+func (b *Builder) Add(x, y Value) *Instr {
+	return b.Emit(Add(x, y))
+}
+
+func (b *Builder) Sub(x, y Value) *Instr {
+	return b.Emit(Sub(x, y))
+}
+
+func (b *Builder) Mul(x, y Value) *Instr {
+	return b.Emit(Mul(x, y))
+}
+
+func (b *Builder) SDiv(x, y Value) *Instr {
+	return b.Emit(SDiv(x, y))
+}
+
+func (b *Builder) ICmp(p Pred, x, y Value) *Instr {
+	return b.Emit(ICmp(p, x, y))
+}
+
+func (b *Builder) Cast(op Op, v Value, to Type) *Instr {
+	return b.Emit(Cast(op, v, to))
+}
+
+func (b *Builder) ZExt(v Value, to Type) *Instr {
+	return b.Emit(ZExt(v, to))
+}
+
+func (b *Builder) Alloca(t Type) *Instr {
+	return b.Emit(Alloca(t))
+}
+
+func (b *Builder) Load(ptr Value) *Instr {
+	return b.Emit(Load(ptr))
+}
+
+func (b *Builder) Store(v, ptr Value) *Instr {
+	return b.Emit(Store(v, ptr))
+}
+
+func (b *Builder) GEP(ptr Value, idx ...Value) *Instr {
+	return b.Emit(GEP(ptr, idx...))
+}
+
+func (b *Builder) Call(callee Value, args ...Value) *Instr {
+	return b.Emit(Call(callee, args...))
+}
+
+func (b *Builder) Extract(agg Value, index int) *Instr {
+	return b.Emit(Extract(agg, index))
+}
+
+func (b *Builder) Phi(t Type, in ...Incoming) *Instr {
+	return b.Emit(Phi(t, in...))
+}
+
+func (b *Builder) Br(to *BasicBlock) *Instr {
+	return b.Emit(Br(to))
+}
+
+func (b *Builder) CondBr(cond Value, then, els *BasicBlock) *Instr {
+	return b.Emit(CondBr(cond, then, els))
+}
+
+func (b *Builder) Ret(v Value) *Instr {
+	return b.Emit(Ret(v))
+}
+
+func (b *Builder) Unreachable() *Instr {
+	return b.Emit(Unreachable())
+}
@@ -0,0 +1,171 @@
+package ir
+
+// DomTree is the dominator tree of a function.
+// A block a dominates b if every path from the entry block to b goes through a.
+type DomTree struct {
+	// idom maps each reachable block to its immediate dominator.
+	// The entry block is mapped to itself.
+	idom map[*BasicBlock]*BasicBlock
+	// order is the reverse postorder of reachable blocks.
+	order []*BasicBlock
+}
+
+// NewDomTree computes the dominator tree of f
+// by the iterative algorithm of Cooper, Harvey and Kennedy.
+func NewDomTree(f *Function) *DomTree {
+	t := &DomTree{idom: map[*BasicBlock]*BasicBlock{}}
+	if f.IsDecl() {
+		return t
+	}
+
+	t.order = ReversePostorder(f)
+	index := make(map[*BasicBlock]int, len(t.order))
+	for i, bb := range t.order {
+		index[bb] = i
+	}
+
+	preds := map[*BasicBlock][]*BasicBlock{}
+	for _, bb := range t.order {
+		for _, s := range bb.Succs() {
+			preds[s] = append(preds[s], bb)
+		}
+	}
+
+	entry := f.Entry()
+	t.idom[entry] = entry
+
+	intersect := func(a, b *BasicBlock) *BasicBlock {
+		for a != b {
+			for index[a] > index[b] {
+				a = t.idom[a]
+			}
+			for index[b] > index[a] {
+				b = t.idom[b]
+			}
+		}
+		return a
+	}
+
+	for changed := true; changed; {
+		changed = false
+		for _, bb := range t.order[1:] {
+			var idom *BasicBlock
+			for _, p := range preds[bb] {
+				if _, ok := t.idom[p]; !ok {
+					continue
+				}
+				if idom == nil {
+					idom = p
+				} else {
+					idom = intersect(p, idom)
+				}
+			}
+			if t.idom[bb] != idom {
+				t.idom[bb] = idom
+				changed = true
+			}
+		}
+	}
+	return t
+}
+
+// Reachable reports whether bb is reachable from the entry block.
+func (t *DomTree) Reachable(bb *BasicBlock) bool {
+	_, ok := t.idom[bb]
+	return ok
+}
+
+// IDom returns the immediate dominator of bb, or nil for the entry block.
+func (t *DomTree) IDom(bb *BasicBlock) *BasicBlock {
+	d := t.idom[bb]
+	if d == bb {
+		return nil
+	}
+	return d
+}
+
+// Dominates reports whether a dominates b. Every block dominates itself.
+func (t *DomTree) Dominates(a, b *BasicBlock) bool {
+	if !t.Reachable(b) {
+		return false
+	}
+	for {
+		if a == b {
+			return true
+		}
+		d := t.IDom(b)
+		if d == nil {
+			return false
+		}
+		b = d
+	}
+}
+
+// Order returns reachable blocks in reverse postorder.
+func (t *DomTree) Order() []*BasicBlock {
+	return t.order
+}
+
+// Children returns the blocks which bb immediately dominates.
+func (t *DomTree) Children(bb *BasicBlock) []*BasicBlock {
+	cs := []*BasicBlock{}
+	for _, b := range t.order {
+		if b != bb && t.idom[b] == bb {
+			cs = append(cs, b)
+		}
+	}
+	return cs
+}
+
+// Frontiers computes the dominance frontier of each reachable block,
+// the blocks where its dominance ends.
+func (t *DomTree) Frontiers() map[*BasicBlock][]*BasicBlock {
+	df := map[*BasicBlock][]*BasicBlock{}
+	added := map[[2]*BasicBlock]bool{}
+
+	preds := map[*BasicBlock][]*BasicBlock{}
+	for _, bb := range t.order {
+		for _, s := range bb.Succs() {
+			preds[s] = append(preds[s], bb)
+		}
+	}
+
+	for _, bb := range t.order {
+		if len(preds[bb]) < 2 {
+			continue
+		}
+		for _, p := range preds[bb] {
+			for r := p; r != nil && r != t.idom[bb]; r = t.IDom(r) {
+				if !added[[2]*BasicBlock{r, bb}] {
+					added[[2]*BasicBlock{r, bb}] = true
+					df[r] = append(df[r], bb)
+				}
+			}
+		}
+	}
+	return df
+}
+
+// ReversePostorder lists blocks reachable from the entry block of f
+// in reverse postorder, so every block comes before its successors except for back edges.
+func ReversePostorder(f *Function) []*BasicBlock {
+	visited := map[*BasicBlock]bool{}
+	post := []*BasicBlock{}
+
+	var visit func(bb *BasicBlock)
+	visit = func(bb *BasicBlock) {
+		visited[bb] = true
+		for _, s := range bb.Succs() {
+			if !visited[s] {
+				visit(s)
+			}
+		}
+		post = append(post, bb)
+	}
+	visit(f.Entry())
+
+	for i, j := 0, len(post)-1; i < j; i, j = i+1, j-1 {
+		post[i], post[j] = post[j], post[i]
+	}
+	return post
+}
@@ -0,0 +1,212 @@
+package ir
+
+// Insert puts i into bb at the position at.
+// A named result is renamed to be unique in the function,
+// and unnamed results are numbered when the function is renumbered.
+func (bb *BasicBlock) Insert(at int, i *Instr) *Instr {
+	if i.HasResult() && i.Name != "" {
+		i.Name = bb.Parent.uniqueName(i.Name)
+	}
+	i.Parent = bb
+	bb.Instrs = append(bb.Instrs, nil)
+	copy(bb.Instrs[at+1:], bb.Instrs[at:])
+	bb.Instrs[at] = i
+	return i
+}
+
+// Remove removes i from bb.
+func (bb *BasicBlock) Remove(i *Instr) {
+	for k, x := range bb.Instrs {
+		if x == i {
+			bb.Instrs = append(bb.Instrs[:k], bb.Instrs[k+1:]...)
+			i.Parent = nil
+			return
+		}
+	}
+}
+
+// Phis returns phis at the top of bb.
+func (bb *BasicBlock) Phis() []*Instr {
+	phis := []*Instr{}
+	for _, i := range bb.Instrs {
+		if i.Op != OpPhi {
+			break
+		}
+		phis = append(phis, i)
+	}
+	return phis
+}
+
+// RemoveIncoming removes the incoming value from bb of this phi.
+func (i *Instr) RemoveIncoming(bb *BasicBlock) {
+	in := i.Incomings[:0]
+	for _, c := range i.Incomings {
+		if c.Block != bb {
+			in = append(in, c)
+		}
+	}
+	i.Incomings = in
+}
+
+// Clone copies i without its parent and result number.
+// Its operands refer the same values as i.
+func (i *Instr) Clone() *Instr {
+	c := *i
+	c.ID = 0
+	c.Parent = nil
+	c.Args = append([]Value{}, i.Args...)
+	for k, a := range c.Args {
+		if m, ok := a.(*Meta); ok {
+			cm := *m
+			c.Args[k] = &cm
+		}
+	}
+	c.Targets = append([]*BasicBlock{}, i.Targets...)
+	c.Incomings = append([]Incoming{}, i.Incomings...)
+	return &c
+}
+
+// IsPure reports whether i can be removed if its result is not used.
+func (i *Instr) IsPure() bool {
+	switch i.Op {
+	case OpStore, OpCall, OpBr, OpRet, OpUnreachable:
+		return false
+	case OpSDiv:
+		// division by zero traps, so it must be kept unless the divisor is known.
+		d, ok := IntValue(i.Args[1])
+		return ok && d != 0
+	default:
+		return true
+	}
+}
+
+// InsertBlock places bb into f at the position at.
+func (f *Function) InsertBlock(at int, bb *BasicBlock) {
+	bb.placed = true
+	bb.Parent = f
+	f.Blocks = append(f.Blocks, nil)
+	copy(f.Blocks[at+1:], f.Blocks[at:])
+	f.Blocks[at] = bb
+}
+
+// IndexOf returns the position of bb in f, or -1 if bb is not placed.
+func (f *Function) IndexOf(bb *BasicBlock) int {
+	for k, b := range f.Blocks {
+		if b == bb {
+			return k
+		}
+	}
+	return -1
+}
+
+// SplitBlock moves instructions of bb from the position at into a new block placed after bb.
+// bb is left without terminator, and phis in the successors come from the new block.
+func (f *Function) SplitBlock(bb *BasicBlock, at int) *BasicBlock {
+	succs := bb.Succs()
+
+	nb := f.NewBlock(f.NextLabel())
+	f.InsertBlock(f.IndexOf(bb)+1, nb)
+	for _, i := range bb.Instrs[at:] {
+		i.Parent = nb
+		nb.Instrs = append(nb.Instrs, i)
+	}
+	bb.Instrs = bb.Instrs[:at:at]
+
+	for _, s := range succs {
+		for _, phi := range s.Phis() {
+			for k, c := range phi.Incomings {
+				if c.Block == bb {
+					phi.Incomings[k].Block = nb
+				}
+			}
+		}
+	}
+	return nb
+}
+
+// RemoveBlock removes bb from f.
+// Incoming values from bb are removed from phis in its successors.
+func (f *Function) RemoveBlock(bb *BasicBlock) {
+	for _, s := range bb.Succs() {
+		for _, phi := range s.Phis() {
+			phi.RemoveIncoming(bb)
+		}
+	}
+	for k, b := range f.Blocks {
+		if b == bb {
+			f.Blocks = append(f.Blocks[:k], f.Blocks[k+1:]...)
+			break
+		}
+	}
+	bb.placed = false
+}
+
+// ReplaceAllUses replaces every use of old in f by new.
+func (f *Function) ReplaceAllUses(old, new Value) {
+	for _, bb := range f.Blocks {
+		for _, i := range bb.Instrs {
+			for k, a := range i.Args {
+				if a == old {
+					i.Args[k] = new
+				}
+				if m, ok := a.(*Meta); ok && m.Value == old {
+					m.Value = new
+				}
+			}
+			for k, c := range i.Incomings {
+				if c.Value == old {
+					i.Incomings[k].Value = new
+				}
+			}
+		}
+	}
+}
+
+// UseCounts counts how many times each value is used in f.
+func (f *Function) UseCounts() map[Value]int {
+	uses := map[Value]int{}
+	for _, bb := range f.Blocks {
+		for _, i := range bb.Instrs {
+			for _, v := range i.Operands() {
+				uses[v]++
+			}
+		}
+	}
+	return uses
+}
+
+// Renumber numbers unnamed registers sequentially from 0 in the order of appearance
+// as LLVM requires, after instructions are added or removed.
+func (f *Function) Renumber() {
+	f.nextID = 0
+	for _, bb := range f.Blocks {
+		for _, i := range bb.Instrs {
+			if i.HasResult() && i.Name == "" {
+				i.ID = f.nextID
+				f.nextID++
+			}
+		}
+	}
+}
+
+// RemoveFunction removes f from m.
+func (m *Module) RemoveFunction(f *Function) {
+	for k, x := range m.Functions {
+		if x == f {
+			m.Functions = append(m.Functions[:k], m.Functions[k+1:]...)
+			break
+		}
+	}
+	delete(m.funcs, f.Name)
+}
+
+// RemoveGlobal removes g from m.
+func (m *Module) RemoveGlobal(g *Global) {
+	for k, x := range m.Globals {
+		if x == g {
+			m.Globals = append(m.Globals[:k], m.Globals[k+1:]...)
+			break
+		}
+	}
+	delete(m.globals, g.Name)
+}
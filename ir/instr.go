@@ -0,0 +1,267 @@
+package ir
+
+import (
+	"fmt"
+)
+
+// Op is the opcode of an instruction.
+type Op string
+
+const (
+	OpAdd         Op = "add"
+	OpSub         Op = "sub"
+	OpMul         Op = "mul"
+	OpSDiv        Op = "sdiv"
+	OpICmp        Op = "icmp"
+	OpZExt        Op = "zext"
+	OpSExt        Op = "sext"
+	OpTrunc       Op = "trunc"
+	OpBitCast     Op = "bitcast"
+	OpAlloca      Op = "alloca"
+	OpLoad        Op = "load"
+	OpStore       Op = "store"
+	OpGEP         Op = "getelementptr"
+	OpCall        Op = "call"
+	OpExtract     Op = "extractvalue"
+	OpPhi         Op = "phi"
+	OpBr          Op = "br"
+	OpRet         Op = "ret"
+	OpUnreachable Op = "unreachable"
+)
+
+// Pred is the condition for `icmp`.
+type Pred string
+
+const (
+	EQ  Pred = "eq"
+	NE  Pred = "ne"
+	SLT Pred = "slt"
+	SLE Pred = "sle"
+	SGT Pred = "sgt"
+	SGE Pred = "sge"
+)
+
+// TailKind tells whether a call can reuse the stack frame of its caller.
+type TailKind string
+
+const (
+	// NoTail is a plain call.
+	NoTail TailKind = ""
+	// Tail hints the callee doesn't access the stack of the caller.
+	Tail TailKind = "tail"
+	// MustTail guarantees the call reuses the stack frame of the caller.
+	MustTail TailKind = "musttail"
+)
+
+// Incoming is a pair of a value and the predecessor block it comes from for `phi`.
+type Incoming struct {
+	Value Value
+	Block *BasicBlock
+}
+
+// Instr is a single instruction.
+type Instr struct {
+	Op Op
+	// Typ is the result type, Void for an instruction without result.
+	Typ Type
+	// ID is the number of the unnamed result register like `%3`.
+	ID int
+	// Name is the name of a named result register like `%x`.
+	Name string
+
+	// Args holds operands. For `call` the first one is the callee.
+	Args []Value
+	// Pred is the condition for `icmp`.
+	Pred Pred
+	// Elem is the element type for `alloca`, `load` and `getelementptr`.
+	Elem Type
+	// Index is the field which `extractvalue` takes out.
+	Index int
+	// Targets holds destinations of `br`.
+	Targets []*BasicBlock
+	// Incomings holds the incoming values of `phi`.
+	Incomings []Incoming
+	// Align is the alignment in bytes for `alloca`, `load` and `store`.
+	Align int
+	// Tail is a marker for `call` like `tail` or `musttail`.
+	Tail TailKind
+	// Dbg is the source location of this instruction, nil if it is unknown.
+	Dbg *Metadata
+
+	Parent *BasicBlock
+}
+
+func (i *Instr) Type() Type {
+	return i.Typ
+}
+
+func (i *Instr) Ident() string {
+	if i.Name != "" {
+		return "%" + i.Name
+	}
+	return fmt.Sprintf("%%%d", i.ID)
+}
+
+// HasResult reports whether this instruction defines a register.
+func (i *Instr) HasResult() bool {
+	return i.Typ != Void
+}
+
+// IsTerminator reports whether this instruction ends a block.
+func (i *Instr) IsTerminator() bool {
+	switch i.Op {
+	case OpBr, OpRet, OpUnreachable:
+		return true
+	default:
+		return false
+	}
+}
+
+// AddIncoming adds an incoming value v from block bb into this phi.
+func (i *Instr) AddIncoming(v Value, bb *BasicBlock) {
+	i.Incomings = append(i.Incomings, Incoming{Value: v, Block: bb})
+}
+
+// Operands returns every value this instruction uses, including phi incoming values
+// and values wrapped as metadata operands.
+func (i *Instr) Operands() []Value {
+	ops := make([]Value, 0, len(i.Args)+len(i.Incomings))
+	for _, a := range i.Args {
+		if m, ok := a.(*Meta); ok {
+			if m.Value != nil {
+				ops = append(ops, m.Value)
+			}
+			continue
+		}
+		ops = append(ops, a)
+	}
+	for _, in := range i.Incomings {
+		ops = append(ops, in.Value)
+	}
+	return ops
+}
+
+func binary(op Op, x, y Value) *Instr {
+	return &Instr{Op: op, Typ: x.Type(), Args: []Value{x, y}}
+}
+
+func Add(x, y Value) *Instr {
+	return binary(OpAdd, x, y)
+}
+
+func Sub(x, y Value) *Instr {
+	return binary(OpSub, x, y)
+}
+
+func Mul(x, y Value) *Instr {
+	return binary(OpMul, x, y)
+}
+
+func SDiv(x, y Value) *Instr {
+	return binary(OpSDiv, x, y)
+}
+
+// ICmp compares x and y by p and results an i1.
+func ICmp(p Pred, x, y Value) *Instr {
+	return &Instr{Op: OpICmp, Typ: I1, Pred: p, Args: []Value{x, y}}
+}
+
+// Cast converts v into type to by op like OpZExt or OpBitCast.
+func Cast(op Op, v Value, to Type) *Instr {
+	return &Instr{Op: op, Typ: to, Args: []Value{v}}
+}
+
+func ZExt(v Value, to Type) *Instr {
+	return Cast(OpZExt, v, to)
+}
+
+func SExt(v Value, to Type) *Instr {
+	return Cast(OpSExt, v, to)
+}
+
+func Trunc(v Value, to Type) *Instr {
+	return Cast(OpTrunc, v, to)
+}
+
+func BitCast(v Value, to Type) *Instr {
+	return Cast(OpBitCast, v, to)
+}
+
+// Alloca allocates a stack slot for t and results a pointer to it.
+// Alignments of memory instructions are decided by the target when they are emitted.
+func Alloca(t Type) *Instr {
+	return &Instr{Op: OpAlloca, Typ: PointerTo(t), Elem: t}
+}
+
+// Load reads the value which ptr points to.
+func Load(ptr Value) *Instr {
+	t := ptr.Type().Elem()
+	return &Instr{Op: OpLoad, Typ: t, Elem: t, Args: []Value{ptr}}
+}
+
+// Store writes v into the memory which ptr points to.
+func Store(v, ptr Value) *Instr {
+	return &Instr{Op: OpStore, Typ: Void, Args: []Value{v, ptr}}
+}
+
+// GEP computes the address of an element from ptr by `getelementptr inbounds`.
+func GEP(ptr Value, idx ...Value) *Instr {
+	elem := ptr.Type().Elem()
+	return &Instr{
+		Op:   OpGEP,
+		Typ:  PointerTo(elemAt(elem, len(idx))),
+		Elem: elem,
+		Args: append([]Value{ptr}, idx...),
+	}
+}
+
+// Call calls callee, which is typed as a function pointer, with args.
+func Call(callee Value, args ...Value) *Instr {
+	return &Instr{
+		Op:   OpCall,
+		Typ:  callee.Type().Return(),
+		Args: append([]Value{callee}, args...),
+	}
+}
+
+// Callee returns the called function of a `call`.
+func (i *Instr) Callee() Value {
+	return i.Args[0]
+}
+
+// CallArgs returns the arguments of a `call`.
+func (i *Instr) CallArgs() []Value {
+	return i.Args[1:]
+}
+
+// Extract takes the field at index out of the struct value agg by `extractvalue`.
+func Extract(agg Value, index int) *Instr {
+	return &Instr{Op: OpExtract, Typ: agg.Type().Fields()[index], Index: index, Args: []Value{agg}}
+}
+
+// Phi chooses a value typed as t by the predecessor block.
+func Phi(t Type, in ...Incoming) *Instr {
+	return &Instr{Op: OpPhi, Typ: t, Incomings: in}
+}
+
+// Br jumps into the block to.
+func Br(to *BasicBlock) *Instr {
+	return &Instr{Op: OpBr, Typ: Void, Targets: []*BasicBlock{to}}
+}
+
+// CondBr jumps into then if cond is true, otherwise into els.
+func CondBr(cond Value, then, els *BasicBlock) *Instr {
+	return &Instr{Op: OpBr, Typ: Void, Args: []Value{cond}, Targets: []*BasicBlock{then, els}}
+}
+
+// Ret returns v from the function, or returns void if v is nil.
+func Ret(v Value) *Instr {
+	if v == nil {
+		return &Instr{Op: OpRet, Typ: Void}
+	}
+	return &Instr{Op: OpRet, Typ: Void, Args: []Value{v}}
+}
+
+func Unreachable() *Instr {
+	return &Instr{Op: OpUnreachable, Typ: Void}
+}
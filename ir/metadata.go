@@ -0,0 +1,101 @@
+package ir
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Metadata is a numbered metadata node like `!3 = !DILocation(line: 1, column: 2, scope: !2)`.
+type Metadata struct {
+	// ID is the number of this node.
+	ID int
+	// Text is the body written in LLVM IR syntax like `!{i32 7, !"Dwarf Version", i32 4}`.
+	Text string
+	// Distinct is true for a node which must not be merged with equal ones.
+	Distinct bool
+}
+
+func (md *Metadata) Ident() string {
+	return fmt.Sprintf("!%d", md.ID)
+}
+
+func (md *Metadata) String() string {
+	if md.Distinct {
+		return fmt.Sprintf("%s = distinct %s", md.Ident(), md.Text)
+	}
+	return fmt.Sprintf("%s = %s", md.Ident(), md.Text)
+}
+
+// NamedMetadata is a module level list of metadata nodes like `!llvm.dbg.cu = !{!0}`.
+type NamedMetadata struct {
+	Name  string
+	Nodes []*Metadata
+}
+
+func (nm *NamedMetadata) String() string {
+	ids := make([]string, 0, len(nm.Nodes))
+	for _, n := range nm.Nodes {
+		ids = append(ids, n.Ident())
+	}
+	return fmt.Sprintf("!%s = !{%s}", nm.Name, strings.Join(ids, ", "))
+}
+
+// NewMetadata adds a metadata node which has the body text.
+func (m *Module) NewMetadata(text string) *Metadata {
+	md := &Metadata{ID: len(m.Metadata), Text: text}
+	m.Metadata = append(m.Metadata, md)
+	return md
+}
+
+// NewDistinctMetadata adds a distinct metadata node which has the body text.
+func (m *Module) NewDistinctMetadata(text string) *Metadata {
+	md := m.NewMetadata(text)
+	md.Distinct = true
+	return md
+}
+
+// AddNamedMetadata appends nodes into the named metadata name, adding it if it doesn't exist.
+func (m *Module) AddNamedMetadata(name string, nodes ...*Metadata) {
+	for _, nm := range m.NamedMetadata {
+		if nm.Name == name {
+			nm.Nodes = append(nm.Nodes, nodes...)
+			return
+		}
+	}
+	m.NamedMetadata = append(m.NamedMetadata, &NamedMetadata{Name: name, Nodes: nodes})
+}
+
+// Meta is an operand typed as `metadata` for intrinsics like `llvm.dbg.declare`.
+// It wraps either a value like `metadata i32* %x` or a node like `metadata !3`.
+type Meta struct {
+	// Value is the wrapped value, nil if this wraps Node.
+	Value Value
+	Node  *Metadata
+	// Text is written as is if both Value and Node are nil, like `!DIExpression()`.
+	Text string
+}
+
+func (mv *Meta) Type() Type {
+	return MetadataType
+}
+
+func (mv *Meta) Ident() string {
+	switch {
+	case mv.Value != nil:
+		return operand(mv.Value)
+	case mv.Node != nil:
+		return mv.Node.Ident()
+	default:
+		return mv.Text
+	}
+}
+
+// IsDebugIntrinsic reports whether i calls an intrinsic which only describes variables for debuggers,
+// like `llvm.dbg.declare` or `llvm.dbg.value`.
+func (i *Instr) IsDebugIntrinsic() bool {
+	if i.Op != OpCall {
+		return false
+	}
+	f, ok := i.Callee().(*Function)
+	return ok && strings.HasPrefix(f.Name, "llvm.dbg.")
+}
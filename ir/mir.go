@@ -0,0 +1,91 @@
+package ir
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MIR prints m for inspection rather than for LLVM.
+// Every value is bound with its type like `%0: i1 = icmp ...`, and every block is headed by
+// the blocks branching into it, the blocks it branches into and its immediate dominator,
+// so the control-flow graph which passes work on can be read directly.
+// Debug information is left out.
+func (m *Module) MIR() string {
+	parts := []string{}
+	if len(m.Globals) > 0 {
+		gs := make([]string, 0, len(m.Globals))
+		for _, g := range m.Globals {
+			kind := "global"
+			if g.Const {
+				kind = "const"
+			}
+			gs = append(gs, fmt.Sprintf("%s %s: %s = %s", kind, g.Ident(), g.Elem, g.Init))
+		}
+		parts = append(parts, strings.Join(gs, "\n"))
+	}
+
+	decls := []string{}
+	for _, f := range m.Functions {
+		if f.IsDecl() {
+			ps := make([]string, 0, len(f.Typ.Params()))
+			for _, p := range f.Typ.Params() {
+				ps = append(ps, string(p))
+			}
+			decls = append(decls, fmt.Sprintf("extern %s(%s) -> %s", f.Ident(), strings.Join(ps, ", "), f.Ret()))
+		}
+	}
+	if len(decls) > 0 {
+		parts = append(parts, strings.Join(decls, "\n"))
+	}
+
+	for _, f := range m.Functions {
+		if !f.IsDecl() {
+			parts = append(parts, f.mir())
+		}
+	}
+	return strings.Join(parts, "\n\n") + "\n"
+}
+
+func (f *Function) mir() string {
+	ps := make([]string, 0, len(f.Params))
+	for _, p := range f.Params {
+		ps = append(ps, fmt.Sprintf("%s: %s", p.Ident(), p.Type()))
+	}
+
+	b := new(strings.Builder)
+	fmt.Fprintf(b, "func %s(%s) -> %s {\n", f.Ident(), strings.Join(ps, ", "), f.Ret())
+	dom := NewDomTree(f)
+	for i, bb := range f.Blocks {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		idom := "-"
+		if !dom.Reachable(bb) {
+			idom = "unreachable"
+		} else if d := dom.IDom(bb); d != nil {
+			idom = d.Name
+		}
+		fmt.Fprintf(b, "%s:  ; preds: %s  succs: %s  idom: %s\n", bb.Name, blockNames(f.Preds(bb)), blockNames(bb.Succs()), idom)
+		for _, i := range bb.Instrs {
+			if i.HasResult() {
+				fmt.Fprintf(b, "  %s: %s = %s\n", i.Ident(), i.Type(), i.body())
+			} else {
+				fmt.Fprintf(b, "  %s\n", i.body())
+			}
+		}
+	}
+	b.WriteString("}")
+	return b.String()
+}
+
+// blockNames joins names of bbs, or `-` if there is none.
+func blockNames(bbs []*BasicBlock) string {
+	if len(bbs) == 0 {
+		return "-"
+	}
+	ns := make([]string, 0, len(bbs))
+	for _, bb := range bbs {
+		ns = append(ns, bb.Name)
+	}
+	return strings.Join(ns, ", ")
+}
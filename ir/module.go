@@ -0,0 +1,212 @@
+package ir
+
+import (
+	"fmt"
+)
+
+// Module is a whole LLVM module which consists of globals and functions.
+type Module struct {
+	Globals   []*Global
+	Functions []*Function
+	// Target is the machine the module is compiled for, nil to write no target in the module.
+	Target *Target
+	// Metadata holds numbered metadata nodes like debug information.
+	Metadata      []*Metadata
+	NamedMetadata []*NamedMetadata
+
+	globals map[string]*Global
+	funcs   map[string]*Function
+}
+
+func NewModule() *Module {
+	return &Module{
+		globals: map[string]*Global{},
+		funcs:   map[string]*Function{},
+	}
+}
+
+// Layout returns the target which decides sizes in m, DefaultTarget if m has no target.
+func (m *Module) Layout() *Target {
+	if m.Target == nil {
+		return DefaultTarget
+	}
+	return m.Target
+}
+
+// NewGlobal adds a global variable named name which holds elem initialized by init.
+func (m *Module) NewGlobal(name string, elem Type, init string) *Global {
+	g := &Global{Name: name, Elem: elem, Init: init, Align: m.Layout().Align(elem)}
+	m.Globals = append(m.Globals, g)
+	m.globals[name] = g
+	return g
+}
+
+// Global finds the global variable named name.
+func (m *Module) Global(name string) *Global {
+	return m.globals[name]
+}
+
+// NewFunction adds a function named name typed as the function pointer type t.
+// It is printed as a declaration until some blocks are added.
+func (m *Module) NewFunction(name string, t Type, params ...*Param) *Function {
+	f := &Function{
+		Name:   name,
+		Typ:    t,
+		Params: params,
+		names:  map[string]int{},
+	}
+	for _, p := range params {
+		f.names[p.Name]++
+	}
+	m.Functions = append(m.Functions, f)
+	m.funcs[name] = f
+	return f
+}
+
+// Function finds the function named name.
+func (m *Module) Function(name string) *Function {
+	return m.funcs[name]
+}
+
+// Global is a module level variable like `@x = global i32 0`.
+type Global struct {
+	Name string
+	// Elem is the type of the held value, the global itself is a pointer to it.
+	Elem Type
+	// Init is the initializer written in LLVM IR syntax like `0` or `c"abc\00"`.
+	Init string
+	// Const is true for an immutable global.
+	Const bool
+	// Linkage is written before the kind of the global, like `private unnamed_addr`.
+	Linkage string
+	Align   int
+}
+
+func (g *Global) Type() Type {
+	return PointerTo(g.Elem)
+}
+
+func (g *Global) Ident() string {
+	return "@" + g.Name
+}
+
+// Function is a function definition or declaration.
+type Function struct {
+	Name string
+	// Typ is the function pointer type of this function.
+	Typ    Type
+	Params []*Param
+	Blocks []*BasicBlock
+	// Dbg is the debug information of this function like `!DISubprogram(...)`.
+	Dbg *Metadata
+
+	// nextID is the number for the next unnamed register.
+	nextID int
+	// nextLabel is the number for the next block label.
+	nextLabel int
+	// names counts how many times each register name is used.
+	names map[string]int
+}
+
+func (f *Function) Type() Type {
+	return f.Typ
+}
+
+func (f *Function) Ident() string {
+	return "@" + f.Name
+}
+
+// Ret returns the result type of this function.
+func (f *Function) Ret() Type {
+	return f.Typ.Return()
+}
+
+// IsDecl reports whether this function is a declaration without body.
+func (f *Function) IsDecl() bool {
+	return len(f.Blocks) == 0
+}
+
+// Entry returns the entry block of this function.
+func (f *Function) Entry() *BasicBlock {
+	return f.Blocks[0]
+}
+
+// NewBlock makes a new block for this function.
+// The block is placed in the function when a Builder starts to emit into it,
+// so blocks are printed in the order of their instructions.
+// Labels share names with values, so the name gets a suffix if a parameter or a value takes it.
+func (f *Function) NewBlock(name string) *BasicBlock {
+	return &BasicBlock{Name: f.uniqueName(name), Parent: f}
+}
+
+// NextLabel makes a new unique block name like `label.3`.
+func (f *Function) NextLabel() string {
+	f.nextLabel += 1
+	return fmt.Sprintf("label.%d", f.nextLabel)
+}
+
+// uniqueName returns name itself when it is first used in this function,
+// otherwise it returns name with a suffix like `x.1`.
+func (f *Function) uniqueName(name string) string {
+	unique := name
+	for n := f.names[name]; f.names[unique] > 0; n++ {
+		unique = fmt.Sprintf("%s.%d", name, n)
+	}
+	f.names[unique]++
+	if unique != name {
+		f.names[name]++
+	}
+	return unique
+}
+
+// Preds returns the blocks which branch into bb.
+func (f *Function) Preds(bb *BasicBlock) []*BasicBlock {
+	preds := []*BasicBlock{}
+	for _, b := range f.Blocks {
+		for _, s := range b.Succs() {
+			if s == bb {
+				preds = append(preds, b)
+				break
+			}
+		}
+	}
+	return preds
+}
+
+// BasicBlock is a labeled sequence of instructions ending with a terminator.
+type BasicBlock struct {
+	Name   string
+	Instrs []*Instr
+	Parent *Function
+
+	placed bool
+}
+
+func (bb *BasicBlock) Type() Type {
+	return "label"
+}
+
+func (bb *BasicBlock) Ident() string {
+	return "%" + bb.Name
+}
+
+// Terminator returns the last instruction if it terminates this block, otherwise nil.
+func (bb *BasicBlock) Terminator() *Instr {
+	if len(bb.Instrs) == 0 {
+		return nil
+	}
+	last := bb.Instrs[len(bb.Instrs)-1]
+	if !last.IsTerminator() {
+		return nil
+	}
+	return last
+}
+
+// Succs returns the blocks which this block branches into.
+func (bb *BasicBlock) Succs() []*BasicBlock {
+	t := bb.Terminator()
+	if t == nil {
+		return nil
+	}
+	return t.Targets
+}
@@ -0,0 +1,165 @@
+package ir
+
+import (
+	"fmt"
+	"strings"
+)
+
+// String prints m as textual LLVM IR.
+// The target comes first, globals follow, functions follow in the order they are added
+// and metadata comes last.
+func (m *Module) String() string {
+	globals := make([]string, 0, len(m.Globals))
+	for _, g := range m.Globals {
+		globals = append(globals, g.String())
+	}
+
+	parts := []string{}
+	if m.Target != nil {
+		parts = append(parts, m.Target.Header())
+	}
+	if len(globals) > 0 {
+		parts = append(parts, strings.Join(globals, "\n"))
+	}
+	for _, f := range m.Functions {
+		parts = append(parts, f.String())
+	}
+	if len(m.NamedMetadata) > 0 || len(m.Metadata) > 0 {
+		mds := make([]string, 0, len(m.NamedMetadata)+len(m.Metadata))
+		for _, nm := range m.NamedMetadata {
+			mds = append(mds, nm.String())
+		}
+		for _, md := range m.Metadata {
+			mds = append(mds, md.String())
+		}
+		parts = append(parts, strings.Join(mds, "\n"))
+	}
+	return strings.Join(parts, "\n\n") + "\n"
+}
+
+func (g *Global) String() string {
+	kind := "global"
+	if g.Const {
+		kind = "constant"
+	}
+	if g.Linkage != "" {
+		kind = g.Linkage + " " + kind
+	}
+	return fmt.Sprintf("%s = %s %s %s, align %d", g.Ident(), kind, g.Elem, g.Init, g.Align)
+}
+
+// String prints f as `declare` if it has no blocks, otherwise as `define`.
+func (f *Function) String() string {
+	if f.IsDecl() {
+		ps := make([]string, 0, len(f.Typ.Params()))
+		for _, p := range f.Typ.Params() {
+			ps = append(ps, string(p))
+		}
+		return fmt.Sprintf("declare %s %s(%s)", f.Ret(), f.Ident(), strings.Join(ps, ", "))
+	}
+
+	ps := make([]string, 0, len(f.Params))
+	for _, p := range f.Params {
+		ps = append(ps, operand(p))
+	}
+
+	b := new(strings.Builder)
+	dbg := ""
+	if f.Dbg != nil {
+		dbg = " !dbg " + f.Dbg.Ident()
+	}
+	fmt.Fprintf(b, "define %s %s(%s)%s {\n", f.Ret(), f.Ident(), strings.Join(ps, ", "), dbg)
+	for i, bb := range f.Blocks {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		b.WriteString(bb.String())
+	}
+	b.WriteString("}")
+	return b.String()
+}
+
+func (bb *BasicBlock) String() string {
+	b := new(strings.Builder)
+	fmt.Fprintf(b, "%s:\n", bb.Name)
+	for _, i := range bb.Instrs {
+		fmt.Fprintf(b, "  %s\n", i)
+	}
+	return b.String()
+}
+
+func (i *Instr) String() string {
+	s := i.body()
+	if i.HasResult() {
+		s = fmt.Sprintf("%s = %s", i.Ident(), s)
+	}
+	if i.Dbg != nil {
+		s += ", !dbg " + i.Dbg.Ident()
+	}
+	return s
+}
+
+// body prints the instruction without its result register.
+func (i *Instr) body() string {
+	switch i.Op {
+	case OpAdd, OpSub, OpMul, OpSDiv:
+		return fmt.Sprintf("%s %s %s, %s", i.Op, i.Typ, ident(i.Args[0]), ident(i.Args[1]))
+	case OpICmp:
+		return fmt.Sprintf("icmp %s %s, %s", i.Pred, operand(i.Args[0]), ident(i.Args[1]))
+	case OpZExt, OpSExt, OpTrunc, OpBitCast:
+		return fmt.Sprintf("%s %s to %s", i.Op, operand(i.Args[0]), i.Typ)
+	case OpAlloca:
+		return fmt.Sprintf("alloca %s, align %d", i.Elem, i.Align)
+	case OpLoad:
+		return fmt.Sprintf("load %s, %s, align %d", i.Elem, operand(i.Args[0]), i.Align)
+	case OpStore:
+		return fmt.Sprintf("store %s, %s, align %d", operand(i.Args[0]), operand(i.Args[1]), i.Align)
+	case OpGEP:
+		return fmt.Sprintf("getelementptr inbounds %s, %s", i.Elem, operands(i.Args))
+	case OpCall:
+		callee := i.Callee()
+		if i.Tail != NoTail {
+			return fmt.Sprintf("%s call %s %s(%s)", i.Tail, callee.Type().Signature(), ident(callee), operands(i.CallArgs()))
+		}
+		return fmt.Sprintf("call %s %s(%s)", callee.Type().Signature(), ident(callee), operands(i.CallArgs()))
+	case OpExtract:
+		return fmt.Sprintf("extractvalue %s, %d", operand(i.Args[0]), i.Index)
+	case OpPhi:
+		in := make([]string, 0, len(i.Incomings))
+		for _, c := range i.Incomings {
+			in = append(in, fmt.Sprintf("[ %s, %s ]", ident(c.Value), label(c.Block)))
+		}
+		return fmt.Sprintf("phi %s %s", i.Typ, strings.Join(in, ", "))
+	case OpBr:
+		if len(i.Args) == 0 {
+			return fmt.Sprintf("br label %s", label(i.Targets[0]))
+		}
+		return fmt.Sprintf("br %s, label %s, label %s", operand(i.Args[0]), label(i.Targets[0]), label(i.Targets[1]))
+	case OpRet:
+		if len(i.Args) == 0 {
+			return "ret void"
+		}
+		return fmt.Sprintf("ret %s", operand(i.Args[0]))
+	case OpUnreachable:
+		return "unreachable"
+	default:
+		panic(fmt.Sprintf("unknown instruction: %s", i.Op))
+	}
+}
+
+// operands formats vs as a comma separated list of typed operands.
+func operands(vs []Value) string {
+	ops := make([]string, 0, len(vs))
+	for _, v := range vs {
+		ops = append(ops, operand(v))
+	}
+	return strings.Join(ops, ", ")
+}
+
+// label formats bb as a label operand like `%entry`.
+func label(bb *BasicBlock) string {
+	if bb == nil {
+		return "<missing>"
+	}
+	return bb.Ident()
+}
@@ -0,0 +1,291 @@
+package ir_test
+
+import (
+	"testing"
+
+	"gotest.tools/assert"
+
+	"github.com/yuniruyuni/lang/ir"
+)
+
+func TestModule_String(t *testing.T) {
+	tests := []struct {
+		name  string
+		build func(m *ir.Module)
+		want  string
+	}{
+		{
+			name: "declaration",
+			build: func(m *ir.Module) {
+				m.NewFunction("printf", ir.FuncType(ir.I32, []ir.Type{"i8*"}, true))
+			},
+			want: `declare i32 @printf(i8*, ...)
+`,
+		},
+		{
+			name: "globals",
+			build: func(m *ir.Module) {
+				m.NewGlobal("x", ir.I32, "1")
+				s := m.NewGlobal(".str", ir.ArrayOf(3, ir.I8), `c"hi\00"`)
+				s.Const = true
+				s.Linkage = "private unnamed_addr"
+				s.Align = 1
+			},
+			want: `@x = global i32 1, align 4
+@.str = private unnamed_addr constant [3 x i8] c"hi\00", align 1
+`,
+		},
+		{
+			name: "arithmetic and memory",
+			build: func(m *ir.Module) {
+				x := &ir.Param{Name: "x", Typ: ir.I32}
+				f := m.NewFunction("f", ir.FuncType(ir.I32, []ir.Type{ir.I32}, false), x)
+
+				b := &ir.Builder{}
+				b.SetFunc(f)
+				slot := b.EmitNamed("y", ir.Alloca(ir.I32))
+				b.Store(b.Mul(x, ir.Int(ir.I32, 2)), slot)
+				b.Ret(b.Add(b.Load(slot), x))
+			},
+			want: `define i32 @f(i32 %x) {
+entry:
+  %y = alloca i32, align 4
+  %0 = mul i32 %x, 2
+  store i32 %0, i32* %y, align 4
+  %1 = load i32, i32* %y, align 4
+  %2 = add i32 %1, %x
+  ret i32 %2
+}
+`,
+		},
+		{
+			name: "branches and phi",
+			build: func(m *ir.Module) {
+				x := &ir.Param{Name: "x", Typ: ir.I32}
+				f := m.NewFunction("abs", ir.FuncType(ir.I32, []ir.Type{ir.I32}, false), x)
+
+				b := &ir.Builder{}
+				b.SetFunc(f)
+				neg, end := b.NewBlock(), b.NewBlock()
+				entry := b.Block
+				b.CondBr(b.ICmp(ir.SLT, x, ir.Int(ir.I32, 0)), neg, end)
+
+				b.SetBlock(neg)
+				y := b.Sub(ir.Int(ir.I32, 0), x)
+				b.Br(end)
+
+				b.SetBlock(end)
+				b.Ret(b.Phi(ir.I32,
+					ir.Incoming{Value: x, Block: entry},
+					ir.Incoming{Value: y, Block: neg},
+				))
+			},
+			want: `define i32 @abs(i32 %x) {
+entry:
+  %0 = icmp slt i32 %x, 0
+  br i1 %0, label %label.1, label %label.2
+
+label.1:
+  %1 = sub i32 0, %x
+  br label %label.2
+
+label.2:
+  %2 = phi i32 [ %x, %entry ], [ %1, %label.1 ]
+  ret i32 %2
+}
+`,
+		},
+		{
+			name: "overflow intrinsic",
+			build: func(m *ir.Module) {
+				add := m.NewFunction("llvm.sadd.with.overflow.i32", ir.FuncType(ir.StructOf(ir.I32, ir.I1), []ir.Type{ir.I32, ir.I32}, false))
+				x := &ir.Param{Name: "x", Typ: ir.I32}
+				f := m.NewFunction("inc", ir.FuncType(ir.I32, []ir.Type{ir.I32}, false), x)
+
+				b := &ir.Builder{}
+				b.SetFunc(f)
+				trap, ok := b.NewBlock(), b.NewBlock()
+				r := b.Call(add, x, ir.Int(ir.I32, 1))
+				b.CondBr(b.Extract(r, 1), trap, ok)
+
+				b.SetBlock(trap)
+				b.Unreachable()
+
+				b.SetBlock(ok)
+				b.Ret(b.Extract(r, 0))
+			},
+			want: `declare { i32, i1 } @llvm.sadd.with.overflow.i32(i32, i32)
+
+define i32 @inc(i32 %x) {
+entry:
+  %0 = call { i32, i1 } (i32,i32) @llvm.sadd.with.overflow.i32(i32 %x, i32 1)
+  %1 = extractvalue { i32, i1 } %0, 1
+  br i1 %1, label %label.1, label %label.2
+
+label.1:
+  unreachable
+
+label.2:
+  %2 = extractvalue { i32, i1 } %0, 0
+  ret i32 %2
+}
+`,
+		},
+		{
+			name: "calls",
+			build: func(m *ir.Module) {
+				s := m.NewGlobal(".str", ir.ArrayOf(3, ir.I8), `c"%d\00"`)
+				printf := m.NewFunction("printf", ir.FuncType(ir.I32, []ir.Type{"i8*"}, true))
+				f := m.NewFunction("main", ir.FuncType(ir.I32, nil, false))
+
+				b := &ir.Builder{}
+				b.SetFunc(f)
+				zero := ir.Int(ir.I64, 0)
+				b.Ret(b.Call(printf, ir.ConstGEP(s, zero, zero), ir.Int(ir.I32, 7)))
+			},
+			want: `@.str = global [3 x i8] c"%d\00", align 1
+
+declare i32 @printf(i8*, ...)
+
+define i32 @main() {
+entry:
+  %0 = call i32 (i8*,...) @printf(i8* getelementptr inbounds ([3 x i8], [3 x i8]* @.str, i64 0, i64 0), i32 7)
+  ret i32 %0
+}
+`,
+		},
+		{
+			name: "target",
+			build: func(m *ir.Module) {
+				arm, err := ir.LookupTarget("armv7-unknown-linux-gnueabihf")
+				if err != nil {
+					panic(err)
+				}
+				m.Target = arm
+				m.NewGlobal("p", "i8*", "null")
+				f := m.NewFunction("f", ir.FuncType(ir.I64, nil, false))
+
+				b := &ir.Builder{Target: arm}
+				b.SetFunc(f)
+				slot := b.Alloca("i32*")
+				b.Store(ir.Null("i32*"), slot)
+				b.Ret(b.Load(b.Alloca(ir.I64)))
+			},
+			want: `target datalayout = "e-m:e-p:32:32-Fi8-i64:64-v128:64:128-a:0:32-n32-S64"
+target triple = "armv7-unknown-linux-gnueabihf"
+
+@p = global i8* null, align 4
+
+define i64 @f() {
+entry:
+  %0 = alloca i32*, align 4
+  store i32* null, i32** %0, align 4
+  %1 = alloca i64, align 8
+  %2 = load i64, i64* %1, align 8
+  ret i64 %2
+}
+`,
+		},
+		{
+			name: "debug information",
+			build: func(m *ir.Module) {
+				m.NewMetadata(`!DIFile(filename: "main.yuni", directory: "/src")`)
+				m.AddNamedMetadata("llvm.dbg.cu", m.NewDistinctMetadata(`!DICompileUnit(language: DW_LANG_C99, file: !0)`))
+				sp := m.NewDistinctMetadata(`!DISubprogram(name: "f", file: !0)`)
+				v := m.NewMetadata(`!DILocalVariable(name: "x", arg: 1, scope: !2, file: !0)`)
+				md := ir.MetadataType
+				dbg := m.NewFunction("llvm.dbg.value", ir.FuncType(ir.Void, []ir.Type{md, md, md}, false))
+
+				x := &ir.Param{Name: "x", Typ: ir.I32}
+				f := m.NewFunction("f", ir.FuncType(ir.I32, []ir.Type{ir.I32}, false), x)
+				f.Dbg = sp
+				b := &ir.Builder{}
+				b.SetFunc(f)
+				b.Loc = m.NewMetadata(`!DILocation(line: 1, column: 1, scope: !2)`)
+				b.Call(dbg, &ir.Meta{Value: x}, &ir.Meta{Node: v}, &ir.Meta{Text: "!DIExpression()"})
+				b.Ret(x)
+			},
+			want: `declare void @llvm.dbg.value(metadata, metadata, metadata)
+
+define i32 @f(i32 %x) !dbg !2 {
+entry:
+  call void (metadata,metadata,metadata) @llvm.dbg.value(metadata i32 %x, metadata !3, metadata !DIExpression()), !dbg !4
+  ret i32 %x, !dbg !4
+}
+
+!llvm.dbg.cu = !{!1}
+!0 = !DIFile(filename: "main.yuni", directory: "/src")
+!1 = distinct !DICompileUnit(language: DW_LANG_C99, file: !0)
+!2 = distinct !DISubprogram(name: "f", file: !0)
+!3 = !DILocalVariable(name: "x", arg: 1, scope: !2, file: !0)
+!4 = !DILocation(line: 1, column: 1, scope: !2)
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := ir.NewModule()
+			tt.build(m)
+			assert.Equal(t, tt.want, m.String())
+		})
+	}
+}
+
+func TestModule_MIR(t *testing.T) {
+	m := ir.NewModule()
+	s := m.NewGlobal(".str", ir.ArrayOf(3, ir.I8), `c"%d\00"`)
+	s.Const = true
+	printf := m.NewFunction("printf", ir.FuncType(ir.I32, []ir.Type{"i8*"}, true))
+
+	// abs loops back to the check once, so that the graph has a back edge and an unreachable block.
+	x := &ir.Param{Name: "x", Typ: ir.I32}
+	f := m.NewFunction("abs", ir.FuncType(ir.I32, []ir.Type{ir.I32}, false), x)
+	b := &ir.Builder{}
+	b.SetFunc(f)
+	check, neg, end, dead := b.NewBlock(), b.NewBlock(), b.NewBlock(), b.NewBlock()
+	entry := b.Block
+	b.Br(check)
+
+	b.SetBlock(check)
+	v := b.Phi(ir.I32, ir.Incoming{Value: x, Block: entry})
+	b.CondBr(b.ICmp(ir.SLT, v, ir.Int(ir.I32, 0)), neg, end)
+
+	b.SetBlock(neg)
+	y := b.Sub(ir.Int(ir.I32, 0), v)
+	v.AddIncoming(y, neg)
+	b.Br(check)
+
+	b.SetBlock(end)
+	zero := ir.Int(ir.I64, 0)
+	b.Call(printf, ir.ConstGEP(s, zero, zero), v)
+	b.Ret(v)
+
+	b.SetBlock(dead)
+	b.Unreachable()
+
+	assert.Equal(t, `const @.str: [3 x i8] = c"%d\00"
+
+extern @printf(i8*, ...) -> i32
+
+func @abs(%x: i32) -> i32 {
+entry:  ; preds: -  succs: label.1  idom: -
+  br label %label.1
+
+label.1:  ; preds: entry, label.2  succs: label.2, label.3  idom: entry
+  %0: i32 = phi i32 [ %x, %entry ], [ %2, %label.2 ]
+  %1: i1 = icmp slt i32 %0, 0
+  br i1 %1, label %label.2, label %label.3
+
+label.2:  ; preds: label.1  succs: label.1  idom: label.1
+  %2: i32 = sub i32 0, %0
+  br label %label.1
+
+label.3:  ; preds: label.1  succs: -  idom: label.1
+  %3: i32 = call i32 (i8*,...) @printf(i8* getelementptr inbounds ([3 x i8], [3 x i8]* @.str, i64 0, i64 0), i32 %0)
+  ret i32 %0
+
+label.4:  ; preds: -  succs: -  idom: unreachable
+  unreachable
+}
+`, m.MIR())
+}
@@ -0,0 +1,118 @@
+package ir
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+)
+
+// Target describes the machine which generated code runs on.
+// It decides the module header and sizes of pointers and integers.
+type Target struct {
+	// Triple is the target triple like `x86_64-pc-linux-gnu`.
+	Triple string
+	// DataLayout is the data layout string which LLVM expects for Triple.
+	DataLayout string
+	// PointerBits is the size of pointers in bits.
+	PointerBits int
+	// Int64Align is the alignment of i64 in bytes, which is 4 on 32-bit x86.
+	Int64Align int
+}
+
+// DefaultTarget is x86-64 Linux, whose sizes are used for a module without target.
+var DefaultTarget = mustLookupTarget("x86_64-pc-linux-gnu")
+
+// layouts lists supported targets by the architecture and the operating system in triples.
+// An empty system matches any system.
+var layouts = []struct {
+	arches      []string
+	system      string
+	layout      string
+	pointerBits int
+	int64Align  int
+}{
+	{arches: []string{"x86_64"}, system: "darwin", layout: "e-m:o-p270:32:32-p271:32:32-p272:64:64-i64:64-f80:128-n8:16:32:64-S128", pointerBits: 64, int64Align: 8},
+	{arches: []string{"x86_64"}, layout: "e-m:e-p270:32:32-p271:32:32-p272:64:64-i64:64-f80:128-n8:16:32:64-S128", pointerBits: 64, int64Align: 8},
+	{arches: []string{"i386", "i486", "i586", "i686"}, layout: "e-m:e-p:32:32-p270:32:32-p271:32:32-p272:64:64-f64:32:64-f80:32-n8:16:32-S128", pointerBits: 32, int64Align: 4},
+	{arches: []string{"aarch64", "arm64"}, system: "darwin", layout: "e-m:o-i64:64-i128:128-n32:64-S128", pointerBits: 64, int64Align: 8},
+	{arches: []string{"aarch64", "arm64"}, layout: "e-m:e-i8:8:32-i16:16:32-i64:64-i128:128-n32:64-S128", pointerBits: 64, int64Align: 8},
+	{arches: []string{"arm", "armv6", "armv7", "armv7a", "armv7l"}, layout: "e-m:e-p:32:32-Fi8-i64:64-v128:64:128-a:0:32-n32-S64", pointerBits: 32, int64Align: 8},
+	{arches: []string{"riscv64"}, layout: "e-m:e-p:64:64-i64:64-i128:128-n64-S128", pointerBits: 64, int64Align: 8},
+	{arches: []string{"riscv32"}, layout: "e-m:e-p:32:32-i64:64-n32-S128", pointerBits: 32, int64Align: 8},
+}
+
+// LookupTarget finds the target for triple.
+func LookupTarget(triple string) (*Target, error) {
+	parts := strings.Split(triple, "-")
+	if len(parts) < 2 {
+		return nil, fmt.Errorf("invalid target triple %s, want the form like x86_64-pc-linux-gnu", triple)
+	}
+	arch := parts[0]
+	darwin := strings.Contains(triple, "-darwin") || strings.Contains(triple, "-macos") || strings.Contains(triple, "-ios")
+	for _, l := range layouts {
+		if l.system == "darwin" && !darwin {
+			continue
+		}
+		for _, a := range l.arches {
+			if a == arch {
+				return &Target{Triple: triple, DataLayout: l.layout, PointerBits: l.pointerBits, Int64Align: l.int64Align}, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("unsupported target triple %s", triple)
+}
+
+func mustLookupTarget(triple string) *Target {
+	t, err := LookupTarget(triple)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// hostTriples maps GOOS/GOARCH of hosts into their triples.
+var hostTriples = map[string]string{
+	"linux/amd64":   "x86_64-pc-linux-gnu",
+	"linux/386":     "i686-pc-linux-gnu",
+	"linux/arm64":   "aarch64-unknown-linux-gnu",
+	"linux/arm":     "armv7-unknown-linux-gnueabihf",
+	"linux/riscv64": "riscv64-unknown-linux-gnu",
+	"darwin/amd64":  "x86_64-apple-darwin",
+	"darwin/arm64":  "arm64-apple-macosx",
+}
+
+// HostTarget returns the target this compiler runs on, or nil if it is not supported.
+func HostTarget() *Target {
+	triple, ok := hostTriples[runtime.GOOS+"/"+runtime.GOARCH]
+	if !ok {
+		return nil
+	}
+	return mustLookupTarget(triple)
+}
+
+// Align returns the alignment in bytes for a value of type ty.
+func (t *Target) Align(ty Type) int {
+	if ty.IsPointer() {
+		return t.PointerBits / 8
+	}
+	if ty == I64 {
+		return t.Int64Align
+	}
+	if ty.IsInt() && ty.Bits() >= 8 {
+		return ty.Bits() / 8
+	}
+	return 1
+}
+
+// IntPtr returns the integer type as wide as pointers, which indexes `getelementptr`.
+func (t *Target) IntPtr() Type {
+	if t.PointerBits == 32 {
+		return I32
+	}
+	return I64
+}
+
+// Header prints the module header like `target triple = "x86_64-pc-linux-gnu"`.
+func (t *Target) Header() string {
+	return fmt.Sprintf("target datalayout = %q\ntarget triple = %q", t.DataLayout, t.Triple)
+}
@@ -0,0 +1,77 @@
+package ir_test
+
+import (
+	"testing"
+
+	"gotest.tools/assert"
+
+	"github.com/yuniruyuni/lang/ir"
+)
+
+func TestLookupTarget(t *testing.T) {
+	tests := []struct {
+		triple      string
+		wantLayout  string
+		wantPointer int
+		wantAligns  map[ir.Type]int
+		wantIntPtr  ir.Type
+	}{
+		{
+			triple:      "x86_64-pc-linux-gnu",
+			wantLayout:  "e-m:e-p270:32:32-p271:32:32-p272:64:64-i64:64-f80:128-n8:16:32:64-S128",
+			wantPointer: 64,
+			wantAligns:  map[ir.Type]int{ir.I1: 1, ir.I32: 4, ir.I64: 8, "i8*": 8},
+			wantIntPtr:  ir.I64,
+		},
+		{
+			triple:      "armv7-unknown-linux-gnueabihf",
+			wantLayout:  "e-m:e-p:32:32-Fi8-i64:64-v128:64:128-a:0:32-n32-S64",
+			wantPointer: 32,
+			wantAligns:  map[ir.Type]int{ir.I32: 4, ir.I64: 8, "i8*": 4, "i32 (i32)*": 4},
+			wantIntPtr:  ir.I32,
+		},
+		{
+			triple:      "i686-pc-linux-gnu",
+			wantLayout:  "e-m:e-p:32:32-p270:32:32-p271:32:32-p272:64:64-f64:32:64-f80:32-n8:16:32-S128",
+			wantPointer: 32,
+			wantAligns:  map[ir.Type]int{ir.I64: 4, "i8*": 4},
+			wantIntPtr:  ir.I32,
+		},
+		{
+			triple:      "arm64-apple-macosx",
+			wantLayout:  "e-m:o-i64:64-i128:128-n32:64-S128",
+			wantPointer: 64,
+			wantAligns:  map[ir.Type]int{ir.I64: 8, "i8*": 8},
+			wantIntPtr:  ir.I64,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.triple, func(t *testing.T) {
+			target, err := ir.LookupTarget(tt.triple)
+			assert.NilError(t, err)
+			assert.Equal(t, tt.triple, target.Triple)
+			assert.Equal(t, tt.wantLayout, target.DataLayout)
+			assert.Equal(t, tt.wantPointer, target.PointerBits)
+			assert.Equal(t, tt.wantIntPtr, target.IntPtr())
+			for ty, want := range tt.wantAligns {
+				assert.Equal(t, want, target.Align(ty), "align of %s", ty)
+			}
+		})
+	}
+}
+
+func TestLookupTarget_Errors(t *testing.T) {
+	tests := []struct {
+		triple string
+		want   string
+	}{
+		{triple: "x86_64", want: "invalid target triple x86_64, want the form like x86_64-pc-linux-gnu"},
+		{triple: "mips-unknown-linux-gnu", want: "unsupported target triple mips-unknown-linux-gnu"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.triple, func(t *testing.T) {
+			_, err := ir.LookupTarget(tt.triple)
+			assert.Error(t, err, tt.want)
+		})
+	}
+}
@@ -0,0 +1,161 @@
+package ir
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Type is a type written in LLVM IR syntax like `i32`, `i8*` or `i32 (i32)*`.
+type Type string
+
+const (
+	Void Type = "void"
+	I1   Type = "i1"
+	I8   Type = "i8"
+	I32  Type = "i32"
+	I64  Type = "i64"
+
+	// MetadataType is the type of metadata operands of intrinsics.
+	MetadataType Type = "metadata"
+)
+
+// intBits holds the bit width of each integer type.
+var intBits = map[Type]int{
+	I1:  1,
+	I8:  8,
+	I32: 32,
+	I64: 64,
+}
+
+// FuncType makes a function pointer type like `i32 (i32,i32)*`
+// for a function that takes params and returns ret.
+func FuncType(ret Type, params []Type, variadic bool) Type {
+	ps := make([]string, 0, len(params)+1)
+	for _, p := range params {
+		ps = append(ps, string(p))
+	}
+	if variadic {
+		ps = append(ps, "...")
+	}
+	return Type(string(ret) + " (" + strings.Join(ps, ",") + ")*")
+}
+
+// PointerTo makes the pointer type for t.
+func PointerTo(t Type) Type {
+	return t + "*"
+}
+
+// ArrayOf makes the array type which has n elements of t.
+func ArrayOf(n int, t Type) Type {
+	return Type(fmt.Sprintf("[%d x %s]", n, t))
+}
+
+// StructOf makes the literal struct type which has fields ts, like `{ i32, i1 }`.
+func StructOf(ts ...Type) Type {
+	fs := make([]string, 0, len(ts))
+	for _, t := range ts {
+		fs = append(fs, string(t))
+	}
+	return Type("{ " + strings.Join(fs, ", ") + " }")
+}
+
+// Fields returns the types of fields of the literal struct type t.
+func (t Type) Fields() []Type {
+	inner := strings.TrimSuffix(strings.TrimPrefix(string(t), "{ "), " }")
+	fs := []Type{}
+	for _, f := range strings.Split(inner, ", ") {
+		fs = append(fs, Type(f))
+	}
+	return fs
+}
+
+// IsInt reports whether t is an integer type.
+func (t Type) IsInt() bool {
+	_, ok := intBits[t]
+	return ok
+}
+
+// Bits returns the bit width of the integer type t.
+func (t Type) Bits() int {
+	return intBits[t]
+}
+
+// IsFunc reports whether t is a function pointer type.
+func (t Type) IsFunc() bool {
+	return strings.HasSuffix(string(t), ")*")
+}
+
+// IsPointer reports whether t is a pointer type (including function pointers).
+func (t Type) IsPointer() bool {
+	return strings.HasSuffix(string(t), "*")
+}
+
+// Elem returns the type which the pointer type t points to.
+func (t Type) Elem() Type {
+	return Type(strings.TrimSuffix(string(t), "*"))
+}
+
+// Signature returns the function type which t points to,
+// like `i32 (i32,i32)` for `i32 (i32,i32)*`.
+// It is used as a callee type for `call` instructions.
+func (t Type) Signature() Type {
+	return t.Elem()
+}
+
+// Return returns the result type of the function pointer type t.
+func (t Type) Return() Type {
+	sig := string(t.Signature())
+	return Type(strings.TrimSpace(sig[:t.paramsBegin()]))
+}
+
+// Params returns the parameter types of the function pointer type t.
+// A variadic function has "..." as its last element.
+func (t Type) Params() []Type {
+	sig := string(t.Signature())
+	inner := sig[t.paramsBegin()+1 : len(sig)-1]
+	if inner == "" {
+		return []Type{}
+	}
+
+	ps := []Type{}
+	depth, from := 0, 0
+	for i, ch := range inner {
+		switch ch {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				ps = append(ps, Type(strings.TrimSpace(inner[from:i])))
+				from = i + 1
+			}
+		}
+	}
+	return append(ps, Type(strings.TrimSpace(inner[from:])))
+}
+
+// IsVariadic reports whether the function pointer type t takes variadic arguments.
+func (t Type) IsVariadic() bool {
+	ps := t.Params()
+	return len(ps) > 0 && ps[len(ps)-1] == "..."
+}
+
+// paramsBegin finds the position of the parenthesis
+// that opens the parameter list of the function pointer type t.
+func (t Type) paramsBegin() int {
+	sig := string(t.Signature())
+	depth := 0
+	for i := len(sig) - 1; i >= 0; i-- {
+		switch sig[i] {
+		case ')':
+			depth++
+		case '(':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	panic("not a function type: " + string(t))
+}
@@ -0,0 +1,119 @@
+package ir
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Value is anything that can be an operand of an instruction.
+type Value interface {
+	// Type returns the type of this value.
+	Type() Type
+	// Ident returns how this value is written as an operand, like `%1`, `@f` or `42`.
+	Ident() string
+}
+
+// Const is a constant value like an integer or a constant expression.
+type Const struct {
+	Typ  Type
+	Text string
+	// Refs holds values referred in Text, like the global of a constant getelementptr.
+	Refs []Value
+}
+
+func (c *Const) Type() Type {
+	return c.Typ
+}
+
+func (c *Const) Ident() string {
+	return c.Text
+}
+
+// Int makes an integer constant v typed as t.
+func Int(t Type, v int) *Const {
+	return &Const{Typ: t, Text: fmt.Sprintf("%d", v)}
+}
+
+// Zero makes the zero value of t, like `0` or `null`.
+func Zero(t Type) *Const {
+	if t.IsPointer() {
+		return Null(t)
+	}
+	return Int(t, 0)
+}
+
+// IntValue returns the value of v if v is an integer constant.
+func IntValue(v Value) (int64, bool) {
+	c, ok := v.(*Const)
+	if !ok || !c.Typ.IsInt() {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(c.Text, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return n, true
+}
+
+// Null makes the null pointer constant typed as t.
+func Null(t Type) *Const {
+	return &Const{Typ: t, Text: "null"}
+}
+
+// ConstGEP makes the constant `getelementptr inbounds` expression
+// which points an element of the global g.
+func ConstGEP(g *Global, idx ...Value) *Const {
+	ops := []string{fmt.Sprintf("%s %s", g.Type(), g.Ident())}
+	for _, i := range idx {
+		ops = append(ops, operand(i))
+	}
+	return &Const{
+		Typ:  PointerTo(elemAt(g.Elem, len(idx))),
+		Text: fmt.Sprintf("getelementptr inbounds (%s, %s)", g.Elem, strings.Join(ops, ", ")),
+		Refs: []Value{g},
+	}
+}
+
+// elemAt returns the element type reached by indexing t with n indices.
+// The first index steps over the pointer, following ones step into arrays.
+func elemAt(t Type, n int) Type {
+	for i := 1; i < n; i++ {
+		s := string(t)
+		if strings.HasPrefix(s, "[") {
+			t = Type(strings.TrimSuffix(s[strings.Index(s, " x ")+3:], "]"))
+		}
+	}
+	return t
+}
+
+// Param is a named parameter of a function.
+type Param struct {
+	Name string
+	Typ  Type
+}
+
+func (p *Param) Type() Type {
+	return p.Typ
+}
+
+func (p *Param) Ident() string {
+	return "%" + p.Name
+}
+
+// operand formats v as a typed operand like `i32 %1`.
+func operand(v Value) string {
+	if v == nil {
+		return ident(v)
+	}
+	return fmt.Sprintf("%s %s", v.Type(), v.Ident())
+}
+
+// ident formats v as an operand without type like `%1`.
+// A missing operand is printed visibly so broken IR can be reported.
+func ident(v Value) string {
+	if v == nil {
+		return "<missing>"
+	}
+	return v.Ident()
+}
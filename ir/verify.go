@@ -0,0 +1,313 @@
+package ir
+
+import (
+	"fmt"
+)
+
+// VerifyError reports that a generated function is not valid LLVM IR.
+// It is always a bug of the compiler, not of the compiled program.
+type VerifyError struct {
+	Func   *Function
+	Reason string
+}
+
+func (e *VerifyError) Error() string {
+	return fmt.Sprintf("compiler bug: invalid IR in %s: %s\n%s", e.Func.Ident(), e.Reason, e.Func)
+}
+
+// Verify checks every function in m meets the rules LLVM requires.
+func Verify(m *Module) error {
+	for _, f := range m.Functions {
+		if err := VerifyFunc(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// VerifyFunc checks f meets the rules LLVM requires:
+// unnamed registers are numbered sequentially, every referred label and register is defined,
+// phis have an incoming value for each predecessor, every block ends with a terminator,
+// every definition dominates its uses and operands are typed as their instructions take.
+func VerifyFunc(f *Function) error {
+	if f.IsDecl() {
+		return nil
+	}
+	v := &verifier{
+		f:      f,
+		blocks: map[*BasicBlock]bool{},
+		params: map[*Param]bool{},
+		pos:    map[*Instr]int{},
+	}
+	if err := v.verify(); err != nil {
+		return &VerifyError{Func: f, Reason: err.Error()}
+	}
+	return nil
+}
+
+type verifier struct {
+	f      *Function
+	blocks map[*BasicBlock]bool
+	params map[*Param]bool
+	// pos is the position of each instruction in its block.
+	pos map[*Instr]int
+	dom *DomTree
+}
+
+func (v *verifier) verify() error {
+	if err := v.verifyNames(); err != nil {
+		return err
+	}
+	for _, bb := range v.f.Blocks {
+		if err := v.verifyBlock(bb); err != nil {
+			return err
+		}
+	}
+
+	v.dom = NewDomTree(v.f)
+	for _, bb := range v.f.Blocks {
+		if err := v.verifyPhis(bb); err != nil {
+			return err
+		}
+		for _, i := range bb.Instrs {
+			if err := v.verifyUses(i); err != nil {
+				return err
+			}
+			if err := verifyTypes(i); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// verifyNames checks names are unique and unnamed registers are numbered from 0 in order.
+func (v *verifier) verifyNames() error {
+	names := map[string]bool{}
+	define := func(n string) error {
+		if names[n] {
+			return fmt.Errorf("%%%s is defined twice", n)
+		}
+		names[n] = true
+		return nil
+	}
+
+	for _, p := range v.f.Params {
+		v.params[p] = true
+		if err := define(p.Name); err != nil {
+			return err
+		}
+	}
+
+	next := 0
+	for _, bb := range v.f.Blocks {
+		v.blocks[bb] = true
+		if err := define(bb.Name); err != nil {
+			return err
+		}
+		for k, i := range bb.Instrs {
+			v.pos[i] = k
+			if !i.HasResult() {
+				continue
+			}
+			if i.Name != "" {
+				if err := define(i.Name); err != nil {
+					return err
+				}
+				continue
+			}
+			if i.ID != next {
+				return fmt.Errorf("%s is numbered out of order, expected %%%d", i.Ident(), next)
+			}
+			next++
+		}
+	}
+	return nil
+}
+
+// verifyBlock checks bb ends with a terminator and branches only into blocks of this function.
+func (v *verifier) verifyBlock(bb *BasicBlock) error {
+	if bb.Terminator() == nil {
+		return fmt.Errorf("block %%%s doesn't end with a terminator", bb.Name)
+	}
+	for k, i := range bb.Instrs {
+		if i.Parent != bb {
+			return fmt.Errorf("`%s` in block %%%s belongs to another block", i, bb.Name)
+		}
+		if i.IsTerminator() && k != len(bb.Instrs)-1 {
+			return fmt.Errorf("terminator `%s` is in the middle of block %%%s", i, bb.Name)
+		}
+		for _, t := range i.Targets {
+			if !v.blocks[t] {
+				return fmt.Errorf("`%s` branches into undefined label %s", i, label(t))
+			}
+		}
+	}
+	if ret := bb.Terminator(); ret.Op == OpRet {
+		var t Type = Void
+		if len(ret.Args) > 0 {
+			t = ret.Args[0].Type()
+		}
+		if t != v.f.Ret() {
+			return fmt.Errorf("`%s` returns %s from a function returning %s", ret, t, v.f.Ret())
+		}
+	}
+	return nil
+}
+
+// verifyPhis checks phis are at the top of bb and have exactly one incoming value for each predecessor.
+func (v *verifier) verifyPhis(bb *BasicBlock) error {
+	preds := v.f.Preds(bb)
+	isPred := map[*BasicBlock]bool{}
+	for _, p := range preds {
+		isPred[p] = true
+	}
+
+	top := true
+	for _, i := range bb.Instrs {
+		if i.Op != OpPhi {
+			top = false
+			continue
+		}
+		if !top {
+			return fmt.Errorf("`%s` is not at the top of block %%%s", i, bb.Name)
+		}
+
+		seen := map[*BasicBlock]bool{}
+		for _, in := range i.Incomings {
+			if in.Block == nil || !v.blocks[in.Block] {
+				return fmt.Errorf("`%s` has an incoming value from an undefined label", i)
+			}
+			if !isPred[in.Block] {
+				return fmt.Errorf("`%s` has an incoming value from %%%s which is not a predecessor of %%%s", i, in.Block.Name, bb.Name)
+			}
+			if seen[in.Block] {
+				return fmt.Errorf("`%s` has incoming values from %%%s twice", i, in.Block.Name)
+			}
+			seen[in.Block] = true
+		}
+		for _, p := range preds {
+			if !seen[p] {
+				return fmt.Errorf("`%s` has no incoming value from predecessor %%%s", i, p.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// verifyUses checks every operand of i is defined and dominates i.
+// A value used by a phi must dominate the end of the incoming block instead.
+func (v *verifier) verifyUses(i *Instr) error {
+	for _, a := range i.Args {
+		if err := v.verifyUse(i, a, i.Parent, v.pos[i]); err != nil {
+			return err
+		}
+	}
+	for _, in := range i.Incomings {
+		if err := v.verifyUse(i, in.Value, in.Block, len(in.Block.Instrs)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// verifyUse checks the operand x of i is available at the position at in the block bb.
+func (v *verifier) verifyUse(i *Instr, x Value, bb *BasicBlock, at int) error {
+	switch x := x.(type) {
+	case nil:
+		return fmt.Errorf("`%s` has a missing operand", i.Op)
+	case *Meta:
+		if x.Value != nil {
+			return v.verifyUse(i, x.Value, bb, at)
+		}
+	case *Param:
+		if !v.params[x] {
+			return fmt.Errorf("`%s` uses undefined parameter %s", i, x.Ident())
+		}
+	case *BasicBlock:
+		if !v.blocks[x] {
+			return fmt.Errorf("`%s` uses undefined label %s", i, x.Ident())
+		}
+	case *Instr:
+		if x.Parent == nil || !v.blocks[x.Parent] || x.Parent.Parent != v.f {
+			return fmt.Errorf("`%s` uses %s which is not defined in this function", i, x.Ident())
+		}
+		if !x.HasResult() {
+			return fmt.Errorf("`%s` uses `%s` which has no result", i, x)
+		}
+		// LLVM doesn't check dominance in unreachable blocks.
+		if !v.dom.Reachable(bb) {
+			return nil
+		}
+		if x.Parent == bb {
+			if v.pos[x] >= at {
+				return fmt.Errorf("`%s` uses %s before its definition", i, x.Ident())
+			}
+			return nil
+		}
+		if !v.dom.Dominates(x.Parent, bb) {
+			return fmt.Errorf("definition of %s in %%%s doesn't dominate its use `%s`", x.Ident(), x.Parent.Name, i)
+		}
+	}
+	return nil
+}
+
+// verifyTypes checks operands of i are typed as i takes.
+func verifyTypes(i *Instr) error {
+	mismatch := func(x Value, want Type) error {
+		return fmt.Errorf("`%s` takes %s typed %s, not %s", i, x.Ident(), want, x.Type())
+	}
+
+	switch i.Op {
+	case OpAdd, OpSub, OpMul, OpSDiv:
+		if !i.Typ.IsInt() {
+			return fmt.Errorf("`%s` computes %s which is not an integer", i, i.Typ)
+		}
+		for _, x := range i.Args {
+			if x.Type() != i.Typ {
+				return mismatch(x, i.Typ)
+			}
+		}
+	case OpICmp:
+		if x, y := i.Args[0], i.Args[1]; x.Type() != y.Type() {
+			return mismatch(y, x.Type())
+		}
+	case OpZExt, OpSExt, OpTrunc:
+		from := i.Args[0].Type()
+		widens := from.Bits() < i.Typ.Bits()
+		if !from.IsInt() || !i.Typ.IsInt() || from == i.Typ || widens == (i.Op == OpTrunc) {
+			return fmt.Errorf("`%s` cannot convert %s into %s", i, from, i.Typ)
+		}
+	case OpStore:
+		if x, ptr := i.Args[0], i.Args[1]; ptr.Type() != PointerTo(x.Type()) {
+			return mismatch(ptr, PointerTo(x.Type()))
+		}
+	case OpCall:
+		ps := i.Callee().Type().Params()
+		args := i.CallArgs()
+		if i.Callee().Type().IsVariadic() {
+			ps = ps[:len(ps)-1]
+		} else if len(args) != len(ps) {
+			return fmt.Errorf("`%s` passes %d arguments to %d parameters", i, len(args), len(ps))
+		}
+		for k, p := range ps {
+			if k >= len(args) {
+				return fmt.Errorf("`%s` passes %d arguments to %d parameters", i, len(args), len(ps))
+			}
+			if _, ok := args[k].(*Meta); !ok && args[k].Type() != p {
+				return mismatch(args[k], p)
+			}
+		}
+	case OpPhi:
+		for _, in := range i.Incomings {
+			if in.Value.Type() != i.Typ {
+				return mismatch(in.Value, i.Typ)
+			}
+		}
+	case OpBr:
+		if len(i.Args) > 0 && i.Args[0].Type() != I1 {
+			return mismatch(i.Args[0], I1)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,260 @@
+package ir_test
+
+import (
+	"strings"
+	"testing"
+
+	"gotest.tools/assert"
+
+	"github.com/yuniruyuni/lang/ir"
+)
+
+// newFunc makes a function `i32 @f(i32 %x)` and a builder placed at its entry block.
+func newFunc() (*ir.Function, *ir.Param, *ir.Builder) {
+	x := &ir.Param{Name: "x", Typ: ir.I32}
+	f := ir.NewModule().NewFunction("f", ir.FuncType(ir.I32, []ir.Type{ir.I32}, false), x)
+	b := &ir.Builder{}
+	b.SetFunc(f)
+	return f, x, b
+}
+
+func TestVerifyFunc(t *testing.T) {
+	zero := ir.Int(ir.I32, 0)
+
+	tests := []struct {
+		name    string
+		build   func() *ir.Function
+		wantErr string
+	}{
+		{
+			name: "valid if expression",
+			build: func() *ir.Function {
+				f, x, b := newFunc()
+				then, els, end := b.NewBlock(), b.NewBlock(), b.NewBlock()
+				b.CondBr(b.ICmp(ir.SLT, x, zero), then, els)
+				b.SetBlock(then)
+				y := b.Sub(zero, x)
+				b.Br(end)
+				b.SetBlock(els)
+				b.Br(end)
+				b.SetBlock(end)
+				b.Ret(b.Phi(ir.I32,
+					ir.Incoming{Value: y, Block: then},
+					ir.Incoming{Value: x, Block: els},
+				))
+				return f
+			},
+		},
+		{
+			name: "valid loop",
+			build: func() *ir.Function {
+				f, x, b := newFunc()
+				entry, loop, end := b.Block, b.NewBlock(), b.NewBlock()
+				b.Br(loop)
+				b.SetBlock(loop)
+				i := b.Phi(ir.I32, ir.Incoming{Value: x, Block: entry})
+				next := b.Sub(i, ir.Int(ir.I32, 1))
+				i.AddIncoming(next, loop)
+				b.CondBr(b.ICmp(ir.SLT, zero, next), loop, end)
+				b.SetBlock(end)
+				b.Ret(next)
+				return f
+			},
+		},
+		{
+			name: "missing terminator",
+			build: func() *ir.Function {
+				f, x, b := newFunc()
+				b.Add(x, x)
+				return f
+			},
+			wantErr: "doesn't end with a terminator",
+		},
+		{
+			name: "terminator in the middle",
+			build: func() *ir.Function {
+				f, x, b := newFunc()
+				b.Ret(x)
+				b.Ret(x)
+				return f
+			},
+			wantErr: "in the middle of block %entry",
+		},
+		{
+			name: "registers numbered out of order",
+			build: func() *ir.Function {
+				f, x, b := newFunc()
+				y := b.Add(x, x)
+				y.ID = 1
+				b.Ret(y)
+				return f
+			},
+			wantErr: "%1 is numbered out of order, expected %0",
+		},
+		{
+			name: "register defined twice",
+			build: func() *ir.Function {
+				f, x, b := newFunc()
+				y := b.EmitNamed("y", ir.Add(x, x))
+				y.Name = "x"
+				b.Ret(y)
+				return f
+			},
+			wantErr: "%x is defined twice",
+		},
+		{
+			name: "branch into an undefined label",
+			build: func() *ir.Function {
+				f, _, b := newFunc()
+				b.Br(b.NewBlock())
+				return f
+			},
+			wantErr: "branches into undefined label %label.1",
+		},
+		{
+			name: "register of another function",
+			build: func() *ir.Function {
+				_, x, other := newFunc()
+				y := other.Add(x, x)
+
+				f, _, b := newFunc()
+				b.Ret(y)
+				return f
+			},
+			wantErr: "uses %0 which is not defined in this function",
+		},
+		{
+			name: "use before definition",
+			build: func() *ir.Function {
+				f, x, b := newFunc()
+				y := ir.Add(x, x)
+				z := b.Add(y, x)
+				b.Emit(y)
+				b.Ret(z)
+				return f
+			},
+			wantErr: "before its definition",
+		},
+		{
+			name: "definition doesn't dominate its use",
+			build: func() *ir.Function {
+				f, x, b := newFunc()
+				then, end := b.NewBlock(), b.NewBlock()
+				b.CondBr(b.ICmp(ir.SLT, x, zero), then, end)
+				b.SetBlock(then)
+				y := b.Sub(zero, x)
+				b.Br(end)
+				b.SetBlock(end)
+				b.Ret(y)
+				return f
+			},
+			wantErr: "definition of %1 in %label.1 doesn't dominate its use `ret i32 %1`",
+		},
+		{
+			name: "phi misses a predecessor",
+			build: func() *ir.Function {
+				f, x, b := newFunc()
+				entry, loop := b.Block, b.NewBlock()
+				b.Br(loop)
+				b.SetBlock(loop)
+				b.Phi(ir.I32, ir.Incoming{Value: x, Block: entry})
+				b.Br(loop)
+				return f
+			},
+			wantErr: "has no incoming value from predecessor %label.1",
+		},
+		{
+			name: "phi from a block which is not a predecessor",
+			build: func() *ir.Function {
+				f, x, b := newFunc()
+				entry, end := b.Block, b.NewBlock()
+				b.Br(end)
+				b.SetBlock(end)
+				b.Ret(b.Phi(ir.I32,
+					ir.Incoming{Value: x, Block: entry},
+					ir.Incoming{Value: x, Block: end},
+				))
+				return f
+			},
+			wantErr: "from %label.1 which is not a predecessor of %label.1",
+		},
+		{
+			name: "phi value doesn't dominate the incoming block",
+			build: func() *ir.Function {
+				f, x, b := newFunc()
+				then, els, end := b.NewBlock(), b.NewBlock(), b.NewBlock()
+				b.CondBr(b.ICmp(ir.SLT, x, zero), then, els)
+				b.SetBlock(then)
+				y := b.Sub(zero, x)
+				b.Br(end)
+				b.SetBlock(els)
+				b.Br(end)
+				b.SetBlock(end)
+				b.Ret(b.Phi(ir.I32,
+					ir.Incoming{Value: y, Block: then},
+					ir.Incoming{Value: y, Block: els},
+				))
+				return f
+			},
+			wantErr: "doesn't dominate its use",
+		},
+		{
+			name: "return type mismatch",
+			build: func() *ir.Function {
+				f, _, b := newFunc()
+				b.Ret(ir.Int(ir.I64, 0))
+				return f
+			},
+			wantErr: "returns i64 from a function returning i32",
+		},
+		{
+			name: "operands of different types",
+			build: func() *ir.Function {
+				f, x, b := newFunc()
+				b.Ret(b.Add(x, ir.Int(ir.I1, 1)))
+				return f
+			},
+			wantErr: "`%0 = add i32 %x, 1` takes 1 typed i32, not i1",
+		},
+		{
+			name: "comparison of different types",
+			build: func() *ir.Function {
+				f, x, b := newFunc()
+				b.ICmp(ir.EQ, x, ir.Int(ir.I64, 0))
+				b.Ret(x)
+				return f
+			},
+			wantErr: "takes 0 typed i32, not i64",
+		},
+		{
+			name: "extension into the same type",
+			build: func() *ir.Function {
+				f, x, b := newFunc()
+				b.Ret(b.Cast(ir.OpSExt, x, ir.I32))
+				return f
+			},
+			wantErr: "cannot convert i32 into i32",
+		},
+		{
+			name: "argument of another type",
+			build: func() *ir.Function {
+				f, _, b := newFunc()
+				b.Ret(b.Call(f, ir.Int(ir.I64, 1)))
+				return f
+			},
+			wantErr: "takes 1 typed i32, not i64",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ir.VerifyFunc(tt.build())
+			if tt.wantErr == "" {
+				assert.NilError(t, err)
+				return
+			}
+			assert.Assert(t, err != nil)
+			assert.Assert(t, strings.Contains(err.Error(), tt.wantErr), err.Error())
+			assert.Assert(t, strings.Contains(err.Error(), "define i32 @f(i32 %x)"), err.Error())
+		})
+	}
+}
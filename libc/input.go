@@ -0,0 +1,114 @@
+package libc
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// Input reads input for the runtime functions like `read_int` in the same way as generated code does:
+// byte by byte with one byte of lookahead, recording whether the last read succeeded.
+type Input struct {
+	r   *bufio.Reader
+	out *bufio.Writer
+	ok  bool
+}
+
+// NewInput makes Input reading r, which flushes out before reading if it is not nil,
+// so that a prompt written before is shown as stdout of C is flushed by a line on a terminal.
+func NewInput(r io.Reader, out *bufio.Writer) *Input {
+	return &Input{r: bufio.NewReader(r), out: out}
+}
+
+// getc reads a byte, or -1 at the end of input.
+func (in *Input) getc() int {
+	if in.out != nil {
+		in.out.Flush()
+	}
+	c, err := in.r.ReadByte()
+	if err != nil {
+		return -1
+	}
+	return int(c)
+}
+
+// ungetc puts the byte c back, which is -1 at the end of input.
+func (in *Input) ungetc(c int) {
+	if c >= 0 {
+		in.r.UnreadByte()
+	}
+}
+
+// ReadInt reads a decimal integer after whitespace, wrapping around at 32 bits,
+// and skips blanks after it through the end of its line. It results 0 if there is no integer.
+func (in *Input) ReadInt() int64 {
+	c := in.getc()
+	for c == ' ' || c == '\t' || c == '\r' || c == '\n' {
+		c = in.getc()
+	}
+	neg := c == '-'
+	if neg {
+		c = in.getc()
+	}
+	if c < '0' || c > '9' {
+		in.ungetc(c)
+		in.ok = false
+		return 0
+	}
+
+	var x int32
+	for ; c >= '0' && c <= '9'; c = in.getc() {
+		x = x*10 + int32(c-'0')
+	}
+	for c == ' ' || c == '\t' || c == '\r' {
+		c = in.getc()
+	}
+	if c != '\n' {
+		in.ungetc(c)
+	}
+	in.ok = true
+	if neg {
+		x = -x
+	}
+	return int64(x)
+}
+
+// ReadChar reads a byte, or -1 at the end of input.
+func (in *Input) ReadChar() int64 {
+	c := in.getc()
+	in.ok = c >= 0
+	return int64(c)
+}
+
+// ReadLine reads a line without its newline, or an empty string at the end of input.
+// The line is escaped as a string literal of yuni, which is unescaped when it is printed.
+func (in *Input) ReadLine() string {
+	c := in.getc()
+	in.ok = c >= 0
+	b := strings.Builder{}
+	for ; c >= 0 && c != '\n'; c = in.getc() {
+		if c == '\\' {
+			b.WriteByte('\\')
+		}
+		b.WriteByte(byte(c))
+	}
+	return b.String()
+}
+
+// OK results 1 if the last read succeeded, otherwise 0.
+func (in *Input) OK() int64 {
+	if in.ok {
+		return 1
+	}
+	return 0
+}
+
+// EOF results 1 if no byte is left in the input, otherwise 0.
+func (in *Input) EOF() int64 {
+	c := in.getc()
+	in.ungetc(c)
+	if c < 0 {
+		return 1
+	}
+	return 0
+}
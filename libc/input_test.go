@@ -0,0 +1,69 @@
+package libc_test
+
+import (
+	"bufio"
+	"fmt"
+	"strings"
+	"testing"
+
+	"gotest.tools/assert"
+
+	"github.com/yuniruyuni/lang/libc"
+)
+
+func TestInput(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		// reads names methods called in order: i for ReadInt, c for ReadChar, l for ReadLine and e for EOF.
+		reads string
+		// want lists each result and OK after it.
+		want string
+	}{
+		{name: "integers on lines", input: "12\n-3\n", reads: "iie", want: "12:1 -3:1 1:1"},
+		{name: "integers on a line", input: "1 2\t 3 \n", reads: "iiie", want: "1:1 2:1 3:1 1:1"},
+		{name: "integer wraps around", input: "4294967297", reads: "i", want: "1:1"},
+		{name: "no integer", input: "x1\n", reads: "ic", want: "0:0 120:1"},
+		{name: "minus without digits", input: "-\n- 3\n", reads: "icii", want: "0:0 10:1 0:0 3:1"},
+		{name: "integer at end of input", input: "  \n", reads: "ie", want: "0:0 1:0"},
+		{name: "line after integer", input: "3\nhello world\n", reads: "ill", want: "3:1 hello world:1 :0"},
+		{name: "empty line", input: "\nx", reads: "lle", want: ":1 x:1 1:1"},
+		{name: "line escapes backslash", input: `a\b`, reads: "l", want: `a\\b:1`},
+		{name: "char at end of input", input: "A", reads: "cce", want: "65:1 -1:0 1:0"},
+		{name: "eof keeps lookahead", input: "7", reads: "ei", want: "0:0 7:1"},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			in := libc.NewInput(strings.NewReader(tt.input), nil)
+			var got []string
+			for _, r := range tt.reads {
+				var v interface{}
+				switch r {
+				case 'i':
+					v = in.ReadInt()
+				case 'c':
+					v = in.ReadChar()
+				case 'l':
+					v = in.ReadLine()
+				case 'e':
+					v = in.EOF()
+				}
+				got = append(got, fmt.Sprintf("%v:%d", v, in.OK()))
+			}
+			assert.Equal(t, strings.Join(got, " "), tt.want)
+		})
+	}
+}
+
+func TestInput_FlushesOutput(t *testing.T) {
+	b := new(strings.Builder)
+	out := bufio.NewWriter(b)
+	in := libc.NewInput(strings.NewReader("3\n"), out)
+
+	out.WriteString("n? ")
+	assert.Equal(t, b.String(), "")
+	assert.Equal(t, in.ReadInt(), int64(3))
+	assert.Equal(t, b.String(), "n? ")
+}
@@ -0,0 +1,120 @@
+// Package libc emulates functions of the C standard library which yuni programs call,
+// so that programs can run without being compiled into native code.
+package libc
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Args reads arguments of a variadic function like printf in order by their C types.
+type Args interface {
+	// Int reads the next argument as an integer.
+	Int() (int64, error)
+	// String reads the next argument as a pointer to a string.
+	String() (string, error)
+}
+
+// ErrTooFewArgs reports that a format requires more arguments than given.
+var ErrTooFewArgs = errors.New("too few arguments for the format")
+
+// Sprintf formats args by the C format string format like sprintf.
+// It supports conversions `d i u x X o c s %` with flags, width, precision and length modifiers.
+// format and strings for `%s` are unescaped as LLVM does for string literals.
+func Sprintf(format string, args Args) (string, error) {
+	format = Unescape(format)
+
+	b := strings.Builder{}
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			b.WriteByte(format[i])
+			continue
+		}
+
+		j := i + 1
+		for j < len(format) && strings.IndexByte("-+ #0", format[j]) >= 0 {
+			j++
+		}
+		for j < len(format) && strings.IndexByte("0123456789.", format[j]) >= 0 {
+			j++
+		}
+		spec := format[i+1 : j]
+		long := false
+		for j < len(format) && strings.IndexByte("hlzjt", format[j]) >= 0 {
+			long = long || format[j] == 'l' || format[j] == 'z' || format[j] == 'j'
+			j++
+		}
+		if j == len(format) {
+			b.WriteString(format[i:])
+			break
+		}
+
+		c := format[j]
+		switch c {
+		case '%':
+			b.WriteByte('%')
+		case 'd', 'i', 'u', 'x', 'X', 'o', 'c':
+			x, err := args.Int()
+			if err != nil {
+				return b.String(), err
+			}
+			b.WriteString(formatInt(spec, c, x, long))
+		case 's':
+			s, err := args.String()
+			if err != nil {
+				return b.String(), err
+			}
+			b.WriteString(fmt.Sprintf("%"+spec+"s", Unescape(s)))
+		default:
+			return b.String(), fmt.Errorf("%%%c of printf is not supported", c)
+		}
+		i = j
+	}
+	return b.String(), nil
+}
+
+// formatInt formats x for the conversion c like `%5d`, reading x as a long for `%ld`.
+func formatInt(spec string, c byte, x int64, long bool) string {
+	switch c {
+	case 'd', 'i':
+		if !long {
+			x = int64(int32(x))
+		}
+		return fmt.Sprintf("%"+spec+"d", x)
+	case 'c':
+		return fmt.Sprintf("%"+spec+"c", rune(byte(x)))
+	}
+
+	u := uint64(x)
+	if !long {
+		u = uint64(uint32(x))
+	}
+	if c == 'u' {
+		c = 'd'
+	}
+	return fmt.Sprintf("%"+spec+string(c), u)
+}
+
+// Unescape decodes escapes in a string literal as LLVM does for `c"..."`:
+// `\\` is a backslash, `\XX` is the byte of the hex digits XX and other backslashes are left as is.
+func Unescape(s string) string {
+	b := strings.Builder{}
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) && s[i+1] == '\\' {
+			b.WriteByte('\\')
+			i++
+			continue
+		}
+		if s[i] == '\\' && i+2 < len(s) {
+			if c, err := strconv.ParseUint(s[i+1:i+3], 16, 8); err == nil {
+				b.WriteByte(byte(c))
+				i += 2
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
@@ -0,0 +1,75 @@
+package libc_test
+
+import (
+	"fmt"
+	"testing"
+
+	"gotest.tools/assert"
+
+	"github.com/yuniruyuni/lang/libc"
+)
+
+// args gives integers and strings for formats in order.
+type args []interface{}
+
+func (a *args) next() (interface{}, error) {
+	if len(*a) == 0 {
+		return nil, libc.ErrTooFewArgs
+	}
+	v := (*a)[0]
+	*a = (*a)[1:]
+	return v, nil
+}
+
+func (a *args) Int() (int64, error) {
+	v, err := a.next()
+	if err != nil {
+		return 0, err
+	}
+	x, ok := v.(int)
+	if !ok {
+		return 0, fmt.Errorf("%v is not an integer", v)
+	}
+	return int64(x), nil
+}
+
+func (a *args) String() (string, error) {
+	v, err := a.next()
+	if err != nil {
+		return "", err
+	}
+	s, ok := v.(string)
+	if !ok {
+		return "", fmt.Errorf("%v is not a string", v)
+	}
+	return s, nil
+}
+
+func TestSprintf(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  string
+		args    args
+		want    string
+		wantErr string
+	}{
+		{name: "integers", format: "%d,%i,%5d,%-5d|,%05d", args: args{1, -2, 3, 4, 5}, want: "1,-2,    3,4    |,00005"},
+		{name: "unsigned", format: "%u,%x,%X,%o,%lx", args: args{-1, 255, 255, 8, -1}, want: "4294967295,ff,FF,10,ffffffffffffffff"},
+		{name: "i32 conversion", format: "%d,%ld", args: args{1 << 32, 1 << 32}, want: "0,4294967296"},
+		{name: "chars and strings", format: "%c%s|%4s", args: args{72, "i", "ab"}, want: "Hi|  ab"},
+		{name: "escapes", format: `%%\0A\\\41\zz`, want: "%\n\\A\\zz"},
+		{name: "too few arguments", format: "%d,%d", args: args{1}, want: "1,", wantErr: libc.ErrTooFewArgs.Error()},
+		{name: "unsupported", format: "%f", args: args{1}, want: "", wantErr: "%f of printf is not supported"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := libc.Sprintf(tt.format, &tt.args)
+			assert.Equal(t, tt.want, got)
+			if tt.wantErr != "" {
+				assert.Error(t, err, tt.wantErr)
+				return
+			}
+			assert.NilError(t, err)
+		})
+	}
+}
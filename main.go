@@ -1,53 +1,525 @@
 package main
 
 import (
-	"errors"
+	"flag"
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strings"
 
+	"github.com/yuniruyuni/lang/amd64"
 	"github.com/yuniruyuni/lang/ast"
+	"github.com/yuniruyuni/lang/fold"
 	"github.com/yuniruyuni/lang/gen"
-	"github.com/yuniruyuni/lang/parse"
-	"github.com/yuniruyuni/lang/token"
+	"github.com/yuniruyuni/lang/interp"
+	"github.com/yuniruyuni/lang/ir"
+	"github.com/yuniruyuni/lang/module"
+	"github.com/yuniruyuni/lang/opt"
+	"github.com/yuniruyuni/lang/toolchain"
+	"github.com/yuniruyuni/lang/vm"
 )
 
-func outputLL(root ast.AST) string {
-	ll := gen.LLFile{AST: root}
-	return string(ll.Generate())
+// stdinName is the file name used for code given from stdin.
+const stdinName = "<stdin>"
+
+// Options configures how the compiler generates code.
+type Options struct {
+	// SearchPath lists directories to find modules imported by a bare name.
+	SearchPath []string
+	// OptLevel selects the optimization pipeline like `-O2`.
+	OptLevel int
+	// PrintAfter is the name of a pass to dump the IR into stderr after it runs.
+	PrintAfter string
+	// Debug attaches debug information to the LLVM IR.
+	Debug bool
+	// Target is the target triple to generate code for, empty for the host.
+	Target string
+	// CheckedArithmetic makes arithmetic trap on signed overflow.
+	CheckedArithmetic bool
+}
+
+// recoverError recovers the error which code generation reports an invalid program by panicking with.
+// It should be deferred directly.
+func recoverError(err *error) {
+	if r := recover(); r != nil {
+		e, ok := r.(error)
+		if !ok {
+			panic(r)
+		}
+		*err = e
+	}
+}
+
+func outputLL(ll *gen.LLFile) (m *ir.Module, err error) {
+	defer recoverError(&err)
+
+	m = ll.Generate()
+
+	// invalid IR is a bug of this compiler, so catch it here before lli complains.
+	if err := ir.Verify(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func outputC(root ast.AST) (src string, err error) {
+	defer recoverError(&err)
+
+	c := gen.CFile{AST: root}
+	return c.Generate(), nil
+}
+
+func outputWAT(root ast.AST) (src string, err error) {
+	defer recoverError(&err)
+
+	w := gen.WATFile{AST: root}
+	return w.Generate(), nil
+}
+
+// foldChecked folds prog after checking it by generating the IR,
+// because folding drops the clause of `if` which is never taken and errors in it would be missed,
+// and removing identities like `x + 0` depends on the type of x.
+func foldChecked(prog *ast.Program) (ast.AST, error) {
+	info, err := check(prog)
+	if err != nil {
+		return nil, err
+	}
+	return fold.Fold(prog, info), nil
 }
 
-func tokenize(code string) ([]*token.Token, error) {
-	t := token.Tokenizer{}
-	tks := t.Tokenize(code)
-	if len(tks) == 0 {
-		return nil, errors.New("failed to tokenize")
+// check checks prog by generating its LLVM IR, where the checks of the language live,
+// and returns what it resolves for the backends walking the tree.
+func check(prog *ast.Program) (*ast.Info, error) {
+	ll := &gen.LLFile{AST: prog}
+	if _, err := outputLL(ll); err != nil {
+		return nil, err
 	}
-	return tks, nil
+	return ll.Info, nil
 }
 
-func Compile(code string) (string, error) {
-	tks, err := tokenize(code)
+// optimize generates the IR of prog and optimizes it as opts selects.
+func optimize(prog *ast.Program, opts Options) (*ir.Module, error) {
+	var target *ir.Target
+	if opts.Target != "" {
+		t, err := ir.LookupTarget(opts.Target)
+		if err != nil {
+			return nil, err
+		}
+		target = t
+	}
+
+	// folding wraps arithmetic on literals around, which must trap at runtime with checked arithmetic.
+	root := ast.AST(prog)
+	if !opts.CheckedArithmetic {
+		folded, err := foldChecked(prog)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate code: %s", err.Error())
+		}
+		root = folded
+	}
+	m, err := outputLL(&gen.LLFile{AST: root, Target: target, Debug: opts.Debug, CheckedArithmetic: opts.CheckedArithmetic})
 	if err != nil {
-		return "", fmt.Errorf("failed to tokenize code: %s", err.Error())
+		return nil, fmt.Errorf("failed to generate code: %s", err.Error())
+	}
+
+	pm := opt.NewManager(opts.OptLevel)
+	pm.PrintAfter = opts.PrintAfter
+	pm.Log = os.Stderr
+	if err := pm.Run(m); err != nil {
+		return nil, fmt.Errorf("failed to optimize code: %s", err.Error())
 	}
+	return m, nil
+}
 
-	root, err := parse.Parse(tks)
+func generate(prog *ast.Program, opts Options) (string, error) {
+	m, err := optimize(prog, opts)
 	if err != nil {
-		return "", fmt.Errorf("failed to parse code: %s", err.Error())
+		return "", err
 	}
+	return m.String(), nil
+}
 
-	return outputLL(root), nil
+// outputMIR prints the optimized IR of prog in SSA form.
+// Variables are promoted into registers even without optimization,
+// so that MIR shows values flowing through phis rather than through stack slots.
+func outputMIR(prog *ast.Program, opts Options) (string, error) {
+	m, err := optimize(prog, opts)
+	if err != nil {
+		return "", err
+	}
+	pm := &opt.Manager{Passes: []opt.Pass{opt.Mem2Reg}}
+	if err := pm.Run(m); err != nil {
+		return "", err
+	}
+	return m.MIR(), nil
 }
 
-func main() {
+// compileAsm compiles the program given as loadArgs reads into x86-64 assembly.
+func compileAsm(fs *flag.FlagSet, opts Options) (string, error) {
+	if opts.Debug {
+		return "", fmt.Errorf("the asm backend cannot emit debug information, use the llvm backend with -debug")
+	}
+	if opts.Target != "" {
+		return "", fmt.Errorf("the asm backend generates code only for x86-64, not %s", opts.Target)
+	}
+	if opts.CheckedArithmetic {
+		return "", fmt.Errorf("the asm backend cannot check arithmetic, use the llvm backend with -checked-arithmetic")
+	}
+	prog, err := loadArgs(fs, opts)
+	if err != nil {
+		return "", err
+	}
+	m, err := optimize(prog, opts)
+	if err != nil {
+		return "", err
+	}
+	src, err := amd64.Generate(m)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate code: %s", err.Error())
+	}
+	return src, nil
+}
+
+// Compile compiles code as a root module placed in current directory.
+func Compile(code string, opts Options) (string, error) {
+	prog, err := module.New(opts.SearchPath).LoadSource(stdinName, code)
+	if err != nil {
+		return "", err
+	}
+	return generate(prog, opts)
+}
+
+// CompileFile compiles the file at path and every module it imports.
+func CompileFile(path string, opts Options) (string, error) {
+	prog, err := module.New(opts.SearchPath).LoadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return generate(prog, opts)
+}
+
+// levelFlag is a flag like `-O2` which selects the optimization level n.
+type levelFlag struct {
+	level *int
+	n     int
+}
+
+func (f levelFlag) String() string {
+	return ""
+}
+
+func (f levelFlag) Set(string) error {
+	*f.level = f.n
+	return nil
+}
+
+func (f levelFlag) IsBoolFlag() bool {
+	return true
+}
+
+// pathList is a flag which can be given multiple times like `-I a -I b`.
+type pathList []string
+
+func (l *pathList) String() string {
+	return strings.Join(*l, string(filepath.ListSeparator))
+}
+
+func (l *pathList) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
+
+// defaultSearchPath is the search path used after directories given by `-I`.
+// It consists of $YUNI_PATH and the `lib` directory next to the `bin` directory.
+func defaultSearchPath() []string {
+	sp := []string{}
+	if env := os.Getenv("YUNI_PATH"); env != "" {
+		sp = append(sp, filepath.SplitList(env)...)
+	}
+	if exe, err := os.Executable(); err == nil {
+		sp = append(sp, filepath.Join(filepath.Dir(exe), "..", "lib"))
+	}
+	return sp
+}
+
+// commonFlags registers flags shared by every command into fs.
+func commonFlags(fs *flag.FlagSet, opts *Options, includes *pathList) {
+	fs.Var(includes, "I", "add a directory to the module search path")
+	for n := 0; n <= opt.MaxLevel; n++ {
+		fs.Var(levelFlag{level: &opts.OptLevel, n: n}, fmt.Sprintf("O%d", n), fmt.Sprintf("optimize at level %d", n))
+	}
+	fs.StringVar(&opts.PrintAfter, "print-after", "", "dump the IR into stderr after the pass ("+strings.Join(opt.PassNames(), ", ")+")")
+	fs.BoolVar(&opts.Debug, "debug", false, "emit debug information to debug executables by yuni source lines and variables")
+	fs.BoolVar(&opts.CheckedArithmetic, "checked-arithmetic", false, "trap on signed overflow of arithmetic instead of wrapping around")
+	fs.StringVar(&opts.Target, "target", "", "generate code for the target triple like armv7-unknown-linux-gnueabihf (default: the host)")
+}
+
+// loadArgs loads the file given as the first argument of fs, or code from stdin without arguments.
+func loadArgs(fs *flag.FlagSet, opts Options) (*ast.Program, error) {
+	l := module.New(opts.SearchPath)
+	if fs.NArg() > 0 {
+		return l.LoadFile(fs.Arg(0))
+	}
 	bytes, err := ioutil.ReadAll(os.Stdin)
 	if err != nil {
 		panic("cannot read stdin")
 	}
-	code := string(bytes)
+	return l.LoadSource(stdinName, string(bytes))
+}
+
+// compileArgs compiles the program given as loadArgs reads.
+func compileArgs(fs *flag.FlagSet, opts Options) (string, error) {
+	prog, err := loadArgs(fs, opts)
+	if err != nil {
+		return "", err
+	}
+	return generate(prog, opts)
+}
+
+// run runs `lang run [flags] [file]` which interprets the program without LLVM,
+// and returns the value `main` returns.
+// A bytecode file written by `lang compile -emit=bytecode` runs on the VM directly.
+func run(args []string) (int, error) {
+	var opts Options
+	var includes pathList
+	var useVM bool
+
+	fs := flag.NewFlagSet("run", flag.ExitOnError)
+	fs.Var(&includes, "I", "add a directory to the module search path")
+	fs.BoolVar(&useVM, "vm", false, "compile the program into bytecode and run it on the VM")
+	fs.Parse(args)
+	opts.SearchPath = append(includes, defaultSearchPath()...)
+
+	if fs.NArg() > 0 && filepath.Ext(fs.Arg(0)) == vm.Ext {
+		data, err := ioutil.ReadFile(fs.Arg(0))
+		if err != nil {
+			return 0, err
+		}
+		p := &vm.Program{}
+		if err := p.UnmarshalBinary(data); err != nil {
+			return 0, fmt.Errorf("failed to load %s: %s", fs.Arg(0), err.Error())
+		}
+		return vm.Run(p, os.Stdin, os.Stdout)
+	}
+
+	prog, err := loadArgs(fs, opts)
+	if err != nil {
+		return 0, err
+	}
+	info, err := check(prog)
+	if err != nil {
+		return 0, fmt.Errorf("failed to generate code: %s", err.Error())
+	}
+	if !useVM {
+		return interp.Run(prog, info, os.Stdin, os.Stdout)
+	}
+
+	p, err := vm.Compile(prog, info)
+	if err != nil {
+		return 0, fmt.Errorf("failed to compile bytecode: %s", err.Error())
+	}
+	return vm.Run(p, os.Stdin, os.Stdout)
+}
+
+// compileBytecode compiles the program given as loadArgs reads into bytecode.
+func compileBytecode(fs *flag.FlagSet, opts Options) ([]byte, error) {
+	prog, err := loadArgs(fs, opts)
+	if err != nil {
+		return nil, err
+	}
+	info, err := check(prog)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate code: %s", err.Error())
+	}
+	p, err := vm.Compile(prog, info)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile bytecode: %s", err.Error())
+	}
+	return p.MarshalBinary()
+}
+
+// compile runs `lang compile [flags] [file]` which writes the program in the format given by `-emit`.
+func compile(args []string) error {
+	var opts Options
+	var includes pathList
+	var emit, output string
+
+	fs := flag.NewFlagSet("compile", flag.ExitOnError)
+	commonFlags(fs, &opts, &includes)
+	fs.StringVar(&emit, "emit", string(gen.LLVM), "the output format ("+gen.BackendNames()+")")
+	fs.StringVar(&output, "o", "", "write the output into the file (default: the source file name with "+vm.Ext+" for bytecode, otherwise stdout)")
+	fs.Parse(args)
+	opts.SearchPath = append(includes, defaultSearchPath()...)
+
+	backend, err := gen.ParseBackend(emit)
+	if err != nil {
+		return err
+	}
+
+	if opts.CheckedArithmetic && backend != gen.LLVM && backend != gen.Asm && backend != gen.MIR {
+		return fmt.Errorf("the %s backend cannot check arithmetic, use the llvm backend with -checked-arithmetic", backend)
+	}
+
+	var out []byte
+	switch backend {
+	case gen.LLVM:
+		ll, err := compileArgs(fs, opts)
+		if err != nil {
+			return err
+		}
+		out = []byte(ll + "\n")
+	case gen.Asm:
+		src, err := compileAsm(fs, opts)
+		if err != nil {
+			return err
+		}
+		out = []byte(src)
+	case gen.C:
+		prog, err := loadArgs(fs, opts)
+		if err != nil {
+			return err
+		}
+		root, err := foldChecked(prog)
+		if err != nil {
+			return fmt.Errorf("failed to generate code: %s", err.Error())
+		}
+		src, err := outputC(root)
+		if err != nil {
+			return fmt.Errorf("failed to generate code: %s", err.Error())
+		}
+		out = []byte(src)
+	case gen.WAT:
+		prog, err := loadArgs(fs, opts)
+		if err != nil {
+			return err
+		}
+		root, err := foldChecked(prog)
+		if err != nil {
+			return fmt.Errorf("failed to generate code: %s", err.Error())
+		}
+		src, err := outputWAT(root)
+		if err != nil {
+			return fmt.Errorf("failed to generate code: %s", err.Error())
+		}
+		out = []byte(src)
+	case gen.MIR:
+		prog, err := loadArgs(fs, opts)
+		if err != nil {
+			return err
+		}
+		mir, err := outputMIR(prog, opts)
+		if err != nil {
+			return err
+		}
+		out = []byte(mir)
+	case gen.Bytecode:
+		bc, err := compileBytecode(fs, opts)
+		if err != nil {
+			return err
+		}
+		out = bc
+		if output == "" {
+			output = "a" + vm.Ext
+			if fs.NArg() > 0 {
+				output = strings.TrimSuffix(filepath.Base(fs.Arg(0)), module.Ext) + vm.Ext
+			}
+		}
+	}
+
+	if output == "" || output == "-" {
+		_, err := os.Stdout.Write(out)
+		return err
+	}
+	return ioutil.WriteFile(output, out, 0644)
+}
+
+// build runs `lang build [flags] [file]` which writes a native executable.
+func build(args []string) error {
+	var opts Options
+	var includes pathList
+	var cfg toolchain.Config
+	var backend string
+
+	fs := flag.NewFlagSet("build", flag.ExitOnError)
+	commonFlags(fs, &opts, &includes)
+	fs.StringVar(&cfg.Output, "o", "", "write the executable into the file (default: the source file name without extension, or a.out)")
+	fs.StringVar(&backend, "backend", string(gen.LLVM), "generate code by llvm with llc, or asm with the assembler")
+	fs.Parse(args)
+
+	opts.SearchPath = append(includes, defaultSearchPath()...)
+	cfg.OptLevel = opts.OptLevel
+	cfg.Triple = opts.Target
+	if cfg.Output == "" {
+		cfg.Output = defaultOutput(fs.Arg(0))
+	}
+
+	switch gen.Backend(backend) {
+	case gen.LLVM:
+		ll, err := compileArgs(fs, opts)
+		if err != nil {
+			return err
+		}
+		if err := toolchain.Build(ll, cfg); err != nil {
+			return fmt.Errorf("failed to build executable: %s", err.Error())
+		}
+	case gen.Asm:
+		src, err := compileAsm(fs, opts)
+		if err != nil {
+			return err
+		}
+		if err := toolchain.BuildAsm(src, cfg); err != nil {
+			return fmt.Errorf("failed to build executable: %s", err.Error())
+		}
+	default:
+		return fmt.Errorf("cannot build executables by the backend %s, want llvm or asm", backend)
+	}
+	return nil
+}
+
+// defaultOutput names the executable built from the source file src by removing its extension.
+// It is a.out for the standard input or a source file without the extension,
+// so the executable never overwrites the source.
+func defaultOutput(src string) string {
+	base := filepath.Base(src)
+	if src == "" || !strings.HasSuffix(base, module.Ext) || base == module.Ext {
+		return "a.out"
+	}
+	return strings.TrimSuffix(base, module.Ext)
+}
+
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "build" {
+		if err := build(os.Args[2:]); err != nil {
+			fmt.Fprint(os.Stderr, err.Error())
+			os.Exit(-1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "compile" {
+		if err := compile(os.Args[2:]); err != nil {
+			fmt.Fprint(os.Stderr, err.Error())
+			os.Exit(-1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "run" {
+		code, err := run(os.Args[2:])
+		if err != nil {
+			fmt.Fprint(os.Stderr, err.Error())
+			os.Exit(-1)
+		}
+		os.Exit(code)
+	}
+
+	var opts Options
+	var includes pathList
+	commonFlags(flag.CommandLine, &opts, &includes)
+	flag.Parse()
+
+	opts.SearchPath = append(includes, defaultSearchPath()...)
 
-	ll, err := Compile(code)
+	ll, err := compileArgs(flag.CommandLine, opts)
 	if err != nil {
 		fmt.Fprint(os.Stderr, err.Error())
 		os.Exit(-1)
@@ -1,7 +1,18 @@
 package main
 
 import (
+	"bytes"
+	"reflect"
+	"strings"
 	"testing"
+
+	"gotest.tools/assert"
+
+	"github.com/yuniruyuni/lang/ast"
+	"github.com/yuniruyuni/lang/conformance"
+	"github.com/yuniruyuni/lang/gen"
+	"github.com/yuniruyuni/lang/interp"
+	"github.com/yuniruyuni/lang/module"
 )
 
 const code = `
@@ -23,7 +34,106 @@ func BenchmarkCompile(b *testing.B) {
 	for i := 0; i < b.N; i++ {
 		b.StartTimer()
 		//nolint:errcheck // for benchmark, err checking should be skipped.
-		Compile(code)
+		Compile(code, Options{})
 		b.StopTimer()
 	}
 }
+
+func TestDefaultOutput(t *testing.T) {
+	for _, tt := range []struct {
+		src  string
+		want string
+	}{
+		{src: "fact.yuni", want: "fact"},
+		{src: "examples/fact.yuni", want: "fact"},
+		{src: "fact", want: "a.out"},
+		{src: ".yuni", want: "a.out"},
+		{src: "", want: "a.out"},
+	} {
+		t.Run(tt.src, func(t *testing.T) {
+			assert.Equal(t, tt.want, defaultOutput(tt.src))
+		})
+	}
+}
+
+func TestCompile_ConstOverflow(t *testing.T) {
+	code := `const N = 2147483647 + 1 func main(){ N }`
+
+	_, err := Compile(code, Options{})
+	assert.NilError(t, err)
+
+	_, err = Compile(code, Options{CheckedArithmetic: true})
+	assert.ErrorContains(t, err, "const N must be a constant expression: integer overflow")
+}
+
+func TestCompile_UntakenBranch(t *testing.T) {
+	_, err := Compile(`func main(){ if 0 { nosuch } else { 1 } }`, Options{})
+	assert.ErrorContains(t, err, "Function nosuch doesn't exist.")
+}
+
+func TestGenerate_Repeat(t *testing.T) {
+	code := `func f(x: i32,) -> i32 { if 1 < 2 { x * 1 + 2 * 3 } else { 0 } } func main(){ let i = 0; while i < 3 { i = i + 0 + 1 }; println(f(i,), "done",); 0 }`
+
+	prog, err := module.New(nil).LoadSource(stdinName, code)
+	assert.NilError(t, err)
+	parsed, err := module.New(nil).LoadSource(stdinName, code)
+	assert.NilError(t, err)
+
+	first, err := generate(prog, Options{})
+	assert.NilError(t, err)
+	second, err := generate(prog, Options{})
+	assert.NilError(t, err)
+	compiled, err := Compile(code, Options{})
+	assert.NilError(t, err)
+
+	// folding and generating don't change the tree, so the same tree compiles into the same module again.
+	assert.Equal(t, first, second)
+	assert.Equal(t, first, compiled)
+	assert.Assert(t, reflect.DeepEqual(parsed, prog))
+}
+
+func TestOutputMIR(t *testing.T) {
+	prog, err := module.New(nil).LoadSource(stdinName, `func main(){ let i = 0; while i < 3 { i = i + 1 }; i }`)
+	assert.NilError(t, err)
+
+	mir, err := outputMIR(prog, Options{})
+	assert.NilError(t, err)
+	assert.Assert(t, !strings.Contains(mir, "alloca"), mir)
+	assert.Assert(t, strings.Contains(mir, "phi i32"), mir)
+}
+
+func TestFoldChecked_KeepsResults(t *testing.T) {
+	cases := append([]conformance.Case{}, conformance.Cases...)
+	cases = append(cases, conformance.Case{
+		Name: "identities on narrower operands",
+		Code: `func t() -> bool { 1 < 2 } func f(c: i8,) -> i32 { let y = c + 0; y = 300; y }
+			func main(){ println(f(1,), ",", t() * 1, ",", t() + 0, ",", 3000000000 + 0,); 0 }`,
+		Want: "300,1,1,-1294967296\n",
+	})
+
+	// interpret runs root, which is checked again to resolve types of nodes made by folding.
+	interpret := func(t *testing.T, root ast.AST, input string) (string, int) {
+		ll := &gen.LLFile{AST: root}
+		_, err := outputLL(ll)
+		assert.NilError(t, err)
+		out := new(bytes.Buffer)
+		code, err := interp.Run(root.(*ast.Program), ll.Info, strings.NewReader(input), out)
+		assert.NilError(t, err)
+		return out.String(), code
+	}
+	for _, tt := range cases {
+		t.Run(tt.Name, func(t *testing.T) {
+			prog, err := module.New(nil).LoadSource(stdinName, tt.Code)
+			assert.NilError(t, err)
+			folded, err := foldChecked(prog)
+			assert.NilError(t, err)
+
+			out, code := interpret(t, prog, tt.Input)
+			assert.Equal(t, tt.Want, out)
+			assert.Equal(t, tt.WantCode, code)
+			out, code = interpret(t, folded, tt.Input)
+			assert.Equal(t, tt.Want, out)
+			assert.Equal(t, tt.WantCode, code)
+		})
+	}
+}
@@ -0,0 +1,197 @@
+package module
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/yuniruyuni/lang/ast"
+	"github.com/yuniruyuni/lang/parse"
+	"github.com/yuniruyuni/lang/token"
+)
+
+// Ext is the file extension for yuni source code.
+const Ext = ".yuni"
+
+// prelude declares functions that every program can use without importing.
+//
+//go:embed prelude.yuni
+var prelude string
+
+// preludeName is the module name for the prelude.
+const preludeName = "prelude"
+
+// Loader reads a root module and every module it imports,
+// and links them into a single ast.Program.
+type Loader struct {
+	// SearchPath lists directories to find modules imported by a bare name like `import "math"`.
+	SearchPath []string
+
+	// modules holds loaded modules by its absolute path.
+	modules map[string]*ast.Module
+	// names holds module names which are already used.
+	names map[ast.Name]bool
+	// loading is the stack of files which are loading now, for detecting import cycles.
+	loading []string
+	// order lists loaded modules so that every module comes after its imports.
+	order []ast.AST
+}
+
+func New(searchPath []string) *Loader {
+	return &Loader{
+		SearchPath: searchPath,
+		modules:    map[string]*ast.Module{},
+		names:      map[ast.Name]bool{},
+	}
+}
+
+// LoadFile loads the file at path as the root module.
+func (l *Loader) LoadFile(path string) (*ast.Program, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	code, err := os.ReadFile(abs)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read %s: %s", path, err.Error())
+	}
+	return l.LoadSource(abs, string(code))
+}
+
+// LoadSource loads code as the root module located at path.
+// Relative imports in code are resolved from the directory of path.
+func (l *Loader) LoadSource(path, code string) (*ast.Program, error) {
+	if _, err := l.load(preludeName, prelude, l.uniqueName(preludeName)); err != nil {
+		return nil, fmt.Errorf("%s: %s", preludeName, err.Error())
+	}
+	if _, err := l.load(path, code, ""); err != nil {
+		return nil, err
+	}
+	return &ast.Program{Modules: l.order}, nil
+}
+
+// load parses code located at path as a module named name.
+func (l *Loader) load(path, code string, name ast.Name) (*ast.Module, error) {
+	l.loading = append(l.loading, path)
+	defer func() { l.loading = l.loading[:len(l.loading)-1] }()
+
+	defs, err := Parse(code)
+	if err != nil {
+		return nil, err
+	}
+
+	imports := map[ast.Name]ast.Name{}
+	for _, d := range defs.Defs {
+		imp, ok := d.(*ast.Import)
+		if !ok {
+			continue
+		}
+
+		alias := importName(imp.Path)
+		if _, ok := imports[alias]; ok {
+			return nil, fmt.Errorf("%s: module %s is imported twice", path, alias)
+		}
+
+		m, err := l.importModule(filepath.Dir(path), imp.Path)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", path, err.Error())
+		}
+		imports[alias] = m.ModName
+	}
+
+	m := &ast.Module{ModName: name, Imports: imports, Defs: defs, File: ast.NewFile(path, code)}
+	m.SetPos(defs.Pos())
+	l.modules[path] = m
+	l.order = append(l.order, m)
+	return m, nil
+}
+
+// importModule loads the module imported as `import "<imp>"` from a file in dir.
+func (l *Loader) importModule(dir, imp string) (*ast.Module, error) {
+	path, err := l.find(dir, imp)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, p := range l.loading {
+		if p == path {
+			cycle := append(append([]string{}, l.loading[i:]...), path)
+			return nil, fmt.Errorf("import cycle: %s", strings.Join(cycle, " -> "))
+		}
+	}
+
+	if m, ok := l.modules[path]; ok {
+		return m, nil
+	}
+
+	code, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read %s: %s", path, err.Error())
+	}
+	return l.load(path, string(code), l.uniqueName(importName(imp)))
+}
+
+// find resolves imp into an absolute file path.
+// A path starting with `./`, `../` or `/` is relative to dir,
+// otherwise it is searched in the search path.
+func (l *Loader) find(dir, imp string) (string, error) {
+	file := imp
+	if filepath.Ext(file) == "" {
+		file += Ext
+	}
+
+	if isRelative(imp) {
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(dir, file)
+		}
+		return filepath.Abs(file)
+	}
+
+	for _, sp := range l.SearchPath {
+		cand := filepath.Join(sp, file)
+		if _, err := os.Stat(cand); err == nil {
+			return filepath.Abs(cand)
+		}
+	}
+	return "", fmt.Errorf("module %q is not found in search path [%s]", imp, strings.Join(l.SearchPath, ", "))
+}
+
+// uniqueName makes a module name from n which is not used by other modules yet.
+func (l *Loader) uniqueName(n ast.Name) ast.Name {
+	name := n
+	for i := 2; l.names[name]; i++ {
+		name = ast.Name(fmt.Sprintf("%s%d", n, i))
+	}
+	l.names[name] = true
+	return name
+}
+
+func isRelative(imp string) bool {
+	return strings.HasPrefix(imp, "./") ||
+		strings.HasPrefix(imp, "../") ||
+		filepath.IsAbs(imp)
+}
+
+// importName is the name to qualify imported names, like `math` for `import "lib/math.yuni"`.
+func importName(imp string) ast.Name {
+	base := filepath.Base(imp)
+	return ast.Name(strings.TrimSuffix(base, filepath.Ext(base)))
+}
+
+// Parse tokenizes and parses code of a single module.
+func Parse(code string) (*ast.Definitions, error) {
+	t := token.Tokenizer{}
+	tks := t.Tokenize(code)
+	if len(tks) == 0 {
+		return nil, errors.New("failed to tokenize code: failed to tokenize")
+	}
+
+	root, err := parse.Parse(tks)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse code: %s", err.Error())
+	}
+	return root.(*ast.Definitions), nil
+}
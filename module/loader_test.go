@@ -0,0 +1,151 @@
+package module_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gotest.tools/assert"
+
+	"github.com/yuniruyuni/lang/ast"
+	"github.com/yuniruyuni/lang/module"
+)
+
+// writeFiles writes files into a new temporary directory and returns the directory.
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	for name, code := range files {
+		path := filepath.Join(dir, name)
+		assert.NilError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		assert.NilError(t, os.WriteFile(path, []byte(code), 0o644))
+	}
+	return dir
+}
+
+// moduleNames lists names of loaded modules except the prelude.
+func moduleNames(prog *ast.Program) []ast.Name {
+	names := []ast.Name{}
+	for _, m := range prog.Modules[1:] {
+		names = append(names, m.Name())
+	}
+	return names
+}
+
+func TestLoader_LoadFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   map[string]string
+		want    []ast.Name
+		wantErr string
+	}{
+		{
+			name: "single file",
+			files: map[string]string{
+				"main.yuni": `func main(){ 0 }`,
+			},
+			want: []ast.Name{""},
+		},
+		{
+			name: "relative import is resolved from the importing file",
+			files: map[string]string{
+				"main.yuni":     `import "./sub/a.yuni" func main(){ 0 }`,
+				"sub/a.yuni":    `import "./b" pub func a(){ 0 }`,
+				"sub/b.yuni":    `pub func b(){ 0 }`,
+				"lib/math.yuni": `pub func abs(){ 0 }`,
+			},
+			want: []ast.Name{"b", "a", ""},
+		},
+		{
+			name: "bare import is searched in the search path",
+			files: map[string]string{
+				"main.yuni":     `import "math" func main(){ 0 }`,
+				"lib/math.yuni": `pub func abs(){ 0 }`,
+			},
+			want: []ast.Name{"math", ""},
+		},
+		{
+			name: "a module imported twice is loaded once",
+			files: map[string]string{
+				"main.yuni": `import "./a" import "./b" func main(){ 0 }`,
+				"a.yuni":    `import "./b" pub func a(){ 0 }`,
+				"b.yuni":    `pub func b(){ 0 }`,
+			},
+			want: []ast.Name{"b", "a", ""},
+		},
+		{
+			name: "modules sharing a file name get distinct names",
+			files: map[string]string{
+				"main.yuni":    `import "./x/util" import "./y/util2" func main(){ 0 }`,
+				"x/util.yuni":  `pub func f(){ 0 }`,
+				"y/util2.yuni": `import "./util" pub func g(){ 0 }`,
+				"y/util.yuni":  `pub func h(){ 0 }`,
+			},
+			want: []ast.Name{"util", "util3", "util2", ""},
+		},
+		{
+			name: "import cycle",
+			files: map[string]string{
+				"main.yuni": `import "./a" func main(){ 0 }`,
+				"a.yuni":    `import "./b" pub func a(){ 0 }`,
+				"b.yuni":    `import "./a" pub func b(){ 0 }`,
+			},
+			wantErr: "import cycle",
+		},
+		{
+			name: "missing module",
+			files: map[string]string{
+				"main.yuni": `import "nothing" func main(){ 0 }`,
+			},
+			wantErr: `module "nothing" is not found`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeFiles(t, tt.files)
+			l := module.New([]string{filepath.Join(dir, "lib")})
+
+			got, err := l.LoadFile(filepath.Join(dir, "main.yuni"))
+			if tt.wantErr != "" {
+				assert.Assert(t, err != nil)
+				assert.Assert(t, strings.Contains(err.Error(), tt.wantErr), err.Error())
+				return
+			}
+
+			assert.NilError(t, err)
+			assert.DeepEqual(t, tt.want, moduleNames(got))
+		})
+	}
+}
+
+func TestLoader_Positions(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"main.yuni": "import \"./util\"\nfunc main() {\n  util.one()\n}\n",
+		"util.yuni": "\npub func one() -> i32 { 1 }\n",
+	})
+
+	prog, err := module.New(nil).LoadFile(filepath.Join(dir, "main.yuni"))
+	assert.NilError(t, err)
+
+	tests := []struct {
+		module   *ast.Module
+		def      int
+		wantPath string
+		wantLine int
+		wantCol  int
+	}{
+		{module: prog.Modules[1].(*ast.Module), def: 0, wantPath: filepath.Join(dir, "util.yuni"), wantLine: 2, wantCol: 1},
+		{module: prog.Modules[2].(*ast.Module), def: 1, wantPath: filepath.Join(dir, "main.yuni"), wantLine: 2, wantCol: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.wantPath, func(t *testing.T) {
+			d := tt.module.Defs.(*ast.Definitions).Defs[tt.def]
+			line, col := tt.module.File.Position(d.Pos().Beg)
+			assert.Equal(t, tt.wantPath, tt.module.File.Path)
+			assert.Equal(t, tt.wantLine, line)
+			assert.Equal(t, tt.wantCol, col)
+		})
+	}
+}
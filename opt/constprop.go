@@ -0,0 +1,177 @@
+package opt
+
+import (
+	"github.com/yuniruyuni/lang/ir"
+)
+
+// constProp replaces instructions whose operands are constants by their results,
+// and phis which choose the same value from every predecessor by the value.
+func constProp(f *ir.Function) bool {
+	changed := false
+	for {
+		c := false
+		for _, bb := range f.Blocks {
+			for _, i := range append([]*ir.Instr{}, bb.Instrs...) {
+				v := evaluate(i)
+				if v == nil {
+					continue
+				}
+				f.ReplaceAllUses(i, v)
+				bb.Remove(i)
+				c = true
+			}
+		}
+		if !c {
+			return changed
+		}
+		changed = true
+	}
+}
+
+// evaluate computes the value of i at compile time, or returns nil if it cannot.
+func evaluate(i *ir.Instr) ir.Value {
+	switch i.Op {
+	case ir.OpAdd, ir.OpSub, ir.OpMul, ir.OpSDiv:
+		x, xok := ir.IntValue(i.Args[0])
+		y, yok := ir.IntValue(i.Args[1])
+		if !xok || !yok {
+			return nil
+		}
+		v, ok := arith(i.Op, x, y)
+		if !ok {
+			return nil
+		}
+		return ir.Int(i.Typ, int(wrap(v, i.Typ)))
+	case ir.OpICmp:
+		if b := unwrapBool(i); b != nil {
+			return b
+		}
+		x, xok := ir.IntValue(i.Args[0])
+		y, yok := ir.IntValue(i.Args[1])
+		if !xok || !yok {
+			return nil
+		}
+		if compare(i.Pred, x, y) {
+			return ir.Int(ir.I1, 1)
+		}
+		return ir.Int(ir.I1, 0)
+	case ir.OpZExt:
+		x, ok := ir.IntValue(i.Args[0])
+		if !ok {
+			return nil
+		}
+		from := i.Args[0].Type()
+		return ir.Int(i.Typ, int(wrap(x, from)&(1<<from.Bits()-1)))
+	case ir.OpSExt:
+		x, ok := ir.IntValue(i.Args[0])
+		if !ok {
+			return nil
+		}
+		// the constant is a value of its own type first, whose top bit is the sign.
+		v := wrap(x, i.Args[0].Type())
+		if i.Args[0].Type() == ir.I1 {
+			v = -v
+		}
+		return ir.Int(i.Typ, int(wrap(v, i.Typ)))
+	case ir.OpTrunc:
+		x, ok := ir.IntValue(i.Args[0])
+		if !ok {
+			return nil
+		}
+		return ir.Int(i.Typ, int(wrap(x, i.Typ)))
+	case ir.OpPhi:
+		return samePhi(i)
+	default:
+		return nil
+	}
+}
+
+// unwrapBool simplifies `icmp ne (zext i1 %b), 0` into `%b`,
+// which comes from using a comparison as a condition.
+func unwrapBool(i *ir.Instr) ir.Value {
+	if i.Pred != ir.NE {
+		return nil
+	}
+	z, ok := ir.IntValue(i.Args[1])
+	if !ok || z != 0 {
+		return nil
+	}
+	ext, ok := i.Args[0].(*ir.Instr)
+	if !ok || ext.Op != ir.OpZExt || ext.Args[0].Type() != ir.I1 {
+		return nil
+	}
+	return ext.Args[0]
+}
+
+func arith(op ir.Op, x, y int64) (int64, bool) {
+	switch op {
+	case ir.OpAdd:
+		return x + y, true
+	case ir.OpSub:
+		return x - y, true
+	case ir.OpMul:
+		return x * y, true
+	default:
+		// division by zero traps at runtime, so it is left as is.
+		if y == 0 {
+			return 0, false
+		}
+		return x / y, true
+	}
+}
+
+func compare(p ir.Pred, x, y int64) bool {
+	switch p {
+	case ir.EQ:
+		return x == y
+	case ir.NE:
+		return x != y
+	case ir.SLT:
+		return x < y
+	case ir.SLE:
+		return x <= y
+	case ir.SGT:
+		return x > y
+	default:
+		return x >= y
+	}
+}
+
+// wrap truncates v into the signed integer type t.
+func wrap(v int64, t ir.Type) int64 {
+	switch t.Bits() {
+	case 1:
+		return v & 1
+	case 8:
+		return int64(int8(v))
+	case 32:
+		return int64(int32(v))
+	default:
+		return v
+	}
+}
+
+// samePhi returns the value if every incoming value of phi is it or phi itself.
+func samePhi(phi *ir.Instr) ir.Value {
+	var same ir.Value
+	for _, c := range phi.Incomings {
+		if c.Value == ir.Value(phi) || c.Value == same {
+			continue
+		}
+		if same != nil && !sameConst(c.Value, same) {
+			return nil
+		}
+		same = c.Value
+	}
+	return same
+}
+
+// sameConst reports whether x and y are the same constant.
+func sameConst(x, y ir.Value) bool {
+	cx, ok := x.(*ir.Const)
+	if !ok {
+		return false
+	}
+	cy, ok := y.(*ir.Const)
+	return ok && cx.Typ == cy.Typ && cx.Text == cy.Text
+}
@@ -0,0 +1,79 @@
+package opt
+
+import (
+	"github.com/yuniruyuni/lang/ir"
+)
+
+// dce removes instructions which have no effect and whose results are not used.
+func dce(f *ir.Function) bool {
+	changed := false
+	for {
+		uses := f.UseCounts()
+		c := false
+		for _, bb := range f.Blocks {
+			for _, i := range append([]*ir.Instr{}, bb.Instrs...) {
+				if i.IsPure() && uses[i] == 0 {
+					bb.Remove(i)
+					c = true
+				}
+			}
+		}
+		if !c {
+			return changed
+		}
+		changed = true
+	}
+}
+
+// globalDCE removes functions and globals which are not referred from `main`.
+func globalDCE(m *ir.Module) bool {
+	live := map[ir.Value]bool{}
+	work := []ir.Value{}
+	mark := func(v ir.Value) {
+		switch v.(type) {
+		case *ir.Function, *ir.Global:
+			if !live[v] {
+				live[v] = true
+				work = append(work, v)
+			}
+		}
+	}
+
+	if main := m.Function("main"); main != nil {
+		mark(main)
+	}
+	for len(work) > 0 {
+		f, ok := work[len(work)-1].(*ir.Function)
+		work = work[:len(work)-1]
+		if !ok {
+			continue
+		}
+		for _, bb := range f.Blocks {
+			for _, i := range bb.Instrs {
+				for _, v := range i.Operands() {
+					mark(v)
+					if c, ok := v.(*ir.Const); ok {
+						for _, r := range c.Refs {
+							mark(r)
+						}
+					}
+				}
+			}
+		}
+	}
+
+	changed := false
+	for _, f := range append([]*ir.Function{}, m.Functions...) {
+		if !live[f] {
+			m.RemoveFunction(f)
+			changed = true
+		}
+	}
+	for _, g := range append([]*ir.Global{}, m.Globals...) {
+		if !live[g] {
+			m.RemoveGlobal(g)
+			changed = true
+		}
+	}
+	return changed
+}
@@ -0,0 +1,186 @@
+package opt
+
+import (
+	"github.com/yuniruyuni/lang/ir"
+)
+
+// inlineLimit is the largest number of instructions in a function to be inlined.
+const inlineLimit = 24
+
+// inline expands calls to small non-recursive functions into their callers.
+func inline(m *ir.Module) bool {
+	recursive := recursiveFuncs(m)
+
+	changed := false
+	for _, f := range m.Functions {
+		for {
+			call := findInlinable(f, recursive)
+			if call == nil {
+				break
+			}
+			inlineCall(call)
+			changed = true
+		}
+	}
+	return changed
+}
+
+// findInlinable finds a call in f which can be inlined.
+func findInlinable(f *ir.Function, recursive map[*ir.Function]bool) *ir.Instr {
+	for _, bb := range f.Blocks {
+		for _, i := range bb.Instrs {
+			if i.Op != ir.OpCall {
+				continue
+			}
+			callee, ok := i.Callee().(*ir.Function)
+			if ok && isInlinable(callee, recursive) {
+				return i
+			}
+		}
+	}
+	return nil
+}
+
+// isInlinable reports whether f is small enough and can be copied into other functions.
+func isInlinable(f *ir.Function, recursive map[*ir.Function]bool) bool {
+	if f.IsDecl() || recursive[f] {
+		return false
+	}
+
+	size := 0
+	for _, bb := range f.Blocks {
+		for _, i := range bb.Instrs {
+			// an alloca out of the entry block grows the stack every time it runs.
+			if i.Op == ir.OpAlloca {
+				return false
+			}
+			size++
+		}
+	}
+	return size <= inlineLimit
+}
+
+// recursiveFuncs finds functions which can call themselves through direct calls.
+func recursiveFuncs(m *ir.Module) map[*ir.Function]bool {
+	callees := map[*ir.Function][]*ir.Function{}
+	for _, f := range m.Functions {
+		for _, bb := range f.Blocks {
+			for _, i := range bb.Instrs {
+				if i.Op != ir.OpCall {
+					continue
+				}
+				if c, ok := i.Callee().(*ir.Function); ok {
+					callees[f] = append(callees[f], c)
+				}
+			}
+		}
+	}
+
+	recursive := map[*ir.Function]bool{}
+	for _, f := range m.Functions {
+		visited := map[*ir.Function]bool{}
+		work := append([]*ir.Function{}, callees[f]...)
+		for len(work) > 0 {
+			c := work[len(work)-1]
+			work = work[:len(work)-1]
+			if c == f {
+				recursive[f] = true
+				break
+			}
+			if visited[c] {
+				continue
+			}
+			visited[c] = true
+			work = append(work, callees[c]...)
+		}
+	}
+	return recursive
+}
+
+// inlineCall replaces call by a copy of the body of its callee.
+// The block of call is split at call, and returns of the copy jump into the rest of the block.
+// The copy is located at call, and debug intrinsics of the callee are dropped
+// since the variables they describe belong to the callee.
+func inlineCall(call *ir.Instr) {
+	bb := call.Parent
+	f := bb.Parent
+	callee := call.Callee().(*ir.Function)
+
+	at := 0
+	for bb.Instrs[at] != call {
+		at++
+	}
+	cont := f.SplitBlock(bb, at+1)
+	bb.Remove(call)
+
+	vals := map[ir.Value]ir.Value{}
+	for k, p := range callee.Params {
+		vals[p] = call.CallArgs()[k]
+	}
+	blocks := map[*ir.BasicBlock]*ir.BasicBlock{}
+	pos := f.IndexOf(bb) + 1
+	for k, cb := range callee.Blocks {
+		nb := f.NewBlock(f.NextLabel())
+		f.InsertBlock(pos+k, nb)
+		blocks[cb] = nb
+	}
+
+	copied := []*ir.Instr{}
+	rets := []ir.Incoming{}
+	for _, cb := range callee.Blocks {
+		nb := blocks[cb]
+		for _, i := range cb.Instrs {
+			if i.Op == ir.OpRet {
+				if len(i.Args) > 0 {
+					rets = append(rets, ir.Incoming{Value: i.Args[0], Block: nb})
+				}
+				nb.Insert(len(nb.Instrs), ir.Br(cont))
+				continue
+			}
+			if i.IsDebugIntrinsic() {
+				continue
+			}
+			c := nb.Insert(len(nb.Instrs), i.Clone())
+			c.Dbg = call.Dbg
+			vals[i] = c
+			copied = append(copied, c)
+		}
+	}
+
+	// operands are mapped after copying everything, since a phi can refer a value defined later.
+	lookup := func(v ir.Value) ir.Value {
+		if c, ok := vals[v]; ok {
+			return c
+		}
+		return v
+	}
+	for _, c := range copied {
+		for k, a := range c.Args {
+			c.Args[k] = lookup(a)
+		}
+		for k, t := range c.Targets {
+			c.Targets[k] = blocks[t]
+		}
+		for k, in := range c.Incomings {
+			c.Incomings[k] = ir.Incoming{Value: lookup(in.Value), Block: blocks[in.Block]}
+		}
+	}
+	for k, r := range rets {
+		rets[k].Value = lookup(r.Value)
+	}
+
+	bb.Insert(len(bb.Instrs), ir.Br(blocks[callee.Entry()]))
+
+	switch len(rets) {
+	case 0:
+		// the callee never returns, so the rest of the block is unreachable.
+		if call.HasResult() {
+			f.ReplaceAllUses(call, ir.Zero(call.Type()))
+		}
+	case 1:
+		f.ReplaceAllUses(call, rets[0].Value)
+	default:
+		phi := cont.Insert(0, ir.Phi(call.Type(), rets...))
+		f.ReplaceAllUses(call, phi)
+	}
+}
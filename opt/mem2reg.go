@@ -0,0 +1,221 @@
+package opt
+
+import (
+	"github.com/yuniruyuni/lang/ir"
+)
+
+// mem2reg promotes allocas which are only loaded and stored into SSA values,
+// inserting phis at the dominance frontiers of their stores.
+func mem2reg(f *ir.Function) bool {
+	// blocks which never run are not renamed, so drop them first.
+	removeUnreachable(f)
+
+	allocas := promotable(f)
+	if len(allocas) == 0 {
+		return false
+	}
+
+	dom := ir.NewDomTree(f)
+	phis := insertPhis(f, dom, allocas)
+
+	r := &renamer{
+		f:       f,
+		dom:     dom,
+		allocas: allocas,
+		phis:    phis,
+		stacks:  map[*ir.Instr][]ir.Value{},
+		repl:    map[ir.Value]ir.Value{},
+	}
+	r.rename(f.Entry())
+
+	for _, bb := range f.Blocks {
+		for _, i := range bb.Instrs {
+			for k, a := range i.Args {
+				i.Args[k] = r.resolve(a)
+			}
+			for k, c := range i.Incomings {
+				i.Incomings[k].Value = r.resolve(c.Value)
+			}
+		}
+	}
+	removeDeclares(f, allocas)
+	for a := range allocas {
+		a.Parent.Remove(a)
+	}
+	return true
+}
+
+// removeDeclares removes debug intrinsics which describe the promoted allocas,
+// since the variables don't live in memory anymore.
+func removeDeclares(f *ir.Function, allocas map[*ir.Instr]bool) {
+	for _, bb := range f.Blocks {
+		for _, i := range append([]*ir.Instr{}, bb.Instrs...) {
+			if !i.IsDebugIntrinsic() {
+				continue
+			}
+			for _, v := range i.Operands() {
+				if a, ok := v.(*ir.Instr); ok && allocas[a] {
+					bb.Remove(i)
+					break
+				}
+			}
+		}
+	}
+}
+
+// promotable finds allocas whose address is used only by loads and stores.
+// Uses by debug intrinsics don't matter, they are removed on promotion.
+func promotable(f *ir.Function) map[*ir.Instr]bool {
+	allocas := map[*ir.Instr]bool{}
+	for _, bb := range f.Blocks {
+		for _, i := range bb.Instrs {
+			if i.Op == ir.OpAlloca {
+				allocas[i] = true
+			}
+		}
+	}
+
+	for _, bb := range f.Blocks {
+		for _, i := range bb.Instrs {
+			if i.IsDebugIntrinsic() {
+				continue
+			}
+			for k, v := range i.Operands() {
+				a, ok := v.(*ir.Instr)
+				if !ok || !allocas[a] {
+					continue
+				}
+				// only used as the address of load and store.
+				address := i.Op == ir.OpLoad || (i.Op == ir.OpStore && k == 1)
+				if !address {
+					delete(allocas, a)
+				}
+			}
+		}
+	}
+	return allocas
+}
+
+// insertPhis inserts an empty phi for each alloca at the blocks where its stores meet.
+func insertPhis(f *ir.Function, dom *ir.DomTree, allocas map[*ir.Instr]bool) map[*ir.Instr]*ir.Instr {
+	df := dom.Frontiers()
+	phis := map[*ir.Instr]*ir.Instr{}
+
+	for _, a := range sortedAllocas(f, allocas) {
+		placed := map[*ir.BasicBlock]bool{}
+		work := []*ir.BasicBlock{}
+		for _, bb := range f.Blocks {
+			for _, i := range bb.Instrs {
+				if i.Op == ir.OpStore && i.Args[1] == a {
+					work = append(work, bb)
+					break
+				}
+			}
+		}
+
+		for len(work) > 0 {
+			bb := work[len(work)-1]
+			work = work[:len(work)-1]
+			for _, d := range df[bb] {
+				if placed[d] {
+					continue
+				}
+				placed[d] = true
+				phi := ir.Phi(a.Elem)
+				phi.Name = a.Name
+				phis[d.Insert(0, phi)] = a
+				work = append(work, d)
+			}
+		}
+	}
+	return phis
+}
+
+// sortedAllocas lists allocas in the order of appearance so the output is stable.
+func sortedAllocas(f *ir.Function, allocas map[*ir.Instr]bool) []*ir.Instr {
+	as := []*ir.Instr{}
+	for _, bb := range f.Blocks {
+		for _, i := range bb.Instrs {
+			if allocas[i] {
+				as = append(as, i)
+			}
+		}
+	}
+	return as
+}
+
+// renamer replaces loads from promoted allocas by the value stored last,
+// walking the dominator tree.
+type renamer struct {
+	f       *ir.Function
+	dom     *ir.DomTree
+	allocas map[*ir.Instr]bool
+	// phis maps each inserted phi to its alloca.
+	phis map[*ir.Instr]*ir.Instr
+	// stacks holds the current value of each alloca.
+	stacks map[*ir.Instr][]ir.Value
+	// repl maps each removed load to the value which replaces it.
+	repl map[ir.Value]ir.Value
+}
+
+func (r *renamer) resolve(v ir.Value) ir.Value {
+	for {
+		n, ok := r.repl[v]
+		if !ok {
+			return v
+		}
+		v = n
+	}
+}
+
+// current returns the value of a at the point renaming now.
+// A load before any store reads the zero value.
+func (r *renamer) current(a *ir.Instr) ir.Value {
+	s := r.stacks[a]
+	if len(s) == 0 {
+		return ir.Zero(a.Elem)
+	}
+	return s[len(s)-1]
+}
+
+func (r *renamer) rename(bb *ir.BasicBlock) {
+	pushed := map[*ir.Instr]int{}
+	push := func(a *ir.Instr, v ir.Value) {
+		r.stacks[a] = append(r.stacks[a], r.resolve(v))
+		pushed[a]++
+	}
+
+	for _, i := range append([]*ir.Instr{}, bb.Instrs...) {
+		switch {
+		case i.Op == ir.OpPhi && r.phis[i] != nil:
+			push(r.phis[i], i)
+		case i.Op == ir.OpLoad && r.isPromoted(i.Args[0]):
+			r.repl[i] = r.current(i.Args[0].(*ir.Instr))
+			bb.Remove(i)
+		case i.Op == ir.OpStore && r.isPromoted(i.Args[1]):
+			push(i.Args[1].(*ir.Instr), i.Args[0])
+			bb.Remove(i)
+		}
+	}
+
+	for _, s := range uniqueSuccs(bb) {
+		for _, phi := range s.Phis() {
+			if a := r.phis[phi]; a != nil {
+				phi.AddIncoming(r.current(a), bb)
+			}
+		}
+	}
+
+	for _, c := range r.dom.Children(bb) {
+		r.rename(c)
+	}
+
+	for a, n := range pushed {
+		r.stacks[a] = r.stacks[a][:len(r.stacks[a])-n]
+	}
+}
+
+func (r *renamer) isPromoted(v ir.Value) bool {
+	a, ok := v.(*ir.Instr)
+	return ok && r.allocas[a]
+}
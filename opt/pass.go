@@ -0,0 +1,152 @@
+// Package opt optimizes generated IR by running passes over ir.Module.
+package opt
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/yuniruyuni/lang/ir"
+)
+
+// Pass is a transformation over a whole module.
+type Pass struct {
+	Name string
+	// Run transforms m and reports whether m is changed.
+	Run func(m *ir.Module) bool
+}
+
+// perFunc makes a module pass from a pass over a single function.
+func perFunc(run func(f *ir.Function) bool) func(m *ir.Module) bool {
+	return func(m *ir.Module) bool {
+		changed := false
+		for _, f := range m.Functions {
+			if f.IsDecl() {
+				continue
+			}
+			if run(f) {
+				changed = true
+			}
+		}
+		return changed
+	}
+}
+
+var (
+	Mem2Reg     = Pass{Name: "mem2reg", Run: perFunc(mem2reg)}
+	ConstProp   = Pass{Name: "constprop", Run: perFunc(constProp)}
+	DCE         = Pass{Name: "dce", Run: perFunc(dce)}
+	SimplifyCFG = Pass{Name: "simplifycfg", Run: perFunc(simplifyCFG)}
+	GlobalDCE   = Pass{Name: "globaldce", Run: globalDCE}
+	Inline      = Pass{Name: "inline", Run: inline}
+	TailRec     = Pass{Name: "tailrec", Run: perFunc(tailRecursion)}
+	TailCall    = Pass{Name: "tailcall", Run: perFunc(markTailCalls)}
+)
+
+// Passes holds every pass by its name.
+var Passes = map[string]Pass{
+	Mem2Reg.Name:     Mem2Reg,
+	ConstProp.Name:   ConstProp,
+	DCE.Name:         DCE,
+	SimplifyCFG.Name: SimplifyCFG,
+	GlobalDCE.Name:   GlobalDCE,
+	Inline.Name:      Inline,
+	TailRec.Name:     TailRec,
+	TailCall.Name:    TailCall,
+}
+
+// PassNames lists names of every pass in alphabetical order.
+func PassNames() []string {
+	names := make([]string, 0, len(Passes))
+	for n := range Passes {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// MaxLevel is the highest optimization level.
+const MaxLevel = 2
+
+// Pipeline returns passes to run for the optimization level like `-O1`.
+func Pipeline(level int) []Pass {
+	switch {
+	case level <= 0:
+		return []Pass{}
+	case level == 1:
+		return []Pass{Mem2Reg, TailRec, ConstProp, SimplifyCFG, DCE, TailCall}
+	default:
+		return []Pass{
+			Mem2Reg, ConstProp, SimplifyCFG, DCE,
+			// inlining can make a function call itself in tail position.
+			Inline, TailRec,
+			// simplifying CFG reveals more constants, such as phis with a single incoming value.
+			ConstProp, SimplifyCFG, DCE,
+			TailCall,
+			GlobalDCE,
+		}
+	}
+}
+
+// Manager runs passes in order and checks the IR after each of them.
+type Manager struct {
+	Passes []Pass
+	// PrintAfter is the name of a pass to print the module into Log after it runs.
+	PrintAfter string
+	Log        io.Writer
+}
+
+// NewManager makes a manager running the pipeline for level.
+func NewManager(level int) *Manager {
+	return &Manager{Passes: Pipeline(level)}
+}
+
+// Run runs every pass over m.
+func (pm *Manager) Run(m *ir.Module) error {
+	if pm.PrintAfter != "" {
+		if _, ok := Passes[pm.PrintAfter]; !ok {
+			return fmt.Errorf("unknown pass %q, available passes are %s", pm.PrintAfter, strings.Join(PassNames(), ", "))
+		}
+		if !pm.runs(pm.PrintAfter) {
+			return fmt.Errorf("pass %q doesn't run in the pipeline, which runs %s", pm.PrintAfter, pm.names())
+		}
+	}
+
+	for _, p := range pm.Passes {
+		p.Run(m)
+		for _, f := range m.Functions {
+			f.Renumber()
+		}
+
+		if err := ir.Verify(m); err != nil {
+			return fmt.Errorf("after %s: %w", p.Name, err)
+		}
+		if p.Name == pm.PrintAfter && pm.Log != nil {
+			fmt.Fprintf(pm.Log, "; *** IR after %s ***\n%s", p.Name, m)
+		}
+	}
+	return nil
+}
+
+// runs reports whether the pass named n is one of pm.Passes.
+func (pm *Manager) runs(n string) bool {
+	for _, p := range pm.Passes {
+		if p.Name == n {
+			return true
+		}
+	}
+	return false
+}
+
+// names lists names of pm.Passes in the order they run, or `no pass` if there is none.
+func (pm *Manager) names() string {
+	if len(pm.Passes) == 0 {
+		return "no pass"
+	}
+	ns := make([]string, 0, len(pm.Passes))
+	for _, p := range pm.Passes {
+		ns = append(ns, p.Name)
+	}
+	return strings.Join(ns, ", ")
+}
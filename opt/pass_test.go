@@ -0,0 +1,218 @@
+package opt_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"gotest.tools/assert"
+
+	"github.com/yuniruyuni/lang/gen"
+	"github.com/yuniruyuni/lang/ir"
+	"github.com/yuniruyuni/lang/module"
+	"github.com/yuniruyuni/lang/opt"
+)
+
+// generate compiles code into a module without optimization.
+func generate(t *testing.T, code string) *ir.Module {
+	t.Helper()
+
+	prog, err := module.New(nil).LoadSource("<test>", code)
+	assert.NilError(t, err)
+
+	ll := gen.LLFile{AST: prog}
+	return ll.Generate()
+}
+
+// body returns the printed function named name.
+func body(m *ir.Module, name string) string {
+	f := m.Function(name)
+	if f == nil {
+		return ""
+	}
+	return f.String()
+}
+
+func TestPipeline(t *testing.T) {
+	tests := []struct {
+		name    string
+		code    string
+		level   int
+		want    []string
+		notWant []string
+	}{
+		{
+			name:  "O0 keeps allocas",
+			code:  `func main(){ let x = 1; x = x + 2; x }`,
+			level: 0,
+			want:  []string{"alloca", "store", "load"},
+		},
+		{
+			name:    "O1 promotes allocas and propagates constants",
+			code:    `func main(){ let x = 1; x = x + 2; x }`,
+			level:   1,
+			want:    []string{"ret i32 3"},
+			notWant: []string{"alloca", "store", "load", "add"},
+		},
+		{
+			name:    "branch on a constant is removed",
+			code:    `func main(){ let c = 1; if c { 10 } else { 20 } }`,
+			level:   1,
+			want:    []string{"ret i32 10"},
+			notWant: []string{"br i1", "phi"},
+		},
+		{
+			name:    "loop variables become phis",
+			code:    `func f(n: i32,) -> i32 { let i = 0; while i < n { i = i + 1 }; i } func main(){ f(3,) }`,
+			level:   1,
+			want:    []string{"%i.1 = phi i32 [ 0, %entry ]", "icmp slt i32 %i.1, %n", "ret i32 %i.1"},
+			notWant: []string{"alloca", "zext"},
+		},
+		{
+			name:    "variables assigned in branches meet in a phi",
+			code:    `func f(c: i32,) -> i32 { let x = 1; if c { x = 2 } else { x = 3 }; x } func main(){ f(1,) }`,
+			level:   1,
+			want:    []string{"%x.1 = phi i32 [ 3, %label.2 ], [ 2, %entry ]", "ret i32 %x.1"},
+			notWant: []string{"alloca"},
+		},
+		{
+			name:    "unused results are removed",
+			code:    `func f(x: i32,) -> i32 { let y = x * 2; x } func main(){ f(1,) }`,
+			level:   1,
+			notWant: []string{"mul"},
+		},
+		{
+			name:  "calls are kept even if unused",
+			code:  `func f(x: i32,) -> i32 { x } func main(){ f(1,); 0 }`,
+			level: 1,
+			want:  []string{"call i32 (i32) @f(i32 1)", "ret i32 0"},
+		},
+		{
+			name:    "small functions are inlined",
+			code:    `func f(x: i32,) -> i32 { x * x } func main(){ f(7,) }`,
+			level:   2,
+			want:    []string{"ret i32 49"},
+			notWant: []string{"call"},
+		},
+		{
+			name:  "recursive functions are not inlined",
+			code:  `func f(n: i32,) -> i32 { if n { f(n - 1,) + 1 } else { 0 } } func main(){ f(3,); 0 }`,
+			level: 2,
+			want:  []string{"call i32 (i32) @f(i32 3)", "call i32 (i32) @f(i32 %"},
+		},
+		{
+			name:    "self tail calls become loops",
+			code:    `func f(n: i32, acc: i32,) -> i32 { if n { f(n - 1, acc + n,) } else { acc } } func main(){ f(3, 0,) }`,
+			level:   1,
+			want:    []string{"%n.1 = phi i32 [ %n, %entry ]", "ret i32 %acc.1"},
+			notWant: []string{"call i32 (i32,i32) @f(i32 %"},
+		},
+		{
+			name:    "self tail calls become loops over a parameter named as the entry block",
+			code:    `func f(entry: i32,) -> i32 { if entry { f(entry - 1,) } else { 0 } } func main(){ let entry = 3; f(entry,) }`,
+			level:   1,
+			want:    []string{"%entry.2 = phi i32 [ %entry, %entry.1 ]"},
+			notWant: []string{"call i32 (i32) @f(i32 %"},
+		},
+		{
+			name:  "calls in tail position are marked",
+			code:  `func g(x: i32,) -> i32 { x } func f(x: i32,) -> i32 { g(x + 1,) } func main(){ f(1,) }`,
+			level: 1,
+			want:  []string{"tail call i32 (i32) @f(i32 1)", "musttail call i32 (i32) @g(i32 %0)"},
+		},
+		{
+			name:  "division by zero traps",
+			code:  `func main(){ let x = 0; 1 / x }`,
+			level: 1,
+			want:  []string{"call void (i8*,i8*,i8*) @yuni_trap(", "unreachable"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := generate(t, tt.code)
+			assert.NilError(t, opt.NewManager(tt.level).Run(m))
+
+			got := body(m, "main") + body(m, "f")
+			for _, w := range tt.want {
+				assert.Assert(t, strings.Contains(got, w), "want %q in\n%s", w, got)
+			}
+			for _, w := range tt.notWant {
+				assert.Assert(t, !strings.Contains(got, w), "don't want %q in\n%s", w, got)
+			}
+		})
+	}
+}
+
+func TestGlobalDCE(t *testing.T) {
+	// used is recursive so that it is not inlined, and unused keeps read in the module.
+	code := `func unused(){ read() } func used(n: i32,) -> i32 { if n { used(n - 1,) } else { 2 } } func main(){ used(3,) }`
+
+	m := generate(t, code)
+	assert.NilError(t, opt.NewManager(1).Run(m))
+	assert.Assert(t, m.Function("unused") != nil)
+	assert.Assert(t, m.Function("yuni_read") != nil)
+
+	m = generate(t, code)
+	assert.NilError(t, opt.NewManager(2).Run(m))
+	assert.Assert(t, m.Function("unused") == nil)
+	assert.Assert(t, m.Function("yuni_read") == nil)
+	assert.Assert(t, m.Function("scanf") == nil)
+	assert.Assert(t, m.Global(".readfmt") == nil)
+	assert.Assert(t, m.Function("used") != nil)
+}
+
+func TestManager_PrintAfter(t *testing.T) {
+	m := generate(t, `func main(){ let x = 1; x }`)
+
+	log := new(bytes.Buffer)
+	pm := opt.NewManager(1)
+	pm.PrintAfter = "mem2reg"
+	pm.Log = log
+	assert.NilError(t, pm.Run(m))
+
+	assert.Assert(t, strings.HasPrefix(log.String(), "; *** IR after mem2reg ***\n"), log.String())
+	assert.Assert(t, strings.Contains(log.String(), "define i32 @main()"), log.String())
+	assert.Assert(t, !strings.Contains(log.String(), "IR after dce"), log.String())
+
+	pm.PrintAfter = "nothing"
+	err := pm.Run(m)
+	assert.Assert(t, err != nil)
+	assert.Assert(t, strings.Contains(err.Error(), `unknown pass "nothing"`), err.Error())
+
+	// globaldce runs only at -O2.
+	pm.PrintAfter = "globaldce"
+	err = pm.Run(m)
+	assert.ErrorContains(t, err, `pass "globaldce" doesn't run in the pipeline, which runs mem2reg, tailrec, constprop, simplifycfg, dce, tailcall`)
+
+	pm = opt.NewManager(0)
+	pm.PrintAfter = "mem2reg"
+	assert.ErrorContains(t, pm.Run(m), `pass "mem2reg" doesn't run in the pipeline, which runs no pass`)
+}
+
+func TestConstProp_Casts(t *testing.T) {
+	tests := []struct {
+		name string
+		op   ir.Op
+		from ir.Type
+		x    int
+		to   ir.Type
+		want string
+	}{
+		{name: "sext wraps into the source type first", op: ir.OpSExt, from: ir.I32, x: 10000000000, to: ir.I64, want: "ret i64 1410065408"},
+		{name: "sext extends the sign", op: ir.OpSExt, from: ir.I8, x: 200, to: ir.I32, want: "ret i32 -56"},
+		{name: "sext of true is -1", op: ir.OpSExt, from: ir.I1, x: 1, to: ir.I32, want: "ret i32 -1"},
+		{name: "zext keeps the bits", op: ir.OpZExt, from: ir.I8, x: -56, to: ir.I32, want: "ret i32 200"},
+		{name: "trunc wraps into the destination type", op: ir.OpTrunc, from: ir.I64, x: 10000000000, to: ir.I32, want: "ret i32 1410065408"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := ir.NewModule()
+			b := &ir.Builder{}
+			b.SetFunc(m.NewFunction("f", ir.FuncType(tt.to, nil, false)))
+			b.Ret(b.Cast(tt.op, ir.Int(tt.from, tt.x), tt.to))
+
+			opt.ConstProp.Run(m)
+			assert.Assert(t, strings.Contains(body(m, "f"), tt.want), body(m, "f"))
+		})
+	}
+}
@@ -0,0 +1,183 @@
+package opt
+
+import (
+	"github.com/yuniruyuni/lang/ir"
+)
+
+// simplifyCFG folds branches on constants, removes unreachable blocks
+// and merges a block into its predecessor when it is the only way to reach it.
+func simplifyCFG(f *ir.Function) bool {
+	changed := false
+	for {
+		c := foldBranches(f)
+		c = removeUnreachable(f) || c
+		c = mergeBlocks(f) || c
+		c = skipEmptyBlocks(f) || c
+		if !c {
+			return changed
+		}
+		changed = true
+	}
+}
+
+// foldBranches turns conditional branches on a constant or into a single block into plain branches.
+func foldBranches(f *ir.Function) bool {
+	changed := false
+	for _, bb := range f.Blocks {
+		t := bb.Terminator()
+		if t.Op != ir.OpBr || len(t.Targets) != 2 {
+			continue
+		}
+
+		then, els := t.Targets[0], t.Targets[1]
+		to, dropped := then, els
+		if then != els {
+			c, ok := ir.IntValue(t.Args[0])
+			if !ok {
+				continue
+			}
+			if c == 0 {
+				to, dropped = els, then
+			}
+			for _, phi := range dropped.Phis() {
+				phi.RemoveIncoming(bb)
+			}
+		}
+
+		bb.Remove(t)
+		bb.Insert(len(bb.Instrs), ir.Br(to))
+		changed = true
+	}
+	return changed
+}
+
+// removeUnreachable removes blocks which cannot be reached from the entry block.
+func removeUnreachable(f *ir.Function) bool {
+	reachable := map[*ir.BasicBlock]bool{}
+	for _, bb := range ir.ReversePostorder(f) {
+		reachable[bb] = true
+	}
+
+	changed := false
+	for _, bb := range append([]*ir.BasicBlock{}, f.Blocks...) {
+		if !reachable[bb] {
+			f.RemoveBlock(bb)
+			changed = true
+		}
+	}
+	return changed
+}
+
+// mergeBlocks merges a block into its predecessor
+// if the predecessor only jumps into it and it has no other predecessors.
+func mergeBlocks(f *ir.Function) bool {
+	changed := false
+	for _, bb := range append([]*ir.BasicBlock{}, f.Blocks...) {
+		if bb == f.Entry() {
+			continue
+		}
+		preds := f.Preds(bb)
+		if len(preds) != 1 {
+			continue
+		}
+		pred := preds[0]
+		if pred == bb || len(pred.Succs()) != 1 {
+			continue
+		}
+
+		// phis have only one incoming value from pred.
+		for _, phi := range bb.Phis() {
+			f.ReplaceAllUses(phi, phi.Incomings[0].Value)
+			bb.Remove(phi)
+		}
+
+		pred.Remove(pred.Terminator())
+		for _, i := range append([]*ir.Instr{}, bb.Instrs...) {
+			bb.Remove(i)
+			i.Parent = pred
+			pred.Instrs = append(pred.Instrs, i)
+		}
+		for _, s := range uniqueSuccs(pred) {
+			for _, phi := range s.Phis() {
+				for k, c := range phi.Incomings {
+					if c.Block == bb {
+						phi.Incomings[k].Block = pred
+					}
+				}
+			}
+		}
+		bb.Instrs = nil
+		f.RemoveBlock(bb)
+		changed = true
+	}
+	return changed
+}
+
+// skipEmptyBlocks redirects branches into a block which only jumps into another block
+// so that they jump into the destination directly.
+func skipEmptyBlocks(f *ir.Function) bool {
+	changed := false
+	for _, bb := range append([]*ir.BasicBlock{}, f.Blocks...) {
+		if bb == f.Entry() || len(bb.Instrs) != 1 || len(bb.Succs()) != 1 {
+			continue
+		}
+		to := bb.Succs()[0]
+		if to == bb {
+			continue
+		}
+
+		// a phi cannot tell which edge comes if a predecessor already jumps into to.
+		preds := f.Preds(bb)
+		toPreds := map[*ir.BasicBlock]bool{}
+		for _, p := range f.Preds(to) {
+			toPreds[p] = true
+		}
+		conflict := false
+		for _, p := range preds {
+			if toPreds[p] {
+				conflict = true
+			}
+		}
+		if conflict {
+			continue
+		}
+
+		for _, phi := range to.Phis() {
+			for _, c := range phi.Incomings {
+				if c.Block != bb {
+					continue
+				}
+				phi.RemoveIncoming(bb)
+				for _, p := range preds {
+					phi.AddIncoming(c.Value, p)
+				}
+				break
+			}
+		}
+		for _, p := range preds {
+			t := p.Terminator()
+			for k, target := range t.Targets {
+				if target == bb {
+					t.Targets[k] = to
+				}
+			}
+		}
+		bb.Instrs = nil
+		f.RemoveBlock(bb)
+		changed = true
+	}
+	return changed
+}
+
+// uniqueSuccs lists successors of bb without duplicates.
+func uniqueSuccs(bb *ir.BasicBlock) []*ir.BasicBlock {
+	seen := map[*ir.BasicBlock]bool{}
+	succs := []*ir.BasicBlock{}
+	for _, s := range bb.Succs() {
+		if !seen[s] {
+			seen[s] = true
+			succs = append(succs, s)
+		}
+	}
+	return succs
+}
@@ -0,0 +1,138 @@
+package opt
+
+import (
+	"github.com/yuniruyuni/lang/ir"
+)
+
+// tailCalls finds calls of f whose results are returned immediately.
+func tailCalls(f *ir.Function) []*ir.Instr {
+	calls := []*ir.Instr{}
+	for _, bb := range f.Blocks {
+		n := len(bb.Instrs)
+		if n < 2 {
+			continue
+		}
+		call, ret := bb.Instrs[n-2], bb.Instrs[n-1]
+		if call.Op != ir.OpCall || ret.Op != ir.OpRet {
+			continue
+		}
+		if call.HasResult() && (len(ret.Args) != 1 || ret.Args[0] != call) {
+			continue
+		}
+		calls = append(calls, call)
+	}
+	return calls
+}
+
+// tailRecursion turns self calls in tail position into jumps to the top of f,
+// so that deep recursion runs in constant stack space.
+// Parameters become phis choosing the arguments of each call.
+func tailRecursion(f *ir.Function) bool {
+	dupReturns(f)
+
+	calls := []*ir.Instr{}
+	for _, c := range tailCalls(f) {
+		if c.Callee() == f {
+			calls = append(calls, c)
+		}
+	}
+	if len(calls) == 0 {
+		return false
+	}
+
+	// the new entry takes over the label of the old one, which becomes the loop header.
+	head := f.Entry()
+	entry := f.NewBlock(f.NextLabel())
+	entry.Name, head.Name = head.Name, entry.Name
+	f.InsertBlock(0, entry)
+
+	// allocas stay in the entry block so that the loop doesn't grow the stack.
+	for _, i := range append([]*ir.Instr{}, head.Instrs...) {
+		if i.Op == ir.OpAlloca {
+			head.Remove(i)
+			entry.Insert(len(entry.Instrs), i)
+		}
+	}
+	entry.Insert(len(entry.Instrs), ir.Br(head))
+
+	phis := make([]*ir.Instr, len(f.Params))
+	for k, p := range f.Params {
+		phi := ir.Phi(p.Typ)
+		phi.Name = p.Name
+		f.ReplaceAllUses(p, phi)
+		phi.AddIncoming(p, entry)
+		phis[k] = head.Insert(k, phi)
+	}
+
+	for _, c := range calls {
+		bb := c.Parent
+		bb.Remove(bb.Terminator())
+		bb.Remove(c)
+		for k, a := range c.CallArgs() {
+			phis[k].AddIncoming(a, bb)
+		}
+		bb.Insert(len(bb.Instrs), ir.Br(head))
+	}
+	return true
+}
+
+// dupReturns copies a block which only returns a phi into predecessors that make a self call,
+// so that a result of the if expression like `if n { f(n - 1,) } else { 0 }` is returned right after the call.
+func dupReturns(f *ir.Function) {
+	for _, r := range append([]*ir.BasicBlock{}, f.Blocks...) {
+		if len(r.Instrs) != 2 || r == f.Entry() {
+			continue
+		}
+		phi, ret := r.Instrs[0], r.Instrs[1]
+		if phi.Op != ir.OpPhi || ret.Op != ir.OpRet || len(ret.Args) != 1 || ret.Args[0] != phi {
+			continue
+		}
+
+		for _, in := range append([]ir.Incoming{}, phi.Incomings...) {
+			bb := in.Block
+			call, ok := in.Value.(*ir.Instr)
+			if !ok || call.Op != ir.OpCall || call.Callee() != f || call.Parent != bb {
+				continue
+			}
+			br := bb.Terminator()
+			if len(br.Targets) != 1 || bb.Instrs[len(bb.Instrs)-2] != call {
+				continue
+			}
+			bb.Remove(br)
+			bb.Insert(len(bb.Instrs), ir.Ret(call))
+			phi.RemoveIncoming(bb)
+		}
+
+		if len(f.Preds(r)) == 0 {
+			f.RemoveBlock(r)
+		}
+	}
+}
+
+// markTailCalls marks calls in tail position with `tail`,
+// or `musttail` if the callee has the same signature as f.
+func markTailCalls(f *ir.Function) bool {
+	changed := false
+	for _, c := range tailCalls(f) {
+		if c.Tail != ir.NoTail || refersStack(c) {
+			continue
+		}
+		c.Tail = ir.Tail
+		if t := c.Callee().Type(); t == f.Type() && !t.IsVariadic() {
+			c.Tail = ir.MustTail
+		}
+		changed = true
+	}
+	return changed
+}
+
+// refersStack reports whether call passes a pointer which can point into the stack of the caller,
+// which a tail call is not allowed to access.
+func refersStack(call *ir.Instr) bool {
+	for _, a := range call.CallArgs() {
+		if _, ok := a.(*ir.Instr); ok && a.Type().IsPointer() {
+			return true
+		}
+	}
+	return false
+}
@@ -8,11 +8,16 @@ import (
 )
 
 // CachedCall calls f() and cache the result if not error.
-// NOTE: This function just can handle named-functions.
+// NOTE: Only rules listed by named are cached.
+// Closures made by combinators like Skip or Option share
+// their code pointer, so they are called without caching.
 func (p *Parser) CachedCall(f NonTerminal, at Pos) (Pos, ast.AST, error) {
-	ptr := reflect.ValueOf(f).Pointer()
+	rule, ok := p.rules[reflect.ValueOf(f).Pointer()]
+	if !ok {
+		return f(at)
+	}
 
-	key := Key{Ptr: ptr, At: at}
+	key := Key{Rule: rule, At: at}
 	res, ok := p.cache[key]
 	if ok {
 		return res.Pos, res.Ast, nil
@@ -63,7 +68,7 @@ func (p *Parser) Concat(m Merger, cands ...NonTerminal) NonTerminal {
 			}
 			asts = append(asts, parsed)
 		}
-		return nx, m(asts), nil
+		return nx, p.locate(m(asts), at, nx), nil
 	}
 }
 
@@ -72,13 +77,51 @@ func (p *Parser) Many(m Merger, cand NonTerminal) NonTerminal {
 	return func(at Pos) (Pos, ast.AST, error) {
 		asts := make([]ast.AST, 0)
 
+		end := at
 		for {
-			nx, parsed, err := p.CachedCall(cand, at)
+			nx, parsed, err := p.CachedCall(cand, end)
 			if err != nil {
-				return at, m(asts), nil
+				return end, p.locate(m(asts), at, end), nil
 			}
-			at = nx
+			end = nx
 			asts = append(asts, parsed)
 		}
 	}
 }
+
+// Option makes a NonTerminal optional.
+// If the NonTerminal doesn't match current tokens,
+// it succeeds with a nil AST without consuming any tokens.
+func (p *Parser) Option(cand NonTerminal) NonTerminal {
+	return func(at Pos) (Pos, ast.AST, error) {
+		nx, parsed, err := p.CachedCall(cand, at)
+		if err != nil {
+			return at, nil, nil
+		}
+		return nx, parsed, nil
+	}
+}
+
+// locate sets the span of tokens from at to nx into n,
+// unless n already has its own span like a node in parentheses.
+func (p *Parser) locate(n ast.AST, at, nx Pos) ast.AST {
+	if n == nil || n.Pos() != (ast.Span{}) {
+		return n
+	}
+	n.(interface{ SetPos(ast.Span) }).SetPos(p.span(at, nx))
+	return n
+}
+
+// span returns the range of source code which tokens from at to nx cover.
+func (p *Parser) span(at, nx Pos) ast.Span {
+	if at < nx {
+		return ast.Span{Beg: p.tokens[at].Beg, End: p.tokens[nx-1].End}
+	}
+	if t := p.LookAt(at); t != nil {
+		return ast.Span{Beg: t.Beg, End: t.Beg}
+	}
+	if at > 0 {
+		return ast.Span{Beg: p.tokens[at-1].End, End: p.tokens[at-1].End}
+	}
+	return ast.Span{}
+}
@@ -2,6 +2,7 @@ package parse
 
 import (
 	"errors"
+	"reflect"
 	"strconv"
 
 	"github.com/yuniruyuni/lang/ast"
@@ -15,9 +16,10 @@ type Pos int
 // NonTerminal expresses non-terminal symbol in parser.
 type NonTerminal func(Pos) (Pos, ast.AST, error)
 
+// Key is a result of the rule named Rule at At in Cache.
 type Key struct {
-	Ptr uintptr
-	At  Pos
+	Rule string
+	At   Pos
 }
 
 type Result struct {
@@ -30,11 +32,17 @@ type Cache map[Key]*Result
 // Parser transforms this language into AST.
 // --- PEG ---
 // AST Emit will happen for x in [x].
-// Root := ( Func )*
+// Root := ( Definition )*
+// Definition := Import | Pub | Member
+// [Import] := import String
+// [Pub] := pub Member
+// Member := Func | Extern | Const | Global
+// [Const] := const VarName = Cond
+// [Global] := var VarName = Cond
 // Execute := Sequence | Statement
 // [Sequence] := Statement ; Execute
 // Statement := While | Let | Assign | Cond | Res
-// [Let] := let Variable = Cond
+// [Let] := let VarName = Cond
 // [Assign] := Variable = Cond
 // Cond := Less | Equal | Expr
 // [Less] := Expr < Cond
@@ -46,17 +54,32 @@ type Cache map[Key]*Result
 // [Mul] := Res * Term
 // [Div] := Res / Term
 // Res := Call | If | Clause | Variable | Integer | String
-// [Variable] := Identifier
+// [Variable] := QualifiedName
+// [VarName] := Identifier
+// QualifiedName := Identifier [ . Identifier ]
 // Clause := ( Cond )
 // [If] := if Execute { Execute } else { Execute }
 // [While] := while Cond { Execute }
-// [Call] := Ident Comma Params Comma
+// [Call] := Callee ( Args )
+// [Callee] := QualifiedName
 // [Args] := ( Cond , )*
-// [Func] := func FuncName(Params){ Execute }
-// [Params] := ( Identifier , )*
+// [Func] := func FuncName(Params) [ RetType ] { Execute }
+// [FuncName] := Identifier
+// RetType := - > TypeExpr
+// [Extern] := extern func FuncName(Params) [ RetType ]
+// [Params] := ( Param , )* [ Param | Ellipsis ]
+// [Param] := Identifier [ : TypeExpr ]
+// [Ellipsis] := . . .
+// TypeExpr := FuncType | PtrType | TypeName
+// [PtrType] := * TypeExpr
+// [FuncType] := fn ( TypeList ) - > TypeExpr
+// [TypeList] := ( TypeExpr , )* [ TypeExpr ]
+// [TypeName] := Identifier
 type Parser struct {
 	tokens []*token.Token
 	cache  Cache
+	// rules names the nonterminals whose results are cached, by their code pointers.
+	rules map[uintptr]string
 }
 
 func (p *Parser) Len() Pos {
@@ -110,7 +133,59 @@ func (p *Parser) Definitions(at Pos) (Pos, ast.AST, error) {
 		func(asts []ast.AST) ast.AST {
 			return &ast.Definitions{Defs: asts}
 		},
-		p.Func,
+		p.Definition,
+	)(at)
+}
+
+func (p *Parser) Definition(at Pos) (Pos, ast.AST, error) {
+	return p.Select(p.Import, p.Pub, p.Member)(at)
+}
+
+func (p *Parser) Import(at Pos) (Pos, ast.AST, error) {
+	return p.Concat(
+		func(asts []ast.AST) ast.AST {
+			return &ast.Import{Path: asts[1].(*ast.String).Word}
+		},
+		p.Skip(kind.Import),
+		p.String,
+	)(at)
+}
+
+func (p *Parser) Pub(at Pos) (Pos, ast.AST, error) {
+	return p.Concat(
+		func(asts []ast.AST) ast.AST {
+			return &ast.Pub{Def: asts[1]}
+		},
+		p.Skip(kind.Pub),
+		p.Member,
+	)(at)
+}
+
+func (p *Parser) Member(at Pos) (Pos, ast.AST, error) {
+	return p.Select(p.Func, p.Extern, p.Const, p.Global)(at)
+}
+
+func (p *Parser) Const(at Pos) (Pos, ast.AST, error) {
+	return p.Concat(
+		func(asts []ast.AST) ast.AST {
+			return &ast.Const{LHS: asts[1], RHS: asts[3]}
+		},
+		p.Skip(kind.Const),
+		p.VarName,
+		p.Skip(kind.Equal),
+		p.Cond,
+	)(at)
+}
+
+func (p *Parser) Global(at Pos) (Pos, ast.AST, error) {
+	return p.Concat(
+		func(asts []ast.AST) ast.AST {
+			return &ast.Global{LHS: asts[1], RHS: asts[3]}
+		},
+		p.Skip(kind.Var),
+		p.VarName,
+		p.Skip(kind.Equal),
+		p.Cond,
 	)(at)
 }
 
@@ -139,7 +214,7 @@ func (p *Parser) Let(at Pos) (Pos, ast.AST, error) {
 			return &ast.Let{LHS: asts[1], RHS: asts[3]}
 		},
 		p.Skip(kind.Let),
-		p.Variable,
+		p.VarName,
 		p.Skip(kind.Equal),
 		p.Cond,
 	)(at)
@@ -286,7 +361,7 @@ func (p *Parser) Call(at Pos) (Pos, ast.AST, error) {
 		func(asts []ast.AST) ast.AST {
 			return &ast.Call{FuncName: asts[0], Args: asts[2]}
 		},
-		p.FuncName,
+		p.Callee,
 		p.Skip(kind.LeftParen),
 		p.Args,
 		p.Skip(kind.RightParen),
@@ -309,32 +384,157 @@ func (p *Parser) Args(at Pos) (Pos, ast.AST, error) {
 func (p *Parser) Func(at Pos) (Pos, ast.AST, error) {
 	return p.Concat(
 		func(asts []ast.AST) ast.AST {
-			return &ast.Func{FuncName: asts[1], Params: asts[3], Execute: asts[6]}
+			return &ast.Func{
+				FuncName: asts[1],
+				Params:   asts[3],
+				RetType:  asts[5],
+				Execute:  asts[7],
+			}
 		},
 		p.Skip(kind.Func),
 		p.FuncName,
 		p.Skip(kind.LeftParen),
 		p.Params,
 		p.Skip(kind.RightParen),
+		p.Option(p.RetType),
 		p.Skip(kind.LeftCurly),
 		p.Execute,
 		p.Skip(kind.RightCurly),
 	)(at)
 }
 
+func (p *Parser) Extern(at Pos) (Pos, ast.AST, error) {
+	return p.Concat(
+		func(asts []ast.AST) ast.AST {
+			return &ast.Extern{
+				FuncName: asts[2],
+				Params:   asts[4],
+				RetType:  asts[6],
+			}
+		},
+		p.Skip(kind.Extern),
+		p.Skip(kind.Func),
+		p.FuncName,
+		p.Skip(kind.LeftParen),
+		p.Params,
+		p.Skip(kind.RightParen),
+		p.Option(p.RetType),
+	)(at)
+}
+
 func (p *Parser) Params(at Pos) (Pos, ast.AST, error) {
-	return p.Many(
+	return p.Concat(
 		func(asts []ast.AST) ast.AST {
-			return &ast.Params{Vars: asts}
+			params := asts[0].(*ast.Params)
+			switch last := asts[1].(type) {
+			case *ast.Param:
+				return &ast.Params{Vars: append(params.Vars, last)}
+			case *ast.Ellipsis:
+				return &ast.Params{Vars: params.Vars, Variadic: true}
+			default:
+				return params
+			}
 		},
-		p.Concat(
-			func(asts []ast.AST) ast.AST { return asts[0] },
-			p.Param,
-			p.Skip(kind.Comma),
+		p.Many(
+			func(asts []ast.AST) ast.AST {
+				return &ast.Params{Vars: asts}
+			},
+			p.Concat(
+				func(asts []ast.AST) ast.AST { return asts[0] },
+				p.Param,
+				p.Skip(kind.Comma),
+			),
+		),
+		p.Option(p.LastParam),
+	)(at)
+}
+
+func (p *Parser) LastParam(at Pos) (Pos, ast.AST, error) {
+	return p.Select(p.Param, p.Ellipsis)(at)
+}
+
+func (p *Parser) Ellipsis(at Pos) (Pos, ast.AST, error) {
+	return p.Concat(
+		func(asts []ast.AST) ast.AST { return &ast.Ellipsis{} },
+		p.Skip(kind.Dot),
+		p.Skip(kind.Dot),
+		p.Skip(kind.Dot),
+	)(at)
+}
+
+func (p *Parser) RetType(at Pos) (Pos, ast.AST, error) {
+	return p.Concat(
+		func(asts []ast.AST) ast.AST { return asts[2] },
+		p.Skip(kind.Minus),
+		p.Skip(kind.Greater),
+		p.TypeExpr,
+	)(at)
+}
+
+func (p *Parser) TypeExpr(at Pos) (Pos, ast.AST, error) {
+	return p.Select(p.FuncType, p.PtrType, p.TypeName)(at)
+}
+
+func (p *Parser) PtrType(at Pos) (Pos, ast.AST, error) {
+	return p.Concat(
+		func(asts []ast.AST) ast.AST {
+			return &ast.PtrType{Elem: asts[1]}
+		},
+		p.Skip(kind.Multiply),
+		p.TypeExpr,
+	)(at)
+}
+
+func (p *Parser) FuncType(at Pos) (Pos, ast.AST, error) {
+	return p.Concat(
+		func(asts []ast.AST) ast.AST {
+			return &ast.FuncType{Params: asts[2], Ret: asts[6]}
+		},
+		p.Skip(kind.Fn),
+		p.Skip(kind.LeftParen),
+		p.TypeList,
+		p.Skip(kind.RightParen),
+		p.Skip(kind.Minus),
+		p.Skip(kind.Greater),
+		p.TypeExpr,
+	)(at)
+}
+
+func (p *Parser) TypeList(at Pos) (Pos, ast.AST, error) {
+	return p.Concat(
+		func(asts []ast.AST) ast.AST {
+			ts := asts[0].(*ast.TypeList).Types
+			if asts[1] != nil {
+				ts = append(ts, asts[1])
+			}
+			return &ast.TypeList{Types: ts}
+		},
+		p.Many(
+			func(asts []ast.AST) ast.AST {
+				return &ast.TypeList{Types: asts}
+			},
+			p.Concat(
+				func(asts []ast.AST) ast.AST { return asts[0] },
+				p.TypeExpr,
+				p.Skip(kind.Comma),
+			),
 		),
+		p.Option(p.TypeExpr),
 	)(at)
 }
 
+func (p *Parser) TypeName(at Pos) (Pos, ast.AST, error) {
+	nx, t := p.Consume(kind.Identifier, at)
+	if t == nil {
+		return at, nil, errors.New("invalid token")
+	}
+	typ, ok := ast.LookupType(ast.Name(t.Str))
+	if !ok {
+		return at, nil, errors.New("unknown type")
+	}
+	return nx, p.locate(&ast.TypeName{TypeName: typ}, at, nx), nil
+}
+
 func (p *Parser) Integer(at Pos) (Pos, ast.AST, error) {
 	nx := at
 	nx, t := p.Consume(kind.Integer, nx)
@@ -346,31 +546,87 @@ func (p *Parser) Integer(at Pos) (Pos, ast.AST, error) {
 	if err != nil {
 		return at, nil, errors.New("Integer constant size over than max bit size")
 	}
-	return nx, &ast.Integer{Value: val}, nil
+	return nx, p.locate(&ast.Integer{Value: val}, at, nx), nil
 }
 
 func (p *Parser) Variable(at Pos) (Pos, ast.AST, error) {
+	nx, n, err := p.QualifiedName(at)
+	if err != nil {
+		return at, nil, err
+	}
+	return nx, p.locate(&ast.Variable{VarName: n}, at, nx), nil
+}
+
+// VarName is the name of a variable being defined, which cannot be qualified by a module.
+func (p *Parser) VarName(at Pos) (Pos, ast.AST, error) {
 	nx, t := p.Consume(kind.Identifier, at)
 	if t == nil {
 		return at, nil, errors.New("invalid token")
 	}
-	return nx, &ast.Variable{VarName: ast.Name(t.Str)}, nil
+	return nx, p.locate(&ast.Variable{VarName: ast.Name(t.Str)}, at, nx), nil
+}
+
+// QualifiedName consumes a name like `x` or `math.x`.
+func (p *Parser) QualifiedName(at Pos) (Pos, ast.Name, error) {
+	nx, t := p.Consume(kind.Identifier, at)
+	if t == nil {
+		return at, "", errors.New("invalid token")
+	}
+
+	dot, d := p.Consume(kind.Dot, nx)
+	if d == nil {
+		return nx, ast.Name(t.Str), nil
+	}
+	end, member := p.Consume(kind.Identifier, dot)
+	if member == nil {
+		return at, "", errors.New("invalid token")
+	}
+	return end, ast.Name(t.Str + "." + member.Str), nil
 }
 
 func (p *Parser) Param(at Pos) (Pos, ast.AST, error) {
+	return p.Concat(
+		func(asts []ast.AST) ast.AST {
+			param := asts[0].(*ast.Param)
+			return &ast.Param{VarName: param.VarName, VarType: asts[1]}
+		},
+		p.ParamName,
+		p.Option(p.ParamType),
+	)(at)
+}
+
+func (p *Parser) ParamName(at Pos) (Pos, ast.AST, error) {
 	nx, t := p.Consume(kind.Identifier, at)
 	if t == nil {
 		return at, nil, errors.New("invalid token")
 	}
-	return nx, &ast.Param{VarName: ast.Name(t.Str)}, nil
+	return nx, p.locate(&ast.Param{VarName: ast.Name(t.Str)}, at, nx), nil
+}
+
+func (p *Parser) ParamType(at Pos) (Pos, ast.AST, error) {
+	return p.Concat(
+		func(asts []ast.AST) ast.AST { return asts[1] },
+		p.Skip(kind.Colon),
+		p.TypeExpr,
+	)(at)
 }
 
+// FuncName is the name of a function being defined, which cannot be qualified by a module.
 func (p *Parser) FuncName(at Pos) (Pos, ast.AST, error) {
 	nx, t := p.Consume(kind.Identifier, at)
 	if t == nil {
 		return at, nil, errors.New("invalid token")
 	}
-	return nx, &ast.FuncName{FuncName: ast.Name(t.Str)}, nil
+	return nx, p.locate(&ast.FuncName{FuncName: ast.Name(t.Str)}, at, nx), nil
+}
+
+// Callee is the name of a function called, like `f` or `math.abs`.
+func (p *Parser) Callee(at Pos) (Pos, ast.AST, error) {
+	nx, n, err := p.QualifiedName(at)
+	if err != nil {
+		return at, nil, err
+	}
+	return nx, p.locate(&ast.FuncName{FuncName: n}, at, nx), nil
 }
 
 func (p *Parser) String(at Pos) (Pos, ast.AST, error) {
@@ -379,11 +635,69 @@ func (p *Parser) String(at Pos) (Pos, ast.AST, error) {
 		return at, nil, errors.New("invalid token")
 	}
 	word := t.Str[1 : len(t.Str)-1]
-	return nx, &ast.String{Word: word}, nil
+	return nx, p.locate(&ast.String{Word: word}, at, nx), nil
 }
 
 func New(tks []*token.Token) *Parser {
-	return &Parser{tokens: tks, cache: Cache{}}
+	p := &Parser{tokens: tks, cache: Cache{}, rules: map[uintptr]string{}}
+	for name, f := range p.named() {
+		p.rules[reflect.ValueOf(f).Pointer()] = name
+	}
+	return p
+}
+
+// named lists the rules of the grammar by their names, which are the keys of their results in Cache.
+func (p *Parser) named() map[string]NonTerminal {
+	return map[string]NonTerminal{
+		"Root":        p.Root,
+		"Definitions": p.Definitions,
+		"Definition":  p.Definition,
+		"Import":      p.Import,
+		"Pub":         p.Pub,
+		"Member":      p.Member,
+		"Const":       p.Const,
+		"Global":      p.Global,
+		"Execute":     p.Execute,
+		"Sequence":    p.Sequence,
+		"Statement":   p.Statement,
+		"Let":         p.Let,
+		"Assign":      p.Assign,
+		"Cond":        p.Cond,
+		"Less":        p.Less,
+		"Equal":       p.Equal,
+		"Expr":        p.Expr,
+		"Add":         p.Add,
+		"Sub":         p.Sub,
+		"Term":        p.Term,
+		"Mul":         p.Mul,
+		"Div":         p.Div,
+		"Res":         p.Res,
+		"Clause":      p.Clause,
+		"If":          p.If,
+		"While":       p.While,
+		"Call":        p.Call,
+		"Args":        p.Args,
+		"Func":        p.Func,
+		"Extern":      p.Extern,
+		"Params":      p.Params,
+		"LastParam":   p.LastParam,
+		"Ellipsis":    p.Ellipsis,
+		"RetType":     p.RetType,
+		"TypeExpr":    p.TypeExpr,
+		"PtrType":     p.PtrType,
+		"FuncType":    p.FuncType,
+		"TypeList":    p.TypeList,
+		"TypeName":    p.TypeName,
+		"Integer":     p.Integer,
+		"Variable":    p.Variable,
+		"Param":       p.Param,
+		"ParamName":   p.ParamName,
+		"ParamType":   p.ParamType,
+		"FuncName":    p.FuncName,
+		"Callee":      p.Callee,
+		"VarName":     p.VarName,
+		"String":      p.String,
+	}
 }
 
 func Parse(tks []*token.Token) (ast.AST, error) {
@@ -5,6 +5,7 @@ import (
 	"strconv"
 	"testing"
 
+	"github.com/google/go-cmp/cmp/cmpopts"
 	"gotest.tools/assert"
 
 	"github.com/yuniruyuni/lang/ast"
@@ -13,6 +14,9 @@ import (
 	"github.com/yuniruyuni/lang/token/kind"
 )
 
+// ignoreSpans compares ASTs without their positions, which TestParse_Spans checks.
+var ignoreSpans = cmpopts.IgnoreTypes(ast.Span{})
+
 func TestParseExecute(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -539,7 +543,7 @@ func TestParseExecute(t *testing.T) {
 			}
 
 			if !tt.invalid {
-				assert.DeepEqual(t, tt.want, got)
+				assert.DeepEqual(t, tt.want, got, ignoreSpans)
 			}
 		})
 	}
@@ -685,6 +689,201 @@ func TestParse(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: `func apply(f: fn(i32) -> i32, x,) -> i32 {f(x,)} parses typed params and a return type`,
+			tokens: []*token.Token{
+				{Kind: kind.Func, Str: "func", Beg: 0, End: 4},
+				{Kind: kind.Identifier, Str: "apply", Beg: 5, End: 10},
+				{Kind: kind.LeftParen, Str: "(", Beg: 10, End: 11},
+				{Kind: kind.Identifier, Str: "f", Beg: 11, End: 12},
+				{Kind: kind.Colon, Str: ":", Beg: 12, End: 13},
+				{Kind: kind.Fn, Str: "fn", Beg: 14, End: 16},
+				{Kind: kind.LeftParen, Str: "(", Beg: 16, End: 17},
+				{Kind: kind.Identifier, Str: "i32", Beg: 17, End: 20},
+				{Kind: kind.RightParen, Str: ")", Beg: 20, End: 21},
+				{Kind: kind.Minus, Str: "-", Beg: 22, End: 23},
+				{Kind: kind.Greater, Str: ">", Beg: 23, End: 24},
+				{Kind: kind.Identifier, Str: "i32", Beg: 25, End: 28},
+				{Kind: kind.Comma, Str: ",", Beg: 28, End: 29},
+				{Kind: kind.Identifier, Str: "x", Beg: 30, End: 31},
+				{Kind: kind.Comma, Str: ",", Beg: 31, End: 32},
+				{Kind: kind.RightParen, Str: ")", Beg: 32, End: 33},
+				{Kind: kind.Minus, Str: "-", Beg: 34, End: 35},
+				{Kind: kind.Greater, Str: ">", Beg: 35, End: 36},
+				{Kind: kind.Identifier, Str: "i32", Beg: 37, End: 40},
+				{Kind: kind.LeftCurly, Str: "{", Beg: 41, End: 42},
+				{Kind: kind.Identifier, Str: "f", Beg: 42, End: 43},
+				{Kind: kind.LeftParen, Str: "(", Beg: 43, End: 44},
+				{Kind: kind.Identifier, Str: "x", Beg: 44, End: 45},
+				{Kind: kind.Comma, Str: ",", Beg: 45, End: 46},
+				{Kind: kind.RightParen, Str: ")", Beg: 46, End: 47},
+				{Kind: kind.RightCurly, Str: "}", Beg: 47, End: 48},
+			},
+			want: &ast.Definitions{
+				Defs: []ast.AST{
+					&ast.Func{
+						FuncName: &ast.FuncName{FuncName: "apply"},
+						Params: &ast.Params{
+							Vars: []ast.AST{
+								&ast.Param{
+									VarName: "f",
+									VarType: &ast.FuncType{
+										Params: &ast.TypeList{
+											Types: []ast.AST{
+												&ast.TypeName{TypeName: "i32"},
+											},
+										},
+										Ret: &ast.TypeName{TypeName: "i32"},
+									},
+								},
+								&ast.Param{VarName: "x"},
+							},
+						},
+						RetType: &ast.TypeName{TypeName: "i32"},
+						Execute: &ast.Call{
+							FuncName: &ast.FuncName{FuncName: "f"},
+							Args: &ast.Args{
+								Values: []ast.AST{
+									&ast.Variable{VarName: "x"},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: `const N = 1 var x = N parses into Const and Global`,
+			tokens: []*token.Token{
+				{Kind: kind.Const, Str: "const", Beg: 0, End: 5},
+				{Kind: kind.Identifier, Str: "N", Beg: 6, End: 7},
+				{Kind: kind.Equal, Str: "=", Beg: 8, End: 9},
+				{Kind: kind.Integer, Str: "1", Beg: 10, End: 11},
+				{Kind: kind.Var, Str: "var", Beg: 12, End: 15},
+				{Kind: kind.Identifier, Str: "x", Beg: 16, End: 17},
+				{Kind: kind.Equal, Str: "=", Beg: 18, End: 19},
+				{Kind: kind.Identifier, Str: "N", Beg: 20, End: 21},
+			},
+			want: &ast.Definitions{
+				Defs: []ast.AST{
+					&ast.Const{
+						LHS: &ast.Variable{VarName: "N"},
+						RHS: &ast.Integer{Value: 1},
+					},
+					&ast.Global{
+						LHS: &ast.Variable{VarName: "x"},
+						RHS: &ast.Variable{VarName: "N"},
+					},
+				},
+			},
+		},
+		{
+			name: `import "math" pub func f(){math.abs(1,)} parses imports, pub and qualified names`,
+			tokens: []*token.Token{
+				{Kind: kind.Import, Str: "import", Beg: 0, End: 6},
+				{Kind: kind.String, Str: `"math"`, Beg: 7, End: 13},
+				{Kind: kind.Pub, Str: "pub", Beg: 14, End: 17},
+				{Kind: kind.Func, Str: "func", Beg: 18, End: 22},
+				{Kind: kind.Identifier, Str: "f", Beg: 23, End: 24},
+				{Kind: kind.LeftParen, Str: "(", Beg: 24, End: 25},
+				{Kind: kind.RightParen, Str: ")", Beg: 25, End: 26},
+				{Kind: kind.LeftCurly, Str: "{", Beg: 26, End: 27},
+				{Kind: kind.Identifier, Str: "math", Beg: 27, End: 31},
+				{Kind: kind.Dot, Str: ".", Beg: 31, End: 32},
+				{Kind: kind.Identifier, Str: "abs", Beg: 32, End: 35},
+				{Kind: kind.LeftParen, Str: "(", Beg: 35, End: 36},
+				{Kind: kind.Integer, Str: "1", Beg: 36, End: 37},
+				{Kind: kind.Comma, Str: ",", Beg: 37, End: 38},
+				{Kind: kind.RightParen, Str: ")", Beg: 38, End: 39},
+				{Kind: kind.RightCurly, Str: "}", Beg: 39, End: 40},
+			},
+			want: &ast.Definitions{
+				Defs: []ast.AST{
+					&ast.Import{Path: "math"},
+					&ast.Pub{
+						Def: &ast.Func{
+							FuncName: &ast.FuncName{FuncName: "f"},
+							Params:   &ast.Params{Vars: []ast.AST{}},
+							Execute: &ast.Call{
+								FuncName: &ast.FuncName{FuncName: "math.abs"},
+								Args: &ast.Args{
+									Values: []ast.AST{
+										&ast.Integer{Value: 1},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: `func a.b(){1} is an error since a definition cannot be qualified`,
+			tokens: []*token.Token{
+				{Kind: kind.Func, Str: "func", Beg: 0, End: 4},
+				{Kind: kind.Identifier, Str: "a", Beg: 5, End: 6},
+				{Kind: kind.Dot, Str: ".", Beg: 6, End: 7},
+				{Kind: kind.Identifier, Str: "b", Beg: 7, End: 8},
+				{Kind: kind.LeftParen, Str: "(", Beg: 8, End: 9},
+				{Kind: kind.RightParen, Str: ")", Beg: 9, End: 10},
+				{Kind: kind.LeftCurly, Str: "{", Beg: 10, End: 11},
+				{Kind: kind.Integer, Str: "1", Beg: 11, End: 12},
+				{Kind: kind.RightCurly, Str: "}", Beg: 12, End: 13},
+			},
+			wantErr: true,
+		},
+		{
+			name: `var a.b = 1 is an error since a definition cannot be qualified`,
+			tokens: []*token.Token{
+				{Kind: kind.Var, Str: "var", Beg: 0, End: 3},
+				{Kind: kind.Identifier, Str: "a", Beg: 4, End: 5},
+				{Kind: kind.Dot, Str: ".", Beg: 5, End: 6},
+				{Kind: kind.Identifier, Str: "b", Beg: 6, End: 7},
+				{Kind: kind.Equal, Str: "=", Beg: 8, End: 9},
+				{Kind: kind.Integer, Str: "1", Beg: 10, End: 11},
+			},
+			wantErr: true,
+		},
+		{
+			name: `extern func printf(fmt: *u8, ...) -> i32 parses into a variadic Extern`,
+			tokens: []*token.Token{
+				{Kind: kind.Extern, Str: "extern", Beg: 0, End: 6},
+				{Kind: kind.Func, Str: "func", Beg: 7, End: 11},
+				{Kind: kind.Identifier, Str: "printf", Beg: 12, End: 18},
+				{Kind: kind.LeftParen, Str: "(", Beg: 18, End: 19},
+				{Kind: kind.Identifier, Str: "fmt", Beg: 19, End: 22},
+				{Kind: kind.Colon, Str: ":", Beg: 22, End: 23},
+				{Kind: kind.Multiply, Str: "*", Beg: 24, End: 25},
+				{Kind: kind.Identifier, Str: "u8", Beg: 25, End: 27},
+				{Kind: kind.Comma, Str: ",", Beg: 27, End: 28},
+				{Kind: kind.Dot, Str: ".", Beg: 29, End: 30},
+				{Kind: kind.Dot, Str: ".", Beg: 30, End: 31},
+				{Kind: kind.Dot, Str: ".", Beg: 31, End: 32},
+				{Kind: kind.RightParen, Str: ")", Beg: 32, End: 33},
+				{Kind: kind.Minus, Str: "-", Beg: 34, End: 35},
+				{Kind: kind.Greater, Str: ">", Beg: 35, End: 36},
+				{Kind: kind.Identifier, Str: "i32", Beg: 37, End: 40},
+			},
+			want: &ast.Definitions{
+				Defs: []ast.AST{
+					&ast.Extern{
+						FuncName: &ast.FuncName{FuncName: "printf"},
+						Params: &ast.Params{
+							Vars: []ast.AST{
+								&ast.Param{
+									VarName: "fmt",
+									VarType: &ast.PtrType{
+										Elem: &ast.TypeName{TypeName: "i8"},
+									},
+								},
+							},
+							Variadic: true,
+						},
+						RetType: &ast.TypeName{TypeName: "i32"},
+					},
+				},
+			},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -695,7 +894,51 @@ func TestParse(t *testing.T) {
 				return
 			}
 
-			assert.DeepEqual(t, tt.want, got)
+			assert.DeepEqual(t, tt.want, got, ignoreSpans)
 		})
 	}
 }
+
+func TestParse_Spans(t *testing.T) {
+	code := "func f(x: i32,) -> i32 {\n  let y = x * 2;\n  (y + 1)\n}"
+	tks := (&token.Tokenizer{}).Tokenize(code)
+	root, err := parse.Parse(tks)
+	assert.NilError(t, err)
+
+	text := func(n ast.AST) string {
+		return code[n.Pos().Beg:n.Pos().End]
+	}
+
+	f := root.(*ast.Definitions).Defs[0].(*ast.Func)
+	assert.Equal(t, code, text(f))
+	assert.Equal(t, "f", text(f.FuncName))
+	assert.Equal(t, "x: i32,", text(f.Params))
+	assert.Equal(t, "x: i32", text(f.Params.(*ast.Params).Vars[0]))
+	assert.Equal(t, "i32", text(f.RetType))
+
+	seq := f.Execute.(*ast.Sequence)
+	let := seq.LHS.(*ast.Let)
+	assert.Equal(t, "let y = x * 2;\n  (y + 1)", text(seq))
+	assert.Equal(t, "let y = x * 2", text(let))
+	assert.Equal(t, "y", text(let.LHS))
+	assert.Equal(t, "x * 2", text(let.RHS))
+	assert.Equal(t, "2", text(let.RHS.(*ast.Mul).RHS))
+	// parentheses are not a node, so the span is of the expression inside.
+	assert.Equal(t, "y + 1", text(seq.RHS))
+}
+
+func TestParser_CachedCall(t *testing.T) {
+	tokens := []*token.Token{{Kind: kind.Integer, Str: "7", Beg: 0, End: 1}}
+	p := parse.New(tokens)
+
+	_, first, err := p.CachedCall(p.Integer, 0)
+	assert.NilError(t, err)
+	_, second, err := p.CachedCall(p.Integer, 0)
+	assert.NilError(t, err)
+	assert.Assert(t, first == second, "a rule must be parsed once at a position")
+
+	closure := func(at parse.Pos) (parse.Pos, ast.AST, error) { return p.Integer(at) }
+	_, first, _ = p.CachedCall(closure, 0)
+	_, second, _ = p.CachedCall(closure, 0)
+	assert.Assert(t, first != second, "a closure must not be cached")
+}
@@ -7,15 +7,16 @@ TARGET="bin/lang"
 test_with() {
     file="$1"
     want="$2"
+    flags="$3"
 
     mkdir -p "${TMPDIR}"
-    cat "$file" | $TARGET > "${OUTPUT}"
+    $TARGET $flags "$file" > "${OUTPUT}"
     got=`lli ${OUTPUT}`
 
     if [ "$got" == "$want" ]; then
-        echo "[SUCCEED] $file => $got"
+        echo "[SUCCEED] $flags $file => $got"
     else
-        echo "[FAILED] $file => want: $want, got: $got"
+        echo "[FAILED] $flags $file => want: $want, got: $got"
     fi
 }
 
@@ -50,6 +51,115 @@ interact() {
     fi
 }
 
+build_with() {
+    file="$1"
+    want="$2"
+    flags="$3"
+
+    mkdir -p "${TMPDIR}"
+    $TARGET build $flags -o "${TMPDIR}/prog" "$file"
+    got=`${TMPDIR}/prog`
+
+    if [ "$got" == "$want" ]; then
+        echo "[SUCCEED(build)] $flags $file => $got"
+    else
+        echo "[FAILED(build)] $flags $file => want: $want, got: $got"
+    fi
+}
+
+trap_with() {
+    args="$1"
+    want="$2"
+    flags="$3"
+
+    mkdir -p "${TMPDIR}"
+    echo "$args" | $TARGET build $flags -o "${TMPDIR}/trap"
+    got=`(${TMPDIR}/trap > /dev/null) 2>&1 | head -n 1`
+
+    if [ "$got" == "$want" ]; then
+        echo "[SUCCEED(trap)] $flags $args => $got"
+    else
+        echo "[FAILED(trap)] $flags $args => want: $want, got: $got"
+    fi
+}
+
+backtrace_with() {
+    args="$1"
+    want="$2"
+    flags="$3"
+
+    mkdir -p "${TMPDIR}"
+    echo "$args" | $TARGET build $flags -o "${TMPDIR}/trap"
+    got=`(${TMPDIR}/trap > /dev/null) 2>&1 | sed -n 's/^    //p' | paste -sd ,`
+
+    if [ "$got" == "$want" ]; then
+        echo "[SUCCEED(backtrace)] $flags $args => $got"
+    else
+        echo "[FAILED(backtrace)] $flags $args => want: $want, got: $got"
+    fi
+}
+
+run_with() {
+    file="$1"
+    want="$2"
+    flags="$3"
+
+    got=`$TARGET run $flags "$file"`
+
+    if [ "$got" == "$want" ]; then
+        echo "[SUCCEED(run)] $flags $file => $got"
+    else
+        echo "[FAILED(run)] $flags $file => want: $want, got: $got"
+    fi
+}
+
+bytecode_with() {
+    file="$1"
+    want="$2"
+
+    mkdir -p "${TMPDIR}"
+    $TARGET compile -emit=bytecode -o "${TMPDIR}/prog.ybc" "$file"
+    got=`$TARGET run "${TMPDIR}/prog.ybc"`
+
+    if [ "$got" == "$want" ]; then
+        echo "[SUCCEED(bytecode)] $file => $got"
+    else
+        echo "[FAILED(bytecode)] $file => want: $want, got: $got"
+    fi
+}
+
+c_with() {
+    file="$1"
+    want="$2"
+
+    mkdir -p "${TMPDIR}"
+    $TARGET compile -emit=c -o "${TMPDIR}/prog.c" "$file"
+    cc -std=c99 -O2 -o "${TMPDIR}/prog" "${TMPDIR}/prog.c"
+    got=`${TMPDIR}/prog`
+
+    if [ "$got" == "$want" ]; then
+        echo "[SUCCEED(c)] $file => $got"
+    else
+        echo "[FAILED(c)] $file => want: $want, got: $got"
+    fi
+}
+
+asm_with() {
+    file="$1"
+    want="$2"
+    flags="$3"
+
+    mkdir -p "${TMPDIR}"
+    $TARGET build -backend=asm $flags -o "${TMPDIR}/prog" "$file"
+    got=`${TMPDIR}/prog`
+
+    if [ "$got" == "$want" ]; then
+        echo "[SUCCEED(asm)] $flags $file => $got"
+    else
+        echo "[FAILED(asm)] $flags $file => want: $want, got: $got"
+    fi
+}
+
 fail() {
     args="$1"
     want="$2"
@@ -102,6 +212,16 @@ test 'func main(){ let x = 10; printf("%d", 20,) }' '20'
 test 'func main(){ let x = 10; x = 20; printf("%d", x,) }' '20'
 test 'func main(){ let x = 10; x = 20; printf("%d", x * 10,) }' '200'
 test 'func main(){ printf("%d", 10,); 100 }' '10'
+test 'func inc(x: i32,) -> i32 { x + 1 } func main(){ let g = inc; printf("%d", g(41,),) }' '42'
+test 'const N = 6 * 7 func main(){ printf("%d", N,) }' '42'
+test 'var n = 1 func main(){ n = n + 1; printf("%d", n,) }' '2'
+test 'import "math" func main(){ printf("%d", math.gcd(0 - 4, 6,),) }' '2'
+test 'extern func putchar(c: i32) -> i32 func main(){ putchar(65,) }' 'A'
+test 'func main(){ print(1, "+", 2, "=", 1 + 2,) }' '1+2=3'
+test 'func f(x: i32,) -> bool { x } func main(){ print(f(2,), ",", f(0,),) }' 'true,false'
+test 'func main(){ printf("%d", if 1 { if 0 { 10 } else { 20 } + 1 } else { 30 },) }' '21'
+test 'func main(){ let i = 0; let s = 0; while i < 3 { if i == 1 { s = s + 10 } else { s = s + 1 }; i = i + 1 }; printf("%d", s,) }' '12'
+test 'func main(){ let i = 0; let s = 0; while i < 3 { let j = 0; while j < i { s = s + 1; j = j + 1 }; i = i + 1 }; printf("%d", s,) }' '3'
 
 test_with 'test/if.yuni' '10'
 test_with 'test/var-if.yuni' '100'
@@ -111,7 +231,122 @@ test_with 'test/while.yuni' '45'
 test_with 'test/fact.yuni' '362880'
 test_with 'test/fundef.yuni' '1'
 test_with 'test/args.yuni' '50'
+test_with 'test/higher.yuni' '20,30,10,0123'
+test_with 'test/global.yuni' '31'
+test_with 'test/import.yuni' '6,6,100'
+test_with 'test/extern.yuni' 'Hi,5,7'
+test_with 'test/nested.yuni' '5,36,3,0,6,51,101,1001'
+test_with 'test/println.yuni' 'yuni:42,true,false,-7'
+test_with 'test/wide.yuni' '10000000000,128,3333333330,44'
+test_with 'test/identity.yuni' '300,1,1,-1294967296'
+test_with 'test/while.yuni' '45' '-O1'
+test_with 'test/fact.yuni' '362880' '-O2'
+test_with 'test/higher.yuni' '20,30,10,0123' '-O2'
+test_with 'test/global.yuni' '31' '-O2'
+test_with 'test/import.yuni' '6,6,100' '-O2'
+test_with 'test/extern.yuni' 'Hi,5,7' '-O2'
+test_with 'test/nested.yuni' '5,36,3,0,6,51,101,1001' '-O2'
+test_with 'test/recursion.yuni' '1784293664,49' '-O1'
+test_with 'test/recursion.yuni' '1784293664,49' '-O2'
+test_with 'test/println.yuni' 'yuni:42,true,false,-7' '-O2'
+test_with 'test/wide.yuni' '10000000000,128,3333333330,44' '-O2'
+test_with 'test/identity.yuni' '300,1,1,-1294967296' '-O2'
+test 'func f(x: i32,) -> bool { x < 3 } func main(){ println(f(2,) + 1, f(1,) == f(2,), 0 - f(5,),); 0 }' '210'
+
+build_with 'test/fact.yuni' '362880'
+build_with 'test/nested.yuni' '5,36,3,0,6,51,101,1001' '-O2'
+build_with 'test/import.yuni' '6,6,100' '-debug'
+build_with 'test/higher.yuni' '20,30,10,0123' '-debug'
+build_with 'test/nested.yuni' '5,36,3,0,6,51,101,1001' '-debug -O2'
+build_with 'test/fact.yuni' '362880' '-target=x86_64-pc-linux-gnu'
+
+trap_with 'func main(){ let x = 0; 1 / x }' '<stdin>:1:25: runtime error: division by zero'
+trap_with 'func main(){ let x = 0; 1 / x }' '<stdin>:1:25: runtime error: division by zero' '-O2'
+trap_with 'func main(){ let x = 0; 1 / x }' '<stdin>:1:25: runtime error: division by zero' '-backend=asm'
+trap_with 'func main(){ let x = 2147483647; x + 1 }' '<stdin>:1:34: runtime error: integer overflow' '-checked-arithmetic'
+trap_with 'func main(){ let x = 65536; x * x }' '<stdin>:1:29: runtime error: integer overflow' '-checked-arithmetic -O2'
+trap_with 'func main(){ let x = 0 - 2147483647; x = x - 1; x / (0 - 1) }' '<stdin>:1:49: runtime error: integer overflow' '-checked-arithmetic'
+trap_with 'func main(){ panic("boom",); 0 }' '<stdin>:1:14: runtime error: panic: boom'
+trap_with 'func main(){ panic("boom",); 0 }' '<stdin>:1:14: runtime error: panic: boom' '-backend=asm'
+trap_with 'func main(){ let x = 3; assert(x < 3, "x is too large",); 0 }' '<stdin>:1:25: runtime error: assertion failed: x is too large'
+trap_with 'func main(){ assert(1 == 2,); 0 }' '<stdin>:1:14: runtime error: assertion failed' '-O2'
+backtrace_with 'func f(x: i32,) -> i32 { assert(x < 3,); x } func g(x: i32,) -> i32 { f(x,) } func main(){ g(1,); g(5,) }' 'f,g,main' '-debug'
+backtrace_with 'func f(x: i32,) -> i32 { 10 / x } func main(){ f(0,) }' 'f,main' '-debug -O2'
+backtrace_with 'func main(){ panic("boom",); 0 }' ''
+
+run_with 'test/fact.yuni' '362880'
+run_with 'test/higher.yuni' '20,30,10,0123'
+run_with 'test/global.yuni' '31'
+run_with 'test/import.yuni' '6,6,100'
+run_with 'test/extern.yuni' 'Hi,5,7'
+run_with 'test/nested.yuni' '5,36,3,0,6,51,101,1001'
+run_with 'test/println.yuni' 'yuni:42,true,false,-7'
+run_with 'test/wide.yuni' '10000000000,128,3333333330,44'
+run_with 'test/identity.yuni' '300,1,1,-1294967296'
+run_with 'test/fact.yuni' '362880' '-vm'
+run_with 'test/higher.yuni' '20,30,10,0123' '-vm'
+run_with 'test/global.yuni' '31' '-vm'
+run_with 'test/import.yuni' '6,6,100' '-vm'
+run_with 'test/extern.yuni' 'Hi,5,7' '-vm'
+run_with 'test/nested.yuni' '5,36,3,0,6,51,101,1001' '-vm'
+run_with 'test/recursion.yuni' '1784293664,49' '-vm'
+run_with 'test/println.yuni' 'yuni:42,true,false,-7' '-vm'
+run_with 'test/wide.yuni' '10000000000,128,3333333330,44' '-vm'
+run_with 'test/identity.yuni' '300,1,1,-1294967296' '-vm'
+
+bytecode_with 'test/nested.yuni' '5,36,3,0,6,51,101,1001'
+bytecode_with 'test/import.yuni' '6,6,100'
+bytecode_with 'test/wide.yuni' '10000000000,128,3333333330,44'
+bytecode_with 'test/identity.yuni' '300,1,1,-1294967296'
+
+c_with 'test/fact.yuni' '362880'
+c_with 'test/higher.yuni' '20,30,10,0123'
+c_with 'test/global.yuni' '31'
+c_with 'test/import.yuni' '6,6,100'
+c_with 'test/extern.yuni' 'Hi,5,7'
+c_with 'test/nested.yuni' '5,36,3,0,6,51,101,1001'
+c_with 'test/recursion.yuni' '1784293664,49'
+c_with 'test/println.yuni' 'yuni:42,true,false,-7'
+c_with 'test/wide.yuni' '10000000000,128,3333333330,44'
+c_with 'test/identity.yuni' '300,1,1,-1294967296'
+
+asm_with 'test/fact.yuni' '362880'
+asm_with 'test/while.yuni' '45'
+asm_with 'test/higher.yuni' '20,30,10,0123'
+asm_with 'test/global.yuni' '31'
+asm_with 'test/import.yuni' '6,6,100'
+asm_with 'test/extern.yuni' 'Hi,5,7'
+asm_with 'test/nested.yuni' '5,36,3,0,6,51,101,1001'
+asm_with 'test/nested.yuni' '5,36,3,0,6,51,101,1001' '-O2'
+asm_with 'test/recursion.yuni' '1784293664,49' '-O1'
+asm_with 'test/println.yuni' 'yuni:42,true,false,-7' '-O2'
+asm_with 'test/wide.yuni' '10000000000,128,3333333330,44'
+asm_with 'test/identity.yuni' '300,1,1,-1294967296'
+asm_with 'test/wide.yuni' '10000000000,128,3333333330,44' '-O2'
+asm_with 'test/identity.yuni' '300,1,1,-1294967296' '-O2'
 
 fail 'if' 'failed to parse code: invalid tokens'
+fail 'const x = 1 func main(){ x = 2 }' 'failed to generate code: Constant x cannot be assigned.'
+fail 'import "./test/modules/util.yuni" func main(){ util.helper(1,) }' 'failed to generate code: helper is not exported by module util.'
+fail 'extern func putchar(c: i32) -> i32 func main(){ putchar(1, 2,) }' 'failed to generate code: Function putchar takes 1 arguments but 2 given.'
+fail 'func main(){ print(main,) }' 'failed to generate code: Value of type i32 ()* cannot be printed.'
+fail 'func read_int() -> i32 { 1 } func main(){ read_int() }' 'failed to generate code: Function read_int is already defined by the runtime.'
+fail 'var x = 1 var x = 2 func main(){ x }' 'failed to generate code: Variable x is already defined.'
+fail 'const x = 1 const x = 2 func main(){ x }' 'failed to generate code: Constant x is already defined.'
+fail 'var f = 1 func f() -> i32 { 2 } func main(){ f }' 'failed to generate code: Function f is already defined.'
+fail 'const s = "a" func main(){ s }' 'failed to generate code: const s must be a constant expression: only integers, arithmetic, comparisons and constants can be used'
+fail 'func main(){ if 0 { nosuch } else { 1 } }' "failed to generate code: Function nosuch doesn't exist."
+fail 'func main(){ printf(1,) }' 'failed to generate code: Argument 1 of function printf must be i8*, not i32.'
+fail 'extern func puts(s: *u8) -> i32 func main(){ let x = 1; puts(x,) }' 'failed to generate code: Argument 1 of function puts must be i8*, not i32.'
+fail 'func f() -> i32 { "a" } func main(){ f() }' 'failed to generate code: Function f must return i32, not i8*.'
+fail 'func inc(x: i32,) -> i32 { x + 1 } func main(){ let g = inc; g = 5; 0 }' 'failed to generate code: Variable g is i32 (i32)*, so a value of type i32 cannot be assigned.'
+fail 'func ap(f: fn(i32) -> i32, x: i32,) -> i32 { f(x,) } func main(){ ap(7, 1,) }' 'failed to generate code: Argument 1 of function ap must be i32 (i32)*, not i32.'
+fail 'func f(g: fn(i32) -> i32,) -> i32 { 1 } func main(){ f(f,) }' 'failed to generate code: Argument 1 of function f must be i32 (i32)*, not i32 (i32 (i32)*)*.'
+fail 'func inc(x: i32,) -> i32 { x + 1 } func main(){ inc + 1 }' 'failed to generate code: Value of type i32 (i32)* cannot be an operand of arithmetic or comparison.'
+fail 'func main(){ assert(1, "a", "b",); 0 }' 'failed to generate code: Function assert takes 1 or 2 arguments but 3 given.'
+fail 'func main(){ panic(1,); 0 }' 'failed to generate code: Message of panic must be a string, not i32.'
 
 interact 'func main(){ let x = read(); printf("%d", x,) }' '23' '23'
+interact 'func main(){ let sum = 0; while eof() == 0 { sum = sum + read_int() }; println(sum, read_ok(),) }' '1 2
+3' '61'
+interact 'func main(){ let s = read_line(); println("[", s, "]", read_char(), read_ok(),) }' 'hello world' '[hello world]-10'
@@ -23,3 +23,13 @@ func IsDigit(ch rune) bool {
 func IsLetter(ch rune) bool {
 	return unicode.IsLetter(ch)
 }
+
+// IsIdentHead checks ch can start an identifier.
+func IsIdentHead(ch rune) bool {
+	return IsLetter(ch) || ch == '_'
+}
+
+// IsIdentTail checks ch can continue an identifier like `i32` or `read_int`.
+func IsIdentTail(ch rune) bool {
+	return IsIdentHead(ch) || IsDigit(ch)
+}
@@ -11,6 +11,7 @@ const (
 	Integer
 	Identifier
 	Less
+	Greater
 	Equal
 	Plus
 	Minus
@@ -25,6 +26,14 @@ const (
 	Let
 	While
 	Func
+	Fn
+	Const
+	Var
+	Import
+	Pub
+	Extern
 	Semicolon
+	Colon
 	Comma
+	Dot
 )
@@ -37,6 +37,18 @@ func (t *Token) Translate() *Token {
 		return t.changeKind(kind.While)
 	case "func":
 		return t.changeKind(kind.Func)
+	case "fn":
+		return t.changeKind(kind.Fn)
+	case "const":
+		return t.changeKind(kind.Const)
+	case "var":
+		return t.changeKind(kind.Var)
+	case "import":
+		return t.changeKind(kind.Import)
+	case "pub":
+		return t.changeKind(kind.Pub)
+	case "extern":
+		return t.changeKind(kind.Extern)
 	default:
 		return t
 	}
@@ -243,9 +243,23 @@ func TestTokenizer_Tokenize(t *testing.T) {
 		},
 		{
 			name: "variable",
+			code: `val`,
+			want: []*token.Token{
+				{Kind: kind.Identifier, Str: "val", Beg: 0, End: 3},
+			},
+		},
+		{
+			name: "var",
 			code: `var`,
 			want: []*token.Token{
-				{Kind: kind.Identifier, Str: "var", Beg: 0, End: 3},
+				{Kind: kind.Var, Str: "var", Beg: 0, End: 3},
+			},
+		},
+		{
+			name: "const",
+			code: `const`,
+			want: []*token.Token{
+				{Kind: kind.Const, Str: "const", Beg: 0, End: 5},
 			},
 		},
 		{
@@ -322,6 +336,35 @@ func TestTokenizer_Tokenize(t *testing.T) {
 				{Kind: kind.RightCurly, Str: "}", Beg: 17, End: 18},
 			},
 		},
+		{
+			name: "identifier with digits and underscore",
+			code: `read_int2`,
+			want: []*token.Token{
+				{Kind: kind.Identifier, Str: "read_int2", Beg: 0, End: 9},
+			},
+		},
+		{
+			name: "typed param",
+			code: `x: i32`,
+			want: []*token.Token{
+				{Kind: kind.Identifier, Str: "x", Beg: 0, End: 1},
+				{Kind: kind.Colon, Str: ":", Beg: 1, End: 2},
+				{Kind: kind.Identifier, Str: "i32", Beg: 3, End: 6},
+			},
+		},
+		{
+			name: "function type",
+			code: `fn(i32)->i32`,
+			want: []*token.Token{
+				{Kind: kind.Fn, Str: "fn", Beg: 0, End: 2},
+				{Kind: kind.LeftParen, Str: "(", Beg: 2, End: 3},
+				{Kind: kind.Identifier, Str: "i32", Beg: 3, End: 6},
+				{Kind: kind.RightParen, Str: ")", Beg: 6, End: 7},
+				{Kind: kind.Minus, Str: "-", Beg: 7, End: 8},
+				{Kind: kind.Greater, Str: ">", Beg: 8, End: 9},
+				{Kind: kind.Identifier, Str: "i32", Beg: 9, End: 12},
+			},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -28,11 +28,14 @@ var table = Transition{
 		{check: Ch('{'), emit: Emit(kind.LeftCurly), next: state.Init, retry: false},
 		{check: Ch('}'), emit: Emit(kind.RightCurly), next: state.Init, retry: false},
 		{check: Ch('<'), emit: Emit(kind.Less), next: state.Init, retry: false},
+		{check: Ch('>'), emit: Emit(kind.Greater), next: state.Init, retry: false},
 		{check: Ch('='), emit: Emit(kind.Equal), next: state.Init, retry: false},
 		{check: Ch(';'), emit: Emit(kind.Semicolon), next: state.Init, retry: false},
+		{check: Ch(':'), emit: Emit(kind.Colon), next: state.Init, retry: false},
 		{check: Ch(','), emit: Emit(kind.Comma), next: state.Init, retry: false},
+		{check: Ch('.'), emit: Emit(kind.Dot), next: state.Init, retry: false},
 		{check: IsDigit, emit: Save, next: state.Integer, retry: true},
-		{check: IsLetter, emit: Save, next: state.Identifier, retry: true},
+		{check: IsIdentHead, emit: Save, next: state.Identifier, retry: true},
 		{check: Any, emit: Emit(kind.Skip), next: state.Init, retry: false},
 	},
 	state.String: Edges{
@@ -48,7 +51,7 @@ var table = Transition{
 		{check: Any, emit: Emit(kind.Integer), next: state.Init, retry: true},
 	},
 	state.Identifier: Edges{
-		{check: IsLetter, emit: Save, next: state.Identifier, retry: false},
+		{check: IsIdentTail, emit: Save, next: state.Identifier, retry: false},
 		{check: Any, emit: Emit(kind.Identifier), next: state.Init, retry: true},
 	},
 }
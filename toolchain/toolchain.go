@@ -0,0 +1,116 @@
+// Package toolchain builds native executables from LLVM IR or assembly with the toolchain in PATH.
+package toolchain
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Linkers lists commands to link object files, in order of preference.
+var Linkers = []string{"clang", "cc"}
+
+// Config configures how an executable is built.
+type Config struct {
+	// Output is the path of the executable to write.
+	Output string
+	// Triple is the target triple like `x86_64-pc-linux-gnu`, empty for the host.
+	Triple string
+	// OptLevel is passed to llc as `-O<n>`.
+	OptLevel int
+	// LLC is the command to compile IR into an object file, `llc` if empty.
+	LLC string
+	// Linker is the command to link the object file, the first found in Linkers if empty.
+	Linker string
+	// Runtime lists object files or libraries linked together, like `-lm`.
+	Runtime []string
+}
+
+// Build compiles ll into an executable at cfg.Output.
+// Intermediate files are written into a temporary directory which is removed afterwards.
+func Build(ll string, cfg Config) error {
+	llc, err := lookTool(cfg.LLC, "llc")
+	if err != nil {
+		return err
+	}
+	linker, err := lookTool(cfg.Linker, Linkers...)
+	if err != nil {
+		return err
+	}
+
+	dir, err := os.MkdirTemp("", "yuni-build-")
+	if err != nil {
+		return fmt.Errorf("cannot make a temporary directory: %s", err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	src := filepath.Join(dir, "main.ll")
+	obj := filepath.Join(dir, "main.o")
+	if err := os.WriteFile(src, []byte(ll), 0o644); err != nil {
+		return fmt.Errorf("cannot write %s: %s", src, err.Error())
+	}
+
+	args := []string{fmt.Sprintf("-O%d", cfg.OptLevel), "-filetype=obj", "-relocation-model=pic", "-o", obj}
+	if cfg.Triple != "" {
+		args = append(args, "-mtriple="+cfg.Triple)
+	}
+	if err := run(llc, append(args, src)...); err != nil {
+		return err
+	}
+
+	args = []string{"-o", cfg.Output, obj}
+	if cfg.Triple != "" && filepath.Base(linker) == "clang" {
+		args = append(args, "--target="+cfg.Triple)
+	}
+	return run(linker, append(args, cfg.Runtime...)...)
+}
+
+// BuildAsm assembles asm, x86-64 assembly for the host, into an executable at cfg.Output
+// with the linker, which is a C compiler driver assembling it with the system assembler.
+// Only Output, Linker and Runtime of cfg are used.
+func BuildAsm(asm string, cfg Config) error {
+	linker, err := lookTool(cfg.Linker, Linkers...)
+	if err != nil {
+		return err
+	}
+
+	dir, err := os.MkdirTemp("", "yuni-build-")
+	if err != nil {
+		return fmt.Errorf("cannot make a temporary directory: %s", err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	src := filepath.Join(dir, "main.s")
+	if err := os.WriteFile(src, []byte(asm), 0o644); err != nil {
+		return fmt.Errorf("cannot write %s: %s", src, err.Error())
+	}
+	return run(linker, append([]string{"-o", cfg.Output, src}, cfg.Runtime...)...)
+}
+
+// lookTool finds the command name, or the first of candidates found in PATH if name is empty.
+func lookTool(name string, candidates ...string) (string, error) {
+	if name != "" {
+		candidates = []string{name}
+	}
+	for _, c := range candidates {
+		if path, err := exec.LookPath(c); err == nil {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("cannot find %s in PATH, install LLVM and clang to build executables", strings.Join(candidates, " or "))
+}
+
+// run runs the command and reports its output on failure.
+func run(name string, args ...string) error {
+	cmd := exec.Command(name, args...)
+	out := new(bytes.Buffer)
+	cmd.Stdout = out
+	cmd.Stderr = out
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s failed: %s\n%s", filepath.Base(name), err.Error(), out.String())
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package toolchain_test
+
+import (
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"gotest.tools/assert"
+
+	"github.com/yuniruyuni/lang/toolchain"
+)
+
+const exitWith42 = `define i32 @main() {
+entry:
+  ret i32 42
+}
+`
+
+func TestBuild(t *testing.T) {
+	if _, err := exec.LookPath("llc"); err != nil {
+		t.Skip("llc is not installed")
+	}
+
+	out := filepath.Join(t.TempDir(), "prog")
+	assert.NilError(t, toolchain.Build(exitWith42, toolchain.Config{Output: out, OptLevel: 2}))
+
+	err := exec.Command(out).Run()
+	exit, ok := err.(*exec.ExitError)
+	assert.Assert(t, ok, "want exit status 42, got %v", err)
+	assert.Equal(t, 42, exit.ExitCode())
+}
+
+func TestBuild_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		ll      string
+		cfg     toolchain.Config
+		wantErr string
+	}{
+		{
+			name:    "missing llc",
+			ll:      exitWith42,
+			cfg:     toolchain.Config{LLC: "no-such-llc"},
+			wantErr: "cannot find no-such-llc in PATH",
+		},
+		{
+			name:    "missing linker",
+			ll:      exitWith42,
+			cfg:     toolchain.Config{Linker: "no-such-cc"},
+			wantErr: "cannot find no-such-cc in PATH",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.cfg.Output = filepath.Join(t.TempDir(), "prog")
+			err := toolchain.Build(tt.ll, tt.cfg)
+			assert.Assert(t, err != nil)
+			assert.Assert(t, strings.Contains(err.Error(), tt.wantErr), err.Error())
+		})
+	}
+}
+
+func TestBuildAsm(t *testing.T) {
+	if runtime.GOOS != "linux" || runtime.GOARCH != "amd64" {
+		t.Skip("the assembly is for x86-64 Linux")
+	}
+	if _, err := exec.LookPath("cc"); err != nil {
+		t.Skip("cc is not installed")
+	}
+
+	asm := "\t.text\n\t.globl main\nmain:\n\tmovl $42, %eax\n\tret\n"
+	out := filepath.Join(t.TempDir(), "prog")
+	assert.NilError(t, toolchain.BuildAsm(asm, toolchain.Config{Output: out, Linker: "cc"}))
+
+	err := exec.Command(out).Run()
+	exit, ok := err.(*exec.ExitError)
+	assert.Assert(t, ok, "want exit status 42, got %v", err)
+	assert.Equal(t, 42, exit.ExitCode())
+}
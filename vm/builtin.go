@@ -0,0 +1,142 @@
+package vm
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/yuniruyuni/lang/libc"
+)
+
+// builtins implements the runtime functions and external functions from libc which programs can call.
+var builtins = map[string]builtinFunc{
+	readBuiltin:      input((*libc.Input).ReadInt),
+	"read_int":       input((*libc.Input).ReadInt),
+	"read_char":      input((*libc.Input).ReadChar),
+	"read_line":      readLine,
+	"read_ok":        input((*libc.Input).OK),
+	"eof":            input((*libc.Input).EOF),
+	printIntBuiltin:  printInt,
+	printBoolBuiltin: printBool,
+	printStrBuiltin:  printStr,
+	trapBuiltin:      trap,
+	"printf":         printf,
+	"putchar":        putchar,
+	"abs":            abs,
+	"labs":           abs,
+}
+
+// unsupported makes a builtin for the external function n which the VM doesn't implement.
+func unsupported(n string) builtinFunc {
+	return func(m *machine, args []int64) (int64, error) {
+		return 0, fmt.Errorf("extern function %s is not supported by the VM", n)
+	}
+}
+
+// input adapts the method f of libc.Input to builtinFunc; reading stdin never fails.
+func input(f func(in *libc.Input) int64) builtinFunc {
+	return func(m *machine, args []int64) (int64, error) {
+		return f(m.in), nil
+	}
+}
+
+// readLine keeps the line in strs made at runtime and results the pointer to it.
+func readLine(m *machine, args []int64) (int64, error) {
+	m.strs = append(m.strs, m.in.ReadLine())
+	return int64(len(m.p.Strings) + len(m.strs) - 1), nil
+}
+
+func printf(m *machine, args []int64) (int64, error) {
+	if len(args) == 0 {
+		return 0, libc.ErrTooFewArgs
+	}
+	format, err := m.str(args[0])
+	if err != nil {
+		return 0, err
+	}
+	s, err := libc.Sprintf(format, &stackArgs{m: m, args: args[1:]})
+	m.out.WriteString(s)
+	return int64(len(s)), err
+}
+
+func printInt(m *machine, args []int64) (int64, error) {
+	m.out.WriteString(strconv.FormatInt(args[0], 10))
+	return 0, nil
+}
+
+func printBool(m *machine, args []int64) (int64, error) {
+	m.out.WriteString(strconv.FormatBool(args[0] != 0))
+	return 0, nil
+}
+
+func printStr(m *machine, args []int64) (int64, error) {
+	s, err := m.str(args[0])
+	if err != nil {
+		return 0, err
+	}
+	m.out.WriteString(libc.Unescape(s))
+	return 0, nil
+}
+
+// trap stops the program unless the condition is true,
+// taking it, the position of the failure, the reason and the detail like the message of `panic`.
+func trap(m *machine, args []int64) (int64, error) {
+	if args[0] != 0 {
+		return 0, nil
+	}
+	ss := []string{}
+	for _, a := range args[1:] {
+		s, err := m.str(a)
+		if err != nil {
+			return 0, err
+		}
+		ss = append(ss, libc.Unescape(s))
+	}
+	return 0, &RuntimeError{Where: ss[0], Reason: ss[1] + ss[2]}
+}
+
+func putchar(m *machine, args []int64) (int64, error) {
+	m.out.WriteByte(byte(args[0]))
+	return args[0], nil
+}
+
+func abs(m *machine, args []int64) (int64, error) {
+	if args[0] < 0 {
+		return -args[0], nil
+	}
+	return args[0], nil
+}
+
+// str returns the string literal or the string read at runtime which the value v points to.
+func (m *machine) str(v int64) (string, error) {
+	if v < 0 || int(v) >= len(m.p.Strings)+len(m.strs) {
+		return "", errors.New("invalid pointer to a string")
+	}
+	if int(v) >= len(m.p.Strings) {
+		return m.strs[int(v)-len(m.p.Strings)], nil
+	}
+	return m.p.Strings[v], nil
+}
+
+// stackArgs reads arguments of printf taken from the stack.
+type stackArgs struct {
+	m    *machine
+	args []int64
+}
+
+func (a *stackArgs) Int() (int64, error) {
+	if len(a.args) == 0 {
+		return 0, libc.ErrTooFewArgs
+	}
+	v := a.args[0]
+	a.args = a.args[1:]
+	return v, nil
+}
+
+func (a *stackArgs) String() (string, error) {
+	v, err := a.Int()
+	if err != nil {
+		return "", err
+	}
+	return a.m.str(v)
+}
@@ -0,0 +1,146 @@
+// Package vm compiles a program into a compact bytecode and runs it on a stack machine.
+// It follows the same semantics as the interpreter and the code generated for LLVM,
+// and a compiled program can be saved into a file to start it quickly later.
+package vm
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Op is an operation of the stack machine.
+type Op uint8
+
+const (
+	// OpPush pushes the integer A.
+	OpPush Op = iota
+	// OpPop drops the top of the stack.
+	OpPop
+	// OpDup pushes the top of the stack again.
+	OpDup
+	// OpLoad pushes the local variable A.
+	OpLoad
+	// OpStore pops a value into the local variable A.
+	OpStore
+	// OpGLoad pushes the global A.
+	OpGLoad
+	// OpGStore pops a value into the global A.
+	OpGStore
+	// OpAdd, OpSub and OpMul pop y and x and push `x op y` wrapped to A bits.
+	// OpDiv wraps it to B bits, and fails by division by zero at the position held in the string A.
+	OpAdd
+	OpSub
+	OpMul
+	OpDiv
+	// OpLess and OpEqual pop y and x and push 1 if `x op y` holds, otherwise 0.
+	OpLess
+	OpEqual
+	// OpWrap truncates the top of the stack to A bits and sign-extends it back.
+	OpWrap
+	// OpJump jumps to the instruction A.
+	OpJump
+	// OpJumpZero pops a value and jumps to the instruction A if it is zero.
+	OpJumpZero
+	// OpCall calls the function A with arguments on the stack.
+	OpCall
+	// OpCallBuiltin calls the builtin A with B arguments on the stack.
+	OpCallBuiltin
+	// OpCallValue pops a function value and calls it with A arguments on the stack.
+	OpCallValue
+	// OpRet returns the top of the stack to the caller.
+	OpRet
+)
+
+var opNames = [...]string{
+	OpPush:        "push",
+	OpPop:         "pop",
+	OpDup:         "dup",
+	OpLoad:        "load",
+	OpStore:       "store",
+	OpGLoad:       "gload",
+	OpGStore:      "gstore",
+	OpAdd:         "add",
+	OpSub:         "sub",
+	OpMul:         "mul",
+	OpDiv:         "div",
+	OpLess:        "less",
+	OpEqual:       "equal",
+	OpWrap:        "wrap",
+	OpJump:        "jump",
+	OpJumpZero:    "jumpzero",
+	OpCall:        "call",
+	OpCallBuiltin: "callbuiltin",
+	OpCallValue:   "callvalue",
+	OpRet:         "ret",
+}
+
+func (op Op) String() string {
+	if int(op) < len(opNames) {
+		return opNames[op]
+	}
+	return fmt.Sprintf("op(%d)", op)
+}
+
+// Instr is an instruction with its operands.
+type Instr struct {
+	Op Op
+	A  int32
+	B  int32
+}
+
+func (i Instr) String() string {
+	switch i.Op {
+	case OpCallBuiltin, OpDiv:
+		return fmt.Sprintf("%s %d %d", i.Op, i.A, i.B)
+	case OpPop, OpDup, OpLess, OpEqual, OpRet:
+		return i.Op.String()
+	default:
+		return fmt.Sprintf("%s %d", i.Op, i.A)
+	}
+}
+
+// Func is a compiled function.
+type Func struct {
+	Name string
+	// Params is the number of parameters, which are the first local variables.
+	Params int
+	// Locals is the number of local variables including parameters.
+	Locals int
+	Code   []Instr
+}
+
+// Program is a compiled program.
+//
+// Every value on the stack is an int64.
+// A string is the index in Strings, and a function value is the index in Funcs,
+// or -1-i for the builtin i.
+type Program struct {
+	// Strings is the constant pool of string literals.
+	Strings []string
+	// Globals is the number of module level variables and constants.
+	Globals int
+	// Builtins lists names of external functions which the runtime implements, like `printf`.
+	Builtins []string
+	Funcs    []*Func
+	// Init is the function which initializes globals, and Main is `main`.
+	Init int
+	Main int
+}
+
+// String disassembles p.
+func (p *Program) String() string {
+	b := strings.Builder{}
+	for i, s := range p.Strings {
+		fmt.Fprintf(&b, "string %d %q\n", i, s)
+	}
+	for i, n := range p.Builtins {
+		fmt.Fprintf(&b, "builtin %d %s\n", i, n)
+	}
+	for i, f := range p.Funcs {
+		fmt.Fprintf(&b, "func %d %s params=%d locals=%d\n", i, f.Name, f.Params, f.Locals)
+		for pc, in := range f.Code {
+			fmt.Fprintf(&b, "  %4d %s\n", pc, in)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,445 @@
+package vm
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/yuniruyuni/lang/ast"
+	"github.com/yuniruyuni/lang/ir"
+)
+
+// readBuiltin is the runtime function reading an integer, which every program can call.
+const readBuiltin = "read"
+
+// inputBuiltins are the other runtime functions reading stdin,
+// which are added to builtins only when a program uses them.
+var inputBuiltins = map[ast.Name]bool{
+	"read_int":  true,
+	"read_char": true,
+	"read_line": true,
+	"read_ok":   true,
+	"eof":       true,
+}
+
+// runtime functions printing a value of each type for `print` and `println`,
+// whose names cannot collide with functions of yuni.
+const (
+	printIntBuiltin  = "print:int"
+	printBoolBuiltin = "print:bool"
+	printStrBuiltin  = "print:str"
+)
+
+// trapBuiltin is the runtime function which stops the program by a runtime error of `panic` or `assert`.
+const trapBuiltin = "trap:unless"
+
+type compiler struct {
+	p        *Program
+	info     *ast.Info
+	funcs    map[ast.Name]int
+	builtins map[ast.Name]int
+	globals  map[ast.Name]int
+	strs     map[string]int
+
+	// module is the module compiling now.
+	module *ast.Module
+	// fn is the function compiling now, and locals maps its variables to their slots.
+	fn     *Func
+	locals map[ast.Name]int
+}
+
+// Compile compiles prog into bytecode.
+// prog must have passed code generation, which checks it and resolves the types of its nodes into info.
+func Compile(prog *ast.Program, info *ast.Info) (p *Program, err error) {
+	c := &compiler{
+		p:        &Program{},
+		info:     info,
+		funcs:    map[ast.Name]int{},
+		builtins: map[ast.Name]int{},
+		globals:  map[ast.Name]int{},
+		strs:     map[string]int{},
+	}
+
+	// nodes which the compiler doesn't support are reported by panicking with an error.
+	defer func() {
+		if r := recover(); r != nil {
+			e, ok := r.(error)
+			if !ok {
+				panic(r)
+			}
+			p, err = nil, e
+		}
+	}()
+
+	c.builtin(readBuiltin)
+	for _, m := range prog.Modules {
+		c.declare(m.(*ast.Module))
+	}
+
+	main, ok := c.funcs["main"]
+	if !ok {
+		return nil, fmt.Errorf("function main doesn't exist")
+	}
+	c.p.Main = main
+
+	c.p.Init = len(c.p.Funcs)
+	c.p.Funcs = append(c.p.Funcs, &Func{Name: "<init>"})
+	c.begin(c.p.Funcs[c.p.Init], nil)
+	for _, m := range prog.Modules {
+		c.compileGlobals(m.(*ast.Module))
+	}
+	c.emit(OpPush, 0)
+	c.emit(OpRet, 0)
+
+	for _, m := range prog.Modules {
+		c.compileFuncs(m.(*ast.Module))
+	}
+	return c.p, nil
+}
+
+// defs lists module level definitions of m, unwrapping `pub`.
+func defs(m *ast.Module) []ast.AST {
+	ds := []ast.AST{}
+	for _, d := range m.Defs.(*ast.Definitions).Defs {
+		if p, ok := d.(*ast.Pub); ok {
+			d = p.Def
+		}
+		ds = append(ds, d)
+	}
+	return ds
+}
+
+// declare allocates functions, builtins and globals defined in m.
+func (c *compiler) declare(m *ast.Module) {
+	for _, d := range defs(m) {
+		switch d := d.(type) {
+		case *ast.Func:
+			c.funcs[m.Qualify(d.Name())] = len(c.p.Funcs)
+			c.p.Funcs = append(c.p.Funcs, &Func{
+				Name:   string(m.Qualify(d.Name())),
+				Params: len(d.Params.(*ast.Params).Vars),
+			})
+		case *ast.Extern:
+			c.builtin(d.Name())
+		case *ast.Const, *ast.Global:
+			c.globals[m.Qualify(d.Name())] = c.p.Globals
+			c.p.Globals++
+		}
+	}
+}
+
+// builtin returns the index of the builtin n, adding it if it is new.
+func (c *compiler) builtin(n ast.Name) int {
+	if i, ok := c.builtins[n]; ok {
+		return i
+	}
+	c.builtins[n] = len(c.p.Builtins)
+	c.p.Builtins = append(c.p.Builtins, string(n))
+	return c.builtins[n]
+}
+
+// lookupBuiltin returns the index of the builtin n, adding it if n is a runtime function used first.
+func (c *compiler) lookupBuiltin(n ast.Name) (int, bool) {
+	if i, ok := c.builtins[n]; ok {
+		return i, true
+	}
+	if !inputBuiltins[n] {
+		return 0, false
+	}
+	return c.builtin(n), true
+}
+
+// compileGlobals emits initialization of globals in m into the init function.
+func (c *compiler) compileGlobals(m *ast.Module) {
+	c.module = m
+	for _, d := range defs(m) {
+		switch d := d.(type) {
+		case *ast.Const:
+			c.expr(d.RHS)
+			c.emit(OpGStore, int32(c.globals[m.Qualify(d.Name())]))
+		case *ast.Global:
+			c.expr(d.RHS)
+			c.emit(OpGStore, int32(c.globals[m.Qualify(d.Name())]))
+		}
+	}
+}
+
+// compileFuncs compiles functions in m.
+func (c *compiler) compileFuncs(m *ast.Module) {
+	c.module = m
+	for _, d := range defs(m) {
+		f, ok := d.(*ast.Func)
+		if !ok {
+			continue
+		}
+		c.begin(c.p.Funcs[c.funcs[m.Qualify(f.Name())]], f.Params.(*ast.Params).Vars)
+		c.expr(f.Execute)
+		// the result is converted to the return type as code generation does.
+		if t := c.info.TypeOf(f.Execute); t.IsInt() && f.Type().IsInt() && t != f.Type() {
+			c.emit(OpWrap, int32(f.Type().Bits()))
+		}
+		c.emit(OpRet, 0)
+	}
+}
+
+// begin starts compiling fn which takes params.
+func (c *compiler) begin(fn *Func, params []ast.AST) {
+	c.fn = fn
+	c.locals = map[ast.Name]int{}
+	for _, p := range params {
+		c.local(p.Name())
+	}
+}
+
+// local allocates a new slot for the variable n.
+func (c *compiler) local(n ast.Name) int32 {
+	c.locals[n] = c.fn.Locals
+	c.fn.Locals++
+	return int32(c.locals[n])
+}
+
+// emit appends an instruction to current function and returns its position.
+func (c *compiler) emit(op Op, a int32) int {
+	c.fn.Code = append(c.fn.Code, Instr{Op: op, A: a})
+	return len(c.fn.Code) - 1
+}
+
+// patch makes the jump at pc jump to the next instruction emitted.
+func (c *compiler) patch(pc int) {
+	c.fn.Code[pc].A = int32(len(c.fn.Code))
+}
+
+// expr emits n which pushes exactly one value.
+func (c *compiler) expr(n ast.AST) {
+	switch n := n.(type) {
+	case *ast.Integer:
+		c.emit(OpPush, int32(n.Value))
+	case *ast.String:
+		c.emit(OpPush, c.str(n.Word))
+	case *ast.Variable:
+		c.load(n)
+	case *ast.Let:
+		c.expr(n.RHS)
+		c.emit(OpDup, 0)
+		c.emit(OpStore, c.local(n.Name()))
+	case *ast.Assign:
+		c.expr(n.RHS)
+		// the value is converted into the type of the variable.
+		if t := c.info.TypeOf(n); t != c.info.TypeOf(n.RHS) {
+			c.emit(OpWrap, int32(t.Bits()))
+		}
+		c.emit(OpDup, 0)
+		c.store(n)
+	case *ast.Sequence:
+		c.expr(n.LHS)
+		c.emit(OpPop, 0)
+		c.expr(n.RHS)
+	case *ast.If:
+		c.expr(n.Cond)
+		toElse := c.emit(OpJumpZero, 0)
+		c.expr(n.Then)
+		toEnd := c.emit(OpJump, 0)
+		c.patch(toElse)
+		c.expr(n.Else)
+		c.patch(toEnd)
+	case *ast.While:
+		// the stack keeps the value of the previous iteration, which is replaced by the next one,
+		// and the first one is 0 for a loop which doesn't iterate.
+		c.emit(OpPush, 0)
+		loop := len(c.fn.Code)
+		c.expr(n.Cond)
+		toEnd := c.emit(OpJumpZero, 0)
+		c.emit(OpPop, 0)
+		c.expr(n.Proc)
+		c.emit(OpJump, int32(loop))
+		c.patch(toEnd)
+	case *ast.Call:
+		c.call(n)
+	case *ast.Add:
+		c.arith(OpAdd, n, n.LHS, n.RHS)
+	case *ast.Sub:
+		c.arith(OpSub, n, n.LHS, n.RHS)
+	case *ast.Mul:
+		c.arith(OpMul, n, n.LHS, n.RHS)
+	case *ast.Div:
+		c.arith(OpDiv, n, n.LHS, n.RHS)
+	case *ast.Less:
+		c.binary(OpLess, n.LHS, n.RHS)
+	case *ast.Equal:
+		c.binary(OpEqual, n.LHS, n.RHS)
+	default:
+		panic(fmt.Errorf("%T cannot be compiled into bytecode", n))
+	}
+}
+
+// str returns the pointer to the string literal of yuni s, adding it if it is new.
+func (c *compiler) str(s string) int32 {
+	if _, ok := c.strs[s]; !ok {
+		c.strs[s] = len(c.p.Strings)
+		c.p.Strings = append(c.p.Strings, s)
+	}
+	return int32(c.strs[s])
+}
+
+// arith emits lhs op rhs for n, which wraps around to the width of the type of n.
+// The divisor can be zero, so OpDiv is given the position to report it.
+func (c *compiler) arith(op Op, n, lhs, rhs ast.AST) {
+	c.expr(lhs)
+	c.expr(rhs)
+	bits := int32(c.info.TypeOf(n).Bits())
+	if op == OpDiv {
+		pc := c.emit(OpDiv, c.where(n))
+		c.fn.Code[pc].B = bits
+		return
+	}
+	c.emit(op, bits)
+}
+
+func (c *compiler) binary(op Op, lhs, rhs ast.AST) {
+	c.expr(lhs)
+	c.expr(rhs)
+	c.emit(op, 0)
+}
+
+// call emits a call of the function named by n, or the function value held in the variable.
+func (c *compiler) call(n *ast.Call) {
+	callee := c.info.Calls[n]
+	switch callee.Builtin {
+	case "":
+	case ast.PanicBuiltin:
+		c.emit(OpPush, 0)
+		c.trap(n, "panic: ", n.Args.(*ast.Args).Values[0])
+		return
+	case ast.AssertBuiltin:
+		args := n.Args.(*ast.Args).Values
+		c.expr(args[0])
+		if len(args) == 1 {
+			c.trap(n, "assertion failed", nil)
+		} else {
+			c.trap(n, "assertion failed: ", args[1])
+		}
+		return
+	default:
+		c.print(n, callee.Builtin)
+		return
+	}
+	params := callee.FuncType.Params()
+	args := n.Args.(*ast.Args).Values
+	for i, a := range args {
+		c.expr(a)
+		// integer arguments are extended or truncated to their parameter as code generation does.
+		if i < len(params) && params[i] != "..." && params[i].IsInt() && c.info.TypeOf(a).IsInt() && c.info.TypeOf(a) != params[i] {
+			c.emit(OpWrap, int32(params[i].Bits()))
+		}
+	}
+
+	if c.isVariable(n.FuncName) {
+		c.load(n.FuncName)
+		c.emit(OpCallValue, int32(len(args)))
+		return
+	}
+
+	r := c.info.Names[n.FuncName]
+	if f, ok := c.funcs[r]; ok {
+		c.emit(OpCall, int32(f))
+		return
+	}
+	b, ok := c.lookupBuiltin(r)
+	if !ok {
+		panic(fmt.Errorf("Function %s doesn't exist.", n.FuncName.Name()))
+	}
+	c.callBuiltin(b, len(args))
+	if ret := callee.FuncType.Return(); ret.IsInt() {
+		c.emit(OpWrap, int32(ret.Bits()))
+	}
+}
+
+// callBuiltin emits calling the builtin b with n arguments on the stack.
+func (c *compiler) callBuiltin(b, n int) {
+	pc := c.emit(OpCallBuiltin, int32(b))
+	c.fn.Code[pc].B = int32(n)
+}
+
+// print emits the builtin `print` or `println` called by n,
+// which prints each argument by the runtime function for its type and pushes 0.
+func (c *compiler) print(n *ast.Call, b ast.Name) {
+	for _, a := range n.Args.(*ast.Args).Values {
+		c.expr(a)
+		switch t := c.info.TypeOf(a); {
+		case t == ir.I1:
+			c.callBuiltin(c.builtin(printBoolBuiltin), 1)
+		case t.IsInt():
+			c.callBuiltin(c.builtin(printIntBuiltin), 1)
+		default:
+			c.callBuiltin(c.builtin(printStrBuiltin), 1)
+		}
+		c.emit(OpPop, 0)
+	}
+	if b == ast.PrintlnBuiltin {
+		c.emit(OpPush, c.str(`\0A`))
+		c.callBuiltin(c.builtin(printStrBuiltin), 1)
+		c.emit(OpPop, 0)
+	}
+	c.emit(OpPush, 0)
+}
+
+// trap emits the runtime function stopping the program by the reason and the message detail located at n
+// unless the condition on the stack is true, and pushes 0. A nil detail is empty.
+func (c *compiler) trap(n *ast.Call, reason string, detail ast.AST) {
+	c.emit(OpPush, c.where(n))
+	c.emit(OpPush, c.str(reason))
+	if detail != nil {
+		c.expr(detail)
+	} else {
+		c.emit(OpPush, c.str(""))
+	}
+	c.callBuiltin(c.builtin(trapBuiltin), 4)
+}
+
+// where makes the string of the position of n like `main.yuni:3:5`, escaped as a string literal.
+func (c *compiler) where(n ast.AST) int32 {
+	return c.str(strings.ReplaceAll(ast.Where(c.module.File, n), `\`, `\5C`))
+}
+
+// isVariable reports whether n refers a local variable or a global.
+func (c *compiler) isVariable(n ast.AST) bool {
+	if _, ok := c.locals[n.Name()]; ok {
+		return true
+	}
+	_, ok := c.globals[c.info.Names[n]]
+	return ok
+}
+
+// load emits pushing the variable, global or function which n refers.
+func (c *compiler) load(n ast.AST) {
+	if l, ok := c.locals[n.Name()]; ok {
+		c.emit(OpLoad, int32(l))
+		return
+	}
+	r := c.info.Names[n]
+	if g, ok := c.globals[r]; ok {
+		c.emit(OpGLoad, int32(g))
+		return
+	}
+	if f, ok := c.funcs[r]; ok {
+		c.emit(OpPush, int32(f))
+		return
+	}
+	if b, ok := c.lookupBuiltin(r); ok {
+		c.emit(OpPush, int32(-1-b))
+		return
+	}
+	panic(fmt.Errorf("Variable %s doesn't exist.", n.Name()))
+}
+
+// store emits popping a value into the variable or global which n refers.
+func (c *compiler) store(n ast.AST) {
+	if l, ok := c.locals[n.Name()]; ok {
+		c.emit(OpStore, int32(l))
+		return
+	}
+	g, ok := c.globals[c.info.Names[n]]
+	if !ok {
+		panic(fmt.Errorf("Variable %s doesn't exist.", n.Name()))
+	}
+	c.emit(OpGStore, int32(g))
+}
@@ -0,0 +1,218 @@
+package vm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Magic starts every bytecode file.
+const Magic = "YBC\x02"
+
+// Ext is the extension of bytecode files.
+const Ext = ".ybc"
+
+// MarshalBinary encodes p into the bytecode file format.
+// Integers are encoded as varints, and strings are prefixed by their length.
+func (p *Program) MarshalBinary() ([]byte, error) {
+	b := &encoder{}
+	b.buf.WriteString(Magic)
+
+	b.uint(len(p.Strings))
+	for _, s := range p.Strings {
+		b.string(s)
+	}
+	b.uint(p.Globals)
+	b.uint(len(p.Builtins))
+	for _, n := range p.Builtins {
+		b.string(n)
+	}
+	b.uint(len(p.Funcs))
+	for _, f := range p.Funcs {
+		b.string(f.Name)
+		b.uint(f.Params)
+		b.uint(f.Locals)
+		b.uint(len(f.Code))
+		for _, in := range f.Code {
+			b.buf.WriteByte(byte(in.Op))
+			b.int(int64(in.A))
+			if in.Op == OpCallBuiltin || in.Op == OpDiv {
+				b.int(int64(in.B))
+			}
+		}
+	}
+	b.uint(p.Init)
+	b.uint(p.Main)
+	return b.buf.Bytes(), nil
+}
+
+// UnmarshalBinary decodes p from data in the bytecode file format.
+// It checks every operand refers an existing function, variable or instruction,
+// so that a broken file cannot crash the VM.
+func (p *Program) UnmarshalBinary(data []byte) (err error) {
+	if !bytes.HasPrefix(data, []byte(Magic)) {
+		return errors.New("not a bytecode file")
+	}
+	d := &decoder{data: data[len(Magic):]}
+
+	// reading out of data is reported by panicking with errTruncated.
+	defer func() {
+		if r := recover(); r != nil {
+			if r != errTruncated {
+				panic(r)
+			}
+			err = fmt.Errorf("invalid bytecode: %s", errTruncated.Error())
+		}
+	}()
+
+	*p = Program{}
+	p.Strings = make([]string, d.count())
+	for i := range p.Strings {
+		p.Strings[i] = d.string()
+	}
+	p.Globals = d.uint()
+	p.Builtins = make([]string, d.count())
+	for i := range p.Builtins {
+		p.Builtins[i] = d.string()
+	}
+	p.Funcs = make([]*Func, d.count())
+	for i := range p.Funcs {
+		f := &Func{Name: d.string(), Params: d.uint(), Locals: d.uint()}
+		f.Code = make([]Instr, d.count())
+		for k := range f.Code {
+			f.Code[k] = Instr{Op: Op(d.byte()), A: int32(d.int())}
+			if op := f.Code[k].Op; op == OpCallBuiltin || op == OpDiv {
+				f.Code[k].B = int32(d.int())
+			}
+		}
+		p.Funcs[i] = f
+	}
+	p.Init = d.uint()
+	p.Main = d.uint()
+
+	if err := p.validate(); err != nil {
+		return fmt.Errorf("invalid bytecode: %s", err.Error())
+	}
+	return nil
+}
+
+// validate checks operands of every instruction are in range.
+func (p *Program) validate() error {
+	if p.Init >= len(p.Funcs) || p.Main >= len(p.Funcs) {
+		return errors.New("entry function doesn't exist")
+	}
+	for _, f := range p.Funcs {
+		if f.Params > f.Locals {
+			return fmt.Errorf("%s has more parameters than locals", f.Name)
+		}
+		if len(f.Code) == 0 || f.Code[len(f.Code)-1].Op != OpRet && f.Code[len(f.Code)-1].Op != OpJump {
+			return fmt.Errorf("%s doesn't end with ret", f.Name)
+		}
+		for pc, in := range f.Code {
+			if err := p.validateInstr(f, in); err != nil {
+				return fmt.Errorf("%s at %d: %s", f.Name, pc, err.Error())
+			}
+		}
+	}
+	return nil
+}
+
+func (p *Program) validateInstr(f *Func, in Instr) error {
+	inRange := func(x int32, n int) bool { return 0 <= x && int(x) < n }
+
+	ok := true
+	switch in.Op {
+	case OpLoad, OpStore:
+		ok = inRange(in.A, f.Locals)
+	case OpGLoad, OpGStore:
+		ok = inRange(in.A, p.Globals)
+	case OpJump, OpJumpZero:
+		ok = inRange(in.A, len(f.Code))
+	case OpCall:
+		ok = inRange(in.A, len(p.Funcs))
+	case OpCallBuiltin:
+		ok = inRange(in.A, len(p.Builtins)) && in.B >= 0
+	case OpCallValue:
+		ok = in.A >= 0
+	case OpAdd, OpSub, OpMul:
+		ok = in.A == 32 || in.A == 64
+	case OpDiv:
+		ok = inRange(in.A, len(p.Strings)) && (in.B == 32 || in.B == 64)
+	case OpPush, OpPop, OpDup, OpLess, OpEqual, OpWrap, OpRet:
+	default:
+		return fmt.Errorf("unknown instruction %s", in.Op)
+	}
+	if !ok {
+		return fmt.Errorf("operand of %s is out of range", in)
+	}
+	return nil
+}
+
+type encoder struct {
+	buf bytes.Buffer
+}
+
+func (e *encoder) uint(x int) {
+	var b [binary.MaxVarintLen64]byte
+	e.buf.Write(b[:binary.PutUvarint(b[:], uint64(x))])
+}
+
+func (e *encoder) int(x int64) {
+	var b [binary.MaxVarintLen64]byte
+	e.buf.Write(b[:binary.PutVarint(b[:], x)])
+}
+
+func (e *encoder) string(s string) {
+	e.uint(len(s))
+	e.buf.WriteString(s)
+}
+
+var errTruncated = errors.New("unexpected end of data")
+
+type decoder struct {
+	data []byte
+}
+
+func (d *decoder) byte() byte {
+	if len(d.data) == 0 {
+		panic(errTruncated)
+	}
+	b := d.data[0]
+	d.data = d.data[1:]
+	return b
+}
+
+func (d *decoder) uint() int {
+	x, n := binary.Uvarint(d.data)
+	if n <= 0 || x > 1<<31 {
+		panic(errTruncated)
+	}
+	d.data = d.data[n:]
+	return int(x)
+}
+
+// count reads the number of elements which follow, each of which takes one byte at least.
+func (d *decoder) count() int {
+	n := d.uint()
+	if n > len(d.data) {
+		panic(errTruncated)
+	}
+	return n
+}
+
+func (d *decoder) int() int64 {
+	x, n := binary.Varint(d.data)
+	if n <= 0 {
+		panic(errTruncated)
+	}
+	d.data = d.data[n:]
+	return x
+}
+
+func (d *decoder) string() string {
+	n := d.count()
+	s := string(d.data[:n])
+	d.data = d.data[n:]
+	return s
+}
@@ -0,0 +1,256 @@
+package vm
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"runtime"
+	"strings"
+
+	"github.com/yuniruyuni/lang/libc"
+)
+
+// maxDepth limits nested calls so that runaway recursion fails instead of exhausting memory.
+const maxDepth = 1 << 20
+
+// RuntimeError is an error which stops the running program, like division by zero.
+type RuntimeError struct {
+	// Func is the name of the function running when the error occurs.
+	Func string
+	// Where is the position of the failure like `main.yuni:3:5`, or empty if it is unknown.
+	Where  string
+	Reason string
+	// Backtrace lists names of functions being called at the failure, the innermost first.
+	Backtrace []string
+}
+
+func (e *RuntimeError) Error() string {
+	msg := fmt.Sprintf("runtime error in %s: %s", e.Func, e.Reason)
+	if e.Where != "" {
+		msg = e.Where + ": runtime error: " + e.Reason
+	}
+	if len(e.Backtrace) > 0 {
+		msg += "\nbacktrace:\n    " + strings.Join(e.Backtrace, "\n    ")
+	}
+	return msg
+}
+
+// builtinFunc implements an external function taking args.
+type builtinFunc func(m *machine, args []int64) (int64, error)
+
+// frame is a function call waiting for its callee to return.
+type frame struct {
+	fn   *Func
+	pc   int
+	base int
+}
+
+type machine struct {
+	p     *Program
+	in    *libc.Input
+	out   *bufio.Writer
+	stack []int64
+	// strs holds strings made at runtime, which are pointed after the string literals.
+	strs []string
+	// globals holds values of module level variables and constants.
+	globals  []int64
+	builtins []builtinFunc
+}
+
+// Run runs p reading stdin from in and writing stdout into out,
+// and returns the value `main` returns.
+func Run(p *Program, in io.Reader, out io.Writer) (int, error) {
+	w := bufio.NewWriter(out)
+	m := &machine{
+		p:       p,
+		in:      libc.NewInput(in, w),
+		out:     w,
+		globals: make([]int64, p.Globals),
+	}
+	defer m.out.Flush()
+
+	for _, n := range p.Builtins {
+		b, ok := builtins[n]
+		if !ok {
+			b = unsupported(n)
+		}
+		m.builtins = append(m.builtins, b)
+	}
+
+	if _, err := m.exec(p.Init); err != nil {
+		return 0, err
+	}
+	v, err := m.exec(p.Main)
+	return int(int32(v)), err
+}
+
+// exec runs the function f until it returns.
+func (m *machine) exec(f int) (v int64, err error) {
+	frames := []frame{}
+	fn := m.p.Funcs[f]
+
+	// bytecode loaded from a file may still underflow the stack or call a missing function.
+	defer func() {
+		if r := recover(); r != nil {
+			e, ok := r.(runtime.Error)
+			if !ok {
+				panic(r)
+			}
+			v, err = 0, &RuntimeError{Func: fn.Name, Reason: "broken bytecode: " + e.Error()}
+		}
+	}()
+
+	base := len(m.stack)
+	m.stack = append(m.stack, make([]int64, fn.Locals)...)
+	pc := 0
+
+	// located adds the function running now and the backtrace to e.
+	located := func(e *RuntimeError) error {
+		e.Func = fn.Name
+		e.Backtrace = []string{fn.Name}
+		for i := len(frames) - 1; i >= 0; i-- {
+			e.Backtrace = append(e.Backtrace, frames[i].fn.Name)
+		}
+		return e
+	}
+	fail := func(format string, args ...interface{}) error {
+		return located(&RuntimeError{Reason: fmt.Sprintf(format, args...)})
+	}
+	// failBuiltin makes the error of a builtin, which knows where it fails if the program traps.
+	failBuiltin := func(err error) error {
+		if e, ok := err.(*RuntimeError); ok {
+			return located(e)
+		}
+		return fail("%s", err.Error())
+	}
+
+	for {
+		in := fn.Code[pc]
+		pc++
+
+		s := m.stack
+		switch in.Op {
+		case OpPush:
+			m.stack = append(s, int64(in.A))
+		case OpPop:
+			m.stack = s[:len(s)-1]
+		case OpDup:
+			m.stack = append(s, s[len(s)-1])
+		case OpLoad:
+			m.stack = append(s, s[base+int(in.A)])
+		case OpStore:
+			s[base+int(in.A)] = s[len(s)-1]
+			m.stack = s[:len(s)-1]
+		case OpGLoad:
+			m.stack = append(s, m.globals[in.A])
+		case OpGStore:
+			m.globals[in.A] = s[len(s)-1]
+			m.stack = s[:len(s)-1]
+		case OpAdd, OpSub, OpMul, OpDiv, OpLess, OpEqual:
+			x, y := s[len(s)-2], s[len(s)-1]
+			var v int64
+			switch in.Op {
+			case OpAdd:
+				v = wrap(x+y, int(in.A))
+			case OpSub:
+				v = wrap(x-y, int(in.A))
+			case OpMul:
+				v = wrap(x*y, int(in.A))
+			case OpDiv:
+				if y == 0 {
+					return 0, located(&RuntimeError{Where: libc.Unescape(m.p.Strings[in.A]), Reason: "division by zero"})
+				}
+				v = wrap(x/y, int(in.B))
+			case OpLess:
+				v = boolToInt(x < y)
+			case OpEqual:
+				v = boolToInt(x == y)
+			}
+			s[len(s)-2] = v
+			m.stack = s[:len(s)-1]
+		case OpWrap:
+			s[len(s)-1] = wrap(s[len(s)-1], int(in.A))
+		case OpJump:
+			pc = int(in.A)
+		case OpJumpZero:
+			m.stack = s[:len(s)-1]
+			if s[len(s)-1] == 0 {
+				pc = int(in.A)
+			}
+		case OpCall, OpCallValue:
+			callee := int64(in.A)
+			if in.Op == OpCallValue {
+				callee = s[len(s)-1]
+				m.stack = s[:len(s)-1]
+				if callee < 0 {
+					if err := m.callBuiltin(int(-1-callee), int(in.A)); err != nil {
+						return 0, failBuiltin(err)
+					}
+					continue
+				}
+			}
+
+			frames = append(frames, frame{fn: fn, pc: pc, base: base})
+			if len(frames) > maxDepth {
+				return 0, fail("stack overflow")
+			}
+			fn, pc = m.p.Funcs[callee], 0
+			base = len(m.stack) - fn.Params
+			m.stack = append(m.stack, make([]int64, fn.Locals-fn.Params)...)
+		case OpCallBuiltin:
+			if err := m.callBuiltin(int(in.A), int(in.B)); err != nil {
+				return 0, failBuiltin(err)
+			}
+		case OpRet:
+			v := s[len(s)-1]
+			m.stack = s[:base]
+			if len(frames) == 0 {
+				return v, nil
+			}
+			top := frames[len(frames)-1]
+			frames = frames[:len(frames)-1]
+			fn, pc, base = top.fn, top.pc, top.base
+			m.stack = append(m.stack, v)
+		default:
+			return 0, fail("invalid instruction %s", in)
+		}
+	}
+}
+
+// callBuiltin calls the builtin b with n arguments on the stack and pushes its result.
+func (m *machine) callBuiltin(b, n int) error {
+	args := append([]int64{}, m.stack[len(m.stack)-n:]...)
+	m.stack = m.stack[:len(m.stack)-n]
+	v, err := m.builtins[b](m, args)
+	if _, ok := err.(*RuntimeError); ok {
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %s", m.p.Builtins[b], err.Error())
+	}
+	m.stack = append(m.stack, v)
+	return nil
+}
+
+// wrap truncates x to the width bits and sign-extends it back, or makes it 0 or 1 for a bool.
+func wrap(x int64, bits int) int64 {
+	switch bits {
+	case 1:
+		return boolToInt(x != 0)
+	case 8:
+		return int64(int8(x))
+	case 16:
+		return int64(int16(x))
+	case 32:
+		return int64(int32(x))
+	default:
+		return x
+	}
+}
+
+func boolToInt(b bool) int64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,150 @@
+package vm_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"gotest.tools/assert"
+
+	"github.com/yuniruyuni/lang/conformance"
+	"github.com/yuniruyuni/lang/gen"
+	"github.com/yuniruyuni/lang/module"
+	"github.com/yuniruyuni/lang/vm"
+)
+
+func compile(t *testing.T, code string) *vm.Program {
+	t.Helper()
+	prog, err := module.New(nil).LoadSource("<test>", code)
+	assert.NilError(t, err)
+	ll := gen.LLFile{AST: prog}
+	ll.Generate()
+
+	p, err := vm.Compile(prog, ll.Info)
+	assert.NilError(t, err)
+	return p
+}
+
+// run runs code by the VM with input, and returns what it writes into stdout.
+func run(t *testing.T, code, input string) (string, int, error) {
+	t.Helper()
+	out := new(bytes.Buffer)
+	exit, err := vm.Run(compile(t, code), strings.NewReader(input), out)
+	return out.String(), exit, err
+}
+
+func TestRun_Conformance(t *testing.T) {
+	for _, tt := range conformance.Cases {
+		t.Run(tt.Name, func(t *testing.T) {
+			out, code, err := run(t, tt.Code, tt.Input)
+			assert.NilError(t, err)
+			assert.Equal(t, tt.Want, out)
+			assert.Equal(t, tt.WantCode, code)
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name     string
+		code     string
+		input    string
+		want     string
+		wantCode int
+		wantErr  string
+	}{
+		{
+			name: "deep recursion",
+			code: `func sum(n: i32, acc: i32,) -> i32 { if n == 0 { acc } else { sum(n - 1, acc + n,) } } func main(){ printf("%d", sum(100000, 0,),); 0 }`,
+			want: "705082704",
+		},
+		{
+			name: "builtin as function value",
+			code: `extern func putchar(c: i32) -> i32 func main(){ let p = putchar; p(72,); p(105,); 0 }`,
+			want: "Hi",
+		},
+		{
+			name:  "read lines, integers and chars until the end of input",
+			code:  `func main(){ println("[", read_line(), "] ", read_ok(),); let sum = 0; while eof() == 0 { sum = sum + read_int() }; println(sum, " ", read_char(), " ", read_ok(),); 0 }`,
+			input: "name\n1 2\n3\n",
+			want:  "[name] 1\n6 -1 0\n",
+		},
+		{
+			name:    "division by zero",
+			code:    `func main(){ let x = 0; printf("before",); 1 / x }`,
+			want:    "before",
+			wantErr: "<test>:1:44: runtime error: division by zero\nbacktrace:\n    main",
+		},
+		{
+			name:    "panic with the position and the backtrace",
+			code:    `func f(x: i32,) -> i32 { if x < 2 { panic("too small",) } else { x } } func main(){ print("before",); f(1,) }`,
+			want:    "before",
+			wantErr: "<test>:1:37: runtime error: panic: too small\nbacktrace:\n    f\n    main",
+		},
+		{
+			name:    "failed assertion",
+			code:    `func main(){ assert(1 < 2,); assert(2 < 1, "order",); 0 }`,
+			wantErr: "<test>:1:30: runtime error: assertion failed: order",
+		},
+		{
+			name:    "unsupported extern",
+			code:    `extern func getchar() -> i32 func main(){ getchar() }`,
+			wantErr: "extern function getchar is not supported",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, code, err := run(t, tt.code, tt.input)
+			assert.Equal(t, tt.want, out)
+			if tt.wantErr != "" {
+				assert.Assert(t, err != nil)
+				assert.Assert(t, strings.Contains(err.Error(), tt.wantErr), err.Error())
+				return
+			}
+			assert.NilError(t, err)
+			assert.Equal(t, tt.wantCode, code)
+		})
+	}
+}
+
+func TestMarshalBinary(t *testing.T) {
+	p := compile(t, `const N = 5 func f(x: i32,) -> i32 { x * N } func main(){ printf("%d\0A", f(0 - 2,),); 3 }`)
+
+	data, err := p.MarshalBinary()
+	assert.NilError(t, err)
+
+	got := &vm.Program{}
+	assert.NilError(t, got.UnmarshalBinary(data))
+	assert.Equal(t, p.String(), got.String())
+
+	out := new(bytes.Buffer)
+	code, err := vm.Run(got, strings.NewReader(""), out)
+	assert.NilError(t, err)
+	assert.Equal(t, "-10\n", out.String())
+	assert.Equal(t, 3, code)
+}
+
+func TestUnmarshalBinary_Errors(t *testing.T) {
+	data, err := compile(t, `func main(){ 0 }`).MarshalBinary()
+	assert.NilError(t, err)
+
+	// the last byte is the index of main.
+	outOfRange := append([]byte{}, data...)
+	outOfRange[len(outOfRange)-1] = 100
+
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr string
+	}{
+		{name: "not bytecode", data: []byte("func main(){ 0 }"), wantErr: "not a bytecode file"},
+		{name: "truncated", data: data[:len(data)-3], wantErr: "invalid bytecode: unexpected end of data"},
+		{name: "out of range", data: outOfRange, wantErr: "invalid bytecode: entry function doesn't exist"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := (&vm.Program{}).UnmarshalBinary(tt.data)
+			assert.Error(t, err, tt.wantErr)
+		})
+	}
+}
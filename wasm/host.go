@@ -0,0 +1,69 @@
+package wasm
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+
+	"github.com/yuniruyuni/lang/libc"
+)
+
+// host makes the functions which a module of yuni imports from "env":
+// the runtime functions, `yuni_trap` and the external functions from libc which the host implements.
+func (m *machine) host(in *libc.Input, out *bufio.Writer) map[string]func(args []int64) int64 {
+	return map[string]func(args []int64) int64{
+		"yuni_trap": func(args []int64) int64 {
+			panic(&Trap{m.cstring(args[0]) + ": runtime error: " + m.cstring(args[1]) + m.cstring(args[2])})
+		},
+		"read":      func(args []int64) int64 { return in.ReadInt() },
+		"read_int":  func(args []int64) int64 { return in.ReadInt() },
+		"read_char": func(args []int64) int64 { return in.ReadChar() },
+		"read_ok":   func(args []int64) int64 { return in.OK() },
+		"eof":       func(args []int64) int64 { return in.EOF() },
+		"putchar": func(args []int64) int64 {
+			out.WriteByte(byte(args[0]))
+			return args[0]
+		},
+		"labs": func(args []int64) int64 {
+			if args[0] < 0 {
+				return -args[0]
+			}
+			return args[0]
+		},
+		"printf": func(args []int64) int64 {
+			s, err := libc.Sprintf(m.cstring(args[0]), &vaArgs{m: m, va: args[1]})
+			if err != nil {
+				panic(&Trap{err.Error()})
+			}
+			out.WriteString(s)
+			return int64(len(s))
+		},
+	}
+}
+
+// cstring reads the null terminated string at addr.
+func (m *machine) cstring(addr int64) string {
+	m.bytes(addr, 0)
+	end := bytes.IndexByte(m.mem[addr:], 0)
+	if end < 0 {
+		panic(&Trap{"out of bounds memory access"})
+	}
+	return string(m.mem[addr : addr+int64(end)])
+}
+
+// vaArgs reads variadic arguments from memory, each of which takes 8 bytes.
+type vaArgs struct {
+	m  *machine
+	va int64
+}
+
+func (a *vaArgs) Int() (int64, error) {
+	v := int64(binary.LittleEndian.Uint64(a.m.bytes(a.va, 8)))
+	a.va += 8
+	return v, nil
+}
+
+func (a *vaArgs) String() (string, error) {
+	v, _ := a.Int()
+	return a.m.cstring(v), nil
+}
@@ -0,0 +1,92 @@
+package wasm
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// sexpr is an atom or a list of the text format.
+type sexpr struct {
+	atom string
+	list []*sexpr
+}
+
+// parseSexprs reads every s-expression in src.
+func parseSexprs(src string) ([]*sexpr, error) {
+	stack := [][]*sexpr{{}}
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == '(':
+			stack = append(stack, []*sexpr{})
+			i++
+		case c == ')':
+			if len(stack) == 1 {
+				return nil, fmt.Errorf("unexpected ) at %d", i)
+			}
+			top := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			stack[len(stack)-1] = append(stack[len(stack)-1], &sexpr{list: top})
+			i++
+		case c == ' ' || c == '\n' || c == '\t' || c == '\r':
+			i++
+		default:
+			j := i
+			if c == '"' {
+				for j++; j < len(src) && src[j] != '"'; j++ {
+					if src[j] == '\\' {
+						j++
+					}
+				}
+				if j >= len(src) {
+					return nil, fmt.Errorf("unterminated string at %d", i)
+				}
+				j++
+			} else {
+				for j < len(src) && !strings.ContainsRune("() \n\t\r", rune(src[j])) {
+					j++
+				}
+			}
+			stack[len(stack)-1] = append(stack[len(stack)-1], &sexpr{atom: src[i:j]})
+			i = j
+		}
+	}
+	if len(stack) != 1 {
+		return nil, fmt.Errorf("missing )")
+	}
+	return stack[0], nil
+}
+
+// head returns the keyword of the list s.
+func (s *sexpr) head() string {
+	if len(s.list) == 0 {
+		return ""
+	}
+	return s.list[0].atom
+}
+
+// unquote decodes the string atom s, whose escapes are `\` and two hexadecimal digits as WATFile writes.
+func unquote(s string) ([]byte, error) {
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return nil, fmt.Errorf("%s is not a string", s)
+	}
+	b := []byte{}
+	s = s[1 : len(s)-1]
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			b = append(b, s[i])
+			continue
+		}
+		if i+3 > len(s) {
+			return nil, fmt.Errorf("invalid escape in %q", s)
+		}
+		c, err := strconv.ParseUint(s[i+1:i+3], 16, 8)
+		if err != nil {
+			return nil, fmt.Errorf("invalid escape in %q", s)
+		}
+		b = append(b, byte(c))
+		i += 2
+	}
+	return b, nil
+}
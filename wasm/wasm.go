@@ -0,0 +1,459 @@
+// Package wasm runs a WebAssembly module in the text format as gen.WATFile generates it,
+// so that the generated code can be checked without a WebAssembly engine.
+// It supports the instructions which WATFile uses and checks heights of the operand stack
+// at the end of each block and function, which an engine would reject while validating.
+package wasm
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/yuniruyuni/lang/libc"
+)
+
+// Trap is an error which stops the running module, like division by zero.
+type Trap struct {
+	Reason string
+}
+
+func (t *Trap) Error() string { return "trap: " + t.Reason }
+
+// pageSize is the size of a page of memory.
+const pageSize = 65536
+
+// maxDepth limits nested calls so that deep recursion traps before it exhausts the stack of Go.
+const maxDepth = 200000
+
+// instr is an instruction with its immediate, if any.
+type instr struct {
+	op  string
+	arg string
+}
+
+// immediates lists instructions taking an immediate atom.
+var immediates = map[string]bool{
+	"i32.const": true, "i64.const": true, "local.get": true, "local.set": true, "local.tee": true,
+	"global.get": true, "global.set": true, "call": true, "br": true, "br_if": true, "block": true, "loop": true,
+}
+
+// function is a function defined by the module or imported from the host.
+type function struct {
+	params []string
+	locals []string
+	code   []instr
+	// ends and elses find the end and the else of each block.
+	ends  map[int]int
+	elses map[int]int
+	// host implements an imported function.
+	host func(args []int64) int64
+}
+
+// machine holds the state of a running module.
+type machine struct {
+	funcs   map[string]*function
+	types   map[string]int
+	globals map[string]int64
+	table   []string
+	mem     []byte
+	depth   int
+}
+
+// label is a block being executed.
+type label struct {
+	name   string
+	loop   bool
+	start  int
+	height int
+}
+
+// Run instantiates the module src with the host functions of yuni and runs its `main`,
+// reading stdin from in and writing stdout into out.
+// It returns the value `main` returns, or an error if src is malformed or the module traps.
+func Run(src string, in io.Reader, out io.Writer) (code int, err error) {
+	w := bufio.NewWriter(out)
+	defer w.Flush()
+
+	m := &machine{funcs: map[string]*function{}, types: map[string]int{}, globals: map[string]int64{}}
+	if err := m.load(src, m.host(libc.NewInput(in, w), w)); err != nil {
+		return 0, err
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			t, ok := r.(*Trap)
+			if !ok {
+				panic(r)
+			}
+			err = t
+		}
+	}()
+	return int(int32(m.call("$main", nil))), nil
+}
+
+// load defines what the module src declares, importing functions from host.
+func (m *machine) load(src string, host map[string]func(args []int64) int64) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			e, ok := r.(error)
+			if _, bug := r.(runtime.Error); !ok || bug {
+				panic(r)
+			}
+			err = e
+		}
+	}()
+
+	mods, err := parseSexprs(src)
+	if err != nil {
+		return err
+	}
+	if len(mods) != 1 || mods[0].head() != "module" {
+		return fmt.Errorf("source must be a module")
+	}
+	for _, f := range mods[0].list[1:] {
+		switch f.head() {
+		case "type":
+			m.types[atom(f, 1)] = countParams(list(f, 2))
+		case "import":
+			name := str(f, 2)
+			h, ok := host[name]
+			if !ok {
+				return fmt.Errorf("unknown import %s", name)
+			}
+			sig := list(f, 3)
+			m.funcs[atom(sig, 1)] = &function{params: make([]string, countParams(sig)), host: h}
+		case "memory":
+			pages := integer(f, len(f.list)-1)
+			m.mem = make([]byte, pages*pageSize)
+		case "global":
+			m.globals[atom(f, 1)] = integer(list(f, len(f.list)-1), 1)
+		case "elem":
+			for i := 3; i < len(f.list); i++ {
+				m.table = append(m.table, atom(f, i))
+			}
+		case "data":
+			addr := integer(list(f, 1), 1)
+			b := []byte(str(f, 2))
+			if addr < 0 || addr+int64(len(b)) > int64(len(m.mem)) {
+				return fmt.Errorf("data at %d is out of memory", addr)
+			}
+			copy(m.mem[addr:], b)
+		case "func":
+			m.funcs[atom(f, 1)] = parseFunc(f)
+		}
+	}
+	return nil
+}
+
+// list returns the i-th element of s, which must be a list.
+func list(s *sexpr, i int) *sexpr {
+	if i >= len(s.list) || s.list[i].list == nil {
+		panic(fmt.Errorf("%s needs a list at %d", s.head(), i))
+	}
+	return s.list[i]
+}
+
+// atom returns the i-th element of s, which must be an atom.
+func atom(s *sexpr, i int) string {
+	if i >= len(s.list) || s.list[i].atom == "" {
+		panic(fmt.Errorf("%s needs an atom at %d", s.head(), i))
+	}
+	return s.list[i].atom
+}
+
+// integer returns the i-th element of s as an integer.
+func integer(s *sexpr, i int) int64 {
+	v, err := strconv.ParseInt(atom(s, i), 10, 64)
+	if err != nil {
+		panic(fmt.Errorf("%s needs an integer at %d", s.head(), i))
+	}
+	return v
+}
+
+// str returns the i-th element of s as a string.
+func str(s *sexpr, i int) string {
+	b, err := unquote(atom(s, i))
+	if err != nil {
+		panic(err)
+	}
+	return string(b)
+}
+
+func countParams(sig *sexpr) int {
+	n := 0
+	for _, e := range sig.list {
+		if e.head() == "param" {
+			n++
+		}
+	}
+	return n
+}
+
+// parseFunc reads the definition of a function and matches each block with its end.
+func parseFunc(f *sexpr) *function {
+	fn := &function{ends: map[int]int{}, elses: map[int]int{}}
+	opens := []int{}
+	for i := 2; i < len(f.list); i++ {
+		e := f.list[i]
+		switch {
+		case e.head() == "param":
+			fn.params = append(fn.params, atom(e, 1))
+		case e.head() == "local":
+			fn.locals = append(fn.locals, atom(e, 1))
+		case e.list != nil:
+			// exports and results.
+		default:
+			in := instr{op: e.atom}
+			if immediates[in.op] || in.op == "i64.store" && i+1 < len(f.list) && strings.HasPrefix(f.list[i+1].atom, "offset=") {
+				i++
+				in.arg = strings.TrimPrefix(atom(f, i), "offset=")
+			}
+			if in.op == "call_indirect" || in.op == "if" {
+				i++
+				in.arg = atom(list(f, i), 1)
+			}
+
+			pc := len(fn.code)
+			switch in.op {
+			case "block", "loop", "if":
+				opens = append(opens, pc)
+			case "else":
+				if len(opens) == 0 {
+					panic(fmt.Errorf("else without if"))
+				}
+				fn.elses[opens[len(opens)-1]] = pc
+			case "end":
+				if len(opens) == 0 {
+					panic(fmt.Errorf("end without block"))
+				}
+				open := opens[len(opens)-1]
+				opens = opens[:len(opens)-1]
+				fn.ends[open] = pc
+				if e, ok := fn.elses[open]; ok {
+					fn.ends[e] = pc
+				}
+			}
+			fn.code = append(fn.code, in)
+		}
+	}
+	if len(opens) != 0 {
+		panic(fmt.Errorf("%s has a block without end", atom(f, 1)))
+	}
+	return fn
+}
+
+// bytes returns n bytes of memory at addr, trapping if they are out of memory.
+func (m *machine) bytes(addr int64, n int) []byte {
+	if addr < 0 || addr+int64(n) > int64(len(m.mem)) {
+		panic(&Trap{"out of bounds memory access"})
+	}
+	return m.mem[addr : addr+int64(n)]
+}
+
+// call calls the function name with args and returns its result.
+func (m *machine) call(name string, args []int64) int64 {
+	fn, ok := m.funcs[name]
+	if !ok {
+		panic(&Trap{"unknown function " + name})
+	}
+	if fn.host != nil {
+		return fn.host(args)
+	}
+	if m.depth >= maxDepth {
+		panic(&Trap{"call stack exhausted"})
+	}
+	m.depth++
+	defer func() { m.depth-- }()
+
+	locals := map[string]int64{}
+	for i, p := range fn.params {
+		locals[p] = args[i]
+	}
+	for _, l := range fn.locals {
+		locals[l] = 0
+	}
+
+	stack := []int64{}
+	pop := func() int64 {
+		if len(stack) == 0 {
+			panic(&Trap{"operand stack underflow in " + name})
+		}
+		v := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		return v
+	}
+	push := func(v int64) { stack = append(stack, v) }
+	i32 := func(v int64) int64 { return int64(int32(v)) }
+	b2i := func(b bool) int64 {
+		if b {
+			return 1
+		}
+		return 0
+	}
+	callArgs := func(n int) []int64 {
+		as := make([]int64, n)
+		for i := n - 1; i >= 0; i-- {
+			as[i] = pop()
+		}
+		return as
+	}
+	callee := func(n string) *function {
+		f, ok := m.funcs[n]
+		if !ok {
+			panic(&Trap{"unknown function " + n})
+		}
+		return f
+	}
+
+	labels := []label{}
+	for pc := 0; pc < len(fn.code); pc++ {
+		in := fn.code[pc]
+		switch in.op {
+		case "i32.const", "i64.const":
+			v, err := strconv.ParseInt(in.arg, 10, 64)
+			if err != nil {
+				panic(&Trap{"invalid constant " + in.arg})
+			}
+			push(v)
+		case "local.get":
+			push(locals[in.arg])
+		case "local.set":
+			locals[in.arg] = pop()
+		case "local.tee":
+			locals[in.arg] = pop()
+			push(locals[in.arg])
+		case "global.get":
+			push(m.globals[in.arg])
+		case "global.set":
+			m.globals[in.arg] = pop()
+		case "drop":
+			pop()
+		case "select":
+			c, y, x := pop(), pop(), pop()
+			if c != 0 {
+				push(x)
+			} else {
+				push(y)
+			}
+		case "i64.add", "i64.sub", "i64.mul", "i64.div_s", "i64.lt_s", "i64.eq":
+			y, x := pop(), pop()
+			switch in.op {
+			case "i64.add":
+				push(x + y)
+			case "i64.sub":
+				push(x - y)
+			case "i64.mul":
+				push(x * y)
+			case "i64.div_s":
+				if y == 0 {
+					panic(&Trap{"integer divide by zero"})
+				}
+				push(x / y)
+			case "i64.lt_s":
+				push(b2i(x < y))
+			case "i64.eq":
+				push(b2i(x == y))
+			}
+		case "i32.add", "i32.sub", "i32.mul", "i32.div_s", "i32.lt_s", "i32.eq":
+			y, x := pop(), pop()
+			switch in.op {
+			case "i32.add":
+				push(i32(x + y))
+			case "i32.sub":
+				push(i32(x - y))
+			case "i32.mul":
+				push(i32(x * y))
+			case "i32.div_s":
+				if y == 0 {
+					panic(&Trap{"integer divide by zero"})
+				}
+				push(i32(x / y))
+			case "i32.lt_s":
+				push(b2i(x < y))
+			case "i32.eq":
+				push(b2i(x == y))
+			}
+		case "i32.eqz", "i64.eqz":
+			push(b2i(pop() == 0))
+		case "i64.extend_i32_s":
+			// i32 values are already held sign extended.
+		case "i32.wrap_i64":
+			push(i32(pop()))
+		case "i32.extend8_s":
+			push(int64(int8(pop())))
+		case "i64.store":
+			off, _ := strconv.Atoi(in.arg)
+			v, addr := pop(), pop()
+			binary.LittleEndian.PutUint64(m.bytes(addr+int64(off), 8), uint64(v))
+		case "call":
+			push(m.call(in.arg, callArgs(len(callee(in.arg).params))))
+		case "call_indirect":
+			i := pop()
+			if i < 0 || i >= int64(len(m.table)) {
+				panic(&Trap{"undefined element"})
+			}
+			f := m.table[i]
+			if len(callee(f).params) != m.types[in.arg] {
+				panic(&Trap{"indirect call type mismatch"})
+			}
+			push(m.call(f, callArgs(m.types[in.arg])))
+		case "block", "loop":
+			labels = append(labels, label{name: in.arg, loop: in.op == "loop", start: pc, height: len(stack)})
+		case "if":
+			c := pop()
+			labels = append(labels, label{start: pc, height: len(stack)})
+			if c == 0 {
+				e, ok := fn.elses[pc]
+				if !ok {
+					// an if results a value, so one without else traps at its end.
+					e = fn.ends[pc] - 1
+				}
+				pc = e
+			}
+		case "else":
+			pc = fn.ends[pc] - 1
+		case "end":
+			l := labels[len(labels)-1]
+			labels = labels[:len(labels)-1]
+			want := l.height
+			if fn.code[l.start].op == "if" {
+				want++
+			}
+			if len(stack) != want {
+				panic(&Trap{fmt.Sprintf("operand stack has %d values at the end of %s, want %d", len(stack), fn.code[l.start].op, want)})
+			}
+		case "br", "br_if":
+			if in.op == "br_if" && pop() == 0 {
+				continue
+			}
+			i := len(labels) - 1
+			for i >= 0 && labels[i].name != in.arg {
+				i--
+			}
+			if i < 0 {
+				panic(&Trap{"unknown label " + in.arg})
+			}
+			l := labels[i]
+			if len(stack) != l.height {
+				panic(&Trap{"branch with values on the operand stack"})
+			}
+			if l.loop {
+				labels, pc = labels[:i+1], l.start
+			} else {
+				labels, pc = labels[:i], fn.ends[l.start]
+			}
+		case "unreachable":
+			panic(&Trap{"unreachable"})
+		default:
+			panic(&Trap{"unknown instruction " + in.op})
+		}
+	}
+	if len(stack) != 1 {
+		panic(&Trap{fmt.Sprintf("%s leaves %d values", name, len(stack))})
+	}
+	return stack[0]
+}
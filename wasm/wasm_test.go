@@ -0,0 +1,326 @@
+package wasm_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"gotest.tools/assert"
+
+	"github.com/yuniruyuni/lang/wasm"
+)
+
+// module wraps fields into a module exporting memory, as WATFile writes.
+func module(fields string) string {
+	return "(module\n  (memory (export \"memory\") 1)\n" + fields + "\n)\n"
+}
+
+// mainFunc wraps body into `main` resulting an i32.
+func mainFunc(body string) string {
+	return module(`(func $main (export "main") (result i32)` + "\n" + body + "\n)")
+}
+
+// printf imports printf and places format at 8 with the variadic arguments at 256.
+func printf(format, body string) string {
+	return module(`(import "env" "printf" (func $printf (param i32) (param i32) (result i32)))
+  (func $main (export "main") (result i32)
+` + body + `
+  )
+  (data (i32.const 8) "` + format + `")`)
+}
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name     string
+		src      string
+		input    string
+		want     string
+		wantCode int
+	}{
+		{
+			name:     "i32 arithmetic wraps around",
+			src:      mainFunc("i32.const 2147483647\ni32.const 1\ni32.add"),
+			wantCode: -2147483648,
+		},
+		{
+			name:     "i64 arithmetic is wrapped by i32.wrap_i64",
+			src:      mainFunc("i64.const 4294967296\ni64.const 3\ni64.mul\ni64.const 7\ni64.add\ni32.wrap_i64"),
+			wantCode: 7,
+		},
+		{
+			name:     "division truncates toward zero",
+			src:      mainFunc("i32.const -7\ni32.const 2\ni32.div_s"),
+			wantCode: -3,
+		},
+		{
+			name:     "extend8_s sign extends a byte",
+			src:      mainFunc("i32.const 200\ni32.extend8_s"),
+			wantCode: -56,
+		},
+		{
+			name:     "select picks the first value if the condition is non-zero",
+			src:      mainFunc("i32.const 1\ni32.const 2\ni32.const 0\nselect\ni32.const 10\ni32.mul\ni32.const 3\ni32.const 4\ni32.const 5\nselect\ni32.add"),
+			wantCode: 23,
+		},
+		{
+			name: "loop counts with locals until br_if leaves the block",
+			src: mainFunc(`(local $i i32)
+block $exit
+  loop $cont
+    local.get $i
+    i32.const 5
+    i32.lt_s
+    i32.eqz
+    br_if $exit
+    local.get $i
+    i32.const 1
+    i32.add
+    local.set $i
+    br $cont
+  end
+end
+local.get $i`),
+			wantCode: 5,
+		},
+		{
+			name:     "if results the value of the taken clause",
+			src:      mainFunc("i32.const 0\nif (result i32)\n  i32.const 1\nelse\n  i32.const 2\nend\ni32.const 1\ni32.eqz\ni32.eqz\nif (result i32)\n  i32.const 10\nelse\n  i32.const 20\nend\ni32.add"),
+			wantCode: 12,
+		},
+		{
+			name: "calls and globals",
+			src: module(`(global $g (mut i32) (i32.const 3))
+  (func $add (param $x i32) (param $y i32) (result i32)
+    local.get $x
+    local.get $y
+    i32.sub
+  )
+  (func $main (export "main") (result i32)
+    i32.const 10
+    global.get $g
+    call $add
+    global.set $g
+    global.get $g
+  )`),
+			wantCode: 7,
+		},
+		{
+			name: "call_indirect calls a function of the table",
+			src: module(`(type $sig (func (param i32) (result i32)))
+  (table 2 funcref)
+  (elem (i32.const 0) func $inc $dbl)
+  (func $inc (param $x i32) (result i32)
+    local.get $x
+    i32.const 1
+    i32.add
+  )
+  (func $dbl (param $x i32) (result i32)
+    local.get $x
+    i32.const 2
+    i32.mul
+  )
+  (func $main (export "main") (result i32)
+    i32.const 20
+    i32.const 1
+    call_indirect (type $sig)
+  )`),
+			wantCode: 40,
+		},
+		{
+			name: "printf reads variadic arguments from memory",
+			src: printf(`%d-%s\0a\00ok\00`, `i32.const 256
+    i64.const -4
+    i64.store
+    i32.const 256
+    i32.const 15
+    i64.store offset=8
+    i32.const 8
+    i32.const 256
+    call $printf`),
+			want:     "-4-ok\n",
+			wantCode: 6,
+		},
+		{
+			name: "host functions read stdin",
+			src: module(`(import "env" "read_int" (func $read_int (result i32)))
+  (import "env" "read_ok" (func $read_ok (result i32)))
+  (import "env" "putchar" (func $putchar (param i32) (result i32)))
+  (func $main (export "main") (result i32)
+    call $report
+  )
+  (func $report (result i32)
+    call $read_int
+    call $read_ok
+    i32.const 48
+    i32.add
+    call $putchar
+    drop
+  )`),
+			input:    "42\n",
+			want:     "1",
+			wantCode: 42,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			out := new(bytes.Buffer)
+			code, err := wasm.Run(tt.src, strings.NewReader(tt.input), out)
+			assert.NilError(t, err)
+			assert.Equal(t, out.String(), tt.want)
+			assert.Equal(t, code, tt.wantCode)
+		})
+	}
+}
+
+func TestRun_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     string
+		want    string
+		wantErr string
+	}{
+		{
+			name:    "division by zero",
+			src:     mainFunc("i32.const 1\ni32.const 0\ni32.div_s"),
+			wantErr: "trap: integer divide by zero",
+		},
+		{
+			name:    "unreachable",
+			src:     mainFunc("unreachable"),
+			wantErr: "trap: unreachable",
+		},
+		{
+			name: "yuni_trap reports the position and the reason after the output",
+			src: module(`(import "env" "printf" (func $printf (param i32) (param i32) (result i32)))
+  (import "env" "yuni_trap" (func $yuni_trap (param i32) (param i32) (param i32) (result i32)))
+  (func $main (export "main") (result i32)
+    i32.const 8
+    i32.const 0
+    call $printf
+    drop
+    i32.const 15
+    i32.const 26
+    i32.const 43
+    call $yuni_trap
+  )
+  (data (i32.const 8) "before\00m.yuni:1:2\00division by zero\00\00")`),
+			want:    "before",
+			wantErr: "trap: m.yuni:1:2: runtime error: division by zero",
+		},
+		{
+			name:    "operand stack underflow",
+			src:     mainFunc("i32.const 1\ni32.add"),
+			wantErr: "trap: operand stack underflow in $main",
+		},
+		{
+			name:    "values left at the end of a block",
+			src:     mainFunc("block $b\n  i32.const 1\nend\ni32.const 0"),
+			wantErr: "trap: operand stack has 1 values at the end of block, want 0",
+		},
+		{
+			name:    "if without a value",
+			src:     mainFunc("i32.const 1\nif (result i32)\nelse\n  i32.const 2\nend"),
+			wantErr: "trap: operand stack has 0 values at the end of if, want 1",
+		},
+		{
+			name:    "branch with values",
+			src:     mainFunc("block $b\n  i32.const 1\n  br $b\nend\ni32.const 0"),
+			wantErr: "trap: branch with values on the operand stack",
+		},
+		{
+			name:    "function leaving two values",
+			src:     mainFunc("i32.const 1\ni32.const 2"),
+			wantErr: "trap: $main leaves 2 values",
+		},
+		{
+			name:    "store out of memory",
+			src:     mainFunc("i32.const 65532\ni64.const 1\ni64.store\ni32.const 0"),
+			wantErr: "trap: out of bounds memory access",
+		},
+		{
+			name: "call_indirect out of the table",
+			src: module(`(type $sig (func (result i32)))
+  (func $main (export "main") (result i32)
+    i32.const 0
+    call_indirect (type $sig)
+  )`),
+			wantErr: "trap: undefined element",
+		},
+		{
+			name: "call_indirect with another signature",
+			src: module(`(type $sig (func (result i32)))
+  (table 1 funcref)
+  (elem (i32.const 0) func $id)
+  (func $id (param $x i32) (result i32)
+    local.get $x
+  )
+  (func $main (export "main") (result i32)
+    i32.const 0
+    call_indirect (type $sig)
+  )`),
+			wantErr: "trap: indirect call type mismatch",
+		},
+		{
+			name: "deep recursion",
+			src: module(`(func $f (result i32)
+    call $f
+  )
+  (func $main (export "main") (result i32)
+    call $f
+  )`),
+			wantErr: "trap: call stack exhausted",
+		},
+		{
+			name:    "unknown instruction",
+			src:     mainFunc("i32.const 1\ni32.popcnt"),
+			wantErr: "trap: unknown instruction i32.popcnt",
+		},
+		{
+			name:    "missing main",
+			src:     module(""),
+			wantErr: "trap: unknown function $main",
+		},
+		{
+			name:    "unknown import",
+			src:     module(`(import "env" "fopen" (func $fopen (param i32) (result i32)))`),
+			wantErr: "unknown import fopen",
+		},
+		{
+			name:    "unbalanced parenthesis",
+			src:     "(module",
+			wantErr: "missing )",
+		},
+		{
+			name:    "unterminated string",
+			src:     `(module (data (i32.const 8) "abc))`,
+			wantErr: "unterminated string at 28",
+		},
+		{
+			name:    "not a module",
+			src:     "(func $main)",
+			wantErr: "source must be a module",
+		},
+		{
+			name:    "block without end",
+			src:     mainFunc("block $b\ni32.const 0"),
+			wantErr: "$main has a block without end",
+		},
+		{
+			name:    "data out of memory",
+			src:     module(`(data (i32.const 65535) "ab")`),
+			wantErr: "data at 65535 is out of memory",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			out := new(bytes.Buffer)
+			_, err := wasm.Run(tt.src, strings.NewReader(""), out)
+			assert.Error(t, err, tt.wantErr)
+			assert.Equal(t, out.String(), tt.want)
+		})
+	}
+}